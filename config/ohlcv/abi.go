@@ -1,6 +1,8 @@
 package ohlcvConfig
 
-const ContractABIDay = `[
+// ContractABI là ABI chung của các contract nến (lấy từ contract ngày); mọi khung thời
+// gian dùng cùng struct FormData
+const ContractABI = `[
 	{
 		"anonymous": false,
 		"inputs": [
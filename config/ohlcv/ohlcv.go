@@ -8,3 +8,59 @@ const PrivateKeyMonth = "e9cef82ff3a2cfdec4819c81850fd28198c477eced7a6d3d4484d2c
 const ContractAddressDay = "0xFf38207Ba2708f01a1Bf107Db21dF0f8A342920E"
 const ContractAddressWeek = "0xbD289012ded5642c6cd01Bd7702CCB25CFc40b76"
 const ContractAddressMonth = "0x249C92cB01267bedc73E6AD0525559F4CA715Ca9"
+
+// Interval là một chuỗi nến: tên khung thời gian theo Binance (1m, 5m, 15m, 1h, 4h, 1d,
+// 1w, 1M, ...) và contract nhận nến nếu có
+type Interval struct {
+	Name string
+	// Offset (ms) cộng vào OpenTime/CloseTime của nến gửi lên contract, giữ như dữ liệu đã
+	// gửi lên contract tuần và tháng trước đây; sink và dashboard dùng giờ gốc của Binance
+	Offset uint64
+	// Publisher nil thì nến chỉ đi vào sink
+	Publisher *Publisher
+}
+
+// Publisher là contract nhận nến của một khung thời gian
+type Publisher struct {
+	// Name dùng cho leader gating, metric và health (vd: ohlcv-day)
+	Name            string
+	ContractAddress string
+	PrivateKey      string
+	// Enabled false thì giữ cấu hình nhưng không gửi giao dịch
+	Enabled bool
+}
+
+// Intervals là các chuỗi nến được stream; thêm một khung thời gian chỉ cần thêm một dòng
+var Intervals = []Interval{
+	{Name: "1m"},
+	{Name: "5m"},
+	{Name: "15m"},
+	{Name: "1h"},
+	{Name: "4h"},
+	{Name: "1d", Publisher: &Publisher{Name: "ohlcv-day", ContractAddress: ContractAddressDay, PrivateKey: PrivateKeyDay}},
+	{Name: "1w", Offset: 271158000, Publisher: &Publisher{Name: "ohlcv-week", ContractAddress: ContractAddressWeek, PrivateKey: PrivateKeyWeek}},
+	{Name: "1M", Offset: 271158000, Publisher: &Publisher{Name: "ohlcv-month", ContractAddress: ContractAddressMonth, PrivateKey: PrivateKeyMonth}},
+}
+
+// IntervalNames trả về tên các khung thời gian đã cấu hình
+func IntervalNames() []string {
+	names := make([]string, 0, len(Intervals))
+	for _, iv := range Intervals {
+		names = append(names, iv.Name)
+	}
+	return names
+}
+
+// Lookup nhận tên khung thời gian từ API; "1mo" là 1M. ok là false khi khung không có
+// trong Intervals.
+func Lookup(v string) (string, bool) {
+	if v == "1mo" {
+		v = "1M"
+	}
+	for _, name := range IntervalNames() {
+		if name == v {
+			return v, true
+		}
+	}
+	return "", false
+}
@@ -1,29 +1,280 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"main/services/admin"
+	"main/services/api"
+	"main/services/archive"
+	"main/services/bus"
+	"main/services/configwatch"
+	"main/services/entities"
+	"main/services/export"
 	// "main/services/bitcoinNetFlow"
 	// "main/services/fearGreedindex"
 	getChains "main/services/get_chains"
-	// "main/services/ohlcv"
-	// "main/services/stablecoin"
+	"main/services/grpcapi"
+	"main/services/headercache"
+	"main/services/health"
+	"main/services/httpserver"
+	"main/services/leader"
+	"main/services/logging"
+	"main/services/metrics"
+	"main/services/ohlcv"
+	"main/services/outbox"
+	"main/services/pipeline"
+	"main/services/prices"
+	"main/services/shard"
+	"main/services/sink"
+	"main/services/stablecoin"
+	"main/services/store"
+	"main/services/supervisor"
+	"main/services/tsdb"
+	"main/services/wsserver"
 	// Onchain_exchange_flow "main/services/Onchain_exchange_flow"
 	// getChains "main/services/get_chains"
 	// real_time_txs "main/services/real_time_TXS"
 )
 
 func main() {
+	// "main admin ..." là CLI gọi admin API của tiến trình đang chạy
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		if err := admin.RunCLI(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// "main import ..." nhập file log cũ vào các sink rồi thoát
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// "main archive ..." xem kho block thô hoặc chạy lại decoder trên block đã lưu
+	if len(os.Args) > 1 && os.Args[1] == "archive" {
+		if err := archive.RunCLI(os.Args[2:], replayArchive); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// "main export ..." xuất dữ liệu đã ingest ra Parquet/CSV theo chain và ngày
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// LOG_LEVEL, LOG_FORMAT (text|json), LOG_DIR: mọi file log nằm trong một thư mục, có xoay vòng
+	if err := logging.Setup(logging.ConfigFromEnv()); err != nil {
+		log.Printf("⚠️ Không thể mở file log, chỉ ghi ra stdout: %v", err)
+	}
+	// Chain, ví và symbol thêm/bớt qua admin API được lưu lại và khôi phục trước khi service chạy
+	if err := admin.Restore(admin.StatePath()); err != nil {
+		log.Printf("⚠️ Không thể khôi phục cấu hình lúc chạy, dùng mặc định: %v", err)
+	}
+	// Dữ liệu block/giao dịch đi vào sink (JSON Lines dưới SINK_DIR), không vào file log
+	fileSink := registerSinks()
+	pipeline.Register(pipeline.Wildcard, pipeline.Wildcard, sink.TransferProcessor{})
+
+	// Store truy vấn được nạp lại từ file của sink rồi nhận tiếp bản ghi mới cho REST API
+	retention, _ := strconv.Atoi(os.Getenv("STORE_RETENTION"))
+	queryStore := store.New(retention)
+	if err := queryStore.Load(fileSink.Dir()); err != nil {
+		log.Printf("⚠️ Không thể nạp dữ liệu cho store: %v", err)
+	}
+	sink.Register("store", queryStore)
+
+	// Giá USD lấy từ nến OHLCV; phải đăng ký trước hub để bộ lọc min_usd dùng giá mới nhất
+	stablecoin.RegisterTokens("bsc")
+	sink.Register("prices", prices.Sink{})
+	// Hub WebSocket nhận event chuẩn từ pipeline và nến từ sink để đẩy cho client đã subscribe
+	hub := wsserver.NewHub(0)
+	pipeline.Register(pipeline.Wildcard, pipeline.Wildcard, hub)
+	sink.Register("ws", hub)
+	// gRPC cho service nội bộ: truy vấn giống REST và StreamTransfers có thể nối tiếp từ cursor
+	grpcServer := grpcapi.New(queryStore)
+	pipeline.Register(pipeline.Wildcard, pipeline.Wildcard, grpcServer)
+
 	log.Println("Starting to fetch Binance coin prices...")
 
+	// Header cache dùng chung có thể lưu xuống đĩa để giữ qua các lần khởi động
+	if dir := os.Getenv("HEADER_CACHE_DIR"); dir != "" {
+		headercache.SetDiskDir(dir)
+	}
+
 	//done
-	// go ohlcv.RunOHLCV()
-	// go stablecoin.Stablecoin()
+	// stablecoin.RegisterFlowProcessor("bsc")
 	// go fearGreedindex.FearGreedindex()
-	// go bitcoinNetFlow.BitcoinNetFlow()
-	getChains.StartGetChains()
+	// go bitcoinNetFlow.BitcoinNetFlow(ctx)
 	// Onchain_exchange_flow.Onchain_exchange_flow()
 	// real_time_txs.Real_time_txs()
 
-	select {}
+	// SIGINT/SIGTERM chỉ được lắng nghe ở đây; các service dừng qua ctx
+	ctx, stop := supervisor.SignalContext(context.Background())
+	defer stop()
+
+	// /metrics cho Prometheus; các endpoint khác đăng ký vào cùng server
+	httpserver.Handle("/metrics", metrics.Handler())
+	// /healthz, /readyz cho orchestrator; ?component=<tiền tố> để kiểm tra riêng một thành phần
+	health.AddSource(health.SupervisorSource)
+	health.AddSource(configwatch.Source)
+	httpserver.HandleFunc("/healthz", health.HealthzHandler)
+	httpserver.HandleFunc("/readyz", health.ReadyzHandler)
+	// /transfers, /blocks, /ohlcv, /stablecoin/flows, /feargreed, /btc/netflow
+	api.Register(queryStore)
+	// /shards: worker nào trong cụm đang chạy chain nào (đọc từ heartbeat dùng chung)
+	httpserver.HandleFunc("/shards", shard.ViewHandler)
+	// /ws: subscribe theo chain, address, token, min_usd, event_type hoặc symbol/interval của nến
+	httpserver.Handle("/ws", hub)
+
+	root := supervisor.New("dsea")
+	// /admin/...: thêm/bớt chain EVM, rule lọc, ví theo dõi và symbol OHLCV khi đang chạy
+	admin.Register(root)
+	root.Add("http", func(ctx context.Context) error {
+		return httpserver.Serve(ctx, httpserver.Addr())
+	}, supervisor.DefaultPolicy)
+	root.Add("grpc", func(ctx context.Context) error {
+		return grpcServer.Serve(ctx, grpcapi.Addr())
+	}, supervisor.DefaultPolicy)
+	// Nhiều replica chạy cùng lúc: ingest ở mọi replica, gửi contract và cảnh báo chỉ ở leader
+	root.Add("leader", leader.Run, supervisor.DefaultPolicy)
+	// Việc chỉ leader làm được xếp vào hộp thư dùng chung ở mọi replica, leader gửi dần
+	root.Add("outbox", outbox.Run, supervisor.DefaultPolicy)
+	root.Add("get_chains", getChains.StartGetChains, supervisor.DefaultPolicy)
+	// Nến Binance của mọi symbol × khung thời gian; admin khởi động lại khi đổi symbol
+	root.Add("ohlcv", ohlcv.Stream, supervisor.DefaultPolicy)
+	// Nạp lại file cấu hình khi bị sửa; cấu hình lỗi bị từ chối và cấu hình cũ vẫn chạy
+	root.Add("configwatch", configwatch.Run, supervisor.DefaultPolicy)
+	// EXPORT_DIR: leader xuất các ngày đã trọn ra Parquet/CSV mỗi giờ
+	if export.Enabled() {
+		root.Add("export", export.Run, supervisor.DefaultPolicy)
+	}
+	// BSCSCAN_API_KEY: theo dõi các ví trong danh sách; /admin/wallets thêm/gỡ ví khi đang chạy
+	if entities.Enabled() {
+		root.Add("wallets", entities.Run, supervisor.DefaultPolicy)
+	}
+	// root.Add("stablecoin", stablecoin.Stablecoin, supervisor.DefaultPolicy)
+	// Hook chạy theo thứ tự ngược: sink được đóng trước, file log đóng sau cùng
+	root.OnShutdown("logs", func(ctx context.Context) error {
+		return logging.Close()
+	})
+	root.OnShutdown("sinks", func(ctx context.Context) error {
+		return sink.CloseAll()
+	})
+
+	if err := root.Run(ctx); err != nil {
+		log.Printf("❌ Dừng với lỗi: %v", err)
+		os.Exit(1)
+	}
+	log.Println("👋 Đã dừng toàn bộ service")
+}
+
+// replayArchive chạy lại block đã lưu qua decoder của chain; transfer mới được ghi vào
+// file sink như khi ingest. Kho không được đăng ký làm sink để block không bị ghi lại.
+func replayArchive(chain string, from, to uint64) (int, error) {
+	if err := admin.Restore(admin.StatePath()); err != nil {
+		log.Printf("⚠️ Không thể khôi phục cấu hình lúc chạy, dùng mặc định: %v", err)
+	}
+	sink.Register("file", sink.NewFileSink("", 256<<20))
+	pipeline.Register(pipeline.Wildcard, pipeline.Wildcard, sink.TransferProcessor{})
+
+	count, err := getChains.ReplayArchivedBlocks(chain, from, to)
+	if closeErr := sink.CloseAll(); err == nil {
+		err = closeErr
+	}
+	return count, err
+}
+
+// registerSinks đăng ký các sink lưu dữ liệu lâu dài, dùng chung cho tiến trình chính
+// và lệnh import
+func registerSinks() *sink.FileSink {
+	fileSink := sink.NewFileSink("", 256<<20)
+	sink.Register("file", fileSink)
+	// Block thô được nén theo segment độ cao dưới ARCHIVE_DIR để chạy lại decoder; chỉ lưu
+	// chain mà block đủ dữ liệu để chạy lại
+	sink.Register("archive", archive.New(getChains.Replayable))
+	// BUS_URL: gửi transfer, block và bản ghi tổng hợp lên NATS cho bên phân tích
+	if cfg, ok := bus.ConfigFromEnv(); ok {
+		busSink, err := bus.New(cfg)
+		if err != nil {
+			log.Printf("⚠️ Không thể kết nối message bus: %v", err)
+		} else {
+			sink.Register("bus", busSink)
+		}
+	}
+	// TSDB_INFLUX_URL, TSDB_REMOTE_WRITE_URL: bản ghi tổng hợp thành time series cho Grafana
+	if cfg, ok, err := tsdb.ConfigFromEnv(); err != nil {
+		log.Printf("⚠️ Cấu hình time series không hợp lệ: %v", err)
+	} else if ok {
+		sink.Register("tsdb", tsdb.New(cfg))
+	}
+	return fileSink
+}
+
+// runImport: main import [-chain <tên>] [-dry-run] [file hoặc thư mục ...]
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	chain := fs.String("chain", "", "chỉ nhập chain này")
+	dryRun := fs.Bool("dry-run", false, "chỉ đọc và đếm, không ghi vào sink")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = getChains.DefaultImportPaths
+	}
+
+	if !*dryRun {
+		registerSinks()
+		pipeline.Register(pipeline.Wildcard, pipeline.Wildcard, sink.TransferProcessor{})
+	}
+	stats, err := getChains.ImportLogs(paths, getChains.ImportOptions{Chain: *chain, DryRun: *dryRun})
+	if closeErr := sink.CloseAll(); err == nil {
+		err = closeErr
+	}
+	log.Printf("📥 Đã nhập %d file: %d block, %d event, %d bản ghi khác, %d trùng, %d không nhận ra",
+		stats.Files, stats.Blocks, stats.Events, stats.Records, stats.Duplicates, stats.Skipped)
+	return err
+}
+
+// runExport: main export [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-datasets a,b] [-format parquet|csv|both] [-out dir]
+func runExport(args []string) error {
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	from := fs.String("from", yesterday, "ngày đầu (UTC)")
+	to := fs.String("to", "", "ngày cuối (UTC), mặc định bằng -from")
+	datasets := fs.String("datasets", "", "danh sách dataset cách nhau bởi dấu phẩy, mặc định tất cả")
+	format := fs.String("format", "", "parquet, csv hoặc both (mặc định EXPORT_FORMAT hoặc parquet)")
+	out := fs.String("out", "", "thư mục xuất (mặc định EXPORT_DIR hoặc "+export.DefaultDir+")")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" {
+		*to = *from
+	}
+	fromDay, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		return err
+	}
+	toDay, err := time.Parse("2006-01-02", *to)
+	if err != nil {
+		return err
+	}
+	opts := export.Options{OutDir: *out, From: fromDay, To: toDay, Format: *format}
+	if *datasets != "" {
+		opts.Datasets = strings.Split(*datasets, ",")
+	}
+
+	stats, err := export.Export(opts)
+	log.Printf("📦 Đã xuất %d file từ %s tới %s: %v", stats.Files, *from, *to, stats.Rows)
+	return err
 }
@@ -0,0 +1,272 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"main/services/entities"
+	getChains "main/services/get_chains"
+	"main/services/httpserver"
+	"main/services/ohlcv"
+	"main/services/supervisor"
+)
+
+const maxBodySize = 1 << 20
+
+type handler struct {
+	token     string
+	statePath string
+	root      *supervisor.Supervisor
+
+	// mu tuần tự hóa các thay đổi để state ghi xuống luôn khớp với state đang chạy
+	mu sync.Mutex
+}
+
+// Register đăng ký /admin/... vào HTTP server dùng chung. Mọi request cần header
+// "Authorization: Bearer <ADMIN_TOKEN>"; không đặt ADMIN_TOKEN thì admin API bị tắt.
+// root dùng để khởi động lại service bị ảnh hưởng (vd: ohlcv khi đổi symbol).
+func Register(root *supervisor.Supervisor) {
+	h := &handler{token: os.Getenv("ADMIN_TOKEN"), statePath: StatePath(), root: root}
+	if h.token == "" {
+		log.Printf("⚠️ ADMIN_TOKEN chưa được đặt, admin API bị tắt")
+	}
+
+	routes := map[string]http.HandlerFunc{
+		"GET /admin/chains":                         h.listChains,
+		"POST /admin/chains":                        h.addChain,
+		"DELETE /admin/chains/{name}":               h.removeChain,
+		"GET /admin/chains/{name}/rules":            h.listRules,
+		"PUT /admin/chains/{name}/rules":            h.putRule,
+		"DELETE /admin/chains/{name}/rules/{label}": h.removeRule,
+		"GET /admin/wallets":                        h.listWallets,
+		"POST /admin/wallets":                       h.addWallet,
+		"DELETE /admin/wallets/{address}":           h.removeWallet,
+		"GET /admin/ohlcv/symbols":                  h.listSymbols,
+		"POST /admin/ohlcv/symbols":                 h.addSymbol,
+		"DELETE /admin/ohlcv/symbols/{symbol}":      h.removeSymbol,
+		"POST /admin/ohlcv/backfill":                h.backfillSymbol,
+	}
+	for pattern, fn := range routes {
+		httpserver.Handle(pattern, h.authorize(fn))
+	}
+}
+
+func (h *handler) authorize(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.token == "" {
+			writeError(w, http.StatusServiceUnavailable, fmt.Errorf("admin API bị tắt (chưa đặt ADMIN_TOKEN)"))
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("token không hợp lệ"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		next(w, r)
+	})
+}
+
+// change áp dụng thay đổi rồi lưu state; lỗi của apply là lỗi của client (400)
+func (h *handler) change(w http.ResponseWriter, r *http.Request, status int, what string, apply func() error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := apply(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	log.Printf("🛠️ [ADMIN] %s (từ %s)", what, r.RemoteAddr)
+
+	if err := saveState(h.statePath); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("đã áp dụng nhưng không lưu được %s: %w", h.statePath, err))
+		return
+	}
+	writeJSON(w, status, map[string]string{"result": what})
+}
+
+func (h *handler) listChains(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": getChains.EVMChains()})
+}
+
+func (h *handler) addChain(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name   string          `json:"name"`
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("body không hợp lệ: %w", err))
+		return
+	}
+	h.change(w, r, http.StatusCreated, "đã bật chain "+req.Name, func() error {
+		return getChains.AddEVMChain(req.Name, req.Config)
+	})
+}
+
+func (h *handler) removeChain(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	h.change(w, r, http.StatusOK, "đã tắt chain "+name, func() error {
+		return getChains.RemoveEVMChain(name)
+	})
+}
+
+func (h *handler) listRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := getChains.FilterRules(r.PathValue("name"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if rules == nil {
+		rules = []getChains.FilterRule{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": rules})
+}
+
+func (h *handler) putRule(w http.ResponseWriter, r *http.Request) {
+	var rule getChains.FilterRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("body không hợp lệ: %w", err))
+		return
+	}
+	chain := r.PathValue("name")
+	h.change(w, r, http.StatusOK, fmt.Sprintf("đã lưu rule %s của chain %s", rule.Label, chain), func() error {
+		return getChains.PutFilterRule(chain, rule)
+	})
+}
+
+func (h *handler) removeRule(w http.ResponseWriter, r *http.Request) {
+	chain, label := r.PathValue("name"), r.PathValue("label")
+	h.change(w, r, http.StatusOK, fmt.Sprintf("đã xóa rule %s của chain %s", label, chain), func() error {
+		return getChains.RemoveFilterRule(chain, label)
+	})
+}
+
+func (h *handler) listWallets(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": entities.GetAllMonitoredAddresses()})
+}
+
+func (h *handler) addWallet(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Address string `json:"address"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("body không hợp lệ: %w", err))
+		return
+	}
+	h.change(w, r, http.StatusCreated, "đã thêm ví "+req.Address, func() error {
+		if !common.IsHexAddress(req.Address) {
+			return fmt.Errorf("địa chỉ không hợp lệ: %q", req.Address)
+		}
+		if !entities.WatchAddress(req.Address) {
+			return fmt.Errorf("ví %s đã được theo dõi", req.Address)
+		}
+		return nil
+	})
+}
+
+func (h *handler) removeWallet(w http.ResponseWriter, r *http.Request) {
+	address := r.PathValue("address")
+	h.change(w, r, http.StatusOK, "đã gỡ ví "+address, func() error {
+		if !entities.UnwatchAddress(address) {
+			return fmt.Errorf("ví %s chưa được theo dõi", address)
+		}
+		return nil
+	})
+}
+
+func (h *handler) listSymbols(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": ohlcv.Symbols()})
+}
+
+func (h *handler) addSymbol(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Symbol string `json:"symbol"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("body không hợp lệ: %w", err))
+		return
+	}
+	h.change(w, r, http.StatusCreated, "đã thêm symbol "+req.Symbol, func() error {
+		if err := ohlcv.AddSymbol(req.Symbol); err != nil {
+			return err
+		}
+		h.restartOHLCV()
+		return nil
+	})
+}
+
+func (h *handler) removeSymbol(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+	h.change(w, r, http.StatusOK, "đã gỡ symbol "+symbol, func() error {
+		if err := ohlcv.RemoveSymbol(symbol); err != nil {
+			return err
+		}
+		h.restartOHLCV()
+		return nil
+	})
+}
+
+// backfillSymbol nạp toàn bộ lịch sử nến của symbol vào candle store ở nền; body:
+// {"symbol": "BTCUSDT", "interval": "1d", "since": "2020-01-01"}, interval và since tùy chọn
+func (h *handler) backfillSymbol(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Symbol   string `json:"symbol"`
+		Interval string `json:"interval"`
+		Since    string `json:"since"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("body không hợp lệ: %w", err))
+		return
+	}
+	if req.Symbol == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("thiếu symbol"))
+		return
+	}
+	var since time.Time
+	if req.Since != "" {
+		t, err := time.Parse(time.DateOnly, req.Since)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("since phải có dạng YYYY-MM-DD: %w", err))
+			return
+		}
+		since = t
+	}
+
+	// Lịch sử từ lúc niêm yết có thể hàng nghìn trang, không giữ request chờ
+	go func() {
+		n, err := ohlcv.BackfillHistory(context.Background(), req.Symbol, req.Interval, since)
+		if err != nil {
+			log.Printf("❌ Nạp lịch sử %s dừng sau %d nến: %v", req.Symbol, n, err)
+			return
+		}
+		log.Printf("✅ Đã nạp %d nến lịch sử %s", n, req.Symbol)
+	}()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "đang nạp lịch sử " + strings.ToUpper(req.Symbol)})
+}
+
+// restartOHLCV kết nối lại luồng OHLCV để dùng danh sách symbol mới
+func (h *handler) restartOHLCV() {
+	if h.root == nil || !h.root.Restart("ohlcv") {
+		log.Printf("⚠️ [ADMIN] Service ohlcv không chạy, danh sách symbol mới có hiệu lực ở lần khởi động sau")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
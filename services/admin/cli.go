@@ -0,0 +1,213 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"main/services/httpserver"
+)
+
+const usage = `Cách dùng: main admin <nhóm> <lệnh> [tham số]
+
+  chains  list
+  chains  add <tên> [file-cấu-hình.json]
+  chains  remove <tên>
+  rules   list <chain>
+  rules   put <chain> <file-rule.json>
+  rules   remove <chain> <label>
+  wallets list | add <địa-chỉ> | remove <địa-chỉ>
+  symbols list | add <symbol> | remove <symbol>
+  symbols backfill <symbol> [khung] [từ-ngày YYYY-MM-DD]
+
+Biến môi trường: ADMIN_URL (mặc định http://127.0.0.1<HTTP_ADDR>), ADMIN_TOKEN`
+
+// RunCLI chạy lệnh admin bằng cách gọi admin API của tiến trình đang chạy
+func RunCLI(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("%s", usage)
+	}
+	group, cmd, rest := args[0], args[1], args[2:]
+
+	arg := func(i int) (string, error) {
+		if i >= len(rest) {
+			return "", fmt.Errorf("thiếu tham số\n\n%s", usage)
+		}
+		return rest[i], nil
+	}
+
+	switch group + " " + cmd {
+	case "chains list":
+		return call(http.MethodGet, "/admin/chains", nil)
+	case "chains add":
+		name, err := arg(0)
+		if err != nil {
+			return err
+		}
+		body := map[string]interface{}{"name": name}
+		if len(rest) > 1 {
+			config, err := readJSONFile(rest[1])
+			if err != nil {
+				return err
+			}
+			body["config"] = config
+		}
+		return call(http.MethodPost, "/admin/chains", body)
+	case "chains remove":
+		name, err := arg(0)
+		if err != nil {
+			return err
+		}
+		return call(http.MethodDelete, "/admin/chains/"+url.PathEscape(name), nil)
+
+	case "rules list":
+		chain, err := arg(0)
+		if err != nil {
+			return err
+		}
+		return call(http.MethodGet, "/admin/chains/"+url.PathEscape(chain)+"/rules", nil)
+	case "rules put":
+		chain, err := arg(0)
+		if err != nil {
+			return err
+		}
+		file, err := arg(1)
+		if err != nil {
+			return err
+		}
+		rule, err := readJSONFile(file)
+		if err != nil {
+			return err
+		}
+		return call(http.MethodPut, "/admin/chains/"+url.PathEscape(chain)+"/rules", rule)
+	case "rules remove":
+		chain, err := arg(0)
+		if err != nil {
+			return err
+		}
+		label, err := arg(1)
+		if err != nil {
+			return err
+		}
+		return call(http.MethodDelete, "/admin/chains/"+url.PathEscape(chain)+"/rules/"+url.PathEscape(label), nil)
+
+	case "wallets list":
+		return call(http.MethodGet, "/admin/wallets", nil)
+	case "wallets add":
+		address, err := arg(0)
+		if err != nil {
+			return err
+		}
+		return call(http.MethodPost, "/admin/wallets", map[string]string{"address": address})
+	case "wallets remove":
+		address, err := arg(0)
+		if err != nil {
+			return err
+		}
+		return call(http.MethodDelete, "/admin/wallets/"+url.PathEscape(address), nil)
+
+	case "symbols list":
+		return call(http.MethodGet, "/admin/ohlcv/symbols", nil)
+	case "symbols add":
+		symbol, err := arg(0)
+		if err != nil {
+			return err
+		}
+		return call(http.MethodPost, "/admin/ohlcv/symbols", map[string]string{"symbol": symbol})
+	case "symbols remove":
+		symbol, err := arg(0)
+		if err != nil {
+			return err
+		}
+		return call(http.MethodDelete, "/admin/ohlcv/symbols/"+url.PathEscape(symbol), nil)
+	case "symbols backfill":
+		symbol, err := arg(0)
+		if err != nil {
+			return err
+		}
+		body := map[string]string{"symbol": symbol}
+		if len(rest) > 1 {
+			body["interval"] = rest[1]
+		}
+		if len(rest) > 2 {
+			body["since"] = rest[2]
+		}
+		return call(http.MethodPost, "/admin/ohlcv/backfill", body)
+	}
+	return fmt.Errorf("lệnh không hợp lệ: %s %s\n\n%s", group, cmd, usage)
+}
+
+func readJSONFile(path string) (json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("%s không phải JSON hợp lệ", path)
+	}
+	return data, nil
+}
+
+func baseURL() string {
+	if u := os.Getenv("ADMIN_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	addr := httpserver.Addr()
+	if strings.HasPrefix(addr, ":") {
+		addr = "127.0.0.1" + addr
+	}
+	return "http://" + addr
+}
+
+// call gửi request tới admin API và in phản hồi JSON
+func call(method, path string, body interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, baseURL()+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("ADMIN_TOKEN"))
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+		}
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, data, "", "  ") == nil {
+		data = pretty.Bytes()
+	}
+	fmt.Println(strings.TrimSpace(string(data)))
+	return nil
+}
@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"main/services/entities"
+	getChains "main/services/get_chains"
+	"main/services/ohlcv"
+)
+
+// DefaultStatePath là file lưu các danh sách đổi được lúc chạy, có thể đổi bằng ADMIN_STATE_FILE.
+// Cấu hình riêng của từng chain (endpoint, rule lọc) vẫn nằm trong config_chain/config-<chain>.json.
+const DefaultStatePath = "./config/runtime.json"
+
+// State là các danh sách theo dõi thay đổi được qua admin API
+type State struct {
+	EVMChains    []string `json:"evm_chains"`
+	Wallets      []string `json:"wallets"`
+	OHLCVSymbols []string `json:"ohlcv_symbols"`
+}
+
+// StatePath trả về đường dẫn file state đã cấu hình
+func StatePath() string {
+	if path := os.Getenv("ADMIN_STATE_FILE"); path != "" {
+		return path
+	}
+	return DefaultStatePath
+}
+
+// Restore nạp state đã lưu vào các package; gọi trước khi các service khởi động.
+// Chưa có file thì giữ danh sách mặc định trong code.
+func Restore(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("file state %s hỏng: %w", path, err)
+	}
+	if state.EVMChains != nil {
+		if err := getChains.SetEVMChains(state.EVMChains); err != nil {
+			return err
+		}
+	}
+	if state.Wallets != nil {
+		entities.SetTargetAddresses(state.Wallets)
+	}
+	if state.OHLCVSymbols != nil {
+		ohlcv.SetSymbols(state.OHLCVSymbols)
+	}
+
+	log.Printf("🛠️ Đã khôi phục cấu hình lúc chạy từ %s (%d chain, %d ví, %d symbol)",
+		path, len(state.EVMChains), len(state.Wallets), len(state.OHLCVSymbols))
+	return nil
+}
+
+func currentState() State {
+	return State{
+		EVMChains:    getChains.EVMChains(),
+		Wallets:      entities.GetAllMonitoredAddresses(),
+		OHLCVSymbols: ohlcv.Symbols(),
+	}
+}
+
+// saveState ghi state hiện tại qua file tạm rồi đổi tên
+func saveState(path string) error {
+	data, err := json.MarshalIndent(currentState(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
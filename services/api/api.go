@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	ohlcvConfig "main/config/ohlcv"
+	"main/services/httpserver"
+	"main/services/ohlcv"
+	"main/services/store"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Register đăng ký các endpoint truy vấn vào HTTP server dùng chung
+func Register(s *store.Store) {
+	h := &handler{store: s}
+	httpserver.HandleFunc("GET /transfers", h.transfers)
+	httpserver.HandleFunc("GET /blocks/{chain}/{height}", h.block)
+	httpserver.HandleFunc("GET /ohlcv/{symbol}", h.ohlcv)
+	httpserver.HandleFunc("GET /ohlcv/{symbol}/live", h.ohlcvLive)
+	httpserver.HandleFunc("GET /stablecoin/flows", h.stablecoinFlows)
+	httpserver.HandleFunc("GET /feargreed", h.fearGreed)
+	httpserver.HandleFunc("GET /btc/netflow", h.btcNetFlow)
+}
+
+type handler struct {
+	store *store.Store
+}
+
+// page là dạng phản hồi chung của các endpoint trả về danh sách
+type page struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+func (h *handler) transfers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit, err := parseLimit(q.Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	query := store.TransferQuery{
+		Chain:   q.Get("chain"),
+		Address: q.Get("address"),
+		Token:   q.Get("token"),
+		Limit:   limit,
+	}
+	if query.From, err = parseTime(q.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("from: %w", err))
+		return
+	}
+	if query.To, err = parseTime(q.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("to: %w", err))
+		return
+	}
+	if v := q.Get("min_amount"); v != "" {
+		amount, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("min_amount không hợp lệ: %s", v))
+			return
+		}
+		query.MinAmount = amount
+	}
+	if v := q.Get("cursor"); v != "" {
+		if query.Cursor, err = strconv.ParseUint(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("cursor không hợp lệ: %s", v))
+			return
+		}
+	}
+
+	items, next := h.store.Transfers(query)
+	resp := page{Data: nonNil(items)}
+	if next != 0 {
+		resp.NextCursor = strconv.FormatUint(next, 10)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) block(w http.ResponseWriter, r *http.Request) {
+	height, err := strconv.ParseUint(r.PathValue("height"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("height không hợp lệ: %s", r.PathValue("height")))
+		return
+	}
+
+	raw, ok := h.store.Block(r.PathValue("chain"), height)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("không tìm thấy khối %d của chain %s", height, r.PathValue("chain")))
+		return
+	}
+	writeJSON(w, http.StatusOK, raw)
+}
+
+func (h *handler) ohlcv(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	interval, ok := ohlcvConfig.Lookup(defaultString(q.Get("interval"), "1d"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("interval không hợp lệ: %s (hỗ trợ %s)", q.Get("interval"), strings.Join(ohlcvConfig.IntervalNames(), ", ")))
+		return
+	}
+	limit, err := parseLimit(q.Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	from, err := parseTime(q.Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("from: %w", err))
+		return
+	}
+	to, err := parseTime(q.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("to: %w", err))
+		return
+	}
+
+	candles := h.store.Candles(r.PathValue("symbol"), interval, unixMilli(from), unixMilli(to), limit)
+	writeJSON(w, http.StatusOK, page{Data: nonNil(candles)})
+}
+
+// ohlcvLive trả về nến đang chạy (chưa chốt) của symbol; interval bỏ trống là mọi khung
+func (h *handler) ohlcvLive(w http.ResponseWriter, r *http.Request) {
+	candles := ohlcv.Live(r.PathValue("symbol"))
+	v := r.URL.Query().Get("interval")
+	if v == "" {
+		writeJSON(w, http.StatusOK, page{Data: candles})
+		return
+	}
+	interval, ok := ohlcvConfig.Lookup(v)
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("interval không hợp lệ: %s (hỗ trợ %s)", v, strings.Join(ohlcvConfig.IntervalNames(), ", ")))
+		return
+	}
+	candle, ok := candles[interval]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("chưa có nến đang chạy của %s %s", r.PathValue("symbol"), interval))
+		return
+	}
+	writeJSON(w, http.StatusOK, page{Data: candle})
+}
+
+func (h *handler) stablecoinFlows(w http.ResponseWriter, r *http.Request) {
+	period, ok := store.FlowPeriod(defaultString(r.URL.Query().Get("period"), "1d"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("period không hợp lệ: %s (hỗ trợ 1d, 1w, 1m)", r.URL.Query().Get("period")))
+		return
+	}
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page{Data: h.store.StablecoinFlows(period, limit)})
+}
+
+func (h *handler) fearGreed(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page{Data: h.store.FearGreed(limit)})
+}
+
+func (h *handler) btcNetFlow(w http.ResponseWriter, r *http.Request) {
+	period, ok := store.NetFlowPeriod(defaultString(r.URL.Query().Get("period"), "daily"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("period không hợp lệ: %s (hỗ trợ daily, weekly, monthly)", r.URL.Query().Get("period")))
+		return
+	}
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page{Data: h.store.BTCNetFlow(period, limit)})
+}
+
+func parseLimit(v string) (int, error) {
+	if v == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("limit không hợp lệ: %s", v)
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return limit, nil
+}
+
+// parseTime nhận RFC3339, ngày (2006-01-02) hoặc unix giây
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("thời gian không hợp lệ: %s", v)
+}
+
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func defaultString(v, fallback string) string {
+	if strings.TrimSpace(v) == "" {
+		return fallback
+	}
+	return v
+}
+
+func nonNil(items []json.RawMessage) []json.RawMessage {
+	if items == nil {
+		return []json.RawMessage{}
+	}
+	return items
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,345 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"main/services/metrics"
+	"main/services/sink"
+)
+
+// DefaultDir chứa kho block thô, mỗi chain một thư mục con; đổi bằng ARCHIVE_DIR
+const DefaultDir = "./data/archive"
+
+// DefaultSegmentBlocks là số block (theo độ cao) của một segment mới, đổi bằng
+// ARCHIVE_SEGMENT_BLOCKS. Segment đã có giữ nguyên khoảng ghi trong index.
+const DefaultSegmentBlocks = 10000
+
+const indexName = "index.json"
+
+// Một frame zstd được đóng sau frameBlocks block hoặc frameAge, tùy điều kiện nào đến
+// trước. Chỉ frame đã đóng mới được ghi vào index, nên khi tiến trình chết giữa chừng
+// chỉ mất phần block của frame đang mở.
+const (
+	frameBlocks = 100
+	frameAge    = time.Minute
+)
+
+// maxOpen: số segment mở cùng lúc của một chain (ingest ở đầu chain và backfill lùi về
+// quá khứ thường ghi vào hai segment khác nhau)
+const maxOpen = 2
+
+// Segment là một file <from>-<to>.jsonl.zst chứa block có độ cao trong [From, To]
+type Segment struct {
+	File    string    `json:"file"`
+	From    uint64    `json:"from_block"`
+	To      uint64    `json:"to_block"`
+	Blocks  int       `json:"blocks"`
+	Bytes   int64     `json:"bytes"`
+	Updated time.Time `json:"updated"`
+}
+
+// Index liệt kê segment của một chain theo thứ tự độ cao
+type Index struct {
+	Chain    string     `json:"chain"`
+	Segments []*Segment `json:"segments"`
+}
+
+type segmentWriter struct {
+	seg  *Segment
+	file *os.File
+	enc  *zstd.Encoder
+
+	// frame đang mở: số block và lúc bắt đầu (0 block nghĩa là chưa mở)
+	pending int
+	opened  time.Time
+	used    time.Time
+}
+
+type chainArchive struct {
+	dir     string
+	index   Index
+	writers map[string]*segmentWriter
+}
+
+// Sink ghi bản ghi "block" (block thô của mọi chain) vào kho lưu trữ. Mỗi dòng trong
+// segment là JSON của sink.Record nên có thể đưa lại cho decoder mà không cần gọi RPC.
+type Sink struct {
+	dir  string
+	size uint64
+	// accept chọn chain được lưu (chỉ chain chạy lại được decoder); nil là mọi chain
+	accept func(chain string) bool
+
+	mu     sync.Mutex
+	chains map[string]*chainArchive
+}
+
+// Dir trả về thư mục kho đã cấu hình
+func Dir() string {
+	if dir := os.Getenv("ARCHIVE_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultDir
+}
+
+// New tạo sink ghi vào Dir() với cỡ segment theo ARCHIVE_SEGMENT_BLOCKS; accept (có thể
+// nil) chọn chain được lưu
+func New(accept func(chain string) bool) *Sink {
+	size := uint64(DefaultSegmentBlocks)
+	if v, err := strconv.ParseUint(os.Getenv("ARCHIVE_SEGMENT_BLOCKS"), 10, 64); err == nil && v > 0 {
+		size = v
+	}
+	return &Sink{dir: Dir(), size: size, accept: accept, chains: make(map[string]*chainArchive)}
+}
+
+func (s *Sink) Write(r sink.Record) error {
+	if r.Kind != "block" || r.Chain == "" || (s.accept != nil && !s.accept(r.Chain)) {
+		return nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ca, err := s.chain(r.Chain)
+	if err != nil {
+		return err
+	}
+	w, err := ca.writer(ca.segmentFor(r.Block, s.size))
+	if err != nil {
+		metrics.ArchiveErrors.WithLabelValues(r.Chain).Inc()
+		return err
+	}
+
+	if w.pending == 0 {
+		w.opened = time.Now()
+	}
+	if _, err := w.enc.Write(append(data, '\n')); err != nil {
+		metrics.ArchiveErrors.WithLabelValues(r.Chain).Inc()
+		return err
+	}
+	w.pending++
+	w.used = time.Now()
+	metrics.ArchiveBlocks.WithLabelValues(r.Chain).Inc()
+
+	if w.pending >= frameBlocks || time.Since(w.opened) >= frameAge {
+		if err := ca.flush(); err != nil {
+			metrics.ArchiveErrors.WithLabelValues(r.Chain).Inc()
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush đóng frame đang mở của mọi segment và ghi lại index
+func (s *Sink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for name, ca := range s.chains {
+		if err := ca.flush(); err != nil {
+			metrics.ArchiveErrors.WithLabelValues(name).Inc()
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (s *Sink) Close() error {
+	err := s.Flush()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ca := range s.chains {
+		for file, w := range ca.writers {
+			if closeErr := w.file.Close(); err == nil {
+				err = closeErr
+			}
+			delete(ca.writers, file)
+		}
+	}
+	return err
+}
+
+func (s *Sink) chain(name string) (*chainArchive, error) {
+	if ca, ok := s.chains[name]; ok {
+		return ca, nil
+	}
+	dir := filepath.Join(s.dir, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	index, err := ReadIndex(s.dir, name)
+	if err != nil {
+		return nil, err
+	}
+	ca := &chainArchive{dir: dir, index: index, writers: make(map[string]*segmentWriter)}
+	s.chains[name] = ca
+	return ca, nil
+}
+
+// segmentFor trả về segment chứa block, tạo mới nếu chưa có. Segment mới được căn theo
+// size nhưng cắt bớt để không chồng lên segment đã có (khi cỡ segment đã đổi).
+func (ca *chainArchive) segmentFor(block, size uint64) *Segment {
+	segs := ca.index.Segments
+	i := sort.Search(len(segs), func(i int) bool { return segs[i].To >= block })
+	if i < len(segs) && segs[i].From <= block {
+		return segs[i]
+	}
+
+	from := block / size * size
+	to := from + size - 1
+	if i > 0 && segs[i-1].To >= from {
+		from = segs[i-1].To + 1
+	}
+	if i < len(segs) && segs[i].From <= to {
+		to = segs[i].From - 1
+	}
+
+	seg := &Segment{File: fmt.Sprintf("%012d-%012d.jsonl.zst", from, to), From: from, To: to}
+	ca.index.Segments = append(segs[:i], append([]*Segment{seg}, segs[i:]...)...)
+	return seg
+}
+
+// writer mở segment để ghi nối. Phần đuôi sau Bytes trong index là frame chưa đóng
+// của lần chạy trước nên bị cắt đi trước khi ghi tiếp.
+func (ca *chainArchive) writer(seg *Segment) (*segmentWriter, error) {
+	if w, ok := ca.writers[seg.File]; ok {
+		return w, nil
+	}
+	if len(ca.writers) >= maxOpen {
+		if err := ca.closeOldest(); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(filepath.Join(ca.dir, seg.File), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil && info.Size() > seg.Bytes {
+		log.Printf("⚠️ [ARCHIVE] Cắt %d byte frame dở của %s", info.Size()-seg.Bytes, seg.File)
+		if err := f.Truncate(seg.Bytes); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if _, err := f.Seek(seg.Bytes, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	enc, err := zstd.NewWriter(f, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w := &segmentWriter{seg: seg, file: f, enc: enc, used: time.Now()}
+	ca.writers[seg.File] = w
+	return w, nil
+}
+
+func (ca *chainArchive) closeOldest() error {
+	var oldest *segmentWriter
+	for _, w := range ca.writers {
+		if oldest == nil || w.used.Before(oldest.used) {
+			oldest = w
+		}
+	}
+	if err := ca.closeFrame(oldest); err != nil {
+		return err
+	}
+	delete(ca.writers, oldest.seg.File)
+	if err := oldest.file.Close(); err != nil {
+		return err
+	}
+	return ca.writeIndex()
+}
+
+// closeFrame kết thúc frame đang mở để phần đã ghi đọc được độc lập
+func (ca *chainArchive) closeFrame(w *segmentWriter) error {
+	if w.pending == 0 {
+		return nil
+	}
+	if err := w.enc.Close(); err != nil {
+		return err
+	}
+	offset, err := w.file.Seek(0, 1)
+	if err != nil {
+		return err
+	}
+	w.enc.Reset(w.file)
+	w.seg.Blocks += w.pending
+	w.seg.Bytes = offset
+	w.seg.Updated = time.Now()
+	w.pending = 0
+	return nil
+}
+
+func (ca *chainArchive) flush() error {
+	for _, w := range ca.writers {
+		if err := ca.closeFrame(w); err != nil {
+			return err
+		}
+	}
+	return ca.writeIndex()
+}
+
+func (ca *chainArchive) writeIndex() error {
+	data, err := json.MarshalIndent(ca.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(ca.dir, indexName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadIndex đọc index của chain; chain chưa có gì trong kho cho index rỗng
+func ReadIndex(dir, chain string) (Index, error) {
+	index := Index{Chain: chain}
+	data, err := os.ReadFile(filepath.Join(dir, chain, indexName))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return index, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return index, fmt.Errorf("index của %s bị hỏng: %w", chain, err)
+	}
+	sort.Slice(index.Segments, func(i, j int) bool { return index.Segments[i].From < index.Segments[j].From })
+	return index, nil
+}
+
+// Chains liệt kê các chain đã có index trong kho
+func Chains(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", indexName))
+	if err != nil {
+		return nil, err
+	}
+	chains := make([]string, 0, len(files))
+	for _, file := range files {
+		chains = append(chains, filepath.Base(filepath.Dir(file)))
+	}
+	sort.Strings(chains)
+	return chains, nil
+}
@@ -0,0 +1,90 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"main/services/sink"
+)
+
+const usage = `Cách dùng: main archive <lệnh> [tham số]
+
+  list   [chain]
+  cat    <chain> <từ-block> <tới-block>
+  replay <chain> <từ-block> <tới-block>   (chain EVM và tron)
+
+Biến môi trường: ARCHIVE_DIR (mặc định ./data/archive)`
+
+// Replayer chạy lại decoder trên block đã lưu, trả về số block đã xử lý
+type Replayer func(chain string, from, to uint64) (int, error)
+
+// RunCLI xem và chạy lại kho block. cat in block ra stdout dạng JSON Lines; replay giao
+// block cho replay (decoder của chain), kết quả đi vào các sink đã đăng ký.
+func RunCLI(args []string, replay Replayer) error {
+	if len(args) < 1 {
+		return fmt.Errorf("%s", usage)
+	}
+	cmd, rest := args[0], args[1:]
+	dir := Dir()
+
+	switch cmd {
+	case "list":
+		chains := rest
+		if len(chains) == 0 {
+			var err error
+			if chains, err = Chains(dir); err != nil {
+				return err
+			}
+		}
+		return list(dir, chains)
+	case "cat", "replay":
+		if len(rest) < 3 {
+			return fmt.Errorf("thiếu tham số\n\n%s", usage)
+		}
+		from, err := strconv.ParseUint(rest[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("từ-block không hợp lệ: %w", err)
+		}
+		to, err := strconv.ParseUint(rest[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("tới-block không hợp lệ: %w", err)
+		}
+		if cmd == "cat" {
+			return cat(dir, rest[0], from, to)
+		}
+		count, err := replay(rest[0], from, to)
+		fmt.Fprintf(os.Stderr, "Đã chạy lại %d block của %s\n", count, rest[0])
+		return err
+	}
+	return fmt.Errorf("lệnh không hợp lệ: %s\n\n%s", cmd, usage)
+}
+
+func list(dir string, chains []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CHAIN\tFILE\tFROM\tTO\tBLOCKS\tBYTES\tUPDATED")
+	for _, chain := range chains {
+		index, err := ReadIndex(dir, chain)
+		if err != nil {
+			return err
+		}
+		for _, seg := range index.Segments {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", chain, seg.File, seg.From, seg.To, seg.Blocks, seg.Bytes, seg.Updated.Format("2006-01-02 15:04:05"))
+		}
+	}
+	return w.Flush()
+}
+
+func cat(dir, chain string, from, to uint64) error {
+	out := bufio.NewWriter(os.Stdout)
+	enc := json.NewEncoder(out)
+	if err := Replay(dir, chain, from, to, func(r sink.Record) error {
+		return enc.Encode(r)
+	}); err != nil {
+		return err
+	}
+	return out.Flush()
+}
@@ -0,0 +1,82 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+
+	"main/services/sink"
+)
+
+// ErrStop trả về từ hàm xử lý để dừng Replay sớm mà không báo lỗi
+var ErrStop = errors.New("archive: dừng đọc")
+
+// Replay đọc lần lượt block của chain có độ cao trong [from, to] và gọi fn cho từng
+// block. Block được trả theo thứ tự segment rồi thứ tự ghi; block được lấy lại sau
+// reorg xuất hiện thêm một lần với hash mới. Data của bản ghi là JSON đã giải mã
+// (map[string]interface{}). Chỉ đọc phần index đã ghi nhận nên chạy được khi sink
+// vẫn đang ghi.
+func Replay(dir, chain string, from, to uint64, fn func(sink.Record) error) error {
+	index, err := ReadIndex(dir, chain)
+	if err != nil {
+		return err
+	}
+	for _, seg := range index.Segments {
+		if seg.To < from || seg.From > to || seg.Bytes == 0 {
+			continue
+		}
+		err := readSegment(filepath.Join(dir, chain, seg.File), seg.Bytes, func(r sink.Record) error {
+			if r.Block < from || r.Block > to {
+				return nil
+			}
+			return fn(r)
+		})
+		if errors.Is(err, ErrStop) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", seg.File, err)
+		}
+	}
+	return nil
+}
+
+func readSegment(path string, size int64, fn func(sink.Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec, err := zstd.NewReader(io.LimitReader(f, size), zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+
+	reader := bufio.NewReaderSize(dec, 1<<20)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && err == nil {
+			var r sink.Record
+			if jsonErr := json.Unmarshal(line, &r); jsonErr != nil {
+				return jsonErr
+			}
+			if fnErr := fn(r); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
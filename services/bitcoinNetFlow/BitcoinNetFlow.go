@@ -1,9 +1,13 @@
 package bitcoinNetFlow
 
-import "main/services/bitcoinNetFlow/processer"
+import (
+	"context"
 
-func BitcoinNetFlow() {
-	go processer.Handle_daily_SMC()
-	go processer.Handle_weekly_SMC()
-	go processer.Handle_monthly_SMC()
+	"main/services/bitcoinNetFlow/processer"
+)
+
+func BitcoinNetFlow(ctx context.Context) {
+	go processer.Handle_daily_SMC(ctx)
+	go processer.Handle_weekly_SMC(ctx)
+	go processer.Handle_monthly_SMC(ctx)
 }
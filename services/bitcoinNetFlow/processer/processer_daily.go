@@ -1,10 +1,8 @@
 package processer
 
 import (
-	"os"
-	"os/signal"
+	"context"
 	"sync"
-	"syscall"
 	"time"
 
 	caculator "main/services/bitcoinNetFlow/caculator_datas"
@@ -18,18 +16,16 @@ var (
 	timeSegmentInterval = 86400          // Khoảng thời gian phân tích 1 ngày (24*3600 giây)
 )
 
-func Handle_daily_SMC() {
-	// Tạo channel để xử lý tín hiệu dừng
+func Handle_daily_SMC(ctx context.Context) {
+	// Tạo channel dừng; tín hiệu hệ điều hành được xử lý tập trung ở main và truyền qua ctx
 	stopChan := make(chan struct{})
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// WaitGroup để đảm bảo tất cả goroutines đều kết thúc an toàn
 	var wg sync.WaitGroup
 
 	// Goroutine xử lý tín hiệu dừng
 	go func() {
-		<-sigChan
+		<-ctx.Done()
 		close(stopChan)
 	}()
 
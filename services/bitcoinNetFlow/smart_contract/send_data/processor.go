@@ -2,11 +2,16 @@ package smartcontract
 
 import (
 	"fmt"
+	"log"
 	"time"
 
 	config "main/config/bitcoinNetFlow"
 	calculator "main/services/bitcoinNetFlow/caculator_datas"
 	StructData "main/services/bitcoinNetFlow/smart_contract"
+	"main/services/health"
+	"main/services/metrics"
+	"main/services/outbox"
+	"main/services/sink"
 )
 
 // ChooseTypeConfig chọn cấu hình contract dựa trên loại thời gian
@@ -24,21 +29,68 @@ func ChooseTypeConfig(timeType string) StructData.ConfigContract {
 }
 
 // ChosseTypeSend chọn loại gửi dữ liệu dựa trên khoảng thời gian
-func ChosseTypeSend(timeType string, config StructData.ConfigContract, methodName string, params map[string]interface{}) {
+func ChosseTypeSend(timeType string, config StructData.ConfigContract, methodName string, params map[string]interface{}) (err error) {
+	name := "btc-netflow-" + timeType
+	defer func() {
+		metrics.ObservePublish(name, err)
+		health.Publisher(name).Done(err)
+	}()
+
 	switch timeType {
 	case "daily":
-		CallContractMethodDaily(config, methodName, params)
+		return CallContractMethodDaily(config, methodName, params)
 	case "weekly":
-		CallContractMethodWeekly(config, methodName, params)
+		return CallContractMethodWeekly(config, methodName, params)
 	case "monthly":
-		CallContractMethodMonthly(config, methodName, params)
+		return CallContractMethodMonthly(config, methodName, params)
 	}
+	return fmt.Errorf("loại thời gian không hợp lệ: %s", timeType)
 }
 
-// SendDataToSMC gửi dữ liệu thời gian thực và lịch sử lên smart contract
-func SendDataToSMC(realTimeData map[time.Time]calculator.RealTimeFlowData, historicalData map[time.Time]calculator.HistoricalFlowData, timeType string) {
-	// Lấy cấu hình contract dựa trên loại thời gian
+// flowParams là một bản ghi netflow chờ gửi; lưu trong hộp thư dạng JSON nên giữ kiểu rõ ràng
+// thay vì map (timestamp đọc lại từ map sẽ thành float64)
+type flowParams struct {
+	Timestamp    uint64 `json:"timestamp"`
+	Incoming     string `json:"incoming"`
+	Outgoing     string `json:"outgoing"`
+	Balance      string `json:"balance"`
+	TokenSymbol  string `json:"tokenSymbol"`
+	ExchangeName string `json:"exchangeName"`
+}
+
+// Hộp thư đi của từng khung thời gian; leader gửi cách nhau 2 giây để tránh quá tải
+var outboxes = map[string]*outbox.Outbox[flowParams]{
+	"daily":   newOutbox("daily"),
+	"weekly":  newOutbox("weekly"),
+	"monthly": newOutbox("monthly"),
+}
+
+func newOutbox(timeType string) *outbox.Outbox[flowParams] {
 	contractConfig := ChooseTypeConfig(timeType)
+	return outbox.New("btc-netflow-"+timeType, 2*time.Second, func(p flowParams) error {
+		return ChosseTypeSend(timeType, contractConfig, "recordFlow", map[string]interface{}{
+			"timestamp":    p.Timestamp,
+			"incoming":     p.Incoming,
+			"outgoing":     p.Outgoing,
+			"balance":      p.Balance,
+			"tokenSymbol":  p.TokenSymbol,
+			"exchangeName": p.ExchangeName,
+		})
+	})
+}
+
+// outboxFor trả về hộp thư của timeType; loại lạ dùng contract daily như ChooseTypeConfig
+func outboxFor(timeType string) *outbox.Outbox[flowParams] {
+	if box, ok := outboxes[timeType]; ok {
+		return box
+	}
+	return outboxes["daily"]
+}
+
+// SendDataToSMC lưu netflow thời gian thực và lịch sử rồi xếp vào hộp thư để leader gửi lên smart contract
+func SendDataToSMC(realTimeData map[time.Time]calculator.RealTimeFlowData, historicalData map[time.Time]calculator.HistoricalFlowData, timeType string) {
+	name := "btc-netflow-" + timeType
+	box := outboxFor(timeType)
 
 	// Xử lý dữ liệu thời gian thực (trước đây là Binance)
 	if len(realTimeData) > 0 {
@@ -49,20 +101,21 @@ func SendDataToSMC(realTimeData map[time.Time]calculator.RealTimeFlowData, histo
 			balance := fmt.Sprintf("%v", data.Balance)
 
 			// Chuẩn bị tham số
-			params := map[string]interface{}{
-				"timestamp":    uint64(timestamp.Unix()),
-				"incoming":     incoming,
-				"outgoing":     outgoing,
-				"balance":      balance,
-				"tokenSymbol":  "BTC",
-				"exchangeName": "Bitcoin",
+			params := flowParams{
+				Timestamp:    uint64(timestamp.Unix()),
+				Incoming:     incoming,
+				Outgoing:     outgoing,
+				Balance:      balance,
+				TokenSymbol:  "BTC",
+				ExchangeName: "Bitcoin",
 			}
 
-			// Gọi phương thức với tham số timeType để xác định loại thời gian
-			ChosseTypeSend(timeType, contractConfig, "recordFlow", params)
+			recordNetFlow(timeType, timestamp, data.Source, data.Incoming, data.Outgoing, data.Balance)
 
-			// Chờ một chút giữa các giao dịch để tránh quá tải
-			time.Sleep(2 * time.Second)
+			// Replica nào cũng xếp vào hộp thư, leader gửi giao dịch
+			if err := box.Put(fmt.Sprintf("realtime/%s/%d", data.Source, timestamp.Unix()), params); err != nil {
+				log.Printf("❌ [%s] Không xếp được netflow %s: %v", name, timestamp.Format(time.RFC3339), err)
+			}
 		}
 	}
 
@@ -74,20 +127,38 @@ func SendDataToSMC(realTimeData map[time.Time]calculator.RealTimeFlowData, histo
 			balance := fmt.Sprintf("%v", data.Balance)
 
 			// Chuẩn bị tham số
-			params := map[string]interface{}{
-				"timestamp":    uint64(timestamp.Unix()),
-				"incoming":     incoming,
-				"outgoing":     outgoing,
-				"balance":      balance,
-				"tokenSymbol":  "BTC",
-				"exchangeName": "Bitcoin",
+			params := flowParams{
+				Timestamp:    uint64(timestamp.Unix()),
+				Incoming:     incoming,
+				Outgoing:     outgoing,
+				Balance:      balance,
+				TokenSymbol:  "BTC",
+				ExchangeName: "Bitcoin",
 			}
 
-			// Gọi phương thức với tham số timeType để xác định loại thời gian
-			ChosseTypeSend(timeType, contractConfig, "recordFlow", params)
+			recordNetFlow(timeType, timestamp, data.Source, data.Incoming, data.Outgoing, data.Balance)
 
-			// Chờ một chút giữa các giao dịch để tránh quá tải
-			time.Sleep(2 * time.Second)
+			// Replica nào cũng xếp vào hộp thư, leader gửi giao dịch
+			if err := box.Put(fmt.Sprintf("historical/%s/%d", data.Source, timestamp.Unix()), params); err != nil {
+				log.Printf("❌ [%s] Không xếp được netflow %s: %v", name, timestamp.Format(time.RFC3339), err)
+			}
 		}
 	}
 }
+
+// recordNetFlow lưu netflow vào sink để API truy vấn được, không chỉ nằm trên smart contract
+func recordNetFlow(timeType string, timestamp time.Time, source string, incoming, outgoing, balance map[string]float64) {
+	sink.Write(sink.Record{
+		Kind:  "btc_netflow",
+		Chain: "bitcoin",
+		Time:  timestamp,
+		Data: map[string]interface{}{
+			"period":    timeType,
+			"source":    source,
+			"timestamp": timestamp.Unix(),
+			"incoming":  incoming,
+			"outgoing":  outgoing,
+			"balance":   balance,
+		},
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"fmt"
+	"log"
 	"math/big"
 	"strings"
 
@@ -35,7 +36,7 @@ func CallContractMethodDaily(config structData.ConfigContract, methodName string
 	publicKey := privateKey.Public().(*ecdsa.PublicKey)
 	address := crypto.PubkeyToAddress(*publicKey)
 
-	fmt.Printf("Địa chỉ ví của bạn: %s\n", address.Hex())
+	log.Printf("Địa chỉ ví của bạn: %s", address.Hex())
 
 	// Phân tích ABI
 	parsedABI, err := abi.JSON(strings.NewReader(config.ContractABI))
@@ -96,24 +97,24 @@ func CallContractMethodDaily(config structData.ConfigContract, methodName string
 		return fmt.Errorf("failed to send transaction: %v", err)
 	}
 
-	fmt.Printf("Transaction đã được gửi: %s\n", signedTx.Hash().Hex())
-	fmt.Printf("Đã gọi hàm %s\n", methodName)
+	log.Printf("Transaction đã được gửi: %s", signedTx.Hash().Hex())
+	log.Printf("Đã gọi hàm %s", methodName)
 
 	// Đợi transaction được xác nhận
-	fmt.Println("Đang đợi transaction được xác nhận...")
+	log.Println("Đang đợi transaction được xác nhận...")
 	receipt, err := bind.WaitMined(context.Background(), client, signedTx)
 	if err != nil {
 		return fmt.Errorf("failed to wait for transaction confirmation: %v", err)
 	}
 
 	for param, value := range params {
-		fmt.Printf("Tham số: %s, Giá trị: %v\n", param, value)
+		log.Printf("Tham số: %s, Giá trị: %v", param, value)
 	}
 
 	if receipt.Status == 1 {
-		fmt.Println("Transaction đã được xác nhận thành công!")
+		log.Println("Transaction đã được xác nhận thành công!")
 	} else {
-		fmt.Println("Transaction thất bại!")
+		log.Println("Transaction thất bại!")
 	}
 
 	return nil
@@ -0,0 +1,362 @@
+package bus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"main/services/health"
+	"main/services/metrics"
+	"main/services/pipeline"
+	"main/services/sink"
+)
+
+// DefaultSubject là cách đặt subject mặc định, đổi bằng BUS_SUBJECT. Các chỗ giữ chỗ:
+// {kind}, {chain}, {address} (ví gửi của transfer) và {token} (contract của transfer).
+const DefaultSubject = "dsea.{kind}.{chain}"
+
+// DefaultBuffer là số message chờ gửi tối đa, đổi bằng BUS_BUFFER
+const DefaultBuffer = 10000
+
+// DefaultStream là stream JetStream nhận bản ghi, đổi bằng BUS_STREAM. Chưa có thì được tạo
+// với subject lấy từ BUS_SUBJECT; đã có thì phải nhận được subject đó.
+const DefaultStream = "DSEA"
+
+// duplicateWindow là cửa sổ chống trùng theo Nats-Msg-Id của stream tạo mới
+const duplicateWindow = 2 * time.Minute
+
+// Header của mỗi message. Nats-Msg-Id giúp JetStream bỏ message trùng, kể cả khi
+// nhiều replica cùng gửi một bản ghi hoặc bản ghi được gửi lại sau khi kết nối lại.
+const (
+	HeaderKind       = "Dsea-Kind"
+	HeaderChain      = "Dsea-Chain"
+	HeaderKey        = "Dsea-Key"
+	HeaderRetraction = "Dsea-Retraction"
+)
+
+const maxAttempts = 5
+
+// Config là cấu hình của sink
+type Config struct {
+	URL       string
+	Subject   string
+	Kinds     map[string]bool // rỗng: gửi mọi loại bản ghi
+	JetStream bool
+	Stream    string
+	Buffer    int
+}
+
+// ConfigFromEnv đọc BUS_URL, BUS_SUBJECT, BUS_KINDS (vd: "transfer,block,ohlcv_1d"),
+// BUS_JETSTREAM, BUS_STREAM và BUS_BUFFER. ok là false khi chưa đặt BUS_URL.
+func ConfigFromEnv() (cfg Config, ok bool) {
+	cfg = Config{
+		URL:       os.Getenv("BUS_URL"),
+		Subject:   os.Getenv("BUS_SUBJECT"),
+		Kinds:     make(map[string]bool),
+		JetStream: os.Getenv("BUS_JETSTREAM") != "false",
+		Stream:    os.Getenv("BUS_STREAM"),
+		Buffer:    DefaultBuffer,
+	}
+	if cfg.Subject == "" {
+		cfg.Subject = DefaultSubject
+	}
+	if cfg.Stream == "" {
+		cfg.Stream = DefaultStream
+	}
+	for _, kind := range strings.Split(os.Getenv("BUS_KINDS"), ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			cfg.Kinds[kind] = true
+		}
+	}
+	if v, err := strconv.Atoi(os.Getenv("BUS_BUFFER")); err == nil && v > 0 {
+		cfg.Buffer = v
+	}
+	return cfg, cfg.URL != ""
+}
+
+// Sink gửi bản ghi lên NATS (JetStream nếu bật) theo thứ tự nhận được. Transfer bị gỡ
+// do reorg được gửi lại dưới dạng message rút lại (header Dsea-Retraction).
+type Sink struct {
+	cfg Config
+	nc  *nats.Conn
+	js  nats.JetStreamContext
+	// streamReady: stream đã được tạo hoặc kiểm tra; broker chưa kết nối lúc New thì
+	// goroutine gửi làm việc này trước message đầu tiên
+	streamReady bool
+
+	queue chan *nats.Msg
+	// inflight đếm message đã nhận nhưng chưa gửi xong (kể cả đang thử lại)
+	inflight atomic.Int64
+	done     chan struct{}
+
+	closeOnce sync.Once
+	mu        sync.RWMutex
+	closed    bool
+}
+
+// New kết nối tới NATS và bắt đầu goroutine gửi
+func New(cfg Config) (*Sink, error) {
+	nc, err := nats.Connect(cfg.URL,
+		nats.Name("dsea"),
+		nats.MaxReconnects(-1),
+		// Broker chưa sẵn sàng lúc khởi động thì kết nối nền; message chờ trong hàng đợi
+		nats.RetryOnFailedConnect(true),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("⚠️ [BUS] Mất kết nối NATS: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			log.Printf("🔌 [BUS] Đã kết nối lại NATS %s", nc.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sink{cfg: cfg, nc: nc, queue: make(chan *nats.Msg, cfg.Buffer), done: make(chan struct{})}
+	if cfg.JetStream {
+		if s.js, err = nc.JetStream(); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		if nc.IsConnected() {
+			if err := s.ensureStream(); err != nil {
+				nc.Close()
+				return nil, err
+			}
+		}
+	}
+	go s.run()
+
+	log.Printf("📡 [BUS] Gửi bản ghi tới %s, subject %s", cfg.URL, cfg.Subject)
+	return s, nil
+}
+
+func (s *Sink) Write(r sink.Record) error {
+	if len(s.cfg.Kinds) > 0 && !s.cfg.Kinds[r.Kind] {
+		return nil
+	}
+	msg, err := s.message(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return fmt.Errorf("bus sink đã đóng")
+	}
+
+	s.inflight.Add(1)
+	select {
+	case s.queue <- msg:
+		return nil
+	default:
+		s.inflight.Add(-1)
+		metrics.BusDropped.WithLabelValues(r.Kind).Inc()
+		return fmt.Errorf("hàng đợi bus đầy (%d), bỏ bản ghi %s", cap(s.queue), r.Kind)
+	}
+}
+
+// message dựng message: subject theo cấu hình, body là JSON của bản ghi
+func (s *Sink) message(r sink.Record) (*nats.Msg, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var address, token string
+	retraction := false
+	if ev, ok := r.Data.(pipeline.Event); ok {
+		address, token, retraction = ev.From, ev.Contract, ev.Removed
+	}
+
+	msg := nats.NewMsg(subject(s.cfg.Subject, r.Kind, r.Chain, address, token))
+	msg.Data = data
+	msg.Header.Set(nats.MsgIdHdr, messageID(r, data))
+	msg.Header.Set(HeaderKind, r.Kind)
+	msg.Header.Set(HeaderChain, r.Chain)
+	msg.Header.Set(HeaderKey, r.Chain+"/"+strings.ToLower(address))
+	if retraction {
+		msg.Header.Set(HeaderRetraction, "true")
+	}
+	return msg, nil
+}
+
+// messageID là khóa chống trùng: transfer và block theo vị trí trên chain, bản ghi tổng
+// hợp theo nội dung (cùng kỳ nhưng số liệu mới vẫn được gửi)
+func messageID(r sink.Record, data []byte) string {
+	if ev, ok := r.Data.(pipeline.Event); ok {
+		id := fmt.Sprintf("%s:%s:%d:%s:%d", r.Kind, ev.Chain, ev.BlockNumber, ev.TxHash, ev.LogIndex)
+		if ev.Removed {
+			id += ":removed"
+		}
+		return id
+	}
+	if r.Kind == "block" {
+		return fmt.Sprintf("block:%s:%d:%s", r.Chain, r.Block, r.Hash)
+	}
+	sum := sha256.Sum256(data)
+	return r.Kind + ":" + r.Chain + ":" + hex.EncodeToString(sum[:16])
+}
+
+// subject điền template; giá trị được đưa về dạng token hợp lệ của NATS
+func subject(template, kind, chain, address, token string) string {
+	return strings.NewReplacer(
+		"{kind}", subjectToken(kind),
+		"{chain}", subjectToken(chain),
+		"{address}", subjectToken(address),
+		"{token}", subjectToken(token),
+	).Replace(template)
+}
+
+func subjectToken(v string) string {
+	if v == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t':
+			return '_'
+		}
+		return r
+	}, strings.ToLower(v))
+}
+
+// run gửi lần lượt từng message, thử lại với backoff trước khi bỏ
+func (s *Sink) run() {
+	defer close(s.done)
+	publisher := health.Publisher("bus")
+
+	for msg := range s.queue {
+		kind := msg.Header.Get(HeaderKind)
+		var err error
+		delay := 200 * time.Millisecond
+		for attempt := 1; attempt <= maxAttempts; attempt++ {
+			if err = s.publish(msg); err == nil {
+				break
+			}
+			if attempt < maxAttempts {
+				time.Sleep(delay)
+				delay *= 2
+			}
+		}
+		publisher.Done(err)
+		if err != nil {
+			metrics.BusErrors.WithLabelValues(kind).Inc()
+			log.Printf("❌ [BUS] Không gửi được %s (%s): %v", msg.Subject, msg.Header.Get(nats.MsgIdHdr), err)
+		} else {
+			metrics.BusPublished.WithLabelValues(kind).Inc()
+		}
+		s.inflight.Add(-1)
+	}
+}
+
+// ensureStream tạo stream nếu chưa có, hoặc kiểm tra stream có sẵn nhận được subject đã
+// cấu hình; nếu không, JetStream trả lỗi "no responders" cho mọi message
+func (s *Sink) ensureStream() error {
+	filter := subjectFilter(s.cfg.Subject)
+	info, err := s.js.StreamInfo(s.cfg.Stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = s.js.AddStream(&nats.StreamConfig{
+			Name:       s.cfg.Stream,
+			Subjects:   []string{filter},
+			Duplicates: duplicateWindow,
+		})
+		if err != nil {
+			return fmt.Errorf("không tạo được stream %s: %w", s.cfg.Stream, err)
+		}
+		log.Printf("📡 [BUS] Đã tạo stream %s cho subject %s", s.cfg.Stream, filter)
+		s.streamReady = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("không đọc được stream %s: %w", s.cfg.Stream, err)
+	}
+	for _, subject := range info.Config.Subjects {
+		if subjectCovers(subject, filter) {
+			s.streamReady = true
+			return nil
+		}
+	}
+	return fmt.Errorf("stream %s (subject %v) không nhận subject %s", s.cfg.Stream, info.Config.Subjects, filter)
+}
+
+// subjectFilter đổi template thành subject wildcard: token có chỗ giữ chỗ thành "*"
+func subjectFilter(template string) string {
+	tokens := strings.Split(template, ".")
+	for i, token := range tokens {
+		if strings.Contains(token, "{") {
+			tokens[i] = "*"
+		}
+	}
+	return strings.Join(tokens, ".")
+}
+
+// subjectCovers cho biết mọi subject khớp filter cũng khớp pattern của stream
+func subjectCovers(pattern, filter string) bool {
+	p, f := strings.Split(pattern, "."), strings.Split(filter, ".")
+	for i, token := range p {
+		if token == ">" {
+			return i < len(f)
+		}
+		if i >= len(f) || (token != "*" && token != f[i]) {
+			return false
+		}
+	}
+	return len(p) == len(f)
+}
+
+func (s *Sink) publish(msg *nats.Msg) error {
+	if s.js != nil {
+		if !s.streamReady {
+			if err := s.ensureStream(); err != nil {
+				return err
+			}
+		}
+		_, err := s.js.PublishMsg(msg, nats.AckWait(5*time.Second))
+		return err
+	}
+	return s.nc.PublishMsg(msg)
+}
+
+// Flush chờ các message đang chờ được gửi xong (tối đa 30 giây)
+func (s *Sink) Flush() error {
+	deadline := time.Now().Add(30 * time.Second)
+	for s.inflight.Load() > 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("hết thời gian chờ gửi %d message lên bus", s.inflight.Load())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return s.nc.FlushTimeout(5 * time.Second)
+}
+
+// Close gửi nốt hàng đợi rồi đóng kết nối
+func (s *Sink) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		err = s.Flush()
+		s.mu.Lock()
+		s.closed = true
+		close(s.queue)
+		s.mu.Unlock()
+		<-s.done
+		if drainErr := s.nc.Drain(); err == nil {
+			err = drainErr
+		}
+	})
+	return err
+}
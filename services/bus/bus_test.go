@@ -0,0 +1,150 @@
+package bus
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+
+	"main/services/pipeline"
+	"main/services/sink"
+)
+
+// runJetStream chạy một NATS server có JetStream trên cổng ngẫu nhiên
+func runJetStream(t *testing.T) *server.Server {
+	t.Helper()
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	s := natstest.RunServer(&opts)
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func testConfig(url string) Config {
+	return Config{
+		URL:       url,
+		Subject:   DefaultSubject,
+		Kinds:     map[string]bool{},
+		JetStream: true,
+		Stream:    DefaultStream,
+		Buffer:    100,
+	}
+}
+
+func transfer(removed bool) sink.Record {
+	ev := pipeline.Event{
+		Chain:       "ethereum",
+		BlockNumber: 100,
+		TxHash:      "0xabc",
+		LogIndex:    1,
+		Contract:    "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+		From:        "0x1111111111111111111111111111111111111111",
+		Amount:      big.NewInt(42),
+		Removed:     removed,
+	}
+	return sink.Record{Kind: sink.KindTransfer, Chain: ev.Chain, Block: ev.BlockNumber, TxHash: ev.TxHash, Data: ev}
+}
+
+func TestNewCreatesStream(t *testing.T) {
+	srv := runJetStream(t)
+	s, err := New(testConfig(srv.ClientURL()))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+
+	info, err := s.js.StreamInfo(DefaultStream)
+	if err != nil {
+		t.Fatalf("stream chưa được tạo: %v", err)
+	}
+	if got := info.Config.Subjects; len(got) != 1 || got[0] != "dsea.*.*" {
+		t.Fatalf("subject của stream = %v, muốn [dsea.*.*]", got)
+	}
+}
+
+func TestNewRejectsStreamWithoutSubject(t *testing.T) {
+	srv := runJetStream(t)
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.AddStream(&nats.StreamConfig{Name: DefaultStream, Subjects: []string{"other.>"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := New(testConfig(srv.ClientURL())); err == nil {
+		s.Close()
+		t.Fatal("New phải báo lỗi khi stream không nhận subject đã cấu hình")
+	}
+}
+
+func TestRetractionIsPublished(t *testing.T) {
+	srv := runJetStream(t)
+	s, err := New(testConfig(srv.ClientURL()))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+
+	sub, err := s.js.SubscribeSync("dsea.transfer.ethereum", nats.DeliverAll())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bản ghi gửi lại bị JetStream bỏ theo Nats-Msg-Id, bản rút lại thì không
+	for _, r := range []sink.Record{transfer(false), transfer(false), transfer(true)} {
+		if err := s.Write(r); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	first, err := sub.NextMsg(2 * time.Second)
+	if err != nil {
+		t.Fatalf("không nhận được transfer: %v", err)
+	}
+	if first.Header.Get(HeaderRetraction) != "" {
+		t.Fatal("transfer gốc không được có header rút lại")
+	}
+	second, err := sub.NextMsg(2 * time.Second)
+	if err != nil {
+		t.Fatalf("không nhận được bản rút lại: %v", err)
+	}
+	if second.Header.Get(HeaderRetraction) != "true" {
+		t.Fatalf("header %s = %q, muốn \"true\"", HeaderRetraction, second.Header.Get(HeaderRetraction))
+	}
+	if _, err := sub.NextMsg(200 * time.Millisecond); err == nil {
+		t.Fatal("transfer gửi trùng phải bị bỏ")
+	}
+}
+
+func TestSubjectCovers(t *testing.T) {
+	cases := []struct {
+		pattern, filter string
+		want            bool
+	}{
+		{"dsea.*.*", "dsea.*.*", true},
+		{"dsea.>", "dsea.*.*", true},
+		{">", "dsea.*.*", true},
+		{"dsea.transfer.*", "dsea.*.*", false},
+		{"dsea.*", "dsea.*.*", false},
+		{"dsea.*.*.*", "dsea.*.*", false},
+	}
+	for _, c := range cases {
+		if got := subjectCovers(c.pattern, c.filter); got != c.want {
+			t.Errorf("subjectCovers(%q, %q) = %v, muốn %v", c.pattern, c.filter, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+// Package candles lưu nến OHLCV theo (sàn, symbol, khung thời gian, openTime) trên đĩa để
+// biết nến nào đã có, phát hiện khoảng trống và nạp bù từ REST của sàn.
+package candles
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"main/services/filelock"
+)
+
+// DefaultDir chứa <sàn>/<SYMBOL>/<khung>.jsonl, đổi bằng CANDLE_DIR
+const DefaultDir = "./data/candles"
+
+// compactSlack: số dòng ghi đè (cùng openTime) cho phép trước khi file được viết lại
+const compactSlack = 1000
+
+// Key định danh một chuỗi nến
+type Key struct {
+	Exchange string
+	Symbol   string // chữ hoa, vd: BTCUSDT
+	Interval string // theo Binance: 1m, 4h, 1d, 1w, 1M, ...
+}
+
+func (k Key) String() string {
+	return k.Exchange + "/" + k.Symbol + "/" + k.Interval
+}
+
+// Candle là một nến như sàn trả về; giá và khối lượng giữ dạng chuỗi để không mất chữ số
+type Candle struct {
+	OpenTime      int64  `json:"open_time"`
+	CloseTime     int64  `json:"close_time"`
+	Open          string `json:"open"`
+	High          string `json:"high"`
+	Low           string `json:"low"`
+	Close         string `json:"close"`
+	Volume        string `json:"volume"`
+	QuoteVolume   string `json:"quote_volume"`
+	Trades        int64  `json:"trades"`
+	TakerBuyBase  string `json:"taker_buy_base"`
+	TakerBuyQuote string `json:"taker_buy_quote"`
+	// Closed là true khi nến đã đóng; nến đang chạy được ghi đè khi có bản mới hơn
+	Closed bool `json:"closed,omitempty"`
+}
+
+// Range là khoảng openTime [From, To] (mili giây, cả hai đầu) còn thiếu nến
+type Range struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// Store giữ chỉ mục openTime của từng chuỗi trong bộ nhớ; nến nằm trong file JSON Lines
+// ghi nối, dòng sau ghi đè dòng trước cùng openTime.
+type Store struct {
+	dir string
+
+	mu     sync.Mutex
+	series map[Key]*series
+}
+
+type series struct {
+	times      []int64 // openTime đã có, tăng dần
+	lines      int     // số dòng trong file
+	lastClosed int64   // openTime của nến đã đóng mới nhất, 0 nếu chưa có
+}
+
+var (
+	defaultStore *Store
+	defaultOnce  sync.Once
+)
+
+// Dir trả về thư mục đã cấu hình
+func Dir() string {
+	if dir := os.Getenv("CANDLE_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultDir
+}
+
+// Default trả về store dùng chung của tiến trình
+func Default() *Store {
+	defaultOnce.Do(func() {
+		defaultStore = New(Dir())
+	})
+	return defaultStore
+}
+
+// New tạo store trong thư mục dir
+func New(dir string) *Store {
+	return &Store{dir: dir, series: make(map[Key]*series)}
+}
+
+// path của chuỗi; 1M đổi thành 1mo để không trùng 1m trên hệ file không phân biệt hoa thường
+func (s *Store) path(key Key) string {
+	name := key.Interval
+	if name == "1M" {
+		name = "1mo"
+	}
+	return filepath.Join(s.dir, key.Exchange, strings.ToUpper(key.Symbol), name+".jsonl")
+}
+
+// load nạp chỉ mục của chuỗi lần đầu dùng; gọi khi đang giữ s.mu
+func (s *Store) load(key Key) (*series, error) {
+	if ser, ok := s.series[key]; ok {
+		return ser, nil
+	}
+	ser := &series{}
+	err := readCandles(s.path(key), func(c Candle) {
+		ser.lines++
+		ser.insert(c.OpenTime)
+		ser.close(c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.series[key] = ser
+	return ser, nil
+}
+
+func (ser *series) insert(t int64) bool {
+	i := sort.Search(len(ser.times), func(i int) bool { return ser.times[i] >= t })
+	if i < len(ser.times) && ser.times[i] == t {
+		return false
+	}
+	ser.times = append(ser.times, 0)
+	copy(ser.times[i+1:], ser.times[i:])
+	ser.times[i] = t
+	return true
+}
+
+func (ser *series) close(c Candle) {
+	if c.Closed && c.OpenTime > ser.lastClosed {
+		ser.lastClosed = c.OpenTime
+	}
+}
+
+// Put ghi các nến vào chuỗi, trả về những nến trước đó chưa có
+func (s *Store) Put(key Key, batch []Candle) ([]Candle, error) {
+	if len(batch) == 0 {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ser, err := s.load(key)
+	if err != nil {
+		return nil, err
+	}
+
+	path := s.path(key)
+	_, unlock, err := filelock.Lock(path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	var added []Candle
+	for _, c := range batch {
+		line, err := json.Marshal(c)
+		if err != nil {
+			f.Close()
+			return added, err
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+		ser.lines++
+		ser.close(c)
+		if ser.insert(c.OpenTime) {
+			added = append(added, c)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return added, err
+	}
+	if err := f.Close(); err != nil {
+		return added, err
+	}
+
+	if ser.lines > len(ser.times)+compactSlack {
+		if err := compact(path); err != nil {
+			return added, err
+		}
+		ser.lines = len(ser.times)
+	}
+	return added, nil
+}
+
+// Bounds trả về openTime đầu và cuối đã có; ok là false khi chuỗi rỗng
+func (s *Store) Bounds(key Key) (first, last int64, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ser, err := s.load(key)
+	if err != nil || len(ser.times) == 0 {
+		return 0, 0, false, err
+	}
+	return ser.times[0], ser.times[len(ser.times)-1], true, nil
+}
+
+// LastClosed trả về openTime của nến đã đóng mới nhất; ok là false khi chưa có nến nào đóng
+func (s *Store) LastClosed(key Key) (openTime int64, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ser, err := s.load(key)
+	if err != nil || ser.lastClosed == 0 {
+		return 0, false, err
+	}
+	return ser.lastClosed, true, nil
+}
+
+// Gaps trả về các khoảng openTime còn thiếu trong [from, to) theo bước của khung thời gian.
+// Chuỗi rỗng thì cả khoảng là một khoảng trống.
+func (s *Store) Gaps(key Key, from, to int64) ([]Range, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ser, err := s.load(key)
+	if err != nil {
+		return nil, err
+	}
+	if from >= to {
+		return nil, nil
+	}
+	if len(ser.times) == 0 {
+		return []Range{{From: from, To: to - 1}}, nil
+	}
+
+	var gaps []Range
+	first := ser.times[0]
+	if from < first {
+		gaps = append(gaps, Range{From: from, To: first - 1})
+	}
+	// Đi theo bước từ nến đầu tiên đã có để các openTime kỳ vọng thẳng hàng với sàn
+	i, open := 0, false
+	for t := first; t < to; t = Next(key.Interval, t) {
+		for i < len(ser.times) && ser.times[i] < t {
+			i++
+		}
+		if i < len(ser.times) && ser.times[i] == t {
+			open = false
+			continue
+		}
+		if t < from {
+			continue
+		}
+		if open {
+			gaps[len(gaps)-1].To = t
+		} else {
+			gaps = append(gaps, Range{From: t, To: t})
+			open = true
+		}
+	}
+	return gaps, nil
+}
+
+// Range đọc các nến có openTime trong [from, to], tăng dần
+func (s *Store) Range(key Key, from, to int64) ([]Candle, error) {
+	s.mu.Lock()
+	path := s.path(key)
+	s.mu.Unlock()
+
+	latest := make(map[int64]Candle)
+	err := readCandles(path, func(c Candle) {
+		if c.OpenTime >= from && c.OpenTime <= to {
+			latest[c.OpenTime] = c
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Candle, 0, len(latest))
+	for _, c := range latest {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].OpenTime < out[j].OpenTime })
+	return out, nil
+}
+
+// Step trả về độ dài cố định của khung thời gian; 0 với khung tháng (1M) vì độ dài thay đổi
+func Step(interval string) time.Duration {
+	if interval == "1M" || len(interval) < 2 {
+		return 0
+	}
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || n <= 0 {
+		return 0
+	}
+	unit := map[byte]time.Duration{
+		's': time.Second, 'm': time.Minute, 'h': time.Hour,
+		'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour,
+	}[interval[len(interval)-1]]
+	return time.Duration(n) * unit
+}
+
+// Next trả về openTime của nến kế tiếp
+func Next(interval string, openTime int64) int64 {
+	if step := Step(interval); step > 0 {
+		return openTime + step.Milliseconds()
+	}
+	if interval == "1M" {
+		return time.UnixMilli(openTime).UTC().AddDate(0, 1, 0).UnixMilli()
+	}
+	// Khung thời gian lạ: bước 1 phút để vòng lặp vẫn kết thúc
+	return openTime + time.Minute.Milliseconds()
+}
+
+func readCandles(path string, fn func(Candle)) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var c Candle
+		// Dòng ghi dở khi tiến trình bị dừng giữa chừng bị bỏ qua
+		if json.Unmarshal(scanner.Bytes(), &c) != nil {
+			continue
+		}
+		fn(c)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("đọc %s: %w", path, err)
+	}
+	return nil
+}
+
+// compact viết lại file chỉ còn bản cuối của mỗi openTime; gọi khi đang giữ khóa file
+func compact(path string) error {
+	latest := make(map[int64]Candle)
+	if err := readCandles(path, func(c Candle) { latest[c.OpenTime] = c }); err != nil {
+		return err
+	}
+	times := make([]int64, 0, len(latest))
+	for t := range latest {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, t := range times {
+		if err := enc.Encode(latest[t]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,184 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"main/services/filelock"
+)
+
+// DefaultPath là file checkpoint mặc định, có thể đổi bằng biến môi trường CHECKPOINT_FILE
+const DefaultPath = "./data/checkpoints.json"
+
+// Entry là khối cuối cùng đã xử lý xong của một chain
+type Entry struct {
+	Height    uint64    `json:"height"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store lưu checkpoint của các chain trong một file JSON, ghi nguyên tử (tmp + rename).
+// Nhiều worker có thể dùng chung file: mỗi lần Flush chỉ ghi đè các chain mà tiến trình
+// này đã đổi, checkpoint của chain do worker khác xử lý được giữ nguyên.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]Entry
+	changed map[string]bool
+}
+
+var (
+	defaultStore *Store
+	defaultOnce  sync.Once
+)
+
+// Default trả về store dùng chung của tiến trình
+func Default() *Store {
+	defaultOnce.Do(func() {
+		path := os.Getenv("CHECKPOINT_FILE")
+		if path == "" {
+			path = DefaultPath
+		}
+		store, err := Open(path)
+		if err != nil {
+			// File hỏng không được làm dừng tiến trình: bắt đầu với store rỗng
+			fmt.Fprintf(os.Stderr, "checkpoint: không thể đọc %s: %v\n", path, err)
+			store = &Store{path: path, entries: make(map[string]Entry), changed: make(map[string]bool)}
+		}
+		defaultStore = store
+	})
+	return defaultStore
+}
+
+// Open đọc checkpoint từ file; file chưa tồn tại thì trả về store rỗng
+func Open(path string) (*Store, error) {
+	entries, err := readEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: path, entries: entries, changed: make(map[string]bool)}, nil
+}
+
+func readEntries(path string) (map[string]Entry, error) {
+	entries := make(map[string]Entry)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return entries, nil
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("file checkpoint không hợp lệ: %w", err)
+	}
+	return entries, nil
+}
+
+// Refresh đọc lại checkpoint của chain từ file, dùng khi nhận chain từ worker khác.
+// Thay đổi chưa Flush của tiến trình này được ưu tiên.
+func (s *Store) Refresh(chain string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.changed[chain] {
+		return nil
+	}
+	entries, err := readEntries(s.path)
+	if err != nil {
+		return err
+	}
+	if entry, ok := entries[chain]; ok {
+		s.entries[chain] = entry
+	} else {
+		delete(s.entries, chain)
+	}
+	return nil
+}
+
+// Get trả về khối cuối cùng đã xử lý của chain
+func (s *Store) Get(chain string) (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[chain]
+	return entry.Height, ok
+}
+
+// Set cập nhật checkpoint trong bộ nhớ; dữ liệu được ghi ra file khi Flush
+func (s *Store) Set(chain string, height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[chain]; ok && entry.Height == height {
+		return
+	}
+	s.entries[chain] = Entry{Height: height, UpdatedAt: time.Now()}
+	s.changed[chain] = true
+}
+
+// All trả về bản sao mọi checkpoint
+func (s *Store) All() map[string]Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string]Entry, len(s.entries))
+	for chain, entry := range s.entries {
+		result[chain] = entry
+	}
+	return result
+}
+
+// Flush ghi checkpoint ra file nếu có thay đổi
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.changed) == 0 {
+		return nil
+	}
+
+	// Khóa riêng vì file checkpoint bị thay bằng rename
+	_, unlock, err := filelock.Lock(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	merged, err := readEntries(s.path)
+	if err != nil {
+		// File hỏng: ghi lại từ bộ nhớ thay vì giữ file hỏng mãi
+		merged = make(map[string]Entry)
+	}
+	for chain, entry := range s.entries {
+		if _, ok := merged[chain]; !ok || s.changed[chain] {
+			merged[chain] = entry
+		}
+	}
+
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	s.changed = make(map[string]bool)
+	return nil
+}
@@ -0,0 +1,227 @@
+package configwatch
+
+import (
+	"context"
+	"crypto/sha256"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"main/services/health"
+	"main/services/metrics"
+)
+
+// KindConfig là loại thành phần trong báo cáo /healthz, /readyz
+const KindConfig = "config"
+
+// debounce gom các event của một lần ghi file (editor thường ghi, chmod, đổi tên liên tiếp)
+const debounce = 500 * time.Millisecond
+
+// Handler kiểm tra rồi áp dụng nội dung mới của file. Trả về lỗi nghĩa là nội dung
+// bị từ chối: handler không được thay đổi gì và cấu hình cũ tiếp tục chạy.
+type Handler func(path string, data []byte) error
+
+type entry struct {
+	path    string
+	handler Handler
+	hash    [sha256.Size]byte
+
+	reloadedAt time.Time
+	reloads    uint64
+	lastError  string
+}
+
+var (
+	mu      sync.Mutex
+	entries = make(map[string]*entry)
+	// watcher khác nil khi Run đang chạy; file đăng ký muộn được thêm thư mục ngay
+	watcher *fsnotify.Watcher
+)
+
+// Register theo dõi file tại path. Nội dung hiện tại coi như đã áp dụng nên handler chỉ
+// được gọi khi file đổi sau đó. Đăng ký lại cùng path thì thay handler.
+func Register(path string, handler Handler) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	data, _ := os.ReadFile(abs)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	e, ok := entries[abs]
+	if !ok {
+		e = &entry{path: path, hash: sha256.Sum256(data)}
+		entries[abs] = e
+	}
+	e.handler = handler
+
+	if watcher != nil {
+		if err := watcher.Add(filepath.Dir(abs)); err != nil {
+			log.Printf("⚠️ [CONFIG] Không theo dõi được %s: %v", filepath.Dir(abs), err)
+		}
+	}
+}
+
+// MarkApplied ghi nhận data là nội dung đã được áp dụng cho path, dùng khi chính tiến
+// trình ghi file (vd: admin API) để thay đổi không bị áp dụng lần hai
+func MarkApplied(path string, data []byte) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if e, ok := entries[abs]; ok {
+		e.hash = sha256.Sum256(data)
+	}
+}
+
+// Run theo dõi thư mục chứa các file đã đăng ký cho tới khi ctx bị hủy.
+// Theo dõi thư mục thay vì file để bắt được cả kiểu ghi file tạm rồi đổi tên.
+func Run(ctx context.Context) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	mu.Lock()
+	dirs := make(map[string]bool)
+	for abs := range entries {
+		dirs[filepath.Dir(abs)] = true
+	}
+	for dir := range dirs {
+		if err := w.Add(dir); err != nil {
+			log.Printf("⚠️ [CONFIG] Không theo dõi được %s: %v", dir, err)
+		}
+	}
+	watcher = w
+	log.Printf("👀 [CONFIG] Theo dõi %d file cấu hình", len(entries))
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		watcher = nil
+		mu.Unlock()
+	}()
+
+	due := make(chan string, 16)
+	timers := make(map[string]*time.Timer)
+	defer func() {
+		for _, t := range timers {
+			t.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("⚠️ [CONFIG] Lỗi theo dõi file: %v", err)
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			abs, err := filepath.Abs(ev.Name)
+			if err != nil || !registered(abs) {
+				continue
+			}
+			if t, ok := timers[abs]; ok {
+				t.Reset(debounce)
+				continue
+			}
+			timers[abs] = time.AfterFunc(debounce, func() {
+				select {
+				case due <- abs:
+				case <-ctx.Done():
+				}
+			})
+		case abs := <-due:
+			delete(timers, abs)
+			reload(abs)
+		}
+	}
+}
+
+func registered(abs string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	_, ok := entries[abs]
+	return ok
+}
+
+// reload đọc file, bỏ qua nếu nội dung không đổi, rồi giao cho handler
+func reload(abs string) {
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		// File đang được thay (đổi tên); event Create sau đó sẽ gọi lại
+		return
+	}
+	hash := sha256.Sum256(data)
+
+	mu.Lock()
+	e, ok := entries[abs]
+	if !ok || e.hash == hash {
+		mu.Unlock()
+		return
+	}
+	path, handler := e.path, e.handler
+	mu.Unlock()
+
+	err = handler(path, data)
+
+	mu.Lock()
+	e.reloadedAt = time.Now()
+	if err != nil {
+		e.lastError = err.Error()
+	} else {
+		e.hash = hash
+		e.reloads++
+		e.lastError = ""
+	}
+	mu.Unlock()
+
+	file := filepath.Base(abs)
+	if err != nil {
+		metrics.ConfigReloads.WithLabelValues(file, "rejected").Inc()
+		log.Printf("❌ [CONFIG] Từ chối cấu hình mới của %s, giữ cấu hình cũ: %v", path, err)
+		return
+	}
+	metrics.ConfigReloads.WithLabelValues(file, "applied").Inc()
+	log.Printf("🔄 [CONFIG] Đã áp dụng cấu hình mới của %s", path)
+}
+
+// Source báo trạng thái các file cấu hình cho /healthz, /readyz. File bị từ chối chỉ
+// có lỗi đi kèm: cấu hình cũ vẫn chạy nên tiến trình vẫn khỏe.
+func Source() []health.Component {
+	mu.Lock()
+	defer mu.Unlock()
+
+	components := make([]health.Component, 0, len(entries))
+	for _, e := range entries {
+		components = append(components, health.Component{
+			Name:      KindConfig + ":" + e.path,
+			Kind:      KindConfig,
+			State:     health.StateConnected,
+			LastEvent: e.reloadedAt,
+			Processed: e.reloads,
+			Error:     e.lastError,
+		})
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i].Name < components[j].Name })
+	return components
+}
@@ -0,0 +1,179 @@
+package dedup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"main/services/filelock"
+)
+
+// DefaultDir chứa key đã xử lý của từng chain (<chain>.keys), đổi bằng DEDUP_DIR.
+// Các worker dùng chung thư mục để chain chuyển sang worker khác không bị phát lại log.
+const DefaultDir = "./data/dedup"
+
+// compactEvery: số key ghi thêm trước khi file được viết lại chỉ còn cửa sổ giữ lại
+const compactEvery = 50000
+
+// Store lưu key dạng "<block>-<tx>-<index>" theo chain. Key được gom trong bộ nhớ và
+// ghi nối vào file khi Flush, nên cần Flush trước khi lưu checkpoint.
+type Store struct {
+	dir string
+
+	mu      sync.Mutex
+	pending map[string][]string
+	written map[string]int
+}
+
+var (
+	defaultStore *Store
+	defaultOnce  sync.Once
+)
+
+// Dir trả về thư mục đã cấu hình
+func Dir() string {
+	if dir := os.Getenv("DEDUP_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultDir
+}
+
+// Default trả về store dùng chung của tiến trình
+func Default() *Store {
+	defaultOnce.Do(func() {
+		defaultStore = New(Dir())
+	})
+	return defaultStore
+}
+
+// New tạo store trong thư mục dir
+func New(dir string) *Store {
+	return &Store{dir: dir, pending: make(map[string][]string), written: make(map[string]int)}
+}
+
+func (s *Store) path(chain string) string {
+	return filepath.Join(s.dir, chain+".keys")
+}
+
+// Load đọc các key của chain có block >= fromBlock
+func (s *Store) Load(chain string, fromBlock uint64) (map[string]bool, error) {
+	_, unlock, err := filelock.Lock(s.path(chain) + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	keys, err := readKeys(s.path(chain), fromBlock)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		result[key] = true
+	}
+	return result, nil
+}
+
+// Add ghi nhận key đã xử lý; key được ghi ra file ở lần Flush kế tiếp
+func (s *Store) Add(chain, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[chain] = append(s.pending[chain], key)
+}
+
+// Flush ghi nối các key đang chờ của chain. Thỉnh thoảng file được viết lại chỉ giữ
+// key có block >= keepFrom để không lớn mãi.
+func (s *Store) Flush(chain string, keepFrom uint64) error {
+	s.mu.Lock()
+	keys := s.pending[chain]
+	delete(s.pending, chain)
+	s.written[chain] += len(keys)
+	compact := s.written[chain] >= compactEvery
+	if compact {
+		s.written[chain] = 0
+	}
+	s.mu.Unlock()
+
+	if len(keys) == 0 && !compact {
+		return nil
+	}
+
+	path := s.path(chain)
+	_, unlock, err := filelock.Lock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if compact {
+		kept, err := readKeys(path, keepFrom)
+		if err != nil {
+			return err
+		}
+		return writeKeys(path, append(kept, keys...))
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, key := range keys {
+		w.WriteString(key)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readKeys(path string, fromBlock uint64) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key := scanner.Text()
+		if block, ok := keyBlock(key); ok && block >= fromBlock {
+			keys = append(keys, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("đọc %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+func writeKeys(path string, keys []string) error {
+	tmp := path + ".tmp"
+	data := strings.Join(keys, "\n")
+	if len(keys) > 0 {
+		data += "\n"
+	}
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func keyBlock(key string) (uint64, bool) {
+	prefix, _, ok := strings.Cut(key, "-")
+	if !ok {
+		return 0, false
+	}
+	block, err := strconv.ParseUint(prefix, 10, 64)
+	return block, err == nil
+}
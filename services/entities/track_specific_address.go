@@ -1,23 +1,46 @@
 package entities
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"math"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// TrackMultipleAddresses theo dõi nhiều địa chỉ ví cùng lúc
-func TrackMultipleAddresses(apiKey string) {
-	log.Printf("[BẮT ĐẦU] Theo dõi %d địa chỉ cụ thể", len(TargetAddresses))
+// Enabled cho biết bộ theo dõi ví có được bật (BSCSCAN_API_KEY được đặt)
+func Enabled() bool {
+	return os.Getenv("BSCSCAN_API_KEY") != ""
+}
+
+// Run là service theo dõi các ví trong danh sách bằng BSCSCAN_API_KEY; ví thêm hay gỡ qua
+// admin khi đang chạy được theo dõi hoặc dừng ngay
+func Run(ctx context.Context) error {
+	return TrackMultipleAddresses(ctx, os.Getenv("BSCSCAN_API_KEY"))
+}
+
+// TrackMultipleAddresses theo dõi nhiều địa chỉ ví cùng lúc cho tới khi ctx bị hủy
+func TrackMultipleAddresses(ctx context.Context, apiKey string) error {
+	addresses := GetAllMonitoredAddresses()
+	log.Printf("[BẮT ĐẦU] Theo dõi %d địa chỉ cụ thể", len(addresses))
+
+	targetAddressesMutex.Lock()
+	trackingAPIKey, trackingCtx = apiKey, ctx
+	targetAddressesMutex.Unlock()
+	defer func() {
+		targetAddressesMutex.Lock()
+		trackingAPIKey, trackingCtx = "", nil
+		targetAddressesMutex.Unlock()
+	}()
 
 	// Khởi tạo dữ liệu ban đầu cho mỗi địa chỉ
-	for _, address := range TargetAddresses {
+	for _, address := range addresses {
 		monitorMutex.Lock()
 		monitorDataMap[address] = &AddressMonitorData{
 			Address:     address,
@@ -26,26 +49,45 @@ func TrackMultipleAddresses(apiKey string) {
 		monitorMutex.Unlock()
 
 		// Tạo goroutine riêng cho mỗi địa chỉ để theo dõi
-		go trackSingleAddress(apiKey, address)
+		go trackSingleAddress(ctx, apiKey, address)
 	}
 
-	// Chờ vô thời hạn
-	select {}
+	<-ctx.Done()
+	log.Printf("[DỪNG] Ngừng theo dõi %d địa chỉ", len(GetAllMonitoredAddresses()))
+	return nil
+}
+
+// sleepCtx ngủ d hoặc tới khi ctx bị hủy; trả về false nếu ctx đã bị hủy
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 // trackSingleAddress theo dõi một địa chỉ ví cụ thể
-func trackSingleAddress(apiKey string, address string) {
+func trackSingleAddress(ctx context.Context, apiKey string, address string) {
 	log.Printf("[THEO DÕI] Bắt đầu theo dõi địa chỉ: %s", address)
 
 	// Cập nhật dữ liệu ban đầu
 	updateAddressData(apiKey, address)
 
 	// Bắt đầu một goroutine để theo dõi giao dịch mới
-	go monitorAddressTransactions(apiKey, address)
+	go monitorAddressTransactions(ctx, apiKey, address)
 
-	// Cập nhật dữ liệu theo định kỳ
+	// Cập nhật dữ liệu theo định kỳ, dừng khi địa chỉ bị gỡ khỏi danh sách hoặc service dừng
 	for {
-		time.Sleep(MonitoringInterval)
+		if !sleepCtx(ctx, MonitoringInterval) {
+			return
+		}
+		if !isTargetAddress(address) {
+			log.Printf("[DỪNG] Ngừng theo dõi địa chỉ: %s", address)
+			return
+		}
 		updateAddressData(apiKey, address)
 	}
 }
@@ -187,12 +229,18 @@ func updateAddressData(apiKey string, address string) {
 }
 
 // monitorAddressTransactions theo dõi các giao dịch mới của địa chỉ
-func monitorAddressTransactions(apiKey string, address string) {
+func monitorAddressTransactions(ctx context.Context, apiKey string, address string) {
 	api := NewBscScanAPI(apiKey)
 	lastCheckTime := time.Now().Unix()
 
 	for {
-		time.Sleep(30 * time.Second) // Tăng thời gian giữa các lần kiểm tra giao dịch để tránh rate limit
+		// Tăng thời gian giữa các lần kiểm tra giao dịch để tránh rate limit
+		if !sleepCtx(ctx, 30*time.Second) {
+			return
+		}
+		if !isTargetAddress(address) {
+			return
+		}
 
 		// Lấy các giao dịch liên quan đến địa chỉ
 		transactions, err := api.GetAddressTransactions(address, lastCheckTime)
@@ -207,7 +255,9 @@ func monitorAddressTransactions(apiKey string, address string) {
 			lastCheckTime = time.Now().Unix()
 
 			// Thêm độ trễ để đảm bảo số dư được cập nhật trên blockchain
-			time.Sleep(5 * time.Second)
+			if !sleepCtx(ctx, 5*time.Second) {
+				return
+			}
 
 			// Xử lý các giao dịch mới
 			for _, tx := range transactions {
@@ -238,7 +288,13 @@ func monitorAddressTransactions(apiKey string, address string) {
 				timestamp := time.Unix(tx.TimeStamp, 0)
 
 				monitorMutex.Lock()
-				monitorDataMap[address].Transactions = append(monitorDataMap[address].Transactions, TransactionInfo{
+				data, exists := monitorDataMap[address]
+				if !exists {
+					// Ví vừa bị gỡ khỏi danh sách
+					monitorMutex.Unlock()
+					return
+				}
+				data.Transactions = append(data.Transactions, TransactionInfo{
 					Hash:      tx.Hash,
 					Timestamp: timestamp,
 					From:      tx.From,
@@ -248,9 +304,9 @@ func monitorAddressTransactions(apiKey string, address string) {
 				})
 
 				// Giới hạn số lượng giao dịch lưu trữ (giữ 50 giao dịch gần nhất)
-				if len(monitorDataMap[address].Transactions) > 50 {
+				if len(data.Transactions) > 50 {
 					// Cắt bỏ giao dịch cũ nhất
-					monitorDataMap[address].Transactions = monitorDataMap[address].Transactions[len(monitorDataMap[address].Transactions)-50:]
+					data.Transactions = data.Transactions[len(data.Transactions)-50:]
 				}
 				monitorMutex.Unlock()
 			}
@@ -276,20 +332,24 @@ func GetMonitorData(address string) (AddressMonitorData, bool) {
 
 // GetAllMonitoredAddresses trả về danh sách tất cả các địa chỉ đang được theo dõi
 func GetAllMonitoredAddresses() []string {
-	return TargetAddresses
+	targetAddressesMutex.RLock()
+	defer targetAddressesMutex.RUnlock()
+	return append([]string(nil), TargetAddresses...)
 }
 
-// AddAddressToMonitor thêm một địa chỉ mới vào danh sách theo dõi
-func AddAddressToMonitor(apiKey string, address string) {
-	// Kiểm tra xem địa chỉ đã tồn tại trong danh sách chưa
+// AddAddressToMonitor thêm một địa chỉ mới vào danh sách theo dõi; goroutine theo dõi dừng
+// cùng ctx của service
+func AddAddressToMonitor(ctx context.Context, apiKey string, address string) {
+	// Kiểm tra xem địa chỉ đã tồn tại trong danh sách chưa, rồi thêm vào danh sách
+	targetAddressesMutex.Lock()
 	for _, addr := range TargetAddresses {
 		if addr == address {
+			targetAddressesMutex.Unlock()
 			return
 		}
 	}
-
-	// Thêm địa chỉ vào danh sách
 	TargetAddresses = append(TargetAddresses, address)
+	targetAddressesMutex.Unlock()
 
 	// Khởi tạo dữ liệu theo dõi cho địa chỉ mới
 	monitorMutex.Lock()
@@ -300,7 +360,7 @@ func AddAddressToMonitor(apiKey string, address string) {
 	monitorMutex.Unlock()
 
 	// Bắt đầu theo dõi địa chỉ mới
-	go trackSingleAddress(apiKey, address)
+	go trackSingleAddress(ctx, apiKey, address)
 
 	log.Printf("[THÊM] Đã thêm địa chỉ %s vào danh sách theo dõi", address)
 }
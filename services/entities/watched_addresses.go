@@ -0,0 +1,90 @@
+package entities
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+)
+
+var (
+	// targetAddressesMutex bảo vệ TargetAddresses, trackingAPIKey và trackingCtx vì danh sách có
+	// thể đổi lúc chạy
+	targetAddressesMutex sync.RWMutex
+	// trackingAPIKey khác rỗng khi TrackMultipleAddresses đang chạy; trackingCtx là ctx của nó
+	trackingAPIKey string
+	trackingCtx    context.Context
+)
+
+func isTargetAddress(address string) bool {
+	targetAddressesMutex.RLock()
+	defer targetAddressesMutex.RUnlock()
+
+	for _, addr := range TargetAddresses {
+		if addr == address {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTargetAddresses thay danh sách ví theo dõi (khôi phục cấu hình đã lưu trước khi bắt đầu theo dõi)
+func SetTargetAddresses(addresses []string) {
+	targetAddressesMutex.Lock()
+	defer targetAddressesMutex.Unlock()
+
+	TargetAddresses = TargetAddresses[:0]
+	for _, addr := range addresses {
+		TargetAddresses = append(TargetAddresses, strings.ToLower(addr))
+	}
+}
+
+// WatchAddress thêm ví vào danh sách theo dõi; nếu bộ theo dõi đang chạy thì bắt đầu theo dõi ngay.
+// Trả về false nếu ví đã có trong danh sách.
+func WatchAddress(address string) bool {
+	address = strings.ToLower(address)
+	if isTargetAddress(address) {
+		return false
+	}
+
+	targetAddressesMutex.RLock()
+	apiKey, ctx := trackingAPIKey, trackingCtx
+	targetAddressesMutex.RUnlock()
+
+	if apiKey != "" {
+		AddAddressToMonitor(ctx, apiKey, address)
+		return true
+	}
+
+	targetAddressesMutex.Lock()
+	defer targetAddressesMutex.Unlock()
+	for _, addr := range TargetAddresses {
+		if addr == address {
+			return false
+		}
+	}
+	TargetAddresses = append(TargetAddresses, address)
+	return true
+}
+
+// UnwatchAddress gỡ ví khỏi danh sách; goroutine theo dõi ví đó tự dừng ở vòng kế tiếp
+func UnwatchAddress(address string) bool {
+	address = strings.ToLower(address)
+
+	targetAddressesMutex.Lock()
+	defer targetAddressesMutex.Unlock()
+
+	for i, addr := range TargetAddresses {
+		if addr == address {
+			TargetAddresses = append(TargetAddresses[:i], TargetAddresses[i+1:]...)
+
+			monitorMutex.Lock()
+			delete(monitorDataMap, address)
+			monitorMutex.Unlock()
+
+			log.Printf("[GỠ] Đã gỡ địa chỉ %s khỏi danh sách theo dõi", address)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+// Package export xuất dữ liệu đã ingest (đọc từ file của FileSink) ra Parquet/CSV theo
+// phân vùng chain và ngày để nạp thẳng vào DuckDB hoặc pandas:
+//
+//	<EXPORT_DIR>/<dataset>/v<phiên bản>/chain=<chain>/date=<YYYY-MM-DD>/<file>.parquet
+//
+// Nến mỗi khung thời gian nằm ở một file riêng (candles_1d, candles_1w, ...) trong cùng
+// phân vùng.
+//
+// Khi đổi cột của một dataset thì tăng Version của nó; file phiên bản cũ giữ nguyên ở thư
+// mục v cũ, và _schema.json trong mỗi thư mục phiên bản mô tả schema của file trong đó.
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"main/services/metrics"
+	"main/services/sink"
+)
+
+// DefaultDir là thư mục xuất mặc định, đổi bằng EXPORT_DIR
+const DefaultDir = "./data/export"
+
+// Định dạng file xuất
+const (
+	FormatParquet = "parquet"
+	FormatCSV     = "csv"
+	FormatBoth    = "both"
+)
+
+// Dataset là một bảng xuất ra
+type Dataset struct {
+	Name    string
+	Version int
+	Kinds   []string // loại bản ghi trong sink, có thể là mẫu (ohlcv_* là mọi khung thời gian)
+	model   any
+	// at là thời điểm xác định phân vùng ngày của bản ghi, dùng để lọc trước khi gom
+	at func(r sink.StoredRecord) time.Time
+	// write ghi các phân vùng của một loại bản ghi, trả về số dòng và số file
+	write func(dst, kind string, records []sink.StoredRecord, days dayRange, format string) (int, int, error)
+}
+
+// Datasets là các bảng có thể xuất, theo thứ tự xuất
+var Datasets = []Dataset{
+	{Name: "transfers", Version: 1, Kinds: []string{sink.KindTransfer}, model: TransferRow{}, at: transferTime,
+		write: func(dst, _ string, records []sink.StoredRecord, days dayRange, format string) (int, int, error) {
+			return writePartitions(dst, "transfers", transferRows(records), days, format)
+		}},
+	{Name: "candles", Version: 1, Kinds: []string{"ohlcv_*"}, model: CandleRow{}, at: candleTime,
+		write: func(dst, kind string, records []sink.StoredRecord, days dayRange, format string) (int, int, error) {
+			interval := strings.TrimPrefix(kind, "ohlcv_")
+			return writePartitions(dst, "candles_"+interval, candleRows(interval, records), days, format)
+		}},
+	{Name: "stablecoin_flows", Version: 1, Kinds: []string{"stablecoin_flow"}, model: FlowRow{}, at: flowTime,
+		write: func(dst, _ string, records []sink.StoredRecord, days dayRange, format string) (int, int, error) {
+			return writePartitions(dst, "stablecoin_flows", flowRows(records), days, format)
+		}},
+	{Name: "btc_netflows", Version: 1, Kinds: []string{"btc_netflow"}, model: NetflowRow{}, at: netflowTime,
+		write: func(dst, _ string, records []sink.StoredRecord, days dayRange, format string) (int, int, error) {
+			return writePartitions(dst, "btc_netflows", netflowRows(records), days, format)
+		}},
+}
+
+// Options là tham số của một lần xuất
+type Options struct {
+	SourceDir string    // thư mục của FileSink; rỗng thì dùng SINK_DIR hoặc sink.DefaultDir
+	OutDir    string    // rỗng thì dùng Dir()
+	From, To  time.Time // xuất các ngày UTC từ From tới hết ngày của To
+	Datasets  []string  // rỗng thì xuất mọi dataset
+	Format    string    // parquet, csv hoặc both; rỗng thì dùng Format()
+}
+
+// Stats đếm số dòng và file đã ghi theo dataset
+type Stats struct {
+	Rows  map[string]int
+	Files int
+}
+
+type partition struct {
+	chain, day string
+}
+
+func partitionOf(chain string, t time.Time) partition {
+	if chain == "" {
+		chain = "unknown"
+	}
+	return partition{chain: chain, day: t.UTC().Format("2006-01-02")}
+}
+
+// dayRange là khoảng ngày "YYYY-MM-DD" (cả hai đầu) được ghi
+type dayRange struct {
+	from, to string
+}
+
+func (d dayRange) contains(day string) bool {
+	return day >= d.from && day <= d.to
+}
+
+// sourceDir là thư mục của FileSink: SINK_DIR hoặc sink.DefaultDir
+func sourceDir() string {
+	if dir := os.Getenv("SINK_DIR"); dir != "" {
+		return dir
+	}
+	return sink.DefaultDir
+}
+
+// Dir trả về thư mục xuất
+func Dir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultDir
+}
+
+// Format trả về định dạng mặc định từ EXPORT_FORMAT (mặc định parquet)
+func Format() string {
+	if format := os.Getenv("EXPORT_FORMAT"); format != "" {
+		return format
+	}
+	return FormatParquet
+}
+
+// Export ghi lại toàn bộ các phân vùng ngày trong khoảng đã chọn. Phân vùng được ghi đè
+// nguyên file nên chạy lại cùng khoảng cho kết quả giống nhau; ngày không có dữ liệu
+// không tạo file.
+func Export(opts Options) (Stats, error) {
+	stats := Stats{Rows: make(map[string]int)}
+	if opts.SourceDir == "" {
+		opts.SourceDir = sourceDir()
+	}
+	if opts.OutDir == "" {
+		opts.OutDir = Dir()
+	}
+	if opts.Format == "" {
+		opts.Format = Format()
+	}
+	switch opts.Format {
+	case FormatParquet, FormatCSV, FormatBoth:
+	default:
+		return stats, fmt.Errorf("định dạng không hợp lệ: %s", opts.Format)
+	}
+	if opts.To.Before(opts.From) {
+		return stats, fmt.Errorf("khoảng thời gian không hợp lệ: %s > %s", opts.From.Format("2006-01-02"), opts.To.Format("2006-01-02"))
+	}
+	days := dayRange{from: opts.From.UTC().Format("2006-01-02"), to: opts.To.UTC().Format("2006-01-02")}
+
+	selected, err := selectDatasets(opts.Datasets)
+	if err != nil {
+		return stats, err
+	}
+	for _, ds := range selected {
+		dst := fmt.Sprintf("%s/%s/v%d", opts.OutDir, ds.Name, ds.Version)
+		if err := writeSchema(dst, ds); err != nil {
+			metrics.ExportErrors.WithLabelValues(ds.Name).Inc()
+			return stats, err
+		}
+		kinds, err := expandKinds(opts.SourceDir, ds.Kinds)
+		if err != nil {
+			return stats, err
+		}
+		for _, kind := range kinds {
+			// Chỉ giữ bản ghi thuộc các ngày được xuất, không nạp cả lịch sử vào bộ nhớ
+			var records []sink.StoredRecord
+			if err := sink.ReadFiles(opts.SourceDir, kind, func(r sink.StoredRecord) error {
+				if days.contains(partitionOf(r.Chain, ds.at(r)).day) {
+					records = append(records, r)
+				}
+				return nil
+			}); err != nil {
+				metrics.ExportErrors.WithLabelValues(ds.Name).Inc()
+				return stats, fmt.Errorf("đọc %s: %w", kind, err)
+			}
+			rows, files, err := ds.write(dst, kind, records, days, opts.Format)
+			stats.Rows[ds.Name] += rows
+			stats.Files += files
+			metrics.ExportRows.WithLabelValues(ds.Name).Add(float64(rows))
+			if err != nil {
+				metrics.ExportErrors.WithLabelValues(ds.Name).Inc()
+				return stats, fmt.Errorf("xuất %s: %w", ds.Name, err)
+			}
+		}
+	}
+	return stats, nil
+}
+
+func selectDatasets(names []string) ([]Dataset, error) {
+	if len(names) == 0 {
+		return Datasets, nil
+	}
+	var selected []Dataset
+	for _, name := range names {
+		found := false
+		for _, ds := range Datasets {
+			if ds.Name == name {
+				selected = append(selected, ds)
+				found = true
+			}
+		}
+		if !found {
+			known := make([]string, 0, len(Datasets))
+			for _, ds := range Datasets {
+				known = append(known, ds.Name)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("dataset không hợp lệ: %s (có: %s)", name, strings.Join(known, ", "))
+		}
+	}
+	return selected, nil
+}
+
+// expandKinds đổi mẫu kind thành các thư mục kind có trong thư mục của FileSink
+func expandKinds(dir string, patterns []string) ([]string, error) {
+	var kinds []string
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, "*?[") {
+			kinds = append(kinds, pattern)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			kinds = append(kinds, filepath.Base(match))
+		}
+	}
+	return kinds, nil
+}
@@ -0,0 +1,345 @@
+package export
+
+import (
+	"encoding/json"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"main/services/pipeline"
+	"main/services/sink"
+)
+
+// Các cột chain và date không nằm trong file mà nằm ở đường dẫn phân vùng
+// (chain=<chain>/date=<ngày>), DuckDB và pandas tự thêm lại khi đọc cả thư mục.
+
+// TransferRow là một transfer chuẩn. Amount giữ dạng chuỗi thập phân vì giá trị token
+// thường vượt int64.
+type TransferRow struct {
+	BlockNumber     int64     `parquet:"block_number"`
+	BlockHash       string    `parquet:"block_hash"`
+	TxHash          string    `parquet:"tx_hash"`
+	LogIndex        int32     `parquet:"log_index"`
+	Contract        string    `parquet:"contract"`
+	From            string    `parquet:"from_address"`
+	To              string    `parquet:"to_address"`
+	Amount          string    `parquet:"amount"`
+	EventSignature  string    `parquet:"event_signature"`
+	TransactionType string    `parquet:"transaction_type"`
+	Rule            string    `parquet:"rule"`
+	Timestamp       time.Time `parquet:"timestamp,timestamp(millisecond)"`
+}
+
+// CandleRow là một nến OHLCV
+type CandleRow struct {
+	Interval         string    `parquet:"interval"`
+	Symbol           string    `parquet:"symbol"`
+	OpenTime         time.Time `parquet:"open_time,timestamp(millisecond)"`
+	CloseTime        time.Time `parquet:"close_time,timestamp(millisecond)"`
+	Open             float64   `parquet:"open"`
+	High             float64   `parquet:"high"`
+	Low              float64   `parquet:"low"`
+	Close            float64   `parquet:"close"`
+	Volume           float64   `parquet:"volume"`
+	QuoteAssetVolume float64   `parquet:"quote_asset_volume"`
+	NumberOfTrades   int64     `parquet:"number_of_trades"`
+	TakerBuyBaseVol  float64   `parquet:"taker_buy_base_volume"`
+	TakerBuyQuoteVol float64   `parquet:"taker_buy_quote_volume"`
+}
+
+// FlowRow là dòng tiền stablecoin của một kỳ
+type FlowRow struct {
+	Duration  string    `parquet:"duration"`
+	Symbol    string    `parquet:"symbol"`
+	Name      string    `parquet:"name"`
+	StartTime time.Time `parquet:"start_time,timestamp(millisecond)"`
+	Incoming  float64   `parquet:"incoming"`
+	Outgoing  float64   `parquet:"outgoing"`
+	NetFlow   float64   `parquet:"net_flow"`
+	Balance   float64   `parquet:"balance"`
+}
+
+// NetflowRow là netflow BTC của một sàn trong một kỳ
+type NetflowRow struct {
+	Period    string    `parquet:"period"`
+	Source    string    `parquet:"source"`
+	Timestamp time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	Exchange  string    `parquet:"exchange"`
+	Incoming  float64   `parquet:"incoming"`
+	Outgoing  float64   `parquet:"outgoing"`
+	Balance   float64   `parquet:"balance"`
+}
+
+// transferRows gom transfer theo phân vùng. Transfer bị gỡ do reorg (Removed) xóa bản
+// ghi gốc thay vì được xuất.
+func transferRows(records []sink.StoredRecord) map[partition][]TransferRow {
+	type keyed struct {
+		part partition
+		row  TransferRow
+	}
+	var order []string
+	kept := make(map[string]keyed)
+	for _, r := range records {
+		var ev pipeline.Event
+		if json.Unmarshal(r.Data, &ev) != nil {
+			continue
+		}
+		key := ev.Key()
+		if ev.Removed {
+			delete(kept, key)
+			continue
+		}
+		ts := eventTime(ev, r)
+		amount := ""
+		if ev.Amount != nil {
+			amount = ev.Amount.String()
+		}
+		if _, ok := kept[key]; !ok {
+			order = append(order, key)
+		}
+		kept[key] = keyed{partitionOf(r.Chain, ts), TransferRow{
+			BlockNumber:     int64(ev.BlockNumber),
+			BlockHash:       ev.BlockHash,
+			TxHash:          ev.TxHash,
+			LogIndex:        int32(ev.LogIndex),
+			Contract:        ev.Contract,
+			From:            ev.From,
+			To:              ev.To,
+			Amount:          amount,
+			EventSignature:  ev.EventSignature,
+			TransactionType: ev.TransactionType,
+			Rule:            ev.Rule,
+			Timestamp:       ts.UTC(),
+		}}
+	}
+
+	rows := make(map[partition][]TransferRow)
+	for _, key := range order {
+		if k, ok := kept[key]; ok {
+			rows[k.part] = append(rows[k.part], k.row)
+		}
+	}
+	for part := range rows {
+		sort.SliceStable(rows[part], func(i, j int) bool {
+			return rows[part][i].BlockNumber < rows[part][j].BlockNumber
+		})
+	}
+	return rows
+}
+
+// candleRows gom nến theo ngày mở nến; nến cùng symbol và giờ mở được ghi lại nhiều
+// lần (cập nhật trong kỳ) thì giữ bản cuối
+func candleRows(interval string, records []sink.StoredRecord) map[partition][]CandleRow {
+	type candle struct {
+		Symbol           string
+		OpenTime         *big.Int
+		Open             *big.Int
+		High             *big.Int
+		Low              *big.Int
+		Close            *big.Int
+		Volume           string
+		CloseTime        *big.Int
+		QuoteAssetVolume string
+		NumberOfTrades   *big.Int
+		TakerBuyBaseVol  string
+		TakerBuyQuoteVol string
+	}
+
+	latest := make(map[string]int)
+	rows := make(map[partition][]CandleRow)
+	for _, r := range records {
+		var c candle
+		if json.Unmarshal(r.Data, &c) != nil || c.OpenTime == nil {
+			continue
+		}
+		row := CandleRow{
+			Interval:         interval,
+			Symbol:           c.Symbol,
+			OpenTime:         time.UnixMilli(c.OpenTime.Int64()).UTC(),
+			CloseTime:        time.UnixMilli(bigInt(c.CloseTime)).UTC(),
+			Open:             price(c.Open),
+			High:             price(c.High),
+			Low:              price(c.Low),
+			Close:            price(c.Close),
+			Volume:           parseFloat(c.Volume),
+			QuoteAssetVolume: parseFloat(c.QuoteAssetVolume),
+			NumberOfTrades:   bigInt(c.NumberOfTrades),
+			TakerBuyBaseVol:  parseFloat(c.TakerBuyBaseVol),
+			TakerBuyQuoteVol: parseFloat(c.TakerBuyQuoteVol),
+		}
+		part := partitionOf(r.Chain, row.OpenTime)
+		key := part.day + "/" + row.Symbol + "/" + strconv.FormatInt(c.OpenTime.Int64(), 10)
+		if i, ok := latest[key]; ok {
+			rows[part][i] = row
+			continue
+		}
+		latest[key] = len(rows[part])
+		rows[part] = append(rows[part], row)
+	}
+	for part := range rows {
+		sort.SliceStable(rows[part], func(i, j int) bool {
+			a, b := rows[part][i], rows[part][j]
+			if a.Symbol != b.Symbol {
+				return a.Symbol < b.Symbol
+			}
+			return a.OpenTime.Before(b.OpenTime)
+		})
+	}
+	return rows
+}
+
+func flowRows(records []sink.StoredRecord) map[partition][]FlowRow {
+	type flow struct {
+		NameCoin  string
+		Symbol    string
+		StartTime string
+		Incoming  float64
+		Outgoing  float64
+		NetFlow   float64
+		Balance   float64
+		Duration  string
+	}
+
+	rows := make(map[partition][]FlowRow)
+	for _, r := range records {
+		var f flow
+		if json.Unmarshal(r.Data, &f) != nil {
+			continue
+		}
+		start := flowStart(f.StartTime, r)
+		part := partitionOf(r.Chain, start)
+		rows[part] = append(rows[part], FlowRow{
+			Duration:  f.Duration,
+			Symbol:    f.Symbol,
+			Name:      f.NameCoin,
+			StartTime: start.UTC(),
+			Incoming:  f.Incoming,
+			Outgoing:  f.Outgoing,
+			NetFlow:   f.NetFlow,
+			Balance:   f.Balance,
+		})
+	}
+	return rows
+}
+
+// netflowRows tách mỗi bản ghi netflow (map theo sàn) thành một dòng cho mỗi sàn
+func netflowRows(records []sink.StoredRecord) map[partition][]NetflowRow {
+	rows := make(map[partition][]NetflowRow)
+	for _, r := range records {
+		var n struct {
+			Period    string             `json:"period"`
+			Source    string             `json:"source"`
+			Timestamp int64              `json:"timestamp"`
+			Incoming  map[string]float64 `json:"incoming"`
+			Outgoing  map[string]float64 `json:"outgoing"`
+			Balance   map[string]float64 `json:"balance"`
+		}
+		if json.Unmarshal(r.Data, &n) != nil {
+			continue
+		}
+		ts := netflowAt(n.Timestamp, r)
+
+		exchanges := make(map[string]bool)
+		for _, m := range []map[string]float64{n.Incoming, n.Outgoing, n.Balance} {
+			for exchange := range m {
+				exchanges[exchange] = true
+			}
+		}
+		names := make([]string, 0, len(exchanges))
+		for exchange := range exchanges {
+			names = append(names, exchange)
+		}
+		sort.Strings(names)
+
+		part := partitionOf(r.Chain, ts)
+		for _, exchange := range names {
+			rows[part] = append(rows[part], NetflowRow{
+				Period:    n.Period,
+				Source:    n.Source,
+				Timestamp: ts.UTC(),
+				Exchange:  exchange,
+				Incoming:  n.Incoming[exchange],
+				Outgoing:  n.Outgoing[exchange],
+				Balance:   n.Balance[exchange],
+			})
+		}
+	}
+	return rows
+}
+
+// Thời điểm phân vùng của từng loại bản ghi; hàm *Time đọc riêng trường cần thiết để
+// Export lọc theo ngày trước khi gom
+
+func eventTime(ev pipeline.Event, r sink.StoredRecord) time.Time {
+	if ev.Timestamp.IsZero() {
+		return r.Time
+	}
+	return ev.Timestamp
+}
+
+func transferTime(r sink.StoredRecord) time.Time {
+	var ev pipeline.Event
+	if json.Unmarshal(r.Data, &ev) != nil {
+		return r.Time
+	}
+	return eventTime(ev, r)
+}
+
+func candleTime(r sink.StoredRecord) time.Time {
+	var c struct{ OpenTime *big.Int }
+	if json.Unmarshal(r.Data, &c) != nil || c.OpenTime == nil {
+		return r.Time
+	}
+	return time.UnixMilli(c.OpenTime.Int64())
+}
+
+// flowStart: Time của bản ghi là đầu kỳ theo giờ máy ghi; StartTime chỉ là chuỗi không múi giờ
+func flowStart(startTime string, r sink.StoredRecord) time.Time {
+	if t, err := time.Parse("2006-01-02 15:04:05", startTime); err == nil && r.Time.IsZero() {
+		return t
+	}
+	return r.Time
+}
+
+func flowTime(r sink.StoredRecord) time.Time {
+	var f struct{ StartTime string }
+	json.Unmarshal(r.Data, &f)
+	return flowStart(f.StartTime, r)
+}
+
+func netflowAt(timestamp int64, r sink.StoredRecord) time.Time {
+	if timestamp > 0 {
+		return time.Unix(timestamp, 0)
+	}
+	return r.Time
+}
+
+func netflowTime(r sink.StoredRecord) time.Time {
+	var n struct {
+		Timestamp int64 `json:"timestamp"`
+	}
+	json.Unmarshal(r.Data, &n)
+	return netflowAt(n.Timestamp, r)
+}
+
+func bigInt(v *big.Int) int64 {
+	if v == nil {
+		return 0
+	}
+	return v.Int64()
+}
+
+// price đổi giá đã nhân 100 trong ResponseOHLCV (xem ohlcv.strToBigInt) về giá thật
+func price(v *big.Int) float64 {
+	if v == nil {
+		return 0
+	}
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(v), big.NewFloat(100)).Float64()
+	return f
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
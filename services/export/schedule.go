@@ -0,0 +1,158 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"main/services/leader"
+	"main/services/logging"
+	"main/services/sink"
+)
+
+// stateFile ghi ngày cuối cùng đã xuất xong và các ngày chờ xuất lại trong EXPORT_DIR
+const stateFile = "_last_export"
+
+// Enabled cho biết job xuất định kỳ có được bật (EXPORT_DIR được đặt)
+func Enabled() bool {
+	return os.Getenv("EXPORT_DIR") != ""
+}
+
+// Run là job xuất định kỳ: mỗi giờ xuất các ngày UTC đã trọn từ sau lần xuất trước tới hôm
+// qua, rồi xuất lại một lần ngày có dữ liệu tới muộn. Chỉ leader xuất để các replica không
+// cùng ghi một thư mục.
+func Run(ctx context.Context) error {
+	logger := logging.Service("export")
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if leader.Allow("export") {
+			if err := catchUp(logger); err != nil {
+				logger.Error("❌ Xuất dữ liệu thất bại", "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// lateDataDelay là thời gian chờ sau lần xuất đầu của một ngày trước khi xuất lại ngày đó
+// (một lần) nếu phân vùng của ngày có bản ghi được ghi sau lần xuất: nến chốt sau nửa đêm,
+// log bị lỡ được quét lại, backfill.
+const lateDataDelay = 6 * time.Hour
+
+// exportState là nội dung stateFile. File cũ chỉ chứa ngày dạng "YYYY-MM-DD" vẫn đọc được.
+type exportState struct {
+	Last string `json:"last"`
+	// Pending: ngày đã xuất lần đầu → lúc xuất, chờ xuất lại một lần nếu có dữ liệu tới muộn
+	Pending map[string]time.Time `json:"pending,omitempty"`
+}
+
+func readState(path string) exportState {
+	st := exportState{Pending: make(map[string]time.Time)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st
+	}
+	if json.Unmarshal(data, &st) != nil {
+		st.Last = strings.TrimSpace(string(data))
+	}
+	if st.Pending == nil {
+		st.Pending = make(map[string]time.Time)
+	}
+	return st
+}
+
+func catchUp(logger *slog.Logger) error {
+	// Bản ghi còn trong bộ đệm của sink phải nằm trong file trước khi đọc
+	if err := sink.FlushAll(); err != nil {
+		return fmt.Errorf("flush sink: %w", err)
+	}
+
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	path := filepath.Join(Dir(), stateFile)
+	st := readState(path)
+	from := yesterday
+	if last, err := time.Parse("2006-01-02", st.Last); err == nil {
+		from = last.AddDate(0, 0, 1)
+	}
+
+	if !from.After(yesterday) {
+		started := time.Now()
+		stats, err := Export(Options{From: from, To: yesterday})
+		if err != nil {
+			return err
+		}
+		logger.Info("📦 Đã xuất dữ liệu", "from", from.Format("2006-01-02"), "to", yesterday.Format("2006-01-02"), "files", stats.Files, "rows", stats.Rows)
+		st.Last = yesterday.Format("2006-01-02")
+		for day := from; !day.After(yesterday); day = day.AddDate(0, 0, 1) {
+			st.Pending[day.Format("2006-01-02")] = started
+		}
+	}
+
+	due := make(map[string]time.Time)
+	for day, exported := range st.Pending {
+		if time.Since(exported) >= lateDataDelay {
+			due[day] = exported
+		}
+	}
+	late, err := lateDays(sourceDir(), due)
+	if err != nil {
+		return err
+	}
+	for day := range due {
+		if late[day] {
+			t, _ := time.Parse("2006-01-02", day)
+			stats, err := Export(Options{From: t, To: t})
+			if err != nil {
+				return err
+			}
+			logger.Info("🔁 Xuất lại ngày có dữ liệu tới muộn", "date", day, "files", stats.Files, "rows", stats.Rows)
+		}
+		delete(st.Pending, day)
+	}
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return atomicWrite(path, func(tmp string) error {
+		return os.WriteFile(tmp, append(data, '\n'), 0o644)
+	})
+}
+
+// lateDays trả về các ngày trong exported có bản ghi thuộc phân vùng của ngày đó được sink
+// ghi sau lần xuất (exported: ngày → lúc xuất). Bản ghi của ngày khác, dù mới, không làm
+// ngày bị xuất lại.
+func lateDays(dir string, exported map[string]time.Time) (map[string]bool, error) {
+	late := make(map[string]bool)
+	if len(exported) == 0 {
+		return late, nil
+	}
+	for _, ds := range Datasets {
+		kinds, err := expandKinds(dir, ds.Kinds)
+		if err != nil {
+			return nil, err
+		}
+		for _, kind := range kinds {
+			if err := sink.ReadFiles(dir, kind, func(r sink.StoredRecord) error {
+				day := partitionOf(r.Chain, ds.at(r)).day
+				if at, ok := exported[day]; ok && r.Ingested.After(at) {
+					late[day] = true
+				}
+				return nil
+			}); err != nil {
+				return nil, fmt.Errorf("đọc %s: %w", kind, err)
+			}
+		}
+	}
+	return late, nil
+}
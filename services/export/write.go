@@ -0,0 +1,164 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// schemaKey là khóa metadata trong file Parquet ghi tên dataset và phiên bản schema
+const schemaKey = "dsea.schema"
+
+// writePartitions ghi mỗi phân vùng trong days ra <dst>/chain=<chain>/date=<ngày>/<name>.<đuôi>
+func writePartitions[T any](dst, name string, rows map[partition][]T, days dayRange, format string) (int, int, error) {
+	total, files := 0, 0
+	schema := filepath.Base(filepath.Dir(dst)) + "/" + filepath.Base(dst)
+	for part, partRows := range rows {
+		if !days.contains(part.day) || len(partRows) == 0 {
+			continue
+		}
+		dir := filepath.Join(dst, "chain="+part.chain, "date="+part.day)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return total, files, err
+		}
+		base := filepath.Join(dir, name)
+		if format != FormatCSV {
+			if err := atomicWrite(base+".parquet", func(path string) error {
+				return parquet.WriteFile(path, partRows, parquet.KeyValueMetadata(schemaKey, schema))
+			}); err != nil {
+				return total, files, err
+			}
+			files++
+		}
+		if format != FormatParquet {
+			if err := atomicWrite(base+".csv", func(path string) error {
+				return writeCSV(path, partRows)
+			}); err != nil {
+				return total, files, err
+			}
+			files++
+		}
+		total += len(partRows)
+	}
+	return total, files, nil
+}
+
+// writeSchema ghi _schema.json của một phiên bản dataset để người đọc biết cột và kiểu
+func writeSchema(dst string, ds Dataset) error {
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	columns := make([]map[string]string, 0)
+	for _, f := range csvFields(reflect.TypeOf(ds.model)) {
+		columns = append(columns, map[string]string{"name": f.name, "type": f.typ})
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"dataset":    ds.Name,
+		"version":    ds.Version,
+		"kinds":      ds.Kinds,
+		"partitions": []string{"chain", "date"},
+		"columns":    columns,
+		"parquet":    parquet.SchemaOf(ds.model).String(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWrite(filepath.Join(dst, "_schema.json"), func(path string) error {
+		return os.WriteFile(path, append(data, '\n'), 0o644)
+	})
+}
+
+// atomicWrite ghi qua file tạm rồi đổi tên để người đọc không thấy file ghi dở
+func atomicWrite(path string, write func(tmp string) error) error {
+	tmp := path + ".tmp"
+	if err := write(tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ghi %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+type csvField struct {
+	index int
+	name  string
+	typ   string
+}
+
+// csvFields lấy tên cột từ tag parquet để CSV và Parquet có cùng cột
+func csvFields(t reflect.Type) []csvField {
+	var fields []csvField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("parquet"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		typ := f.Type.Kind().String()
+		if f.Type == reflect.TypeOf(time.Time{}) {
+			typ = "timestamp"
+		}
+		fields = append(fields, csvField{index: i, name: name, typ: typ})
+	}
+	return fields
+}
+
+func writeCSV[T any](path string, rows []T) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := bufio.NewWriter(f)
+	w := csv.NewWriter(buf)
+	fields := csvFields(reflect.TypeOf(rows).Elem())
+	record := make([]string, len(fields))
+	for i, field := range fields {
+		record[i] = field.name
+	}
+	if err := w.Write(record); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		v := reflect.ValueOf(row)
+		for i, field := range fields {
+			record[i] = csvValue(v.Field(field.index))
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func csvValue(v reflect.Value) string {
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return x.UTC().Format(time.RFC3339)
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case int32:
+		return strconv.FormatInt(int64(x), 10)
+	}
+	return fmt.Sprint(v.Interface())
+}
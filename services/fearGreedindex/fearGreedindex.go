@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"strconv"
 	"time"
+
+	"main/services/sink"
 )
 
 type FormDataAdjusted struct {
@@ -106,7 +108,7 @@ func getFearGreedData() ([]FormDataAdjusted, int, error) {
 
 func FearGreedindex() {
 	// Lấy và log 30 ngày đầu tiên
-	fmt.Println("Đang lấy dữ liệu Fear & Greed Index cho 30 ngày trước...")
+	log.Println("Đang lấy dữ liệu Fear & Greed Index cho 30 ngày trước...")
 	formDataList, timeUntilUpdate, err := getFearGreedData()
 	if err != nil {
 		log.Printf("Có lỗi xảy ra khi lấy dữ liệu ban đầu: %v", err)
@@ -118,21 +120,22 @@ func FearGreedindex() {
 	for i, formData := range formDataList {
 		// Chuyển timestamp sang định dạng thời gian dễ đọc
 		t := time.Unix(formData.Timestamp.Int64(), 0)
-		fmt.Printf("Ngày %d (%s):\n", 30-i, t.Format("2006-01-02"))
-		fmt.Printf("Timestamp: %s\n", formData.Timestamp.String())
-		fmt.Printf("Value: %s\n", formData.Value.String())
-		fmt.Printf("Value Classification: %s\n", formData.ValueClassification)
-		fmt.Println("-------------------")
+		log.Printf("Ngày %d (%s):", 30-i, t.Format("2006-01-02"))
+		log.Printf("Timestamp: %s", formData.Timestamp.String())
+		log.Printf("Value: %s", formData.Value.String())
+		log.Printf("Value Classification: %s", formData.ValueClassification)
+		log.Println("-------------------")
+		sink.Write(sink.Record{Kind: "feargreed", Time: t, Data: formData})
 		// Gửi dữ liệu đến SMC (giả sử ConnectToSMC đã được định nghĩa)
 		// ConnectToSMC(formData)
 	}
 
 	// Vòng lặp để log dữ liệu mới sau mỗi lần cập nhật
 	for {
-		fmt.Printf("Chờ %d giây để lấy dữ liệu ngày tiếp theo...\n", timeUntilUpdate)
+		log.Printf("Chờ %d giây để lấy dữ liệu ngày tiếp theo...", timeUntilUpdate)
 		time.Sleep(time.Duration(timeUntilUpdate) * time.Second)
 
-		fmt.Println("Đang lấy dữ liệu Fear & Greed Index mới nhất...")
+		log.Println("Đang lấy dữ liệu Fear & Greed Index mới nhất...")
 		formDataList, timeUntilUpdate, err = getFearGreedData()
 		if err != nil {
 			log.Printf("Có lỗi xảy ra: %v", err)
@@ -143,11 +146,12 @@ func FearGreedindex() {
 		// Chỉ log ngày mới nhất (index 0)
 		latest := formDataList[0]
 		t := time.Unix(latest.Timestamp.Int64(), 0)
-		fmt.Printf("Dữ liệu mới nhất (%s):\n", t.Format("2006-01-02"))
-		fmt.Printf("Timestamp: %s\n", latest.Timestamp.String())
-		fmt.Printf("Value: %s\n", latest.Value.String())
-		fmt.Printf("Value Classification: %s\n", latest.ValueClassification)
-		fmt.Printf("Thời gian cập nhật tiếp theo: %d giây\n\n", timeUntilUpdate)
+		log.Printf("Dữ liệu mới nhất (%s):", t.Format("2006-01-02"))
+		log.Printf("Timestamp: %s", latest.Timestamp.String())
+		log.Printf("Value: %s", latest.Value.String())
+		log.Printf("Value Classification: %s", latest.ValueClassification)
+		log.Printf("Thời gian cập nhật tiếp theo: %d giây", timeUntilUpdate)
+		sink.Write(sink.Record{Kind: "feargreed", Time: t, Data: latest})
 		// Gửi dữ liệu mới nhất đến SMC
 		// ConnectToSMC(latest)
 	}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"fmt"
-	"log"
 	"math/big"
 	"strings"
 
@@ -16,14 +15,32 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 
 	feargreedindex "main/config/fearGreedindex"
+
+	"main/services/health"
+	"main/services/metrics"
+	"main/services/outbox"
 )
 
-func ConnectToSMC(FormData FormDataAdjusted) {
+// fearGreedOutbox giữ chỉ số chờ gửi, theo timestamp của chỉ số
+var fearGreedOutbox = outbox.New("fear-greed", 0, sendToSMC)
+
+// ConnectToSMC xếp chỉ số vào hộp thư đi; leader gửi lên contract qua sendToSMC
+func ConnectToSMC(FormData FormDataAdjusted) error {
+	return fearGreedOutbox.Put(FormData.Timestamp.String(), FormData)
+}
+
+func sendToSMC(FormData FormDataAdjusted) (err error) {
+	defer func() {
+		metrics.ObservePublish("fear-greed", err)
+		health.Publisher("fear-greed").Done(err)
+	}()
+
 	// Kết nối WebSocket tới node BSC Testnet
 	client, err := ethclient.Dial("wss://bsc-testnet-rpc.publicnode.com")
 	if err != nil {
-		log.Fatalf("Error creating client: %v", err)
+		return fmt.Errorf("error creating client: %w", err)
 	}
+	defer client.Close()
 
 	// Địa chỉ hợp đồng mà bạn muốn lắng nghe sự kiện
 	contractAddr := common.HexToAddress(feargreedindex.ContractAddress)
@@ -31,39 +48,39 @@ func ConnectToSMC(FormData FormDataAdjusted) {
 	// Parse ABI
 	contractABI, err := abi.JSON(strings.NewReader(feargreedindex.ContractABI))
 	if err != nil {
-		fmt.Printf("Error parsing ABI: %v\n", err)
+		return fmt.Errorf("error parsing ABI: %w", err)
 	}
 
 	// Private key của người gửi (dùng cho giao dịch)
 	privateKey, err := crypto.HexToECDSA(feargreedindex.PrivateKey)
 	if err != nil {
-		log.Fatalf("Failed to load private key: %v", err)
+		return fmt.Errorf("failed to load private key: %w", err)
 	}
 
 	// Địa chỉ từ private key
 	publicKey := privateKey.Public()
 	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
 	if !ok {
-		log.Fatal("Error casting public key to ECDSA")
+		return fmt.Errorf("error casting public key to ECDSA")
 	}
 	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
 
 	// Lấy nonce của tài khoản người gửi
 	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
 	if err != nil {
-		log.Fatalf("Failed to get nonce: %v", err)
+		return fmt.Errorf("failed to get nonce: %w", err)
 	}
 
 	// Lấy gasPrice hiện tại
 	gasPrice, err := client.SuggestGasPrice(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to get gas price: %v", err)
+		return fmt.Errorf("failed to get gas price: %w", err)
 	}
 
 	// Dữ liệu được mã hóa cho hàm recordData
 	data, err := contractABI.Pack("recordIndex", FormData)
 	if err != nil {
-		log.Fatalf("Failed to pack function call date: %v", err)
+		return fmt.Errorf("failed to pack function call date: %w", err)
 	}
 
 	// Tạo giao dịch
@@ -74,7 +91,7 @@ func ConnectToSMC(FormData FormDataAdjusted) {
 	})
 
 	if err != nil {
-		log.Fatalf("Failed to estimate gas: %v", err)
+		return fmt.Errorf("failed to estimate gas: %w", err)
 	}
 	gasLimit = gasLimit * 12 / 10 // Add 20% buffer
 	tx := types.NewTransaction(nonce, contractAddr, big.NewInt(0), gasLimit, gasPrice, data)
@@ -82,17 +99,18 @@ func ConnectToSMC(FormData FormDataAdjusted) {
 	// Ký giao dịch bằng private key
 	chainID, err := client.NetworkID(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to get chain ID: %v", err)
+		return fmt.Errorf("failed to get chain ID: %w", err)
 	}
 
 	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
 	if err != nil {
-		log.Fatalf("Failed to sign transaction: %v", err)
+		return fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
 	// Gửi giao dịch
 	err = client.SendTransaction(context.Background(), signedTx)
 	if err != nil {
-		log.Fatalf("Failed to send transactionDate: %v", err)
+		return fmt.Errorf("failed to send transactionDate: %w", err)
 	}
+	return nil
 }
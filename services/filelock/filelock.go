@@ -0,0 +1,26 @@
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Lock mở (tạo nếu chưa có) file tại path và giữ khóa độc quyền trên nó, kể cả giữa
+// các tiến trình dùng chung thư mục. Gọi unlock để nhả khóa và đóng file.
+func Lock(path string) (f *os.File, unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, nil, err
+	}
+	f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package filelock
+
+import "os"
+
+// Không có flock: khóa chỉ còn là quy ước, hai tiến trình có thể cùng ghi trong một khoảnh khắc
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
@@ -2,10 +2,15 @@ package get_chains
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/ethclient"
+
+	"main/services/checkpoint"
+	"main/services/dedup"
+	"main/services/logging"
 )
 
 func GetChainData(chainName string) *ChainData {
@@ -26,7 +31,7 @@ func InitChainData(chainName string) *ChainData {
 		chains[chainName] = &ChainData{
 			LastProcessedBlock:  big.NewInt(0),
 			ProcessedTxs:        make(map[string]bool),
-			DisconnectedChannel: make(chan struct{}, 100),
+			DisconnectedChannel: make(chan struct{}, 1),
 			LogData:             make(map[string]interface{}),
 			IsProcessingReorg:   false,
 		}
@@ -35,51 +40,109 @@ func InitChainData(chainName string) *ChainData {
 	return chains[chainName]
 }
 
-func handle_chain(chainName string) {
+// handle_chain chạy ingest WebSocket của một chain EVM cho tới khi ctx bị hủy.
+// Khi dừng, các log đã nhận được xử lý hết qua pipeline rồi mới lưu checkpoint.
+func handle_chain(ctx context.Context, chainName string) error {
 	chainData := InitChainData(chainName)
 
-	load_config(chooseChain[chainName], chainName)
+	if err := load_config(chain_config_path(chainName), chainName); err != nil {
+		return fmt.Errorf("không thể tải cấu hình cho %s: %w", chainName, err)
+	}
 
-	client, err := ethclient.Dial(chainData.Config.WssRPC)
+	client, err := ethclient.DialContext(ctx, chainData.Config.WssRPC)
 	if err != nil {
-		log.Fatalf("Không thể kết nối đến RPC cho %s: %v", chainName, err)
+		return fmt.Errorf("không thể kết nối đến RPC cho %s: %w", chainName, err)
 	}
 	defer client.Close()
+	chainData.Client = client
+
+	// Pipeline chạy trên context riêng để vẫn xử lý nốt hàng đợi sau khi ctx của service bị hủy
+	pipelineCtx, stopPipeline := context.WithCancel(context.Background())
+	defer stopPipeline()
+
+	if err := start_handle(ctx, pipelineCtx, client, chainName); err != nil {
+		return err
+	}
 
 	go func() {
-		for range chainData.DisconnectedChannel {
-			handle_disconnected_logs(client, chainName)
-			log.Printf("Đã xử lý xong các log bị bỏ lỡ cho %s", chainName)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-chainData.DisconnectedChannel:
+				handle_disconnected_logs(client, chainName)
+				logging.Chain("get_chains", chainName).Info("Đã xử lý xong các log bị bỏ lỡ")
+			}
 		}
 	}()
 
-	start_handle(client, chainName)
-
-	select {}
+	checkpointTicker := time.NewTicker(checkpointInterval)
+	defer checkpointTicker.Stop()
+
+	for {
+		select {
+		case <-checkpointTicker.C:
+			save_checkpoint(chainName)
+		case <-ctx.Done():
+			if !chainData.Pipeline.Drain(30 * time.Second) {
+				logging.Chain("get_chains", chainName).Warn("⚠️ Hết thời gian chờ pipeline xử lý xong")
+			}
+			save_checkpoint(chainName)
+			logging.Chain("get_chains", chainName).Info("🛑 Đã dừng ingest WebSocket")
+			return nil
+		}
+	}
 }
 
-func start_handle(client *ethclient.Client, chainName string) {
-	ctx := context.Background()
-
+func start_handle(ctx, pipelineCtx context.Context, client *ethclient.Client, chainName string) error {
 	chainData := GetChainData(chainName)
 	if chainData == nil {
-		log.Fatalf("Không tìm thấy dữ liệu cho chain %s", chainName)
+		return fmt.Errorf("không tìm thấy dữ liệu cho chain %s", chainName)
 	}
 
-	currentBlock, err := client.BlockNumber(context.Background())
+	currentBlock, err := client.BlockNumber(ctx)
 	if err != nil {
-		log.Fatalf("Không thể lấy số khối hiện tại cho chain %s: %v", chainName, err)
+		return fmt.Errorf("không thể lấy số khối hiện tại cho chain %s: %w", chainName, err)
 	}
-	chainData.LastProcessedBlock = big.NewInt(int64(currentBlock))
 
-	log.Printf("======= KHỞI ĐỘNG HỆ THỐNG CHO CHAIN %s =======", chainName)
-	log.Printf("Khởi tạo lastProcessedBlock = %d", currentBlock)
-	log.Printf("TransferSignature: %s", chainData.Config.TransferSignature)
-	log.Printf("==================================")
+	logger := logging.Chain("get_chains", chainName)
 
-	log.Printf("Bắt đầu xử lý logs theo thời gian thực cho chain %s...", chainName)
+	// Tiếp tục từ checkpoint để monitor quét lại các khối bị bỏ lỡ khi tiến trình dừng.
+	// Checkpoint được đọc lại từ file vì chain có thể vừa chuyển từ worker khác sang.
+	if err := checkpoint.Default().Refresh(chainName); err != nil {
+		logger.Warn("⚠️ Không đọc lại được checkpoint", "error", err)
+	}
+	startBlock := currentBlock
+	if saved, ok := checkpoint.Default().Get(chainName); ok && saved < currentBlock {
+		startBlock = saved
+		logger.Info("📌 Khôi phục checkpoint", "block", saved, "head", currentBlock)
+	}
+
+	// Log đã xử lý (ở worker này hoặc worker trước) trong cửa sổ quét lại không bị phát lần hai
+	seen, err := dedup.Default().Load(chainName, dedup_window_start(startBlock))
+	if err != nil {
+		logger.Warn("⚠️ Không đọc được dedup store", "error", err)
+	}
+
+	chainData.mu.Lock()
+	chainData.LastProcessedBlock = new(big.Int).SetUint64(startBlock)
+	// Chain có thể vừa chuyển từ worker khác sang: mốc checkpoint bắt đầu lại từ điểm khôi phục
+	chainData.checkpointHeight = startBlock
+	for key := range seen {
+		chainData.ProcessedTxs[key] = true
+	}
+	chainData.mu.Unlock()
+
+	logger.Info("======= KHỞI ĐỘNG HỆ THỐNG CHO CHAIN =======", "block", startBlock, "transfer_signature", chainData.Config.TransferSignature)
+
+	chainData.Pipeline = new_chain_pipeline(chainName)
+	chainData.Pipeline.Start(pipelineCtx)
+
+	logger.Info("Bắt đầu xử lý logs theo thời gian thực")
 	go handle_logs(ctx, client, chainName)
 
-	log.Printf("Bắt đầu giám sát logs bị bỏ lỡ cho chain %s...", chainName)
+	logger.Info("Bắt đầu giám sát logs bị bỏ lỡ")
 	go periodic_logs_monitoring(ctx, client, chainName)
+
+	return nil
 }
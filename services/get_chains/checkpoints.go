@@ -0,0 +1,63 @@
+package get_chains
+
+import (
+	"context"
+	"time"
+
+	"main/services/checkpoint"
+	"main/services/dedup"
+	"main/services/logging"
+)
+
+// Chu kỳ ghi checkpoint định kỳ; khi tắt tiến trình checkpoint luôn được ghi lần cuối
+const checkpointInterval = 30 * time.Second
+
+// Số khối trước checkpoint còn giữ key dedup, bằng cửa sổ làm sạch ProcessedTxs
+const dedupWindow = 1000
+
+// save_checkpoint ghi khối cao nhất đã xử lý theo chiều tiến của chain xuống file checkpoint
+func save_checkpoint(chainName string) {
+	chainData := GetChainData(chainName)
+	if chainData == nil {
+		return
+	}
+
+	chainData.mu.Lock()
+	height := chainData.checkpointHeight
+	chainData.mu.Unlock()
+
+	if height == 0 {
+		return
+	}
+
+	// Key dedup phải nằm trên đĩa trước checkpoint: worker nhận chain sau đó quét lại từ
+	// checkpoint và dựa vào chúng để bỏ qua log đã gửi
+	if err := dedup.Default().Flush(chainName, dedup_window_start(height)); err != nil {
+		logging.Chain("get_chains", chainName).Error("❌ Không thể lưu dedup store", "block", height, "error", err)
+		return
+	}
+
+	store := checkpoint.Default()
+	store.Set(chainName, height)
+	if err := store.Flush(); err != nil {
+		logging.Chain("get_chains", chainName).Error("❌ Không thể lưu checkpoint", "block", height, "error", err)
+	}
+}
+
+// advance_checkpoint dời mốc checkpoint lên height nếu cao hơn; gọi khi đang giữ chainData.mu
+func advance_checkpoint(chainData *ChainData, height uint64) {
+	chainData.checkpointHeight = max(chainData.checkpointHeight, height)
+}
+
+// dedup_window_start là khối cũ nhất còn giữ key dedup khi đang ở height
+func dedup_window_start(height uint64) uint64 {
+	if height <= dedupWindow {
+		return 0
+	}
+	return height - dedupWindow
+}
+
+// flush_checkpoints là shutdown hook: ghi mọi checkpoint còn trong bộ nhớ
+func flush_checkpoints(ctx context.Context) error {
+	return checkpoint.Default().Flush()
+}
@@ -2,22 +2,70 @@ package get_chains
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
 	"os"
 )
 
-func load_config(filePath string, chainName string) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		log.Fatalf("Failed to open config file: %v", err)
+// load_config nạp cấu hình từ file ở lần khởi động đầu của chain. Các lần khởi động lại sau
+// hot reload dùng bản đã kiểm tra mà apply_chain_config đã đặt, không đọc lại file.
+func load_config(filePath string, chainName string) error {
+	chainData := InitChainData(chainName)
+	chainData.mu.Lock()
+	loaded := chainData.configLoaded
+	chainData.mu.Unlock()
+	if loaded {
+		return nil
 	}
-	defer file.Close()
 
-	chainData := InitChainData(chainName)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	cfg, filters, err := parse_chain_config(data)
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", filePath, err)
+	}
+	set_chain_config(chainData, cfg, filters)
+	return nil
+}
 
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&chainData.Config)
+// parse_chain_config giải mã cấu hình vào biến riêng rồi kiểm tra, chưa đụng tới trạng thái chain
+func parse_chain_config(data []byte) (Config, []*compiledFilter, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	if cfg.RPC == "" || cfg.WssRPC == "" {
+		return Config{}, nil, fmt.Errorf("cấu hình cần rpc và wssRpc")
+	}
+	filters, err := compile_filter_rules(cfg.Filters)
 	if err != nil {
-		log.Fatalf("Failed to decode config file: %v", err)
+		return Config{}, nil, fmt.Errorf("invalid filter rules: %w", err)
 	}
+	return cfg, filters, nil
+}
+
+// apply_chain_config thay cấu hình của chain đã có trạng thái; chain chưa chạy lần nào sẽ đọc
+// file khi khởi động
+func apply_chain_config(chainName string, cfg Config, filters []*compiledFilter) {
+	if chainData := GetChainData(chainName); chainData != nil {
+		set_chain_config(chainData, cfg, filters)
+	}
+}
+
+// unload_chain_config buộc lần khởi động sau đọc lại file cấu hình
+func unload_chain_config(chainName string) {
+	if chainData := GetChainData(chainName); chainData != nil {
+		chainData.mu.Lock()
+		chainData.configLoaded = false
+		chainData.mu.Unlock()
+	}
+}
+
+func set_chain_config(chainData *ChainData, cfg Config, filters []*compiledFilter) {
+	chainData.mu.Lock()
+	defer chainData.mu.Unlock()
+	chainData.Config = cfg
+	chainData.Filters = filters
+	chainData.configLoaded = true
 }
@@ -1,8 +1,10 @@
 package configs
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 
 	"main/services/get_chains/model"
@@ -17,6 +19,8 @@ func LoadConfig(filePath, chainName string) error {
 	}
 	defer file.Close()
 
+	model.ChainDataMapVanLock.Lock()
+	defer model.ChainDataMapVanLock.Unlock()
 	data, exists := model.ChainDataMapVan[chainName]
 	if !exists {
 		if chainName == "tezos" {
@@ -45,41 +49,68 @@ func LoadConfig(filePath, chainName string) error {
 		model.ChainDataMapVan[chainName] = data
 	}
 
-	// Decode cấu hình dựa trên loại chuỗi
-	if chainName == "tezos" {
+	config, err := decodeConfig(chainName, file)
+	if err != nil {
+		return err
+	}
+	data.SetConfigVan(config)
+	return nil
+}
+
+// ReloadConfig nạp lại cấu hình của chain đang chạy từ nội dung file mới. Nội dung lỗi
+// bị từ chối và cấu hình cũ được giữ; các handler đọc Config() mỗi lần gọi nên endpoint
+// mới được dùng ngay từ request kế tiếp.
+func ReloadConfig(chainName string, content []byte) error {
+	config, err := decodeConfig(chainName, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	model.ChainDataMapVanLock.Lock()
+	defer model.ChainDataMapVanLock.Unlock()
+	if data, exists := model.ChainDataMapVan[chainName]; exists {
+		data.SetConfigVan(config)
+	}
+	return nil
+}
+
+// decodeConfig decode cấu hình dựa trên loại chuỗi
+func decodeConfig(chainName string, r io.Reader) (interface{}, error) {
+	switch chainName {
+	case "tezos":
 		var config model.ConfigTezos
-		if err := json.NewDecoder(file).Decode(&config); err != nil {
-			return err
+		if err := json.NewDecoder(r).Decode(&config); err != nil {
+			return nil, err
 		}
-		data.SetConfigVan(config)
-	} else if chainName == "elrond" {
+		return config, nil
+	case "elrond":
 		var config model.ConfigElrond
-		if err := json.NewDecoder(file).Decode(&config); err != nil {
-			return err
+		if err := json.NewDecoder(r).Decode(&config); err != nil {
+			return nil, err
 		}
-		data.SetConfigVan(config)
-	} else if chainName == "algorand" {
+		return config, nil
+	case "algorand":
 		var config model.ConfigAlgorand
-		if err := json.NewDecoder(file).Decode(&config); err != nil {
-			return err
+		if err := json.NewDecoder(r).Decode(&config); err != nil {
+			return nil, err
 		}
-		data.SetConfigVan(config)
-	} else if chainName == "stellar" { // Thêm trường hợp stellar
+		return config, nil
+	case "stellar":
 		var config model.ConfigStellar
-		if err := json.NewDecoder(file).Decode(&config); err != nil {
-			return err
+		if err := json.NewDecoder(r).Decode(&config); err != nil {
+			return nil, err
 		}
 		if config.HorizonURL == "" {
-			return fmt.Errorf("horizon_url is empty in config file")
+			return nil, fmt.Errorf("horizon_url is empty in config file")
 		}
-		data.SetConfigVan(config)
+		return config, nil
 	}
-
-	return nil
+	return nil, fmt.Errorf("chain %s không được hỗ trợ", chainName)
 }
 
 // GetChainData lấy dữ liệu chuỗi từ ChainDataMap
 func GetChainData(chainName string) model.ChainDataVan {
+	model.ChainDataMapVanLock.Lock()
+	defer model.ChainDataMapVanLock.Unlock()
 	data, exists := model.ChainDataMapVan[chainName]
 	if !exists {
 		return nil
@@ -10,4 +10,9 @@ type Config struct {
 	TransferSignature   string `json:"transferSignature"`
 	Chain               string `json:"chain"`
 	TimeNeedToBlock     int    `json:"timeNeedToBlock"`
+
+	// Budget của provider cho endpoint RPC (0 = không giới hạn)
+	RequestsPerSecond     float64            `json:"requestsPerSecond"`
+	ComputeUnitsPerSecond float64            `json:"computeUnitsPerSecond"`
+	ComputeUnitCosts      map[string]float64 `json:"computeUnitCosts"`
 }
@@ -0,0 +1,79 @@
+package get_chains
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"main/services/pipeline"
+)
+
+// log_to_event chuyển một log EVM thành event chuẩn cho các processor
+func log_to_event(vLog *types.Log, chainName string, logMap map[string]interface{}) pipeline.Event {
+	ev := pipeline.Event{
+		Chain:       chainName,
+		BlockNumber: vLog.BlockNumber,
+		BlockHash:   vLog.BlockHash.Hex(),
+		TxHash:      vLog.TxHash.Hex(),
+		LogIndex:    vLog.Index,
+		Contract:    vLog.Address.Hex(),
+		Amount:      log_amount(vLog.Data),
+		Timestamp:   time.Now(),
+		Removed:     vLog.Removed,
+		Raw:         logMap,
+	}
+
+	if len(vLog.Topics) > 0 {
+		ev.EventSignature = vLog.Topics[0].Hex()
+	}
+	if len(vLog.Topics) > 1 {
+		ev.From = common.BytesToAddress(vLog.Topics[1].Bytes()).Hex()
+	}
+	if len(vLog.Topics) > 2 {
+		ev.To = common.BytesToAddress(vLog.Topics[2].Bytes()).Hex()
+	}
+	if rule, ok := logMap["rule"].(string); ok {
+		ev.Rule = rule
+	}
+	if txType, ok := logMap["transaction_type"].(string); ok {
+		ev.TransactionType = txType
+	}
+	if timestamp, ok := logMap["timestamp"].(string); ok {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", timestamp, time.Local); err == nil {
+			ev.Timestamp = t
+		}
+	}
+
+	return ev
+}
+
+// tx_map_to_event chuyển dữ liệu từ extractTransactionData thành event chuẩn
+func tx_map_to_event(logData map[string]interface{}) pipeline.Event {
+	ev := pipeline.Event{
+		Amount: new(big.Int),
+		Raw:    logData,
+	}
+
+	ev.Chain, _ = logData["name_chain"].(string)
+	ev.BlockNumber, _ = logData["block_number"].(uint64)
+	ev.TxHash, _ = logData["tx_hash"].(string)
+	ev.Contract, _ = logData["address"].(string)
+	ev.From, _ = logData["from_address"].(string)
+	ev.To, _ = logData["to_address"].(string)
+	ev.EventSignature, _ = logData["event_signature"].(string)
+	ev.TransactionType, _ = logData["transaction_type"].(string)
+	ev.Rule, _ = logData["rule"].(string)
+
+	if amount, ok := logData["amount"].(string); ok {
+		ev.Amount.SetString(amount, 10)
+	}
+	if timestamp, ok := logData["timestamp"].(string); ok {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", timestamp, time.Local); err == nil {
+			ev.Timestamp = t
+		}
+	}
+
+	return ev
+}
@@ -0,0 +1,309 @@
+package get_chains
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Selector của các hàm ERC20 dùng để giải mã người nhận và số lượng từ input
+const (
+	erc20TransferSelector     = "0xa9059cbb"
+	erc20TransferFromSelector = "0x23b872dd"
+)
+
+// compiledFilter là dạng đã biên dịch của FilterRule, dùng cho FilterQuery và bộ lọc cục bộ
+type compiledFilter struct {
+	label     string
+	contracts map[common.Address]bool
+	topics    [][]common.Hash
+	methods   map[string]bool
+	minAmount *big.Int
+	from      map[common.Address]bool
+	to        map[common.Address]bool
+}
+
+func compile_filter_rules(rules []FilterRule) ([]*compiledFilter, error) {
+	filters := make([]*compiledFilter, 0, len(rules))
+
+	for i, rule := range rules {
+		if len(rule.Topics) > 4 {
+			return nil, fmt.Errorf("rule %d (%s): tối đa 4 vị trí topic, nhận %d", i, rule.Label, len(rule.Topics))
+		}
+
+		f := &compiledFilter{
+			label:   rule.Label,
+			methods: make(map[string]bool),
+		}
+		if f.label == "" {
+			f.label = fmt.Sprintf("rule-%d", i)
+		}
+
+		var err error
+		if f.contracts, err = to_address_set(rule.Contracts); err != nil {
+			return nil, fmt.Errorf("rule %s: contracts: %w", f.label, err)
+		}
+		if f.from, err = to_address_set(rule.From); err != nil {
+			return nil, fmt.Errorf("rule %s: from: %w", f.label, err)
+		}
+		if f.to, err = to_address_set(rule.To); err != nil {
+			return nil, fmt.Errorf("rule %s: to: %w", f.label, err)
+		}
+
+		for _, position := range rule.Topics {
+			hashes := make([]common.Hash, 0, len(position))
+			for _, topic := range position {
+				hashes = append(hashes, common.HexToHash(topic))
+			}
+			f.topics = append(f.topics, hashes)
+		}
+
+		for _, method := range rule.Methods {
+			method = strings.ToLower(method)
+			if len(method) != 10 || !strings.HasPrefix(method, "0x") {
+				return nil, fmt.Errorf("rule %s: selector không hợp lệ %q", f.label, method)
+			}
+			f.methods[method] = true
+		}
+
+		if rule.MinAmount != "" {
+			minAmount, ok := new(big.Int).SetString(rule.MinAmount, 10)
+			if !ok {
+				return nil, fmt.Errorf("rule %s: minAmount không hợp lệ %q", f.label, rule.MinAmount)
+			}
+			f.minAmount = minAmount
+		}
+
+		filters = append(filters, f)
+	}
+
+	return filters, nil
+}
+
+// log_filters lọc ra các rule dựa trên event log; rule có methods chỉ áp dụng cho giao dịch
+func log_filters(filters []*compiledFilter) []*compiledFilter {
+	var result []*compiledFilter
+	for _, f := range filters {
+		if len(f.methods) == 0 {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+func has_log_filters(filters []*compiledFilter) bool {
+	return len(log_filters(filters)) > 0
+}
+
+// has_transaction_filters cho biết chain có rule theo selector (methods) cần duyệt từng giao dịch
+func has_transaction_filters(filters []*compiledFilter) bool {
+	for _, f := range filters {
+		if len(f.methods) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// to_address_set từ chối địa chỉ sai định dạng thay vì để HexToAddress đệm thành địa chỉ không bao giờ khớp
+func to_address_set(addresses []string) (map[common.Address]bool, error) {
+	set := make(map[common.Address]bool, len(addresses))
+	for _, addr := range addresses {
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("địa chỉ không hợp lệ %q", addr)
+		}
+		set[common.HexToAddress(addr)] = true
+	}
+	return set, nil
+}
+
+// filters_to_query gộp các rule thành một FilterQuery duy nhất.
+// Vị trí topic chỉ bị ràng buộc khi mọi rule đều ràng buộc vị trí đó,
+// phần còn lại được lọc lại bằng match_log.
+func filters_to_query(filters []*compiledFilter, fromBlock, toBlock *big.Int) ethereum.FilterQuery {
+	query := ethereum.FilterQuery{
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+	}
+
+	filters = log_filters(filters)
+
+	anyContract := false
+	seen := make(map[common.Address]bool)
+	for _, f := range filters {
+		if len(f.contracts) == 0 {
+			anyContract = true
+			break
+		}
+		for addr := range f.contracts {
+			if !seen[addr] {
+				seen[addr] = true
+				query.Addresses = append(query.Addresses, addr)
+			}
+		}
+	}
+	if anyContract {
+		query.Addresses = nil
+	}
+
+	for position := 0; position < 4; position++ {
+		var union []common.Hash
+		constrained := true
+		seenTopic := make(map[common.Hash]bool)
+
+		for _, f := range filters {
+			if position >= len(f.topics) || len(f.topics[position]) == 0 {
+				constrained = false
+				break
+			}
+			for _, topic := range f.topics[position] {
+				if !seenTopic[topic] {
+					seenTopic[topic] = true
+					union = append(union, topic)
+				}
+			}
+		}
+
+		if !constrained {
+			union = nil
+		}
+		query.Topics = append(query.Topics, union)
+	}
+
+	// Bỏ các vị trí wildcard ở cuối để query gọn hơn
+	for len(query.Topics) > 0 && len(query.Topics[len(query.Topics)-1]) == 0 {
+		query.Topics = query.Topics[:len(query.Topics)-1]
+	}
+
+	return query
+}
+
+// match_log trả về label của rule đầu tiên khớp với log
+func match_log(filters []*compiledFilter, vLog *types.Log) (string, bool) {
+	filters = log_filters(filters)
+	if len(filters) == 0 {
+		return "", true
+	}
+
+	var from, to common.Address
+	if len(vLog.Topics) > 1 {
+		from = common.BytesToAddress(vLog.Topics[1].Bytes())
+	}
+	if len(vLog.Topics) > 2 {
+		to = common.BytesToAddress(vLog.Topics[2].Bytes())
+	}
+	amount := log_amount(vLog.Data)
+
+	for _, f := range filters {
+		if len(f.contracts) > 0 && !f.contracts[vLog.Address] {
+			continue
+		}
+		if !f.match_topics(vLog.Topics) {
+			continue
+		}
+		if !f.match_parties(from, to, amount) {
+			continue
+		}
+		return f.label, true
+	}
+
+	return "", false
+}
+
+// match_transaction áp dụng các rule có khai báo methods cho giao dịch lấy từ eth_getBlockByNumber
+func match_transaction(filters []*compiledFilter, tx map[string]interface{}) (string, bool) {
+	input, _ := tx["input"].(string)
+	if len(input) < 10 {
+		return "", false
+	}
+	method := strings.ToLower(input[:10])
+
+	toHex, _ := tx["to"].(string)
+	fromHex, _ := tx["from"].(string)
+	contract := common.HexToAddress(toHex)
+	from := common.HexToAddress(fromHex)
+	to := contract
+
+	amount := new(big.Int)
+	if valueHex, _ := tx["value"].(string); len(valueHex) > 2 {
+		amount.SetString(valueHex[2:], 16)
+	}
+
+	args := input[10:]
+	switch method {
+	case erc20TransferSelector:
+		if len(args) >= 128 {
+			to = common.HexToAddress(args[24:64])
+			amount.SetString(args[64:128], 16)
+		}
+	case erc20TransferFromSelector:
+		if len(args) >= 192 {
+			from = common.HexToAddress(args[24:64])
+			to = common.HexToAddress(args[88:128])
+			amount.SetString(args[128:192], 16)
+		}
+	}
+
+	for _, f := range filters {
+		if len(f.methods) == 0 || !f.methods[method] {
+			continue
+		}
+		if len(f.contracts) > 0 && !f.contracts[contract] {
+			continue
+		}
+		if !f.match_parties(from, to, amount) {
+			continue
+		}
+		return f.label, true
+	}
+
+	return "", false
+}
+
+func (f *compiledFilter) match_topics(topics []common.Hash) bool {
+	for position, accepted := range f.topics {
+		if len(accepted) == 0 {
+			continue
+		}
+		if position >= len(topics) {
+			return false
+		}
+
+		found := false
+		for _, topic := range accepted {
+			if topics[position] == topic {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *compiledFilter) match_parties(from, to common.Address, amount *big.Int) bool {
+	if len(f.from) > 0 && !f.from[from] {
+		return false
+	}
+	if len(f.to) > 0 && !f.to[to] {
+		return false
+	}
+	if f.minAmount != nil && amount.Cmp(f.minAmount) < 0 {
+		return false
+	}
+	return true
+}
+
+// log_amount đọc word đầu tiên của data (giá trị của Transfer/Approval/Deposit/Withdrawal)
+func log_amount(data []byte) *big.Int {
+	if len(data) >= 32 {
+		return new(big.Int).SetBytes(data[:32])
+	}
+	return new(big.Int).SetBytes(data)
+}
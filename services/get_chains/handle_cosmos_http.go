@@ -1,6 +1,7 @@
 package get_chains
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,8 +11,14 @@ import (
 	"strconv"
 	"sync"
 	"time"
+
+	"main/services/poller"
+	"main/services/sink"
 )
 
+// Chu kỳ khối ước tính của Cosmos Hub, scheduler tự học lại từ dữ liệu thực
+const cosmosBlockTime = 6 * time.Second
+
 // Cấu trúc để lưu trữ lại khối xử lý cuối cùng
 type CosmosChainData struct {
 	LastProcessedBlock int64
@@ -196,31 +203,15 @@ func getCosmosTxsForBlock(height int64, rpcURL string) (*CosmosTxSearchResponse,
 	return &txResp, nil
 }
 
-// Ghi block vào file log - giữ nguyên
-func writeCosmosBlockToFile(height int64, block *CosmosBlockResponse, txCount int, chainName string) {
-	filePath := fmt.Sprintf("./services/get_chains/log/block_data_cosmos_%s.log", chainName)
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		log.Printf("Không thể mở file %s: %v", filePath, err)
-		return
-	}
-	defer file.Close()
-
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	blockData := fmt.Sprintf("[%s] Block: %d, Transactions: %d\n", timestamp, height, txCount)
-
-	blockJSON, err := json.MarshalIndent(block, "", "  ")
-	if err != nil {
-		log.Printf("Không thể chuyển đổi block thành JSON: %v", err)
-	} else {
-		blockData += string(blockJSON) + "\n----------------------------------------\n"
-	}
-
-	if _, err := file.WriteString(blockData); err != nil {
-		log.Printf("Không thể ghi vào file %s: %v", filePath, err)
-	} else {
-		log.Printf("Đã ghi khối %d (%d giao dịch) vào file cho chain %s", height, txCount, chainName)
-	}
+// Ghi block vào sink dữ liệu
+func writeCosmosBlockToSink(height int64, block *CosmosBlockResponse, chainName string) {
+	sink.Write(sink.Record{
+		Kind:  "block",
+		Chain: chainName,
+		Block: uint64(height),
+		Hash:  block.Result.BlockID.Hash,
+		Data:  block,
+	})
 }
 
 // Trích xuất dữ liệu giao dịch từ Cosmos - Sử dụng phương pháp chuyển đổi JSON
@@ -351,8 +342,7 @@ func processCosmosBlock(height int64, chainName string, rpcURL string) error {
 	log.Printf("Số lượng giao dịch trong block #%s: %d", block.Result.Block.Header.Height, txCount)
 	logMutex.Unlock()
 
-	// Ghi block vào file log
-	writeCosmosBlockToFile(height, block, txCount, chainName)
+	writeCosmosBlockToSink(height, block, chainName)
 
 	// Tạo danh sách các giao dịch - tương tự như trong handle_cosmos_ws.go
 	var transactions []TransactionRecord
@@ -390,10 +380,10 @@ func processCosmosBlock(height int64, chainName string, rpcURL string) error {
 }
 
 // Xử lý các block từ quá khứ đến hiện tại
-func continueHandleCosmosHTTP(chainName string) {
+func continueHandleCosmosHTTP(ctx context.Context, chainName string) error {
 	chainData := GetCosmosChainData(chainName)
 	if chainData == nil {
-		log.Fatalf("Không tìm thấy dữ liệu cho chain %s", chainName)
+		return fmt.Errorf("không tìm thấy dữ liệu cho chain %s", chainName)
 	}
 
 	// Khởi tạo block bắt đầu (có thể cấu hình trong file)
@@ -409,13 +399,17 @@ func continueHandleCosmosHTTP(chainName string) {
 
 	blockCounter := 0
 	currentBlock := startBlockNumber
+	scheduler := new_http_scheduler(chainName, chainData.Config.RPC, cosmosBlockTime)
 
-	for {
+	for ctx.Err() == nil {
 		blockCounter++
 
 		// Mỗi 50 khối, kiểm tra xem có bị bỏ lỡ khối nào không
 		if blockCounter%50 == 0 {
 			// Lấy block hiện tại từ API
+			if err := scheduler.Acquire(ctx, "status"); err != nil {
+				return nil
+			}
 			url := fmt.Sprintf("%s/status", chainData.Config.RPC)
 			resp, err := http.Get(url)
 
@@ -437,6 +431,7 @@ func continueHandleCosmosHTTP(chainName string) {
 					if json.Unmarshal(body, &statusResp) == nil {
 						latestBlock, err := strconv.ParseInt(statusResp.Result.SyncInfo.LatestBlockHeight, 10, 64)
 						if err == nil {
+							scheduler.ObserveHead(uint64(latestBlock))
 							gap := latestBlock - currentBlock
 							if gap > 20 {
 								// Có khoảng cách lớn, xử lý nhanh các khối bị bỏ lỡ
@@ -451,13 +446,23 @@ func continueHandleCosmosHTTP(chainName string) {
 								logMutex.Unlock()
 
 								for i := currentBlock; i <= nextBatchEnd; i++ {
+									// Đang tụt lại nên chỉ giới hạn bởi budget của endpoint
+									if err := scheduler.Acquire(ctx, "block"); err != nil {
+										return nil
+									}
 									if err := processCosmosBlock(i, chainName, chainData.Config.RPC); err != nil {
 										logMutex.Lock()
 										log.Printf("Lỗi khi xử lý khối %d: %v, tiếp tục...", i, err)
 										logMutex.Unlock()
+										if poller.IsRateLimited(err) {
+											scheduler.RateLimited()
+											if !sleep_ctx(ctx, scheduler.Next()) {
+												return nil
+											}
+										}
 									}
-									time.Sleep(50 * time.Millisecond)
 								}
+								scheduler.ObserveProcessed(uint64(nextBatchEnd))
 
 								currentBlock = nextBatchEnd + 1
 
@@ -479,6 +484,8 @@ func continueHandleCosmosHTTP(chainName string) {
 		// Xử lý khối tiếp theo
 		err := processCosmosBlock(currentBlock, chainName, chainData.Config.RPC)
 		if err == nil {
+			scheduler.Success()
+			scheduler.ObserveProcessed(uint64(currentBlock))
 			currentBlock++
 
 			cosmosProcessLock.Lock()
@@ -486,36 +493,48 @@ func continueHandleCosmosHTTP(chainName string) {
 				chainData.LastProcessedBlock = currentBlock - 1
 			}
 			cosmosProcessLock.Unlock()
-
-			time.Sleep(200 * time.Millisecond)
+		} else if poller.IsRateLimited(err) {
+			scheduler.RateLimited()
+			logMutex.Lock()
+			log.Printf("⏳ Bị giới hạn tốc độ khi lấy khối %d, giãn thời gian poll: %v", currentBlock, scheduler.Next())
+			logMutex.Unlock()
 		} else {
-			sleepTime := 500 * time.Millisecond
+			// Khối chưa có nghĩa là đã bắt kịp đầu chuỗi
+			scheduler.ObserveHead(uint64(currentBlock - 1))
 			logMutex.Lock()
-			log.Printf("Đợi %v trước khi thử lại khối %d", sleepTime, currentBlock)
+			log.Printf("Đợi %v trước khi thử lại khối %d", scheduler.Next(), currentBlock)
 			logMutex.Unlock()
-			time.Sleep(sleepTime)
+		}
+
+		if err := scheduler.Wait(ctx, "block"); err != nil {
+			return nil
 		}
 	}
+	return nil
 }
 
 // Xử lý các block từ hiện tại ngược về quá khứ
-func reverseHandleCosmosHTTP(chainName string, pastDuration time.Duration) {
+func reverseHandleCosmosHTTP(ctx context.Context, chainName string, pastDuration time.Duration) error {
 	chainData := GetCosmosChainData(chainName)
 	if chainData == nil {
-		log.Fatalf("Không tìm thấy dữ liệu cho chain %s", chainName)
+		return fmt.Errorf("không tìm thấy dữ liệu cho chain %s", chainName)
 	}
 
 	// Lấy block hiện tại
 	url := fmt.Sprintf("%s/status", chainData.Config.RPC)
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Fatalf("Lỗi khi lấy trạng thái chain: %v", err)
+		return fmt.Errorf("lỗi khi lấy trạng thái chain: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf("Lỗi khi đọc phản hồi: %v", err)
+		return fmt.Errorf("lỗi khi đọc phản hồi: %w", err)
 	}
 
 	var statusResp struct {
@@ -530,12 +549,12 @@ func reverseHandleCosmosHTTP(chainName string, pastDuration time.Duration) {
 	}
 
 	if err := json.Unmarshal(body, &statusResp); err != nil {
-		log.Fatalf("Lỗi khi parse JSON: %v", err)
+		return fmt.Errorf("lỗi khi parse JSON: %w", err)
 	}
 
 	latestBlock, err := strconv.ParseInt(statusResp.Result.SyncInfo.LatestBlockHeight, 10, 64)
 	if err != nil {
-		log.Fatalf("Lỗi khi chuyển đổi số khối: %v", err)
+		return fmt.Errorf("lỗi khi chuyển đổi số khối: %w", err)
 	}
 
 	latestBlockTime, err := time.Parse(time.RFC3339, statusResp.Result.SyncInfo.LatestBlockTime)
@@ -555,14 +574,25 @@ func reverseHandleCosmosHTTP(chainName string, pastDuration time.Duration) {
 
 	blockCounter := 0
 	currentBlock := latestBlock
+	scheduler := new_http_scheduler(chainName, chainData.Config.RPC, cosmosBlockTime)
 
-	for {
+	for ctx.Err() == nil {
 		blockCounter++
 
+		// Quét ngược là backfill nên chỉ bị giới hạn bởi budget của endpoint
+		if err := scheduler.Acquire(ctx, "block"); err != nil {
+			return nil
+		}
+
 		// Xử lý khối hiện tại
 		err := processCosmosBlock(currentBlock, chainName, chainData.Config.RPC)
 		if err == nil {
+			scheduler.Success()
+
 			// Lấy thông tin block để kiểm tra điều kiện dừng
+			if err := scheduler.Acquire(ctx, "block"); err != nil {
+				return nil
+			}
 			block, err := getCosmosBlock(currentBlock, chainData.Config.RPC)
 			if err == nil {
 				blockTime := block.Result.Block.Header.Time
@@ -590,14 +620,17 @@ func reverseHandleCosmosHTTP(chainName string, pastDuration time.Duration) {
 				log.Printf("🔄 Đã quét ngược %d khối, hiện tại ở khối %d", blockCounter, currentBlock)
 				logMutex.Unlock()
 			}
-
-			time.Sleep(200 * time.Millisecond)
 		} else {
-			sleepTime := 500 * time.Millisecond
+			if poller.IsRateLimited(err) {
+				scheduler.RateLimited()
+			}
+			sleepTime := max(scheduler.Next(), 500*time.Millisecond)
 			logMutex.Lock()
 			log.Printf("Đợi %v trước khi thử lại khối %d", sleepTime, currentBlock)
 			logMutex.Unlock()
-			time.Sleep(sleepTime)
+			if !sleep_ctx(ctx, sleepTime) {
+				return nil
+			}
 		}
 
 		// Dừng khi đã quét đến khối 1
@@ -612,21 +645,13 @@ func reverseHandleCosmosHTTP(chainName string, pastDuration time.Duration) {
 	logMutex.Lock()
 	log.Printf("✅ Hoàn thành xử lý các block ngược cho chain %s", chainName)
 	logMutex.Unlock()
+	return nil
 }
 
-func handle_cosmos_http() {
+// handle_cosmos_http quét ngược 1 giờ rồi theo dõi khối mới tới khi ctx bị hủy
+func handle_cosmos_http(ctx context.Context) error {
 	chainName := "cosmos"
 
-	// Mở file log giống như trong handle_cosmos_ws.go
-	logFile, err := os.OpenFile("./services/get_chains/log/cosmos_http.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatalf("Không thể mở file log: %v", err)
-	}
-	defer logFile.Close()
-
-	// Chuyển hướng log vào file
-	log.SetOutput(logFile)
-
 	// Khởi tạo chainData và sử dụng ngay sau khi khai báo
 	chainData := InitCosmosChainData(chainName)
 	log.Printf("Khởi tạo dữ liệu cho chain %s, khối cuối cùng: %d",
@@ -634,7 +659,7 @@ func handle_cosmos_http() {
 
 	// Tải cấu hình
 	if err := load_cosmos_config("./services/get_chains/config_chain/config-cosmos.json", chainName); err != nil {
-		log.Fatalf("Không thể tải cấu hình: %v", err)
+		return fmt.Errorf("không thể tải cấu hình: %w", err)
 	}
 
 	// Xử lý các khối từ giờ trở về 1 giờ trước
@@ -646,12 +671,14 @@ func handle_cosmos_http() {
 	logMutex.Unlock()
 
 	// Bắt đầu quét ngược từ hiện tại về quá khứ
-	reverseHandleCosmosHTTP(chainName, pastDuration)
+	if err := reverseHandleCosmosHTTP(ctx, chainName, pastDuration); err != nil {
+		return err
+	}
 
 	logMutex.Lock()
 	log.Printf("Bước 2: Tiếp tục quét từ quá khứ đến hiện tại và theo dõi các khối mới")
 	logMutex.Unlock()
 
 	// Sau đó tiếp tục quét từ quá khứ đến hiện tại
-	continueHandleCosmosHTTP(chainName)
+	return continueHandleCosmosHTTP(ctx, chainName)
 }
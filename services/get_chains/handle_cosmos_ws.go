@@ -8,13 +8,14 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/signal"
 	"regexp"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"main/services/health"
+	"main/services/metrics"
 )
 
 // Config cấu trúc để lưu trữ dữ liệu từ file config
@@ -56,35 +57,26 @@ type TransactionRecord struct {
 // Mutex để đồng bộ hóa ghi log
 var logMutex sync.Mutex
 
-func handle_cosmos_ws() {
-	// Mở file log
-	logFile, err := os.OpenFile("./services/get_chains/log/cosmos_ws.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatalf("Không thể mở file log: %v", err)
-	}
-	defer logFile.Close()
-
-	// Chuyển hướng log vào file
-	log.SetOutput(logFile)
-
+// handle_cosmos_ws đọc block mới tới khi ctx bị hủy; trả lỗi khi socket hỏng để supervisor kết nối lại
+func handle_cosmos_ws(ctx context.Context) error {
 	// Đọc file cấu hình
 	configFile, err := os.ReadFile("./services/get_chains/config_chain/config-cosmos.json")
 	if err != nil {
-		log.Fatalf("Không thể đọc file cấu hình: %v", err)
+		return fmt.Errorf("không thể đọc file cấu hình: %w", err)
 	}
 
 	// Parse cấu hình
 	var ConfigCosmos ConfigCosmos
 	if err := json.Unmarshal(configFile, &ConfigCosmos); err != nil {
-		log.Fatalf("Không thể parse file cấu hình: %v", err)
+		return fmt.Errorf("không thể parse file cấu hình: %w", err)
 	}
 
 	log.Printf("Kết nối đến blockchain %s qua WebSocket: %s\n", ConfigCosmos.Chain, ConfigCosmos.WssRpc)
 
 	// Kết nối WebSocket
-	c, _, err := websocket.DefaultDialer.Dial(ConfigCosmos.WssRpc, nil)
+	c, _, err := websocket.DefaultDialer.DialContext(ctx, ConfigCosmos.WssRpc, nil)
 	if err != nil {
-		log.Fatalf("Không thể kết nối WebSocket: %v", err)
+		return fmt.Errorf("không thể kết nối WebSocket: %w", err)
 	}
 	defer c.Close()
 
@@ -97,26 +89,20 @@ func handle_cosmos_ws() {
 	}
 
 	if err := c.WriteJSON(subscribeMsg); err != nil {
-		log.Fatalf("Không thể gửi yêu cầu đăng ký: %v", err)
+		return fmt.Errorf("không thể gửi yêu cầu đăng ký: %w", err)
 	}
 
-	// Xử lý tín hiệu để thoát chương trình một cách an toàn
-	ctx, cancel := context.WithCancel(context.Background())
+	// ctx cha bị hủy hoặc socket hỏng đều dừng vòng đọc
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		<-sigCh
-		log.Println("Nhận tín hiệu thoát, đang đóng kết nối...")
-		cancel()
-	}()
+	var readErr error
+	readDone := make(chan struct{})
 
 	log.Println("Đang lắng nghe các block mới...")
 
 	// Lắng nghe các block mới
 	go func() {
+		defer close(readDone)
 		for {
 			select {
 			case <-ctx.Done():
@@ -124,19 +110,31 @@ func handle_cosmos_ws() {
 			default:
 				_, message, err := c.ReadMessage()
 				if err != nil {
-					log.Printf("Lỗi khi đọc tin nhắn: %v", err)
-					time.Sleep(time.Second)
-					continue
+					if ctx.Err() != nil {
+						return
+					}
+					// Kết nối đã hỏng: dừng để supervisor kết nối lại thay vì đọc mãi trên socket chết
+					log.Printf("Lỗi khi đọc tin nhắn: %v, kết nối lại", err)
+					metrics.WSReconnects.WithLabelValues("cosmos").Inc()
+					health.Stream("cosmos").Fail(err)
+					readErr = err
+					cancel()
+					return
 				}
 
 				// Xử lý thông điệp block
+				health.Stream("cosmos").Event()
 				processBlockData(message, ConfigCosmos.Chain)
 			}
 		}
 	}()
 
 	<-ctx.Done()
-	log.Println("Chương trình đã kết thúc")
+	// Đóng socket để goroutine đang chờ ReadMessage thoát ra
+	c.Close()
+	<-readDone
+	log.Println("Đã dừng lắng nghe cosmos")
+	return readErr
 }
 
 // Xử lý dữ liệu block nhận được
@@ -2,20 +2,30 @@ package get_chains
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"math/big"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
+
+	"main/services/headercache"
+	"main/services/logging"
+	"main/services/metrics"
+	"main/services/pipeline"
+	"main/services/poller"
+	"main/services/sink"
 )
 
 func extractTransactionData(tx map[string]interface{}, chainName string, blockTime string) map[string]interface{} {
+	return extract_transaction_data(tx, chainName, blockTime, Parse_event_signature_name)
+}
+
+// extract_transaction_data như extractTransactionData nhưng tên của signature lấy qua resolve
+func extract_transaction_data(tx map[string]interface{}, chainName string, blockTime string, resolve func(string) (string, error)) map[string]interface{} {
 	logData := make(map[string]interface{})
 
 	// Lấy dữ liệu cơ bản từ transaction
@@ -53,10 +63,10 @@ func extractTransactionData(tx map[string]interface{}, chainName string, blockTi
 		logData["event_signature"] = input[:10]
 
 		// Phân tích event signature để lấy transaction_type
-		if transactionType, err := Parse_event_signature_name(input[:10]); err == nil {
+		if transactionType, err := resolve(input[:10]); err == nil {
 			logData["transaction_type"] = transactionType
 		} else {
-			log.Printf("Không thể parse event signature %s: %v", input[:10], err)
+			logging.Chain("get_chains", chainName).Debug("Không thể parse event signature", "signature", input[:10], "tx", txHash, "error", err)
 			logData["transaction_type"] = "Unknown"
 		}
 	} else {
@@ -67,157 +77,61 @@ func extractTransactionData(tx map[string]interface{}, chainName string, blockTi
 	return logData
 }
 
-func importLogFileToDatabase(logFilePath string, chainName string) error {
-	log.Printf("🔄 Bắt đầu nhập dữ liệu từ file %s vào database...", logFilePath)
-
-	data, err := os.ReadFile(logFilePath)
-	if err != nil {
-		return fmt.Errorf("không thể đọc file log: %v", err)
-	}
-
-	// Tách file thành các block riêng biệt
-	blocks := strings.Split(string(data), "----------------------------------------")
-
-	for _, blockData := range blocks {
-		if strings.TrimSpace(blockData) == "" {
-			continue
-		}
-
-		lines := strings.Split(blockData, "\n")
-		if len(lines) < 2 {
-			continue
-		}
-
-		// Parse dòng header để lấy thông tin block
-		headerLine := strings.TrimSpace(lines[0])
-		headerParts := strings.Split(headerLine, "] ")
-		if len(headerParts) < 2 {
-			continue
-		}
-
-		timestamp := strings.Trim(headerParts[0], "[")
-		blockInfo := headerParts[1]
-
-		blockNumberStr := ""
-		if parts := strings.Split(blockInfo, ", "); len(parts) > 0 {
-			blockNumberStr = strings.TrimPrefix(parts[0], "Block: ")
-		}
-
-		// Parse JSON block data
-		var block map[string]interface{}
-		jsonStart := strings.Index(blockData, "{")
-		if jsonStart == -1 {
-			continue
-		}
-
-		jsonData := blockData[jsonStart:]
-		if err := json.Unmarshal([]byte(jsonData), &block); err != nil {
-			log.Printf("Lỗi khi parse JSON của block: %v", err)
-			continue
-		}
-
-		// Lấy transactions từ block
-		transactions, ok := block["transactions"].([]interface{})
-		if !ok {
-			continue
-		}
-
-		for _, tx := range transactions {
-			txMap, ok := tx.(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			// Trích xuất dữ liệu transaction
-			logData := extractTransactionData(txMap, chainName, timestamp)
-			blockNumber, _ := new(big.Int).SetString(blockNumberStr, 10)
-			if blockNumber != nil {
-				logData["block_number"] = blockNumber.Uint64()
-			}
-		}
-	}
-
-	log.Printf("✅ Hoàn thành nhập dữ liệu từ file %s vào database", logFilePath)
-	return nil
-}
-
-// Hàm ghi block vào file
-func write_block_to_file(blockNumber *big.Int, block map[string]interface{}, txCount int, chainName string) {
-	filePath := fmt.Sprintf("./services/get_chains/block_data_%s.log", chainName)
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		log.Printf("Không thể mở file %s: %v", filePath, err)
-		return
-	}
-	defer file.Close()
-
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	blockData := fmt.Sprintf("[%s] Block: %s, Transactions: %d\n", timestamp, blockNumber.String(), txCount)
-
-	blockJSON, err := json.MarshalIndent(block, "", "  ")
-	if err != nil {
-		log.Printf("Không thể chuyển đổi block thành JSON: %v", err)
-	} else {
-		blockData += string(blockJSON) + "\n----------------------------------------\n"
-	}
-
-	if _, err := file.WriteString(blockData); err != nil {
-		log.Printf("Không thể ghi vào file %s: %v", filePath, err)
-	} else {
-		log.Printf("Đã ghi khối %s (%d giao dịch) vào file cho chain %s", blockNumber.String(), txCount, chainName)
-	}
+// Hàm ghi block vào sink dữ liệu (không ghi vào file log)
+func write_block_to_sink(blockNumber *big.Int, block map[string]interface{}, txCount int, chainName string) {
+	blockHash, _ := block["hash"].(string)
+	sink.Write(sink.Record{
+		Kind:  "block",
+		Chain: chainName,
+		Block: blockNumber.Uint64(),
+		Hash:  blockHash,
+		Data:  block,
+	})
+	logging.Chain("get_chains", chainName).Debug("Đã ghi khối vào sink", "block", blockNumber.Uint64(), "tx_count", txCount)
 }
 
-// Hàm ghi transaction vào file
-func writeTransactionToFile(tx map[string]interface{}) {
+// Hàm ghi transaction vào sink dữ liệu
+func write_transaction_to_sink(tx map[string]interface{}, blockNumber *big.Int, chainName string) {
 	txHash, _ := tx["hash"].(string)
 	if txHash == "" {
-		log.Printf("Bỏ qua ghi log cho giao dịch không có hash")
+		logging.Chain("get_chains", chainName).Debug("Bỏ qua giao dịch không có hash", "block", blockNumber.Uint64())
 		return
 	}
 
-	filePath := "./services/get_chains/log/transactions.log"
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		log.Printf("Không thể mở file %s: %v", filePath, err)
-		return
-	}
-	defer file.Close()
-
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	txData := fmt.Sprintf("[%s] Transaction: %s\n", timestamp, txHash)
-
-	txJSON, err := json.MarshalIndent(tx, "", "  ")
-	if err != nil {
-		log.Printf("Không thể chuyển đổi transaction thành JSON: %v", err)
-	} else {
-		txData += string(txJSON) + "\n----------------------------------------\n"
-	}
-
-	if _, err := file.WriteString(txData); err != nil {
-		log.Printf("Không thể ghi vào file %s: %v", filePath, err)
-	} else {
-		log.Printf("Đã ghi giao dịch %s vào file", txHash)
-	}
+	sink.Write(sink.Record{
+		Kind:   "transaction",
+		Chain:  chainName,
+		Block:  blockNumber.Uint64(),
+		TxHash: txHash,
+		Data:   tx,
+	})
 }
 
+// errBlockNotFound: node trả về null cho eth_getBlockByNumber, tức khối chưa được tạo
+var errBlockNotFound = errors.New("khối chưa có trên node")
+
 // Xử lý block
-func processBlock(client *rpc.Client, blockNumber *big.Int, chainName string) error {
+func processBlock(ctx context.Context, client *rpc.Client, blockNumber *big.Int, chainName string) error {
 	var block map[string]interface{}
 	blockHex := fmt.Sprintf("0x%x", blockNumber)
+	logger := logging.Chain("get_chains", chainName).With("block", blockNumber.Uint64())
 
-	log.Printf("🔍 Đang lấy thông tin khối: %s", blockHex)
+	logger.Debug("🔍 Đang lấy thông tin khối")
 
+	start := time.Now()
 	err := client.Call(&block, "eth_getBlockByNumber", blockHex, true)
+	metrics.ObserveRPC(rpc_endpoint(chainName), "eth_getBlockByNumber", start, err)
 	if err != nil {
-		log.Printf("❌ Lỗi khi lấy khối %s: %v", blockHex, err)
+		logger.Error("❌ Lỗi khi lấy khối", "error", err)
 		return err
 	}
 
-	log.Printf("✅ Đã lấy khối: %s - %s", blockHex, blockNumber.String())
-
+	if block == nil {
+		logger.Debug("⏳ Khối chưa có trên node")
+		return errBlockNotFound
+	}
 	if block["transactions"] == nil {
-		log.Printf("⚠️ Khối %s không có giao dịch nào", blockHex)
+		logger.Warn("⚠️ Khối không có giao dịch nào")
 		return fmt.Errorf("không tìm thấy giao dịch")
 	}
 
@@ -231,48 +145,59 @@ func processBlock(client *rpc.Client, blockNumber *big.Int, chainName string) er
 		blockTimeInt.SetString(blockTimeHex[2:], 16)
 	}
 
-	write_block_to_file(blockNumber, block, txCount, chainName)
+	if header, ok := headercache.FromBlockMap(block); ok {
+		headercache.ForChain(chainName).Put(header)
+	}
 
-	log.Printf("🔄 Đang xử lý %d giao dịch từ khối %s", txCount, blockNumber.String())
+	write_block_to_sink(blockNumber, block, txCount, chainName)
 
-	for i, tx := range transactions {
+	logger.Debug("🔄 Đang xử lý giao dịch của khối", "tx_count", txCount)
+
+	chainData := GetChainData(chainName)
+	// Chỉ duyệt từng giao dịch khi có rule theo selector; transfer mặc định đến từ log
+	matchTxs := chainData != nil && has_transaction_filters(chainData.Filters)
+	for _, tx := range transactions {
 		txMap := tx.(map[string]interface{})
-		txHash, _ := txMap["hash"].(string)
-		log.Printf("   📝 Xử lý giao dịch (%d/%d): %s", i+1, txCount, txHash)
 
-		// Vẫn giữ lại việc ghi log ra file nếu cần
-		writeTransactionToFile(txMap)
+		write_transaction_to_sink(txMap, blockNumber, chainName)
+		if matchTxs {
+			txMap["timestamp"] = blockTimeHex
+			processTransaction(txMap, chainName)
+		}
 	}
 
-	log.Printf("✅ Hoàn thành xử lý khối %s - %s", blockHex, blockNumber.String())
-	return nil
-}
+	if chainData != nil && has_log_filters(chainData.Filters) {
+		process_block_logs(ctx, client, blockNumber, chainName)
+	}
 
-func importAllLogFiles() {
-	chains := []string{"bsc", "avalanche"}
+	metrics.BlocksProcessed.WithLabelValues(chainName).Inc()
 
-	for _, chain := range chains {
-		logFilePath := fmt.Sprintf("./services/get_chains/log/block_data_%s.log", chain)
-		if _, err := os.Stat(logFilePath); err == nil {
-			log.Printf("Tìm thấy file log cho chain %s, bắt đầu nhập dữ liệu...", chain)
-			if err := importLogFileToDatabase(logFilePath, chain); err != nil {
-				log.Printf("❌ Lỗi khi nhập dữ liệu từ %s: %v", logFilePath, err)
-			}
-		}
-	}
+	blockHash, _ := block["hash"].(string)
+	pipeline.DispatchBlock(pipeline.Block{
+		Chain:     chainName,
+		Number:    blockNumber.Uint64(),
+		Hash:      blockHash,
+		Timestamp: time.Unix(blockTimeInt.Int64(), 0),
+		TxCount:   txCount,
+	})
+
+	logger.Info("✅ Hoàn thành xử lý khối", "tx_count", txCount, "hash", blockHash)
+	return nil
 }
 
 // Xử lý transaction
 func processTransaction(tx map[string]interface{}, chainName string) {
+	logger := logging.Chain("get_chains", chainName)
+
 	to, ok := tx["to"].(string)
 	if !ok || to == "" {
-		log.Printf("   ⏩ Bỏ qua giao dịch tạo hợp đồng")
+		logger.Debug("⏩ Bỏ qua giao dịch tạo hợp đồng")
 		return
 	}
 
 	txHash, ok := tx["hash"].(string)
 	if !ok || txHash == "" {
-		log.Printf("   ⏩ Bỏ qua giao dịch không hợp lệ")
+		logger.Debug("⏩ Bỏ qua giao dịch không hợp lệ")
 		return
 	}
 
@@ -286,33 +211,64 @@ func processTransaction(tx map[string]interface{}, chainName string) {
 	input, _ := tx["input"].(string)
 	from, _ := tx["from"].(string)
 
-	log.Printf("   💼 Giao dịch: %s", txHash)
-	log.Printf("   📤 Từ: %s", from)
-	log.Printf("   📥 Đến: %s", toAddress)
-	log.Printf("   💰 Giá trị: %s wei", value.String())
+	logger = logger.With("tx", txHash)
+	logger.Debug("💼 Giao dịch", "from", from, "to", toAddress, "value_wei", value.String())
 
 	chainData := GetChainData(chainName)
 	if chainData == nil {
-		log.Printf("Không tìm thấy dữ liệu cho chain %s", chainName)
+		logger.Warn("Không tìm thấy dữ liệu cho chain")
 		return
 	}
 
-	if len(input) >= 10 && input[:10] == chainData.Config.TransferSignature {
-		log.Printf("💰 GIAO DỊCH TRANSFER PHÁT HIỆN 💰")
-		log.Printf("   Chain: %s", chainName)
-		log.Printf("   Contract: %s", toAddress)
-		log.Printf("   TxHash: %s", txHash)
-		log.Printf("   Input: %s", input[:20]+"...")
+	if len(chainData.Filters) > 0 {
+		if rule, ok := match_transaction(chainData.Filters, tx); ok {
+			logger.Info("🎯 Giao dịch khớp rule", "rule", rule, "contract", toAddress)
+			tx["rule"] = rule
+			process_transfer_and_save(chainName, tx)
+		}
+		return
+	}
 
+	if len(input) >= 10 && input[:10] == chainData.Config.TransferSignature {
+		logger.Info("💰 Phát hiện giao dịch transfer", "contract", toAddress)
 		process_transfer_and_save(chainName, tx)
 	}
 }
 
+// Lấy các event trong một khối và đưa qua pipeline của chain như log từ subscription;
+// stage decode lọc theo filter rule và dedup
+func process_block_logs(ctx context.Context, client *rpc.Client, blockNumber *big.Int, chainName string) {
+	logger := logging.Chain("get_chains", chainName).With("block", blockNumber.Uint64())
+
+	query, err := create_query(chainName, blockNumber, blockNumber)
+	if err != nil {
+		logger.Error("❌ Không thể tạo query lấy logs", "error", err)
+		return
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	start := time.Now()
+	logs, err := ethclient.NewClient(client).FilterLogs(queryCtx, query)
+	cancel()
+	metrics.ObserveRPC(rpc_endpoint(chainName), "eth_getLogs", start, err)
+	if err != nil {
+		logger.Error("❌ Lỗi khi lấy logs của khối", "error", err)
+		return
+	}
+
+	for _, vLog := range logs {
+		if err := submit_log(ctx, chainName, vLog); err != nil {
+			logger.Warn("⚠️ Không thể đưa log vào pipeline", "tx", vLog.TxHash.Hex(), "error", err)
+			return
+		}
+	}
+}
+
 // Xử lý và lưu thông tin transfer
 func process_transfer_and_save(chainName string, tx map[string]interface{}) {
 	chainData := GetChainData(chainName)
 	if chainData == nil {
-		log.Printf("Không tìm thấy dữ liệu cho chain %s", chainName)
+		logging.Chain("get_chains", chainName).Warn("Không tìm thấy dữ liệu cho chain")
 		return
 	}
 
@@ -329,9 +285,17 @@ func process_transfer_and_save(chainName string, tx map[string]interface{}) {
 
 	// Trích xuất dữ liệu
 	logData := extractTransactionData(tx, chainName, blockTime)
+	if rule, ok := tx["rule"].(string); ok {
+		logData["rule"] = rule
+	}
 
 	// Cập nhật LogData của chain
+	chainData.mu.Lock()
 	chainData.LogData = logData
+	chainData.mu.Unlock()
+
+	metrics.TransfersProcessed.WithLabelValues(chainName).Inc()
+	pipeline.Dispatch(tx_map_to_event(logData))
 }
 
 // Hàm duyệt ngược từ thời gian hiện tại về quá khứ
@@ -361,7 +325,7 @@ func processBlocksInReverse(client *rpc.Client, pastDuration time.Duration) (map
 
 		err := client.Call(&block, "eth_getBlockByNumber", blockHex, true)
 		if err != nil {
-			log.Printf("❌ Lỗi khi lấy khối %s: %v", blockHex, err)
+			logging.Service("get_chains").Error("❌ Lỗi khi lấy khối", "block", latestBlock.Uint64(), "error", err)
 			break
 		}
 
@@ -372,7 +336,7 @@ func processBlocksInReverse(client *rpc.Client, pastDuration time.Duration) (map
 			blockTimeInt.SetString(blockTimeHex[2:], 16)
 		}
 		blockTime := time.Unix(blockTimeInt.Int64(), 0)
-		fmt.Printf("Thời gian của block: %s", blockTime)
+		logging.Service("get_chains").Debug("Thời gian của block", "block", latestBlock.Uint64(), "time", blockTime)
 
 		// Kiểm tra nếu blockTime nhỏ hơn hoặc bằng targetTime thì dừng
 		if blockTime.Before(targetTime) || blockTime.Equal(targetTime) {
@@ -389,11 +353,35 @@ func processBlocksInReverse(client *rpc.Client, pastDuration time.Duration) (map
 	return blockDataMap, nil
 }
 
+// new_chain_scheduler tạo scheduler poll cho chain, chu kỳ ban đầu lấy từ TimeNeedToBlock
+func new_chain_scheduler(chainName string, config Config) *poller.Scheduler {
+	return poller.NewScheduler(chainName, poller.Config{
+		InitialInterval:       time.Duration(config.TimeNeedToBlock) * time.Millisecond,
+		Endpoint:              config.RPC,
+		RequestsPerSecond:     config.RequestsPerSecond,
+		ComputeUnitsPerSecond: config.ComputeUnitsPerSecond,
+		ComputeUnitCosts:      config.ComputeUnitCosts,
+	})
+}
+
+// Số request/giây mặc định tới một endpoint HTTP khi cấu hình chain không khai báo budget
+const defaultRequestsPerSecond = 10
+
+// new_http_scheduler tạo scheduler cho scanner HTTP của chain không phải EVM (cosmos, tron,
+// vechain): chu kỳ ban đầu là blockTime, budget mặc định thay cho độ trễ cố định
+func new_http_scheduler(chainName, endpoint string, blockTime time.Duration) *poller.Scheduler {
+	return poller.NewScheduler(chainName, poller.Config{
+		InitialInterval:   blockTime,
+		Endpoint:          endpoint,
+		RequestsPerSecond: defaultRequestsPerSecond,
+	})
+}
+
 // Khởi động xử lý HTTP
-func continueHandleHTTP(client *ethclient.Client, chainName string) {
+func continueHandleHTTP(ctx context.Context, client *ethclient.Client, chainName string) error {
 	chainData := GetChainData(chainName)
 	if chainData == nil {
-		log.Fatalf("Không tìm thấy dữ liệu cho chain %s", chainName)
+		return fmt.Errorf("không tìm thấy dữ liệu cho chain %s", chainName)
 	}
 
 	// Khởi tạo block bắt đầu (có thể cấu hình trong file)
@@ -401,28 +389,40 @@ func continueHandleHTTP(client *ethclient.Client, chainName string) {
 	chainData.LastProcessedBlock = new(big.Int).Set(blockNumber)
 	chainData.LastProcessedBlock.Sub(chainData.LastProcessedBlock, big.NewInt(1))
 
-	log.Printf("======= KHỞI ĐỘNG HỆ THỐNG CHO CHAIN %s =======", chainName)
-	log.Printf("Khởi tạo lastProcessedBlock = %d", chainData.LastProcessedBlock)
-	log.Printf("Bắt đầu quét từ khối = %d", blockNumber)
-	log.Printf("TransferSignature: %s", chainData.Config.TransferSignature)
-	log.Printf("==================================")
+	logger := logging.Chain("get_chains", chainName)
+	logger.Info("======= KHỞI ĐỘNG HỆ THỐNG =======", "block", blockNumber.Uint64(), "transfer_signature", chainData.Config.TransferSignature)
 
 	blockCounter := 0
 	rpcClient := client.Client()
+	scheduler := new_chain_scheduler(chainName, chainData.Config)
 
 	for {
+		if ctx.Err() != nil {
+			return nil
+		}
 		blockCounter++
 
 		// Mỗi 50 khối, kiểm tra xem có bị bỏ lỡ khối nào không
 		if blockCounter%50 == 0 {
 			var latestBlockHex string
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			err := rpcClient.CallContext(ctx, &latestBlockHex, "eth_blockNumber")
+			if err := scheduler.Acquire(ctx, "eth_blockNumber"); err != nil {
+				return nil
+			}
+			callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			start := time.Now()
+			err := rpcClient.CallContext(callCtx, &latestBlockHex, "eth_blockNumber")
 			cancel()
+			metrics.ObserveRPC(chainData.Config.RPC, "eth_blockNumber", start, err)
+
+			if poller.IsRateLimited(err) {
+				scheduler.RateLimited()
+			}
 
 			if err == nil {
 				latestBlock := new(big.Int)
 				latestBlock.SetString(latestBlockHex[2:], 16)
+				scheduler.ObserveHead(latestBlock.Uint64())
+				observe_head(chainName, latestBlock.Uint64())
 
 				gap := new(big.Int).Sub(latestBlock, blockNumber)
 				if gap.Cmp(big.NewInt(20)) > 0 {
@@ -432,57 +432,75 @@ func continueHandleHTTP(client *ethclient.Client, chainName string) {
 						nextBatchEnd.Set(latestBlock)
 					}
 
-					log.Printf("⚠️ Phát hiện khoảng cách %d khối trên chain %s. Đang quét nhanh từ %d đến %d...",
-						gap, chainName, blockNumber, nextBatchEnd)
+					logger.Warn("⚠️ Phát hiện khoảng cách khối, đang quét nhanh", "gap", gap, "from", blockNumber.Uint64(), "to", nextBatchEnd.Uint64())
 
 					for i := new(big.Int).Set(blockNumber); i.Cmp(nextBatchEnd) <= 0; i.Add(i, big.NewInt(1)) {
-						if err := processBlock(rpcClient, i, chainName); err != nil {
-							log.Printf("Lỗi khi xử lý khối %d: %v, tiếp tục...", i, err)
+						// Đang tụt lại nên chỉ giới hạn bởi budget của endpoint
+						if err := scheduler.Acquire(ctx, "eth_getBlockByNumber"); err != nil {
+							return nil
+						}
+						if err := processBlock(ctx, rpcClient, i, chainName); err != nil {
+							logger.Warn("Lỗi khi xử lý khối, tiếp tục...", "block", i.Uint64(), "error", err)
+							if poller.IsRateLimited(err) {
+								scheduler.RateLimited()
+								if !sleep_ctx(ctx, scheduler.Next()) {
+									return nil
+								}
+							}
 						}
-						time.Sleep(50 * time.Millisecond)
 					}
+					scheduler.ObserveProcessed(nextBatchEnd.Uint64())
+					observe_processed(chainName, nextBatchEnd.Uint64())
 
 					blockNumber.Set(nextBatchEnd)
 					blockNumber.Add(blockNumber, big.NewInt(1))
 
-					processLock.Lock()
+					chainData.mu.Lock()
 					chainData.LastProcessedBlock.Set(nextBatchEnd)
-					processLock.Unlock()
+					advance_checkpoint(chainData, nextBatchEnd.Uint64())
+					chainData.mu.Unlock()
 
-					log.Printf("✅ Đã quét nhanh đến khối %d", nextBatchEnd)
+					logger.Info("✅ Đã quét nhanh đến khối", "block", nextBatchEnd.Uint64())
 					continue
 				}
 			}
 		}
 
 		// Xử lý khối tiếp theo
-		err := processBlock(rpcClient, blockNumber, chainName)
+		err := processBlock(ctx, rpcClient, blockNumber, chainName)
 		if err == nil {
+			scheduler.Success()
+			scheduler.ObserveProcessed(blockNumber.Uint64())
+			observe_processed(chainName, blockNumber.Uint64())
 			blockNumber = new(big.Int).Add(blockNumber, big.NewInt(1))
 
-			processLock.Lock()
+			chainData.mu.Lock()
 			if blockNumber.Cmp(chainData.LastProcessedBlock) > 0 {
 				chainData.LastProcessedBlock = new(big.Int).Set(blockNumber)
 				chainData.LastProcessedBlock.Sub(chainData.LastProcessedBlock, big.NewInt(1))
 			}
-			processLock.Unlock()
-
-			time.Sleep(200 * time.Millisecond)
+			advance_checkpoint(chainData, blockNumber.Uint64()-1)
+			chainData.mu.Unlock()
+		} else if errors.Is(err, errBlockNotFound) {
+			// Khối chưa có nghĩa là đã bắt kịp đầu chuỗi
+			scheduler.ObserveHead(blockNumber.Uint64() - 1)
+			logger.Debug("Đợi trước khi thử lại khối", "block", blockNumber.Uint64(), "delay", scheduler.Next())
 		} else {
-			sleepTime := time.Duration(chainData.Config.TimeNeedToBlock) * time.Millisecond
-			if sleepTime < 500*time.Millisecond {
-				sleepTime = 500 * time.Millisecond
-			}
-			log.Printf("Đợi %v trước khi thử lại khối %d", sleepTime, blockNumber)
-			time.Sleep(sleepTime)
+			// 429 hoặc lỗi RPC tạm thời: giãn thời gian poll theo cấp số nhân
+			scheduler.RateLimited()
+			logger.Warn("⏳ Lỗi khi lấy khối, giãn thời gian poll", "block", blockNumber.Uint64(), "delay", scheduler.Next(), "error", err)
+		}
+
+		if err := scheduler.Wait(ctx, "eth_getBlockByNumber"); err != nil {
+			return nil
 		}
 	}
 }
 
-func reverseHandleHTTP(client *ethclient.Client, chainName string, pastDuration time.Duration) {
+func reverseHandleHTTP(ctx context.Context, client *ethclient.Client, chainName string, pastDuration time.Duration) error {
 	chainData := GetChainData(chainName)
 	if chainData == nil {
-		log.Fatalf("Không tìm thấy dữ liệu cho chain %s", chainName)
+		return fmt.Errorf("không tìm thấy dữ liệu cho chain %s", chainName)
 	}
 
 	// Kết nối đến RPC client
@@ -490,11 +508,11 @@ func reverseHandleHTTP(client *ethclient.Client, chainName string, pastDuration
 
 	// Lấy block hiện tại để bắt đầu quét ngược
 	var latestBlockHex string
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	err := rpcClient.CallContext(ctx, &latestBlockHex, "eth_blockNumber")
+	callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	err := rpcClient.CallContext(callCtx, &latestBlockHex, "eth_blockNumber")
 	cancel()
 	if err != nil {
-		log.Fatalf("Lỗi khi lấy block hiện tại: %v", err)
+		return fmt.Errorf("lỗi khi lấy block hiện tại: %w", err)
 	}
 
 	// Chuyển đổi từ hex sang big.Int
@@ -504,107 +522,99 @@ func reverseHandleHTTP(client *ethclient.Client, chainName string, pastDuration
 	// Tính thời gian mục tiêu
 	targetTime := time.Now().Add(-pastDuration)
 
-	log.Printf("======= KHỞI ĐỘNG HỆ THỐNG NGƯỢC CHO CHAIN %s =======", chainName)
-	log.Printf("Bắt đầu quét từ khối hiện tại = %d", blockNumber)
-	log.Printf("Thời gian mục tiêu: %v", targetTime)
-	log.Printf("TransferSignature: %s", chainData.Config.TransferSignature)
-	log.Printf("==================================")
+	logger := logging.Chain("get_chains", chainName)
+	logger.Info("======= KHỞI ĐỘNG HỆ THỐNG NGƯỢC =======", "block", blockNumber.Uint64(), "target_time", targetTime, "transfer_signature", chainData.Config.TransferSignature)
 
 	blockCounter := 0
+	scheduler := new_chain_scheduler(chainName, chainData.Config)
 
 	for {
-		
+
 		blockCounter++
 
+		// Quét ngược là backfill nên chỉ bị giới hạn bởi budget của endpoint
+		if err := scheduler.Acquire(ctx, "eth_getBlockByNumber"); err != nil {
+			return nil
+		}
+
 		// Xử lý khối hiện tại
-		err := processBlock(rpcClient, blockNumber, chainName)
+		err := processBlock(ctx, rpcClient, blockNumber, chainName)
 		if err == nil {
-			// Lấy thời gian của block để kiểm tra điều kiện dừng
-			var block map[string]interface{}
-			blockHex := fmt.Sprintf("0x%x", blockNumber)
-
-			if err := rpcClient.Call(&block, "eth_getBlockByNumber", blockHex, false); err == nil {
-				fmt.Println("Block:", block)
-				// Lấy thời gian của block
-				blockTimeHex, _ := block["timestamp"].(string)
-				blockTimeInt := new(big.Int)
-				if len(blockTimeHex) > 2 {
-					blockTimeInt.SetString(blockTimeHex[2:], 16)
-				}
-				blockTime := time.Unix(blockTimeInt.Int64(), 0)
-				
+			scheduler.Success()
+
+			// Thời gian của block lấy từ header cache (processBlock vừa nạp), không gọi RPC lần nữa
+			if header, ok := headercache.ForChain(chainName).Lookup(blockNumber.Uint64()); ok {
+				blockTime := header.Timestamp()
+
 				// Kiểm tra nếu đã đạt đến thời gian mục tiêu
 				if blockTime.Before(targetTime) || blockTime.Equal(targetTime) {
-					log.Printf("✅ Đã đạt đến thời gian mục tiêu tại khối %d", blockNumber)
+					logger.Info("✅ Đã đạt đến thời gian mục tiêu", "block", blockNumber.Uint64())
 					break
 				}
 			}
-			// Giảm số block để quét ngược về quá khứ
+			// Giảm số block để quét ngược về quá khứ. Quét ngược không dời LastProcessedBlock hay
+			// checkpoint: khối cũ hơn đã xử lý không có nghĩa là ingest đã tiến tới đó
 			blockNumber = new(big.Int).Sub(blockNumber, big.NewInt(1))
 
-			// Cập nhật LastProcessedBlock
-			processLock.Lock()
-			chainData.LastProcessedBlock = new(big.Int).Set(blockNumber)
-			chainData.LastProcessedBlock.Add(chainData.LastProcessedBlock, big.NewInt(1))
-			processLock.Unlock()
-
 			// Mỗi 50 khối, in thông tin tiến độ
 			if blockCounter%50 == 0 {
-				log.Printf("🔄 Đã quét ngược %d khối, hiện tại ở khối %d", blockCounter, blockNumber)
+				logger.Info("🔄 Tiến độ quét ngược", "scanned", blockCounter, "block", blockNumber.Uint64())
 			}
-
-			time.Sleep(200 * time.Millisecond)
 		} else {
-			sleepTime := time.Duration(chainData.Config.TimeNeedToBlock) * time.Millisecond
+			if poller.IsRateLimited(err) {
+				scheduler.RateLimited()
+			}
+			sleepTime := scheduler.Next()
 			if sleepTime < 500*time.Millisecond {
 				sleepTime = 500 * time.Millisecond
 			}
-			log.Printf("Đợi %v trước khi thử lại khối %d", sleepTime, blockNumber)
-			time.Sleep(sleepTime)
+			logger.Warn("Đợi trước khi thử lại khối", "block", blockNumber.Uint64(), "delay", sleepTime, "error", err)
+			if !sleep_ctx(ctx, sleepTime) {
+				return nil
+			}
 		}
 	}
 
-	log.Printf("✅ Hoàn thành xử lý các block ngược cho chain %s", chainName)
+	logger.Info("✅ Hoàn thành xử lý các block ngược")
+	return nil
 }
 
 // Xử lý chain qua HTTP
-func handle_chain_http(chainName string) {
+func handle_chain_http(ctx context.Context, chainName string) error {
 	chainData := InitChainData(chainName)
-	load_config(chooseChain[chainName], chainName)
+	if err := load_config(chain_config_path(chainName), chainName); err != nil {
+		return fmt.Errorf("không thể tải cấu hình cho %s: %w", chainName, err)
+	}
 
-	client, err := ethclient.Dial(chainData.Config.WssRPC)
+	client, err := ethclient.DialContext(ctx, chainData.Config.WssRPC)
 	if err != nil {
-		log.Fatalf("Không thể kết nối đến RPC cho %s: %v", chainName, err)
+		return fmt.Errorf("không thể kết nối đến RPC cho %s: %w", chainName, err)
 	}
 	defer client.Close()
 	pastDuration := 1 * time.Hour
 
-	// reverseHandleHTTP(client, chainName, pastDuration)
-	reverseHandleHTTP(client, chainName, pastDuration)
+	// return continueHandleHTTP(ctx, client, chainName)
+	return reverseHandleHTTP(ctx, client, chainName, pastDuration)
 
 	// continueHandleCosmosHTTP(chainName)
 }
 
-func handle_http() {
-	// Trước tiên, nhập dữ liệu từ file log vào database (nếu có)
-	go importAllLogFiles()
-
-	// Sau đó tiếp tục với các chain
-	chains := []string{
-		"ethereum",
-		"bsc",
-		"avalanche",
-		"polygon",
-		"arbitrum",
-		"optimism",
-		"fantom",
-		"base",
+// sleep_ctx ngủ trong d, trả về false nếu ctx bị hủy trước đó
+func sleep_ctx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
 	}
+}
 
-	for _, chain := range chains {
-		go handle_chain_http(chain)
+// rpc_endpoint trả về endpoint HTTP của chain dùng làm nhãn metrics
+func rpc_endpoint(chainName string) string {
+	if chainData := GetChainData(chainName); chainData != nil {
+		return chainData.Config.RPC
 	}
-
-	// Giữ cho chương trình chạy mãi
-	select {}
+	return chainName
 }
@@ -2,16 +2,23 @@ package get_chains
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
+
+	"main/services/logging"
+	"main/services/poller"
+	"main/services/sink"
 )
 
+// Chu kỳ khối của Tron
+const tronBlockTime = 3 * time.Second
+
 // Logger cho việc ghi log vào file
 var tronFileLogger *log.Logger
 
@@ -92,30 +99,14 @@ type TronTransactionLog struct {
 	Nonce           string `json:"nonce,omitempty"`
 }
 
-// Thiết lập logger
+// Thiết lập logger ghi vào <LOG_DIR>/tron_scan.log
 func setupTronLogger() {
-	// Tạo thư mục logs nếu chưa tồn tại
-	if _, err := os.Stat("./services/get_chains/logs"); os.IsNotExist(err) {
-		err := os.Mkdir("./services/get_chains/logs", 0755)
-		if err != nil {
-			log.Fatalf("Không thể tạo thư mục logs: %v", err)
-		}
-	}
-
-	// Tạo file log với tên bao gồm timestamp
-	timeStr := time.Now().Format("2006-01-02_15-04-05")
-	logFile, err := os.Create(fmt.Sprintf("./services/get_chains/logs/tron_scan_%s.log", timeStr))
-	if err != nil {
-		log.Fatalf("Không thể tạo file log: %v", err)
-	}
-
-	// Thiết lập logger
-	tronFileLogger = log.New(logFile, "", log.LstdFlags)
+	tronFileLogger = logging.Logger("tron_scan.log", "")
 	tronFileLogger.Println("===== BẮT ĐẦU QUÉT BLOCKCHAIN TRON =====")
 }
 
 // Hàm chính để quét các khối theo khoảng thời gian
-func scanTronBlocksByTimeRange(duration time.Duration) error {
+func scanTronBlocksByTimeRange(ctx context.Context, duration time.Duration) error {
 	// Thiết lập logger
 	setupTronLogger()
 
@@ -141,10 +132,24 @@ func scanTronBlocksByTimeRange(duration time.Duration) error {
 	// Bắt đầu quét từ khối mới nhất và lùi dần
 	currentBlockNum := latestBlock.Number
 	processedBlocks := 0
+	scheduler := new_http_scheduler("tron", nodeURL, tronBlockTime)
+
+	for ctx.Err() == nil {
+		// Quét ngược là backfill nên chỉ bị giới hạn bởi budget của endpoint
+		if err := scheduler.Acquire(ctx, "eth_getBlockByNumber"); err != nil {
+			break
+		}
 
-	for {
 		// Lấy thông tin khối hiện tại
 		currentBlock, err := getTronBlockByNum(currentBlockNum, nodeURL)
+		if poller.IsRateLimited(err) {
+			scheduler.RateLimited()
+			log.Printf("⏳ Bị giới hạn tốc độ khi lấy khối #%d, thử lại sau %v", currentBlockNum, scheduler.Next())
+			if !sleep_ctx(ctx, scheduler.Next()) {
+				break
+			}
+			continue
+		}
 		if err != nil {
 			return fmt.Errorf("không thể lấy khối #%d: %v", currentBlockNum, err)
 		}
@@ -158,6 +163,7 @@ func scanTronBlocksByTimeRange(duration time.Duration) error {
 		}
 
 		// Xử lý khối hiện tại
+		scheduler.Success()
 		processTronBlock(currentBlock, nodeURL)
 		processedBlocks++
 
@@ -174,9 +180,6 @@ func scanTronBlocksByTimeRange(duration time.Duration) error {
 		} else {
 			break
 		}
-
-		// Thêm độ trễ nhỏ để tránh quá tải API
-		time.Sleep(100 * time.Millisecond)
 	}
 
 	log.Printf("Hoàn thành quét %d khối", processedBlocks)
@@ -563,16 +566,32 @@ func processTronBlock(block *TronBlock, nodeURL string) {
 	log.Printf("Đang xử lý khối Tron #%d, ID: %s, có %d giao dịch, thời gian: %s",
 		block.Number, block.BlockID, txCount, formattedBlockTime)
 
+	sink.Write(sink.Record{
+		Kind:  "block",
+		Chain: "tron",
+		Block: block.Number,
+		Hash:  block.BlockID,
+		Time:  blockTime,
+		Data:  block,
+	})
+
+	processTronTransactions(block)
+}
+
+// processTronTransactions ghi các giao dịch trong khối ra sink; dùng cả khi chạy lại khối
+// từ kho lưu trữ
+func processTronTransactions(block *TronBlock) {
+	txCount := len(block.Transactions)
+	formattedBlockTime := time.Unix(int64(block.Timestamp/1000), 0).UTC().Format(time.RFC3339Nano)
+
 	// Xử lý các giao dịch trong khối
 	if txCount > 0 {
 		for _, tx := range block.Transactions {
-			tronFileLogger.Printf("\nGIAO DỊCH TRONG BLOCK #%d:", block.Number)
-
 			// Xác định thời gian khối
 			txBlockTime := formattedBlockTime
 
 			// Xử lý từng hợp đồng trong giao dịch
-			for i, contract := range tx.RawData.Contract {
+			for _, contract := range tx.RawData.Contract {
 				// Xác định loại giao dịch
 				_ = contract.Type
 
@@ -649,66 +668,41 @@ func processTronBlock(block *TronBlock, nodeURL string) {
 					Data:            dataInput,
 				}
 
-				// Định dạng JSON đẹp
-				txJSON, _ := json.MarshalIndent(txLog, "", "    ")
-				tronFileLogger.Println(string(txJSON))
-
+				sink.Write(sink.Record{
+					Kind:   "transaction",
+					Chain:  "tron",
+					Block:  block.Number,
+					TxHash: tx.TxID,
+					Data:   txLog,
+				})
 
-				// Thêm log chi tiết cho contract nếu có data
-				if dataInput != "" && dataInput != "0x" {
-					contractDetail := map[string]interface{}{
-						"index":            i + 1,
-						"method_id":        methodID,
-						"method_name":      methodName,
-						"method_signature": methodSignature,
-						"data_length":      len(dataInput),
-						"contract_type":    contract.Type,
-					}
-
-					if len(dataInput) > 100 {
-						contractDetail["data_preview"] = dataInput[:100] + "... (còn nữa)"
-					} else {
-						contractDetail["data"] = dataInput
-					}
-
-					contractJSON, _ := json.MarshalIndent(contractDetail, "", "    ")
-					tronFileLogger.Printf("CHI TIẾT CONTRACT #%d:\n%s", i+1, string(contractJSON))
-				}
 			}
 		}
-	} else {
-		tronFileLogger.Printf("\nKhối này không chứa giao dịch nào")
 	}
-
-	// Dòng phân cách giữa các khối
-	tronFileLogger.Println("\n" + strings.Repeat("-", 80) + "\n")
 }
 
-func HandleTronHTTP() {
-    log.Println("Bắt đầu quét blockchain Tron qua HTTP...")
-    
-    // Quét các khối trong 24 giờ qua
-    duration := 24 * time.Hour
-    
-    // Tạo goroutine để quét không chặn luồng chính
-    go func() {
-        err := scanTronBlocksByTimeRange(duration)
-        if err != nil {
-            log.Printf("Lỗi khi quét blockchain Tron: %v", err)
-        }
-        
-        // Thiết lập quét định kỳ (mỗi 1 giờ)
-        ticker := time.NewTicker(1 * time.Hour)
-        defer ticker.Stop()
-        
-        for range ticker.C {
-            log.Println("Bắt đầu quét định kỳ blockchain Tron...")
-            err := scanTronBlocksByTimeRange(1 * time.Hour) // Chỉ quét 1 giờ gần nhất
-            if err != nil {
-                log.Printf("Lỗi khi quét định kỳ blockchain Tron: %v", err)
-            }
-        }
-    }()
-    
-    log.Println("Đã khởi động quét blockchain Tron qua HTTP")
+// HandleTronHTTP quét 24 giờ gần nhất rồi quét lại mỗi giờ tới khi ctx bị hủy
+func HandleTronHTTP(ctx context.Context) error {
+	log.Println("Bắt đầu quét blockchain Tron qua HTTP...")
+
+	// Quét các khối trong 24 giờ qua
+	if err := scanTronBlocksByTimeRange(ctx, 24*time.Hour); err != nil {
+		log.Printf("Lỗi khi quét blockchain Tron: %v", err)
+	}
+
+	// Thiết lập quét định kỳ (mỗi 1 giờ) tới khi ctx bị hủy
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		log.Println("Bắt đầu quét định kỳ blockchain Tron...")
+		if err := scanTronBlocksByTimeRange(ctx, 1*time.Hour); err != nil { // Chỉ quét 1 giờ gần nhất
+			log.Printf("Lỗi khi quét định kỳ blockchain Tron: %v", err)
+		}
+	}
 }
@@ -2,45 +2,31 @@ package get_chains
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"main/services/logging"
 )
 
 // Logger cho việc ghi log vào file
 var tronWSFileLogger *log.Logger
 
-// Thiết lập logger cho WebSocket Tron
+// Thiết lập logger cho WebSocket Tron, ghi vào <LOG_DIR>/tron_ws.log
 func setupTronWSLogger() {
-	// Tạo thư mục logs nếu chưa tồn tại
-	if _, err := os.Stat("./services/get_chains/logs"); os.IsNotExist(err) {
-		err := os.Mkdir("./services/get_chains/logs", 0755)
-		if err != nil {
-			log.Fatalf("Không thể tạo thư mục logs: %v", err)
-		}
-	}
-
-	// Tạo file log với tên bao gồm timestamp
-	timeStr := time.Now().Format("2006-01-02_15-04-05")
-	logFile, err := os.Create(fmt.Sprintf("./services/get_chains/logs/tron_ws_%s.log", timeStr))
-	if err != nil {
-		log.Fatalf("Không thể tạo file log: %v", err)
-	}
-
-	// Thiết lập logger
-	tronWSFileLogger = log.New(logFile, "", log.LstdFlags)
+	tronWSFileLogger = logging.Logger("tron_ws.log", "")
 	tronWSFileLogger.Println("===== BẮT ĐẦU THEO DÕI BLOCKCHAIN TRON THEO THỜI GIAN THỰC =====")
 }
 
-// Hàm chính để xử lý WebSocket Tron
-func handle_tron_ws() {
+// Hàm chính để xử lý WebSocket Tron; chạy tới khi ctx bị hủy
+func handle_tron_ws(ctx context.Context) error {
 	// Thiết lập logger
 	setupTronWSLogger()
 
@@ -75,7 +61,7 @@ func handle_tron_ws() {
 		}
 
 		if err != nil {
-			log.Fatalf("Không thể kết nối đến bất kỳ node Tron nào: %v", err)
+			return fmt.Errorf("không thể kết nối đến bất kỳ node Tron nào: %w", err)
 		}
 	}
 
@@ -89,12 +75,14 @@ func handle_tron_ws() {
 	pollInterval := 3 * time.Second
 
 	// Vòng lặp chính để theo dõi các khối mới
-	for {
+	for ctx.Err() == nil {
 		// Lấy khối mới nhất hiện tại
 		currentLatestBlock, err := getTronLatestBlockWS(primaryNodeURL)
 		if err != nil {
 			log.Printf("Lỗi khi lấy khối mới nhất: %v, thử lại sau %s", err, pollInterval)
-			time.Sleep(pollInterval)
+			if !sleep_ctx(ctx, pollInterval) {
+				return nil
+			}
 			continue
 		}
 
@@ -106,7 +94,7 @@ func handle_tron_ws() {
 				currentLatestBlock.Number)
 
 			// Xử lý từng khối mới, từ cũ đến mới
-			for blockNum := lastProcessedBlock + 1; blockNum <= currentLatestBlock.Number; blockNum++ {
+			for blockNum := lastProcessedBlock + 1; blockNum <= currentLatestBlock.Number && ctx.Err() == nil; blockNum++ {
 				// Lấy thông tin chi tiết của khối
 				block, err := getTronBlockByNumWS(blockNum, primaryNodeURL)
 				if err != nil {
@@ -125,8 +113,11 @@ func handle_tron_ws() {
 		}
 
 		// Chờ đến lần kiểm tra tiếp theo
-		time.Sleep(pollInterval)
+		if !sleep_ctx(ctx, pollInterval) {
+			return nil
+		}
 	}
+	return nil
 }
 
 // Lấy khối mới nhất của Tron (phiên bản WebSocket)
@@ -521,7 +512,7 @@ func checkTronWebSocketSupport() bool {
 }
 
 // Hàm chính để xử lý Tron theo thời gian thực
-func handle_tron_realtime() {
+func handle_tron_realtime(ctx context.Context) error {
 	// Kiểm tra xem có hỗ trợ WebSocket không
 	if checkTronWebSocketSupport() {
 		log.Println("Đã tìm thấy hỗ trợ WebSocket cho Tron, sử dụng phương thức WebSocket")
@@ -531,12 +522,13 @@ func handle_tron_realtime() {
 	} else {
 		log.Println("Không tìm thấy hỗ trợ WebSocket cho Tron, sử dụng phương thức polling")
 		// Nếu không có hỗ trợ WebSocket, sử dụng phương thức polling
-		handle_tron_ws()
+		return handle_tron_ws(ctx)
 	}
+	return nil
 }
 
-// Hàm để xử lý các khối Tron theo thời gian thực với khả năng phục hồi
-func handle_tron_ws_resilient() {
+// Hàm để xử lý các khối Tron theo thời gian thực với khả năng phục hồi; chạy tới khi ctx bị hủy
+func handle_tron_ws_resilient(ctx context.Context) error {
 	// Thiết lập logger
 	setupTronWSLogger()
 
@@ -574,14 +566,14 @@ func handle_tron_ws_resilient() {
 	}
 
 	if lastProcessedBlock == 0 {
-		log.Fatalf("Không thể kết nối đến bất kỳ node Tron nào")
+		return fmt.Errorf("không thể kết nối đến bất kỳ node Tron nào")
 	}
 
 	// Khoảng thời gian giữa các lần kiểm tra khối mới (3 giây)
 	pollInterval := 3 * time.Second
 
 	// Vòng lặp chính để theo dõi các khối mới
-	for {
+	for ctx.Err() == nil {
 		// Lấy khối mới nhất hiện tại
 		var currentLatestBlock *TronBlock
 		var nodeWorking bool = false
@@ -602,7 +594,9 @@ func handle_tron_ws_resilient() {
 
 		if !nodeWorking {
 			log.Printf("Tất cả các node đều không hoạt động, thử lại sau %s", pollInterval)
-			time.Sleep(pollInterval)
+			if !sleep_ctx(ctx, pollInterval) {
+				return nil
+			}
 			continue
 		}
 
@@ -614,7 +608,7 @@ func handle_tron_ws_resilient() {
 				currentLatestBlock.Number)
 
 			// Xử lý từng khối mới, từ cũ đến mới
-			for blockNum := lastProcessedBlock + 1; blockNum <= currentLatestBlock.Number; blockNum++ {
+			for blockNum := lastProcessedBlock + 1; blockNum <= currentLatestBlock.Number && ctx.Err() == nil; blockNum++ {
 				// Lấy thông tin chi tiết của khối
 				block, err := getTronBlockByNumWS(blockNum, primaryNodeURL)
 				if err != nil {
@@ -653,6 +647,9 @@ func handle_tron_ws_resilient() {
 		}
 
 		// Chờ đến lần kiểm tra tiếp theo
-		time.Sleep(pollInterval)
+		if !sleep_ctx(ctx, pollInterval) {
+			return nil
+		}
 	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package get_chains
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,8 +9,13 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"main/services/poller"
 )
 
+// Chu kỳ khối của VeChain
+const vechainBlockTime = 10 * time.Second
+
 // Cấu trúc dữ liệu cho BlockVechainHttp
 type BlockVechainHttp struct {
 	ID           string   `json:"id"`
@@ -65,7 +71,7 @@ type TransactionLoghttp struct {
 }
 
 // Hàm chính để quét các khối theo khoảng thời gian
-func scanBlocksByTimeRange(duration time.Duration) error {
+func scanBlocksByTimeRange(ctx context.Context, duration time.Duration) error {
 	// Node chính và dự phòng
 	primaryNodeURL := "https://mainnet.veblocks.net"
 	backupNodeURL := "https://sync-mainnet.vechain.org"
@@ -93,8 +99,9 @@ func scanBlocksByTimeRange(duration time.Duration) error {
 	// Bắt đầu quét từ khối mới nhất và lùi dần
 	currentBlock := latestBlock
 	processedBlocks := 0
+	scheduler := new_http_scheduler("vechain", primaryNodeURL, vechainBlockTime)
 
-	for {
+	for ctx.Err() == nil {
 		// Kiểm tra điều kiện dừng theo thời gian
 		blockTime := time.Unix(int64(currentBlock.Timestamp), 0)
 		if blockTime.Before(minTimeToScan) {
@@ -104,7 +111,7 @@ func scanBlocksByTimeRange(duration time.Duration) error {
 		}
 
 		// Xử lý khối hiện tại
-		processBlockVechain(currentBlock, primaryNodeURL, backupNodeURL)
+		processBlockVechain(ctx, scheduler, currentBlock, primaryNodeURL, backupNodeURL)
 		processedBlocks++
 
 		// Nếu đã quét quá nhiều khối, có thể cân nhắc dừng để tránh quá tải
@@ -114,8 +121,19 @@ func scanBlocksByTimeRange(duration time.Duration) error {
 			break
 		}
 
-		// Lấy khối cha (khối trước đó)
+		// Lấy khối cha (khối trước đó); quét ngược là backfill nên chỉ bị giới hạn bởi budget
+		if err := scheduler.Acquire(ctx, "block"); err != nil {
+			break
+		}
 		parentBlock, err := getBlockByID(currentBlock.ParentID, primaryNodeURL)
+		for poller.IsRateLimited(err) {
+			scheduler.RateLimited()
+			log.Printf("⏳ Bị giới hạn tốc độ khi lấy khối cha, thử lại sau %v", scheduler.Next())
+			if !sleep_ctx(ctx, scheduler.Next()) {
+				return nil
+			}
+			parentBlock, err = getBlockByID(currentBlock.ParentID, primaryNodeURL)
+		}
 		if err != nil {
 			log.Printf("Không thể lấy khối cha từ node chính, thử node dự phòng: %v", err)
 			parentBlock, err = getBlockByID(currentBlock.ParentID, backupNodeURL)
@@ -125,10 +143,8 @@ func scanBlocksByTimeRange(duration time.Duration) error {
 		}
 
 		// Cập nhật khối hiện tại là khối cha để tiếp tục quét ngược
+		scheduler.Success()
 		currentBlock = parentBlock
-
-		// Thêm độ trễ nhỏ để tránh quá tải API
-		time.Sleep(100 * time.Millisecond)
 	}
 
 	log.Printf("Hoàn thành quét %d khối", processedBlocks)
@@ -280,7 +296,7 @@ func hexToDecimalValuehttp(hexValue string) string {
 }
 
 // Xử lý thông tin khối và các giao dịch trong khối
-func processBlockVechain(block *BlockVechainHttp, primaryNodeURL, backupNodeURL string) {
+func processBlockVechain(ctx context.Context, scheduler *poller.Scheduler, block *BlockVechainHttp, primaryNodeURL, backupNodeURL string) {
 	txCount := len(block.Transactions)
 	blockTime := time.Unix(int64(block.Timestamp), 0).UTC()
 	formattedBlockTime := blockTime.Format(time.RFC3339Nano)
@@ -314,6 +330,9 @@ func processBlockVechain(block *BlockVechainHttp, primaryNodeURL, backupNodeURL
 			fileLogger.Printf("\nGIAO DỊCH TRONG BLOCK #%d:", block.Number)
 
 			// Lấy thông tin giao dịch từ node chính
+			if err := scheduler.Acquire(ctx, "transaction"); err != nil {
+				return
+			}
 			tx, err := getTransactionhttp(txID, primaryNodeURL)
 			if err != nil {
 				// Thử node dự phòng nếu node chính không hoạt động
@@ -404,17 +423,17 @@ func processBlockVechain(block *BlockVechainHttp, primaryNodeURL, backupNodeURL
 	fileLogger.Println("\n" + strings.Repeat("-", 80) + "\n")
 }
 
-// Hàm main để chạy chức năng quét
-func handle_http_vechain() {
+// Hàm main để chạy chức năng quét; dừng sớm khi ctx bị hủy
+func handle_http_vechain(ctx context.Context) error {
 	// Quét các khối trong vòng 1 giờ trước
 	duration := 1 * time.Hour
 
 	log.Printf("Bắt đầu quét các khối VeChain trong khoảng %s vừa qua", duration)
 
-	err := scanBlocksByTimeRange(duration)
-	if err != nil {
-		log.Fatalf("Lỗi khi quét khối: %v", err)
+	if err := scanBlocksByTimeRange(ctx, duration); err != nil {
+		return fmt.Errorf("lỗi khi quét khối: %w", err)
 	}
 
 	log.Println("Quét khối hoàn tất")
+	return nil
 }
@@ -1,6 +1,7 @@
 package get_chains
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,12 +9,15 @@ import (
 	"math/big"
 	"net/http"
 	"net/url"
-	"os"
-	"os/signal"
 	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"main/services/health"
+	"main/services/logging"
+	"main/services/metrics"
+	"main/services/sink"
 )
 
 // Cấu trúc dữ liệu cho khối
@@ -85,18 +89,10 @@ type TransactionLogVechainWS struct {
 // Logger để ghi log ra file
 var fileLogger *log.Logger
 
-// Thiết lập logger
+// Thiết lập logger ghi vào <LOG_DIR>/vechain_ws.log
 func setupLoggerVechainWS() {
-	// Tạo file log với tên chứa timestamp
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	logFile, err := os.Create(fmt.Sprintf("./services/get_chains/vechain_blocks_%s.log", timestamp))
-	if err != nil {
-		log.Fatal("Không thể tạo file log: ", err)
-	}
-
-	// Khởi tạo logger ghi vào file
-	fileLogger = log.New(logFile, "", log.Ldate|log.Ltime)
-	fileLogger.Println("=== Bắt đầu ghi log VeChain Blocks và Transactions ===")
+	fileLogger = logging.Logger("vechain_ws.log", "")
+	fileLogger.Println("=== Bắt đầu theo dõi VeChain Blocks và Transactions ===")
 }
 
 // Lấy dữ liệu giao dịch từ API REST
@@ -286,8 +282,8 @@ func getMethodNameVechainWS(methodID string) (string, string) {
 	return name, signature
 }
 
-
-func handle_wss_vechain() {
+// handle_wss_vechain nhận khối mới qua WebSocket tới khi ctx bị hủy; trả lỗi khi kết nối hỏng để supervisor kết nối lại
+func handle_wss_vechain(ctx context.Context) error {
 	// Thiết lập logger
 	setupLoggerVechainWS()
 
@@ -314,7 +310,7 @@ func handle_wss_vechain() {
 	dialer := websocket.DefaultDialer
 	dialer.HandshakeTimeout = 45 * time.Second
 
-	c, _, err := dialer.Dial(u.String(), header)
+	c, _, err := dialer.DialContext(ctx, u.String(), header)
 	if err != nil {
 		alternativeEndpoints := []string{
 			"wss://mainnet.veblocks.net/subscriptions/beat",
@@ -324,7 +320,7 @@ func handle_wss_vechain() {
 		var connected bool
 		for _, endpoint := range alternativeEndpoints {
 			log.Printf("Thử kết nối đến endpoint thay thế: %s", endpoint)
-			alt, _, altErr := dialer.Dial(endpoint, header)
+			alt, _, altErr := dialer.DialContext(ctx, endpoint, header)
 			if altErr == nil {
 				log.Printf("Kết nối thành công đến %s", endpoint)
 				c = alt
@@ -334,7 +330,7 @@ func handle_wss_vechain() {
 		}
 
 		if !connected {
-			log.Fatal("Không thể kết nối đến bất kỳ endpoint WebSocket nào!")
+			return fmt.Errorf("không thể kết nối đến bất kỳ endpoint WebSocket nào: %w", err)
 		}
 	}
 	defer c.Close()
@@ -343,12 +339,9 @@ func handle_wss_vechain() {
 	log.Printf("Kết nối WebSocket thành công, đang chờ khối mới...")
 	fileLogger.Printf("Kết nối WebSocket thành công, đang chờ khối mới...")
 
-	// Đăng ký để nhận thông báo khi có gián đoạn
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-
 	// Kênh để nhận tin nhắn
 	done := make(chan struct{})
+	var readErr error
 
 	// Goroutine để đọc tin nhắn
 	go func() {
@@ -356,11 +349,19 @@ func handle_wss_vechain() {
 		for {
 			_, message, err := c.ReadMessage()
 			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				readErr = err
 				log.Println("Lỗi đọc tin nhắn: ", err)
+				// Supervisor sẽ kết nối lại
+				metrics.WSReconnects.WithLabelValues("vechain").Inc()
+				health.Stream("vechain").Fail(err)
 				return
 			}
 
 			log.Printf("Nhận được dữ liệu khối mới")
+			health.Stream("vechain").Event()
 
 			// Xử lý thông tin khối nhận được
 			var block BlockVechainWS
@@ -376,8 +377,7 @@ func handle_wss_vechain() {
 			log.Printf("Nhận được khối #%d, ID: %s, có %d giao dịch",
 				block.Number, block.ID, txCount)
 
-			// In thông tin khối
-			fileLogger.Printf("=== THÔNG TIN KHỐI #%d ===", block.Number)
+			fileLogger.Printf("Khối #%d: %d giao dịch", block.Number, txCount)
 			blockInfo := map[string]interface{}{
 				"block_height": fmt.Sprintf("%d", block.Number),
 				"block_hash":   block.ID,
@@ -392,15 +392,17 @@ func handle_wss_vechain() {
 				"tx_count":     txCount,
 			}
 
-			// Định dạng JSON đẹp
-			blockJSON, _ := json.MarshalIndent(blockInfo, "", "    ")
-			fileLogger.Println(string(blockJSON))
+			sink.Write(sink.Record{
+				Kind:  "block",
+				Chain: "vechain",
+				Block: uint64(block.Number),
+				Hash:  block.ID,
+				Data:  blockInfo,
+			})
 
 			// Xử lý các giao dịch trong khối
 			if txCount > 0 {
 				for _, txID := range block.Transactions {
-					fileLogger.Printf("\nGIAO DỊCH TRONG BLOCK #%d:", block.Number)
-
 					// Lấy thông tin giao dịch
 					tx, err := getTransactionVechainWS(txID, veChainNodeRESTURL)
 					if err != nil {
@@ -418,7 +420,7 @@ func handle_wss_vechain() {
 					var txBlockTime string
 
 					// Với mỗi clause, tạo một log giao dịch riêng biệt
-					for j, clause := range tx.Clauses {
+					for _, clause := range tx.Clauses {
 						txType := determineTransactionTypeVechainWS(clause.Data)
 						methodID := ""
 						if len(clause.Data) >= 10 {
@@ -454,37 +456,16 @@ func handle_wss_vechain() {
 							txLog.Nonce = tx.Nonce
 						}
 
-						// Định dạng JSON đẹp
-						txJSON, _ := json.MarshalIndent(txLog, "", "    ")
-						fileLogger.Println(string(txJSON))
-
-						// Thêm log chi tiết cho clause nếu có data
-						if len(clause.Data) > 10 && clause.Data != "0x" {
-							clauseDetail := map[string]interface{}{
-								"index":            j + 1,
-								"method_id":        methodID,
-								"method_name":      methodName,
-								"method_signature": methodSignature,
-								"data_length":      len(clause.Data),
-							}
-
-							if len(clause.Data) > 100 {
-								clauseDetail["data_preview"] = clause.Data[:100] + "... (còn nữa)"
-							} else {
-								clauseDetail["data"] = clause.Data
-							}
-
-							clauseJSON, _ := json.MarshalIndent(clauseDetail, "", "    ")
-							fileLogger.Printf("CHI TIẾT CLAUSE #%d:\n%s", j+1, string(clauseJSON))
-						}
+						sink.Write(sink.Record{
+							Kind:   "transaction",
+							Chain:  "vechain",
+							Block:  uint64(block.Number),
+							TxHash: txID,
+							Data:   txLog,
+						})
 					}
 				}
-			} else {
-				fileLogger.Printf("\nKhối này không chứa giao dịch nào")
 			}
-
-			// Dòng phân cách giữa các khối
-			fileLogger.Println("\n" + strings.Repeat("-", 80) + "\n")
 		}
 	}()
 
@@ -495,15 +476,15 @@ func handle_wss_vechain() {
 	for {
 		select {
 		case <-done:
-			return
+			return readErr
 		case <-ticker.C:
 			// Gửi ping để giữ kết nối
 			if err := c.WriteMessage(websocket.PingMessage, nil); err != nil {
 				log.Println("Lỗi gửi ping: ", err)
-				return
+				return err
 			}
-		case <-interrupt:
-			log.Println("Nhận tín hiệu ngắt, đang đóng kết nối...")
+		case <-ctx.Done():
+			log.Println("Đang dừng, đóng kết nối...")
 			fileLogger.Println("=== Kết thúc ghi log ===")
 
 			// Đóng kết nối
@@ -517,8 +498,11 @@ func handle_wss_vechain() {
 			select {
 			case <-done:
 			case <-time.After(time.Second):
+				// Đóng socket để goroutine đọc thoát ra
+				c.Close()
+				<-done
 			}
-			return
+			return nil
 		}
 	}
 }
@@ -0,0 +1,582 @@
+package get_chains
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"main/services/dedup"
+	"main/services/logging"
+	"main/services/pipeline"
+	"main/services/sink"
+)
+
+// DefaultImportPaths là nơi các phiên bản cũ ghi file log (.log, không đệ quy)
+var DefaultImportPaths = []string{".", "log", "services/get_chains", "services/get_chains/log", "services/get_chains/logs"}
+
+// ImportOptions điều chỉnh một lần nhập
+type ImportOptions struct {
+	Chain  string // chỉ nhập chain này; rỗng là mọi chain
+	DryRun bool   // chỉ đọc và đếm, không ghi vào sink
+}
+
+// ImportStats đếm kết quả của một lần nhập
+type ImportStats struct {
+	Files      int `json:"files"`
+	Blocks     int `json:"blocks"`
+	Events     int `json:"events"`
+	Records    int `json:"records"`    // bản ghi JSON Lines khác transfer và block
+	Duplicates int `json:"duplicates"` // đã nhập ở file trước hoặc lần chạy trước
+	Skipped    int `json:"skipped"`    // đối tượng JSON không nhận ra định dạng
+}
+
+// Chain id của EVM trong transactions.log (file không ghi tên chain)
+var evmChainIDs = map[string]string{
+	"0x1":    "ethereum",
+	"0x38":   "bsc",
+	"0x89":   "polygon",
+	"0xa4b1": "arbitrum",
+	"0xa":    "optimism",
+	"0x2105": "base",
+	"0xa86a": "avalanche",
+	"0xfa":   "fantom",
+}
+
+// chain_id ghi trong log của các chain không phải EVM
+var logChainIDs = map[string]string{
+	"NetXdQprcVkpaWU":  "tezos",
+	"algorand-mainnet": "algorand",
+	"cosmoshub-4":      "cosmos",
+	"elrond-mainnet":   "elrond",
+}
+
+var (
+	logTimePrefix  = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) ?`)
+	evmBlockHeader = regexp.MustCompile(`^\[([^\]]+)\] Block: (\d+), Transactions: \d+`)
+	evmTxHeader    = regexp.MustCompile(`^\[([^\]]+)\] Transaction: 0x`)
+	blockMention   = regexp.MustCompile(`(?:BLOCK|KHỐI|Khối|khối) #(\d+)`)
+	// Dòng TransferContract của ingest Tron WebSocket; "Số lượng" là giá trị gốc (sun)
+	// có dấu phẩy ngăn cách dù in kèm chữ TRX
+	tronWSTransfer = regexp.MustCompile(`^Giao dịch TRX: (0x[0-9a-fA-F]+), Từ: (\S*), Đến: (\S*), Số lượng: ([\d,]+) TRX, Khối: #(\d+), Thời gian: (.+)$`)
+)
+
+type importer struct {
+	opts  ImportOptions
+	store *dedup.Store
+	seen  map[string]map[string]bool
+	stats ImportStats
+}
+
+// ImportLogs đọc các file log đã ghi ở phiên bản cũ (block dump EVM, transactions.log,
+// log JSON của VeChain/Cosmos/Tron/Tezos/Algorand/Stellar) và file JSON Lines của sink,
+// chuyển thành event chuẩn và block rồi ghi vào các sink đã đăng ký. Thư mục trong
+// paths được quét lấy file .log; file .jsonl phải chỉ rõ. Key đã nhập được lưu dưới
+// <DEDUP_DIR>/import nên chạy lại hoặc nhập file trùng nội dung không ghi hai lần.
+func ImportLogs(paths []string, opts ImportOptions) (ImportStats, error) {
+	imp := &importer{
+		opts:  opts,
+		store: dedup.New(filepath.Join(dedup.Dir(), "import")),
+		seen:  make(map[string]map[string]bool),
+	}
+
+	files, err := import_files(paths)
+	if err != nil {
+		return imp.stats, err
+	}
+	for _, file := range files {
+		if err := imp.import_file(file); err != nil {
+			logging.Service("import").Warn("⚠️ Bỏ qua file", "file", file, "error", err)
+			continue
+		}
+		imp.stats.Files++
+	}
+
+	if !opts.DryRun {
+		for chain := range imp.seen {
+			if err := imp.store.Flush(chain, 0); err != nil {
+				return imp.stats, fmt.Errorf("không lưu được key đã nhập của %s: %w", chain, err)
+			}
+		}
+	}
+	return imp.stats, nil
+}
+
+// import_files mở rộng thư mục thành các file .log bên trong (trừ log giá Binance)
+func import_files(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.log"))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if !strings.HasSuffix(match, "_market.log") {
+				files = append(files, match)
+			}
+		}
+	}
+	return files, nil
+}
+
+func (imp *importer) import_file(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	before := imp.stats
+	if strings.HasSuffix(path, ".jsonl") {
+		err = imp.import_jsonl(f)
+	} else {
+		err = imp.import_text(f, file_chain(path))
+	}
+	if err != nil {
+		return err
+	}
+	logging.Service("import").Info("📥 Đã nhập file", "file", path,
+		"blocks", imp.stats.Blocks-before.Blocks, "events", imp.stats.Events-before.Events,
+		"records", imp.stats.Records-before.Records, "duplicates", imp.stats.Duplicates-before.Duplicates)
+	return nil
+}
+
+// file_chain lấy tên chain từ block_data_<chain>.log (block_data_cosmos_cosmos.log là cosmos)
+func file_chain(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), ".log")
+	if !strings.HasPrefix(name, "block_data_") {
+		return ""
+	}
+	chain, _, _ := strings.Cut(strings.TrimPrefix(name, "block_data_"), "_")
+	return chain
+}
+
+// logContext là những gì các dòng log trước một đối tượng JSON cho biết về nó
+type logContext struct {
+	logTime time.Time
+	header  string // "block" hoặc "tx" với block dump EVM và transactions.log
+	block   uint64
+	clause  bool
+}
+
+// import_text đọc file log có đối tượng JSON in nhiều dòng xen giữa các dòng log.
+// File còn dấu xung đột merge thì chỉ giữ phần HEAD.
+func (imp *importer) import_text(f *os.File, fileChain string) error {
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1<<20), 64<<20)
+
+	var (
+		ctx      logContext
+		obj      []string
+		depth    int
+		conflict bool
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "<<<<<<< "):
+			continue
+		case line == "=======":
+			conflict = true
+			continue
+		case strings.HasPrefix(line, ">>>>>>> "):
+			conflict = false
+			continue
+		}
+		if conflict {
+			continue
+		}
+
+		if obj != nil {
+			obj = append(obj, line)
+			if depth += brace_delta(line); depth <= 0 {
+				imp.import_object(fileChain, ctx, strings.Join(obj, "\n"))
+				obj, ctx.clause = nil, false
+			}
+			continue
+		}
+
+		body := line
+		if m := logTimePrefix.FindStringSubmatch(line); m != nil {
+			if t, err := time.ParseInLocation("2006/01/02 15:04:05", m[1], time.Local); err == nil {
+				ctx.logTime = t
+			}
+			body = line[len(m[0]):]
+		}
+
+		switch {
+		case strings.TrimSpace(body) == "{":
+			obj, depth = []string{"{"}, 1
+		case strings.HasPrefix(body, "----------------------------------------"):
+			ctx.header = ""
+		case evmBlockHeader.MatchString(body):
+			m := evmBlockHeader.FindStringSubmatch(body)
+			ctx.header = "block"
+			ctx.block, _ = strconv.ParseUint(m[2], 10, 64)
+			if t, err := time.ParseInLocation("2006-01-02 15:04:05", m[1], time.Local); err == nil {
+				ctx.logTime = t
+			}
+		case evmTxHeader.MatchString(body):
+			m := evmTxHeader.FindStringSubmatch(body)
+			ctx.header = "tx"
+			if t, err := time.ParseInLocation("2006-01-02 15:04:05", m[1], time.Local); err == nil {
+				ctx.logTime = t
+			}
+		case tronWSTransfer.MatchString(body):
+			imp.import_tron_ws_line(tronWSTransfer.FindStringSubmatch(body))
+		case strings.Contains(body, "CHI TIẾT CLAUSE"):
+			ctx.clause = true
+		case blockMention.MatchString(body):
+			ctx.block, _ = strconv.ParseUint(blockMention.FindStringSubmatch(body)[1], 10, 64)
+		}
+	}
+	return scanner.Err()
+}
+
+// brace_delta đếm { trừ } nằm ngoài chuỗi JSON của một dòng
+func brace_delta(line string) int {
+	delta, inString, escaped := 0, false, false
+	for _, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case !inString && c == '{':
+			delta++
+		case !inString && c == '}':
+			delta--
+		}
+	}
+	return delta
+}
+
+func (imp *importer) import_object(fileChain string, ctx logContext, raw string) {
+	if ctx.clause {
+		// Chi tiết clause của VeChain đã nằm trong giao dịch in ngay trước
+		return
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		imp.stats.Skipped++
+		return
+	}
+
+	txHash, _ := m["tx_hash"].(string)
+	chainID, _ := m["chainId"].(string)
+	result, _ := m["result"].(map[string]interface{})
+	switch {
+	case ctx.header == "block" && m["transactions"] != nil:
+		imp.import_evm_block(fileChain, ctx, m)
+	case ctx.header == "tx" && chainID != "":
+		imp.import_evm_tx(evm_chain_name(chainID), ctx, m)
+	case result != nil && result["block"] != nil:
+		imp.import_cosmos_block(m, result)
+	case txHash != "":
+		imp.import_log_tx(ctx, m)
+	case m["block_hash"] != nil && m["block_height"] != nil:
+		imp.import_log_block(m)
+	default:
+		imp.stats.Skipped++
+	}
+}
+
+func evm_chain_name(chainID string) string {
+	if name, ok := evmChainIDs[strings.ToLower(chainID)]; ok {
+		return name
+	}
+	return "evm-" + chainID
+}
+
+func log_chain_name(chainID string) string {
+	if name, ok := logChainIDs[chainID]; ok {
+		return name
+	}
+	return strings.ToLower(chainID)
+}
+
+// import_evm_block nhập block dump EVM: block thô và mỗi giao dịch thành một event
+func (imp *importer) import_evm_block(chain string, ctx logContext, block map[string]interface{}) {
+	transactions, _ := block["transactions"].([]interface{})
+	if chain == "" && len(transactions) > 0 {
+		if tx, ok := transactions[0].(map[string]interface{}); ok {
+			chainID, _ := tx["chainId"].(string)
+			chain = evm_chain_name(chainID)
+		}
+	}
+	if chain == "" {
+		imp.stats.Skipped++
+		return
+	}
+
+	number := ctx.block
+	if n := hex_uint64(block["number"]); n > 0 {
+		number = n
+	}
+	hash, _ := block["hash"].(string)
+	blockTime := ctx.logTime
+	if ts := hex_uint64(block["timestamp"]); ts > 0 {
+		blockTime = time.Unix(int64(ts), 0)
+	}
+
+	imp.write_block(chain, number, hash, blockTime, block)
+	for _, tx := range transactions {
+		if txMap, ok := tx.(map[string]interface{}); ok {
+			imp.dispatch_evm_tx(chain, hash, blockTime, txMap)
+		}
+	}
+}
+
+// import_evm_tx nhập một giao dịch của transactions.log. File không có thời gian khối
+// nên dùng thời điểm ghi log (chậm hơn khối vài giây).
+func (imp *importer) import_evm_tx(chain string, ctx logContext, tx map[string]interface{}) {
+	blockHash, _ := tx["blockHash"].(string)
+	imp.dispatch_evm_tx(chain, blockHash, ctx.logTime, tx)
+}
+
+func (imp *importer) dispatch_evm_tx(chain, blockHash string, blockTime time.Time, tx map[string]interface{}) {
+	txHash, _ := tx["hash"].(string)
+	if txHash == "" {
+		imp.stats.Skipped++
+		return
+	}
+	logData := extract_transaction_data(tx, chain, blockTime.Format("2006-01-02 15:04:05"), cached_signature_name)
+	ev := tx_map_to_event(logData)
+	ev.BlockHash = blockHash
+	imp.dispatch(ev)
+}
+
+// cached_signature_name chỉ tra bảng signature đã biết: nhập hàng nghìn giao dịch không
+// được gọi API 4byte cho từng giao dịch
+func cached_signature_name(hexSignature string) (string, error) {
+	if names := eventSignature[hexSignature]; len(names) > 0 {
+		return names[0], nil
+	}
+	return "Unknown", nil
+}
+
+// import_cosmos_block nhập block Cosmos (phản hồi JSON-RPC /block) làm block thô
+func (imp *importer) import_cosmos_block(raw, result map[string]interface{}) {
+	block, _ := result["block"].(map[string]interface{})
+	header, _ := block["header"].(map[string]interface{})
+	blockID, _ := result["block_id"].(map[string]interface{})
+
+	chainID, _ := header["chain_id"].(string)
+	heightStr, _ := header["height"].(string)
+	height, _ := strconv.ParseUint(heightStr, 10, 64)
+	hash, _ := blockID["hash"].(string)
+	timeStr, _ := header["time"].(string)
+	blockTime, _ := time.Parse(time.RFC3339Nano, timeStr)
+	if height == 0 {
+		imp.stats.Skipped++
+		return
+	}
+	imp.write_block(log_chain_name(chainID), height, hash, blockTime, raw)
+}
+
+// import_log_block nhập thông tin khối in trong log (VeChain)
+func (imp *importer) import_log_block(m map[string]interface{}) {
+	chainID, _ := m["chain_id"].(string)
+	heightStr, _ := m["block_height"].(string)
+	height, _ := strconv.ParseUint(heightStr, 10, 64)
+	hash, _ := m["block_hash"].(string)
+	timeStr, _ := m["block_time"].(string)
+	blockTime, _ := time.Parse(time.RFC3339Nano, timeStr)
+	if chainID == "" || height == 0 {
+		imp.stats.Skipped++
+		return
+	}
+	imp.write_block(log_chain_name(chainID), height, hash, blockTime, m)
+}
+
+// import_log_tx nhập giao dịch JSON của các chain không phải EVM. Algorand ghi
+// block_height 0 nên khối lấy từ dòng "GIAO DỊCH TRONG BLOCK #..." ngay trước.
+func (imp *importer) import_log_tx(ctx logContext, m map[string]interface{}) {
+	str := func(key string) string {
+		v, _ := m[key].(string)
+		return v
+	}
+
+	ev := pipeline.Event{
+		Chain:           log_chain_name(str("chain_id")),
+		BlockHash:       str("block_hash"),
+		TxHash:          str("tx_hash"),
+		From:            str("from"),
+		To:              str("to"),
+		Contract:        str("token"),
+		TransactionType: str("tx_type"),
+		Amount:          new(big.Int),
+		Raw:             m,
+	}
+	if ev.Chain == "" {
+		imp.stats.Skipped++
+		return
+	}
+	ev.BlockNumber, _ = strconv.ParseUint(str("block_height"), 10, 64)
+	if ev.BlockNumber == 0 {
+		ev.BlockNumber = ctx.block
+	}
+	if sig := str("method_signature"); sig != "" && !strings.EqualFold(sig, "unknown") {
+		ev.EventSignature = sig
+	}
+	for _, key := range []string{"amount", "value_decimal", "value"} {
+		if amount, ok := parse_amount(str(key)); ok {
+			ev.Amount = amount
+			break
+		}
+	}
+	for _, key := range []string{"block_time", "timestamp"} {
+		if t, err := time.Parse(time.RFC3339Nano, str(key)); err == nil && t.Year() > 1970 {
+			ev.Timestamp = t
+			break
+		}
+	}
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = ctx.logTime
+	}
+	imp.dispatch(ev)
+}
+
+func (imp *importer) import_tron_ws_line(m []string) {
+	ev := pipeline.Event{
+		Chain:           "tron",
+		TxHash:          m[1],
+		From:            m[2],
+		To:              m[3],
+		TransactionType: "simple_transfer",
+		Amount:          new(big.Int),
+	}
+	if amount, ok := parse_amount(strings.ReplaceAll(m[4], ",", "")); ok {
+		ev.Amount = amount
+	}
+	ev.BlockNumber, _ = strconv.ParseUint(m[5], 10, 64)
+	ev.Timestamp, _ = time.ParseInLocation("2006-01-02 15:04:05", m[6], time.Local)
+	imp.dispatch(ev)
+}
+
+// parse_amount đọc số nguyên thập phân hoặc hex (0x...)
+func parse_amount(s string) (*big.Int, bool) {
+	if s == "" {
+		return nil, false
+	}
+	if strings.HasPrefix(s, "0x") {
+		return new(big.Int).SetString(s[2:], 16)
+	}
+	return new(big.Int).SetString(s, 10)
+}
+
+func hex_uint64(v interface{}) uint64 {
+	s, _ := v.(string)
+	if len(s) <= 2 {
+		return 0
+	}
+	n, _ := strconv.ParseUint(s[2:], 16, 64)
+	return n
+}
+
+// import_jsonl nhập file JSON Lines của sink (vd: chép từ replica khác)
+func (imp *importer) import_jsonl(f *os.File) error {
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1<<20), 64<<20)
+	for scanner.Scan() {
+		var r sink.StoredRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.Kind == "" {
+			imp.stats.Skipped++
+			continue
+		}
+		record := r.Record
+		record.Data = r.Data
+
+		switch record.Kind {
+		case sink.KindTransfer:
+			var ev pipeline.Event
+			if err := json.Unmarshal(r.Data, &ev); err != nil {
+				imp.stats.Skipped++
+				continue
+			}
+			imp.dispatch(ev)
+		case "block":
+			imp.write_block(record.Chain, record.Block, record.Hash, record.Time, r.Data)
+		default:
+			if !imp.wanted(record.Chain) {
+				continue
+			}
+			imp.stats.Records++
+			if !imp.opts.DryRun {
+				sink.Write(record)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func (imp *importer) wanted(chain string) bool {
+	return imp.opts.Chain == "" || imp.opts.Chain == chain
+}
+
+// first_time ghi nhận key và cho biết nó chưa được nhập trước đó
+func (imp *importer) first_time(chain, key string) bool {
+	seen, ok := imp.seen[chain]
+	if !ok {
+		var err error
+		if seen, err = imp.store.Load(chain, 0); err != nil {
+			logging.Chain("import", chain).Warn("⚠️ Không đọc được key đã nhập", "error", err)
+			seen = make(map[string]bool)
+		}
+		imp.seen[chain] = seen
+	}
+	if seen[key] {
+		imp.stats.Duplicates++
+		return false
+	}
+	seen[key] = true
+	if !imp.opts.DryRun {
+		imp.store.Add(chain, key)
+	}
+	return true
+}
+
+func (imp *importer) dispatch(ev pipeline.Event) {
+	if !imp.wanted(ev.Chain) {
+		return
+	}
+	if !imp.first_time(ev.Chain, fmt.Sprintf("%d-%s-%d", ev.BlockNumber, ev.TxHash, ev.LogIndex)) {
+		return
+	}
+	imp.stats.Events++
+	if !imp.opts.DryRun {
+		pipeline.Dispatch(ev)
+	}
+}
+
+func (imp *importer) write_block(chain string, number uint64, hash string, blockTime time.Time, data interface{}) {
+	if !imp.wanted(chain) {
+		return
+	}
+	if !imp.first_time(chain, fmt.Sprintf("%d-block-%s", number, hash)) {
+		return
+	}
+	imp.stats.Blocks++
+	if !imp.opts.DryRun {
+		sink.Write(sink.Record{Kind: "block", Chain: chain, Block: number, Hash: hash, Time: blockTime, Data: data})
+	}
+}
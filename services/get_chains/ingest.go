@@ -0,0 +1,127 @@
+package get_chains
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"main/services/headercache"
+	"main/services/logging"
+	"main/services/metrics"
+	"main/services/pipeline"
+)
+
+// Kích thước hàng đợi của mỗi stage. Khi đầy, subscription/FilterLogs bị chặn
+// thay vì làm rơi log hoặc khóa toàn bộ các chain khác.
+const ingestQueueSize = 1000
+
+// new_chain_pipeline tạo pipeline decode → enrich → sink riêng cho một chain EVM.
+// Stage fetch chính là subscription/FilterLogs gọi submit_log.
+// Mỗi stage dùng 1 worker để giữ thứ tự log trong chain; các chain chạy song song.
+func new_chain_pipeline(chainName string) *pipeline.Pipeline {
+	return pipeline.New(chainName,
+		pipeline.StageConfig{
+			Name:      "decode",
+			Workers:   1,
+			QueueSize: ingestQueueSize,
+			Fn: func(ctx context.Context, item interface{}) (interface{}, error) {
+				vLog, ok := item.(types.Log)
+				if !ok {
+					return nil, fmt.Errorf("phần tử không phải types.Log: %T", item)
+				}
+				if decoded := process_handle_log(vLog, chainName); decoded != nil {
+					return decoded, nil
+				}
+				return nil, nil
+			},
+		},
+		pipeline.StageConfig{
+			Name:      "enrich",
+			Workers:   1,
+			QueueSize: ingestQueueSize,
+			Fn: func(ctx context.Context, item interface{}) (interface{}, error) {
+				decoded := item.(*decodedLog)
+				enrich_decoded_log(ctx, decoded, chainName)
+				return decoded, nil
+			},
+		},
+		pipeline.StageConfig{
+			Name:      "sink",
+			Workers:   1,
+			QueueSize: ingestQueueSize,
+			Fn: func(ctx context.Context, item interface{}) (interface{}, error) {
+				sink_decoded_log(item.(*decodedLog), chainName)
+				return nil, nil
+			},
+		},
+	)
+}
+
+// submit_log là stage fetch: đưa log vào pipeline của chain, chặn khi pipeline đầy.
+// Chain chưa có pipeline (vd: chạy một lần) thì xử lý đồng bộ.
+func submit_log(ctx context.Context, chainName string, vLog types.Log) error {
+	chainData := GetChainData(chainName)
+	if chainData == nil {
+		return fmt.Errorf("không tìm thấy dữ liệu cho chain %s", chainName)
+	}
+
+	if chainData.Pipeline != nil {
+		return chainData.Pipeline.Submit(ctx, vLog)
+	}
+
+	if decoded := process_handle_log(vLog, chainName); decoded != nil {
+		enrich_decoded_log(ctx, decoded, chainName)
+		sink_decoded_log(decoded, chainName)
+	}
+	return nil
+}
+
+func enrich_decoded_log(ctx context.Context, decoded *decodedLog, chainName string) {
+	resolve_transaction_type(decoded.logMap)
+
+	chainData := GetChainData(chainName)
+	if chainData == nil || chainData.Client == nil {
+		return
+	}
+
+	// Thời gian khối lấy từ header cache dùng chung, mỗi khối chỉ gọi RPC một lần
+	cache := headercache.ForChain(chainName)
+	fetch := headercache.EthFetcher(chainData.Client)
+
+	fetchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	header, err := cache.Get(fetchCtx, decoded.vLog.BlockNumber, fetch)
+	if err != nil {
+		logging.Chain("get_chains", chainName).Warn("⚠️ Không thể lấy header khối", "block", decoded.vLog.BlockNumber, "tx", decoded.vLog.TxHash.Hex(), "error", err)
+		return
+	}
+	decoded.logMap["timestamp"] = header.Timestamp().Format("2006-01-02 15:04:05")
+
+	if decoded.boundary != nil {
+		if previous, err := cache.Get(fetchCtx, decoded.boundary.Number, fetch); err == nil {
+			decoded.boundary.Hash = previous.Hash
+			decoded.boundary.Timestamp = previous.Timestamp()
+		}
+	}
+}
+
+func sink_decoded_log(decoded *decodedLog, chainName string) {
+	chainData := GetChainData(chainName)
+	if chainData == nil {
+		return
+	}
+
+	chainData.mu.Lock()
+	chainData.LogData = decoded.logMap
+	chainData.mu.Unlock()
+
+	if decoded.boundary != nil {
+		pipeline.DispatchBlock(*decoded.boundary)
+	}
+	metrics.TransfersProcessed.WithLabelValues(chainName).Inc()
+	pipeline.Dispatch(log_to_event(&decoded.vLog, chainName, decoded.logMap))
+	commit_decoded_log(chainData, decoded, chainName)
+}
@@ -2,27 +2,29 @@ package get_chains
 
 import (
 	"context"
-	"log"
 	"math/big"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/ethclient"
+
+	"main/services/logging"
+	"main/services/metrics"
 )
 
 func periodic_logs_monitoring(ctx context.Context, client *ethclient.Client, chainName string) {
+	logger := logging.Chain("get_chains", chainName)
 	chainData := GetChainData(chainName)
 	if chainData == nil {
-		log.Printf("Không tìm thấy dữ liệu cho chain %s", chainName)
+		logger.Warn("Không tìm thấy dữ liệu cho chain")
 		return
 	}
 
 	ticker := time.NewTicker(time.Duration(chainData.Config.TimeNeedToBlock) * time.Millisecond)
 	defer ticker.Stop()
 
-	log.Printf("Bắt đầu giám sát các logs bị bỏ lỡ cho chain %s (khoảng thời gian: %d ms)",
-		chainName, chainData.Config.TimeNeedToBlock)
+	logger.Info("Bắt đầu giám sát các logs bị bỏ lỡ", "interval_ms", chainData.Config.TimeNeedToBlock)
 
 	for {
 		select {
@@ -31,60 +33,58 @@ func periodic_logs_monitoring(ctx context.Context, client *ethclient.Client, cha
 				return
 			}
 
-			bgCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			bgCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			start := time.Now()
 			latestBlock, err := client.BlockNumber(bgCtx)
 			cancel()
+			metrics.ObserveRPC(chainData.Config.WssRPC, "eth_blockNumber", start, err)
 
 			if err != nil {
-				log.Printf("Không thể lấy số khối mới nhất cho chain %s: %v", chainName, err)
+				logger.Warn("Không thể lấy số khối mới nhất", "error", err)
 				continue
 			}
+			observe_head(chainName, latestBlock)
 
-			processLock.Lock()
+			chainData.mu.Lock()
 			currentLastProcessed := new(big.Int).Set(chainData.LastProcessedBlock)
-			processLock.Unlock()
+			chainData.mu.Unlock()
 
 			currentLatestBlock := big.NewInt(int64(latestBlock))
 			blockDiff := new(big.Int).Sub(currentLatestBlock, currentLastProcessed)
 
 			if blockDiff.Cmp(big.NewInt(1)) > 0 {
-				log.Printf("Phát hiện %d khối bị bỏ lỡ cho chain %s. Đang lấy logs...",
-					blockDiff, chainName)
-
-				select {
-				case chainData.DisconnectedChannel <- struct{}{}:
-				default:
-					log.Printf("Channel DisconnectedChannel đã đầy, bỏ qua tín hiệu lấy logs bị bỏ lỡ")
-				}
+				logger.Info("Phát hiện khối bị bỏ lỡ, đang lấy logs...", "missed", blockDiff, "block", latestBlock)
+				signal_disconnected(chainData)
 			}
 
 		case <-ctx.Done():
-			log.Println("Dừng giám sát logs bị bỏ lỡ theo yêu cầu context")
+			logger.Info("Dừng giám sát logs bị bỏ lỡ theo yêu cầu context")
 			return
 		}
 	}
 }
 
 func handle_disconnected_logs(client *ethclient.Client, chainName string) {
+	logger := logging.Chain("get_chains", chainName)
 	chainData := GetChainData(chainName)
 	if chainData == nil {
-		log.Printf("Không tìm thấy dữ liệu cho chain %s", chainName)
+		logger.Warn("Không tìm thấy dữ liệu cho chain")
 		return
 	}
 
-	processLock.Lock()
+	chainData.mu.Lock()
 	if chainData.IsProcessingReorg {
-		log.Printf("Đã có một quá trình xử lý reorg đang chạy cho chain %s, bỏ qua...", chainName)
-		processLock.Unlock()
+		logger.Info("Đã có một quá trình xử lý reorg đang chạy, bỏ qua...")
+		chainData.mu.Unlock()
 		return
 	}
 	chainData.IsProcessingReorg = true
-	processLock.Unlock()
+	chainData.mu.Unlock()
 
 	defer func() {
-		processLock.Lock()
+		chainData.mu.Lock()
 		chainData.IsProcessingReorg = false
-		processLock.Unlock()
+		chainData.mu.Unlock()
 	}()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
@@ -92,131 +92,107 @@ func handle_disconnected_logs(client *ethclient.Client, chainName string) {
 
 	latestBlock, err := client.BlockNumber(ctx)
 	if err != nil {
-		log.Printf("❌ Không thể lấy số khối mới nhất cho chain %s: %v", chainName, err)
+		logger.Error("❌ Không thể lấy số khối mới nhất", "error", err)
 		return
 	}
 
-	processLock.Lock()
+	chainData.mu.Lock()
 	fromBlock := new(big.Int).Add(chainData.LastProcessedBlock, big.NewInt(1))
-	processLock.Unlock()
+	if chainData.PendingReorgFrom != nil {
+		if chainData.PendingReorgFrom.Cmp(fromBlock) < 0 {
+			fromBlock.Set(chainData.PendingReorgFrom)
+		}
+		chainData.PendingReorgFrom = nil
+	}
+	chainData.mu.Unlock()
 
 	toBlock := big.NewInt(int64(latestBlock))
 
 	if fromBlock.Cmp(toBlock) > 0 {
-		log.Printf("ℹ️ Không có khối mới để xử lý cho chain %s. Khối mới nhất đã xử lý: %d",
-			chainName, chainData.LastProcessedBlock)
+		logger.Info("ℹ️ Không có khối mới để xử lý", "block", latestBlock)
 		return
 	}
 
 	if blockDiff := new(big.Int).Sub(toBlock, fromBlock).Int64(); blockDiff > 5000 {
-		log.Printf("⚠️ Khoảng khối quá lớn (%d) cho chain %s, giới hạn xuống 5000 khối", blockDiff, chainName)
+		logger.Warn("⚠️ Khoảng khối quá lớn, giới hạn xuống 5000 khối", "blocks", blockDiff)
 		toBlock = new(big.Int).Add(fromBlock, big.NewInt(5000))
 	}
 
-	log.Printf("🔍 Đang lấy các log bị bỏ lỡ từ khối %d đến %d cho chain %s...",
-		fromBlock, toBlock, chainName)
+	logger.Info("🔍 Đang lấy các log bị bỏ lỡ", "from", fromBlock, "to", toBlock)
 
 	process_query_range(client, chainName, fromBlock, toBlock)
 
-	processLock.Lock()
+	// Chờ pipeline xử lý xong các log vừa lấy trước khi dời mốc khối đã xử lý
+	if chainData.Pipeline != nil && !chainData.Pipeline.Drain(2*time.Minute) {
+		logger.Warn("⚠️ Pipeline chưa xử lý xong sau 2 phút", "from", fromBlock, "to", toBlock)
+	}
+
+	chainData.mu.Lock()
 	chainData.LastProcessedBlock = toBlock
-	processLock.Unlock()
+	advance_checkpoint(chainData, toBlock.Uint64())
+	chainData.mu.Unlock()
 
-	log.Printf("✅ Đã cập nhật khối cuối cùng được xử lý cho %s = %d", chainName, toBlock)
-	log.Printf("🎉 Đã xử lý xong tất cả logs bị bỏ lỡ trong khoảng từ %d đến %d cho chain %s",
-		fromBlock, toBlock, chainName)
+	logger.Info("🎉 Đã xử lý xong logs bị bỏ lỡ, cập nhật khối cuối cùng đã xử lý", "from", fromBlock, "to", toBlock)
 }
 
+// process_query_range lấy logs trong [fromBlock, toBlock] theo từng khoảng tối đa 1000 khối
+// và đưa vào pipeline của chain
 func process_query_range(client *ethclient.Client, chainName string, fromBlock, toBlock *big.Int) {
+	logger := logging.Chain("get_chains", chainName)
 	maxBlockRange := big.NewInt(1000)
 	blockDiff := new(big.Int).Sub(toBlock, fromBlock)
+	endpoint := chainName
+	if chainData := GetChainData(chainName); chainData != nil {
+		endpoint = chainData.Config.WssRPC
+	}
 	totalBlocks := blockDiff.Int64() + 1
+	totalRanges := (blockDiff.Int64() / maxBlockRange.Int64()) + 1
 
-	log.Printf("📊 Bắt đầu quét %d khối từ %d đến %d cho chain %s",
-		totalBlocks, fromBlock, toBlock, chainName)
+	logger.Info("📊 Bắt đầu quét khoảng khối", "from", fromBlock, "to", toBlock, "blocks", totalBlocks, "ranges", totalRanges)
 
-	if blockDiff.Cmp(maxBlockRange) > 0 {
-		log.Printf("⚙️ Khoảng khối quá lớn (%d), đang chia thành các khoảng nhỏ hơn...", totalBlocks)
+	currentFromBlock := new(big.Int).Set(fromBlock)
+	processedRanges := 0
+	totalLogs := 0
 
-		currentFromBlock := new(big.Int).Set(fromBlock)
-		processedRanges := 0
-		totalRanges := (blockDiff.Int64() / maxBlockRange.Int64()) + 1
-		totalLogs := 0
-
-		for currentFromBlock.Cmp(toBlock) < 0 {
-			processedRanges++
-
-			currentToBlock := new(big.Int).Add(currentFromBlock, maxBlockRange)
-			if currentToBlock.Cmp(toBlock) > 0 {
-				currentToBlock.Set(toBlock)
-			}
-
-			rangeSize := new(big.Int).Sub(currentToBlock, currentFromBlock).Int64() + 1
-
-			log.Printf("🔄 Xử lý khoảng con (%d/%d): %d đến %d (%d khối) cho chain %s",
-				processedRanges, totalRanges, currentFromBlock, currentToBlock, rangeSize, chainName)
-
-			query := create_query(chainName, currentFromBlock, currentToBlock)
-
-			queryCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			logs, err := client.FilterLogs(queryCtx, query)
-			cancel()
+	for currentFromBlock.Cmp(toBlock) <= 0 {
+		processedRanges++
 
-			if err != nil {
-				log.Printf("❌ Lỗi khi lấy logs từ khối %d đến %d cho chain %s: %v",
-					currentFromBlock, currentToBlock, chainName, err)
-			} else {
-				logCount := len(logs)
-				totalLogs += logCount
-
-				if logCount > 0 {
-					log.Printf("✅ Tìm thấy %d giao dịch trong khoảng từ %d đến %d cho chain %s",
-						logCount, currentFromBlock, currentToBlock, chainName)
-
-					for i, vLog := range logs {
-						log.Printf("   📝 Xử lý giao dịch (%d/%d) tại khối %d, tx %s",
-							i+1, logCount, vLog.BlockNumber, vLog.TxHash.Hex())
-						process_handle_log(vLog, chainName)
-					}
-				} else {
-					log.Printf("⚠️ Không tìm thấy giao dịch nào trong khoảng từ %d đến %d cho chain %s",
-						currentFromBlock, currentToBlock, chainName)
-				}
-			}
-
-			currentFromBlock = new(big.Int).Add(currentToBlock, big.NewInt(1))
+		currentToBlock := new(big.Int).Add(currentFromBlock, maxBlockRange)
+		if currentToBlock.Cmp(toBlock) > 0 {
+			currentToBlock.Set(toBlock)
 		}
+		rangeLogger := logger.With("from", currentFromBlock.Uint64(), "to", currentToBlock.Uint64())
 
-		log.Printf("📋 Tổng kết: Đã quét %d khoảng, tìm thấy %d giao dịch từ khối %d đến %d cho chain %s",
-			processedRanges, totalLogs, fromBlock, toBlock, chainName)
-	} else {
-		query := create_query(chainName, fromBlock, toBlock)
+		query, err := create_query(chainName, currentFromBlock, currentToBlock)
+		if err != nil {
+			rangeLogger.Error("❌ Không thể tạo query lấy logs", "error", err)
+			return
+		}
 
 		queryCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		start := time.Now()
 		logs, err := client.FilterLogs(queryCtx, query)
 		cancel()
+		metrics.ObserveRPC(endpoint, "eth_getLogs", start, err)
 
 		if err != nil {
-			log.Printf("❌ Lỗi khi lấy logs từ khối %d đến %d cho chain %s: %v",
-				fromBlock, toBlock, chainName, err)
-			return
-		}
-
-		logCount := len(logs)
-		if logCount > 0 {
-			log.Printf("✅ Tìm thấy %d giao dịch trong khoảng từ khối %d đến %d cho chain %s",
-				logCount, fromBlock, toBlock, chainName)
+			rangeLogger.Error("❌ Lỗi khi lấy logs", "error", err)
+		} else {
+			totalLogs += len(logs)
+			rangeLogger.Debug("🔄 Đã lấy logs của khoảng con", "range", processedRanges, "logs", len(logs))
 
-			for i, vLog := range logs {
-				log.Printf("   📝 Xử lý giao dịch (%d/%d) tại khối %d, tx %s",
-					i+1, logCount, vLog.BlockNumber, vLog.TxHash.Hex())
-				process_handle_log(vLog, chainName)
+			for _, vLog := range logs {
+				logger.Debug("📝 Xử lý giao dịch", "block", vLog.BlockNumber, "tx", vLog.TxHash.Hex())
+				if err := submit_log(context.Background(), chainName, vLog); err != nil {
+					logger.Error("❌ Không thể đưa log vào pipeline", "block", vLog.BlockNumber, "tx", vLog.TxHash.Hex(), "error", err)
+				}
 			}
-		} else {
-			log.Printf("⚠️ Không tìm thấy giao dịch nào trong khoảng từ khối %d đến %d cho chain %s",
-				fromBlock, toBlock, chainName)
 		}
+
+		currentFromBlock = new(big.Int).Add(currentToBlock, big.NewInt(1))
 	}
+
+	logger.Info("📋 Đã quét xong khoảng khối", "from", fromBlock, "to", toBlock, "ranges", processedRanges, "logs", totalLogs)
 }
 
 func clean_processed_transactions(chainName string, cutoffBlock uint64) {
@@ -236,6 +212,5 @@ func clean_processed_transactions(chainName string, cutoffBlock uint64) {
 		}
 	}
 	chainData.ProcessedTxs = newMap
-	log.Printf("✅ Đã làm sạch map processedTransactions cho %s. Kích thước mới: %d",
-		chainName, len(chainData.ProcessedTxs))
+	logging.Chain("get_chains", chainName).Info("✅ Đã làm sạch map processedTransactions", "size", len(chainData.ProcessedTxs), "cutoff_block", cutoffBlock)
 }
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"math/big"
 	"strings"
 	"time"
@@ -13,59 +12,134 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+
+	"main/services/dedup"
+	"main/services/logging"
+	"main/services/metrics"
+	"main/services/pipeline"
 )
 
-func process_handle_log(vLog types.Log, chainName string) {
-	processLock.Lock()
-	defer processLock.Unlock()
+// decodedLog là kết quả của stage decode, đi tiếp qua enrich và sink
+type decodedLog struct {
+	vLog     types.Log
+	logMap   map[string]interface{}
+	boundary *pipeline.Block
+
+	// txKey được ghi vào dedup store và advance dời LastProcessedBlock sau khi sink xong,
+	// để checkpoint không vượt qua log còn nằm trong pipeline
+	txKey   string
+	advance bool
+}
 
+func process_handle_log(vLog types.Log, chainName string) *decodedLog {
 	chainData := GetChainData(chainName)
 	if chainData == nil {
-		log.Printf("Không tìm thấy dữ liệu cho chain %s", chainName)
-		return
+		logging.Chain("get_chains", chainName).Warn("Không tìm thấy dữ liệu cho chain")
+		return nil
 	}
 
+	chainData.mu.Lock()
+	defer chainData.mu.Unlock()
+
 	blockNumber := big.NewInt(int64(vLog.BlockNumber))
 	txHash := vLog.TxHash.Hex()
 
 	txKey := fmt.Sprintf("%d-%s-%d", vLog.BlockNumber, txHash, vLog.Index)
 
+	rule, matched := match_log(chainData.Filters, &vLog)
+	if !matched {
+		return nil
+	}
+
 	logMap := log_to_map(&vLog, chainName)
-	chainData.LogData = logMap
+	if rule != "" {
+		logMap["rule"] = rule
+	}
+
+	logger := logging.Chain("get_chains", chainName).With("block", vLog.BlockNumber, "tx", txHash)
+	logger.Debug("💼 Giao dịch", "address", vLog.Address.Hex(), "index", vLog.Index)
 
-	log.Printf("💼 GIAO DỊCH: Khối=%d, TxHash=%s, Địa chỉ=%s, Index=%d",
-		vLog.BlockNumber, txHash, vLog.Address.Hex(), vLog.Index)
+	// Log bị gỡ do reorg có cùng txKey với log gốc và nằm ở khối cũ, nên phải xử lý trước
+	// kiểm tra reorg và dedup để bản rút lại tới được sink
+	if vLog.Removed {
+		removedKey := txKey + "-removed"
+		if chainData.ProcessedTxs[removedKey] {
+			metrics.DedupHits.WithLabelValues(chainName).Inc()
+			return nil
+		}
+		chainData.ProcessedTxs[removedKey] = true
+		// Log có thể được đưa lại vào khối mới cùng số, khi đó phải xử lý lại
+		delete(chainData.ProcessedTxs, txKey)
+		logger.Info("↩️ Log bị gỡ do reorg, gửi bản rút lại")
+		return &decodedLog{vLog: vLog, logMap: logMap}
+	}
 
-	if blockNumber.Cmp(chainData.LastProcessedBlock) <= 0 {
-		if !chainData.IsProcessingReorg {
-			log.Printf("⚠️ Phát hiện reorg trên chain %s, đang xử lý lại từ khối %d",
-				chainName, blockNumber)
+	// Trong lúc quét lại (reorg/bị lỡ), log của khối cũ được phép đi tiếp và chỉ bị chặn bởi dedup
+	if blockNumber.Cmp(chainData.LastProcessedBlock) < 0 && !chainData.IsProcessingReorg {
+		logger.Warn("⚠️ Phát hiện reorg, đang xử lý lại từ khối này")
+		metrics.ReorgsDetected.WithLabelValues(chainName).Inc()
 
-			select {
-			case chainData.DisconnectedChannel <- struct{}{}:
-				chainData.IsProcessingReorg = true
-			default:
-				log.Printf("Channel đã đầy, bỏ qua tín hiệu reorg")
-			}
+		if chainData.PendingReorgFrom == nil || blockNumber.Cmp(chainData.PendingReorgFrom) < 0 {
+			chainData.PendingReorgFrom = new(big.Int).Set(blockNumber)
 		}
-		return
+		signal_disconnected(chainData)
+		return nil
 	}
 
 	if chainData.ProcessedTxs[txKey] {
-		return
+		metrics.DedupHits.WithLabelValues(chainName).Inc()
+		return nil
 	}
 
 	if len(chainData.ProcessedTxs) > 100000 {
-		log.Printf("⚠️ Map processedTransactions cho %s quá lớn (%d), đang làm sạch...",
-			chainName, len(chainData.ProcessedTxs))
+		logger.Warn("⚠️ Map processedTransactions quá lớn, đang làm sạch...", "size", len(chainData.ProcessedTxs))
 		cutoffBlock := blockNumber.Uint64() - 1000
 		clean_processed_transactions(chainName, cutoffBlock)
 	} else {
 		chainData.ProcessedTxs[txKey] = true
 	}
+	delete(chainData.ProcessedTxs, txKey+"-removed")
+
+	decoded := &decodedLog{vLog: vLog, logMap: logMap, txKey: txKey}
+
+	// LastProcessedBlock chỉ dời sau sink nên decode theo dõi khối mới nhất đã decode riêng
+	head := max(chainData.decodedBlock, chainData.LastProcessedBlock.Uint64())
+	if !chainData.IsProcessingReorg && vLog.BlockNumber > head {
+		// Khối trước đã nhận đủ log, báo ranh giới khối cho các processor
+		if head > 0 {
+			decoded.boundary = &pipeline.Block{Chain: chainName, Number: head, Timestamp: time.Now()}
+		}
+		chainData.decodedBlock = vLog.BlockNumber
+		decoded.advance = true
+	}
+
+	return decoded
+}
+
+// commit_decoded_log ghi nhận log đã tới sink: lưu key dedup và dời khối đã xử lý
+func commit_decoded_log(chainData *ChainData, decoded *decodedLog, chainName string) {
+	if decoded.txKey != "" {
+		dedup.Default().Add(chainName, decoded.txKey)
+	}
+	if !decoded.advance {
+		return
+	}
+
+	chainData.mu.Lock()
+	defer chainData.mu.Unlock()
+	if decoded.vLog.BlockNumber > chainData.LastProcessedBlock.Uint64() {
+		chainData.LastProcessedBlock.SetUint64(decoded.vLog.BlockNumber)
+		observe_processed(chainName, decoded.vLog.BlockNumber)
+	}
+	advance_checkpoint(chainData, decoded.vLog.BlockNumber)
+}
 
-	if !chainData.IsProcessingReorg && blockNumber.Cmp(chainData.LastProcessedBlock) > 0 {
-		chainData.LastProcessedBlock.Set(blockNumber)
+// signal_disconnected báo cho goroutine xử lý log bị bỏ lỡ. Channel chỉ chứa 1 tín hiệu:
+// nếu đã đầy thì một lần quét đang chờ sẽ bao phủ luôn khoảng này nên không mất tín hiệu.
+func signal_disconnected(chainData *ChainData) {
+	select {
+	case chainData.DisconnectedChannel <- struct{}{}:
+	default:
 	}
 }
 
@@ -77,15 +151,16 @@ func handle_logs(ctx context.Context, client *ethclient.Client, chainName string
 	retryCount := 0
 
 	sessionProcessed := make(map[string]bool)
+	logger := logging.Chain("get_chains", chainName)
 
-	chainData := GetChainData(chainName)
-	if chainData == nil {
-		log.Fatalf("Không tìm thấy dữ liệu cho chain %s", chainName)
+	if GetChainData(chainName) == nil {
+		logger.Error("❌ Không tìm thấy dữ liệu cho chain, dừng xử lý logs")
+		return
 	}
 
 	for {
 		if ctx.Err() != nil {
-			log.Println("Context đã kết thúc, dừng xử lý logs")
+			logger.Info("Context đã kết thúc, dừng xử lý logs")
 			return
 		}
 
@@ -105,13 +180,19 @@ func handle_logs(ctx context.Context, client *ethclient.Client, chainName string
 					}
 
 					logKey := fmt.Sprintf("%d-%s-%d", vLog.BlockNumber, vLog.TxHash.Hex(), vLog.Index)
+					if vLog.Removed {
+						logKey += "-removed"
+					}
 
 					if sessionProcessed[logKey] {
+						metrics.DedupHits.WithLabelValues(chainName).Inc()
 						continue
 					}
 
 					sessionProcessed[logKey] = true
-					process_handle_log(vLog, chainName)
+					if err := submit_log(subCtx, chainName, vLog); err != nil {
+						return
+					}
 
 				case <-subCtx.Done():
 					return
@@ -119,7 +200,7 @@ func handle_logs(ctx context.Context, client *ethclient.Client, chainName string
 			}
 		}()
 
-		log.Printf("Thiết lập subscription mới cho logs từ khối mới nhất (chain: %s)", chainName)
+		logger.Info("Thiết lập subscription mới cho logs từ khối mới nhất")
 		err := subscribe_to_logs(subCtx, client, logs, chainName)
 		cancel()
 
@@ -127,7 +208,7 @@ func handle_logs(ctx context.Context, client *ethclient.Client, chainName string
 
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
-				log.Println("Context bị hủy, dừng lắng nghe logs")
+				logger.Info("Context bị hủy, dừng lắng nghe logs")
 				return
 			}
 
@@ -136,12 +217,11 @@ func handle_logs(ctx context.Context, client *ethclient.Client, chainName string
 			currentRetryDelay := retryDelay + jitter
 
 			if retryCount > maxRetries {
-				log.Printf("Đã vượt quá số lần thử lại tối đa (%d), đang reset bộ đếm", maxRetries)
+				logger.Warn("Đã vượt quá số lần thử lại tối đa, đang reset bộ đếm", "max_retries", maxRetries)
 				retryCount = 0
 			}
 
-			log.Printf("Lỗi kết nối log cho %s: %v. Thử lại sau %v... (lần thử: %d/%d)",
-				chainName, err, currentRetryDelay, retryCount, maxRetries)
+			logger.Error("Lỗi kết nối log, sẽ thử lại", "error", err, "retry_in", currentRetryDelay, "attempt", retryCount, "max_retries", maxRetries)
 
 			timer := time.NewTimer(currentRetryDelay)
 			select {
@@ -149,7 +229,7 @@ func handle_logs(ctx context.Context, client *ethclient.Client, chainName string
 				retryDelay = min(retryDelay*2, maxRetryDelay)
 			case <-ctx.Done():
 				timer.Stop()
-				log.Println("Context bị hủy trong khi đợi thử lại")
+				logger.Info("Context bị hủy trong khi đợi thử lại")
 				return
 			}
 		} else {
@@ -170,9 +250,13 @@ func subscribe_to_logs(ctx context.Context, client *ethclient.Client, logs chan
 		return fmt.Errorf("không thể lấy số khối hiện tại: %v", err)
 	}
 
-	log.Printf("🔄 Bắt đầu theo dõi từ block mới nhất: %d cho chain %s", latestBlock, chainName)
+	logger := logging.Chain("get_chains", chainName)
+	logger.Info("🔄 Bắt đầu theo dõi từ block mới nhất", "block", latestBlock)
 	currentBlock := big.NewInt(int64(latestBlock))
-	query := create_query(chainName, currentBlock, nil)
+	query, err := create_query(chainName, currentBlock, nil)
+	if err != nil {
+		return err
+	}
 
 	sub, err := client.SubscribeFilterLogs(ctx, query, logs)
 	if err != nil {
@@ -180,23 +264,27 @@ func subscribe_to_logs(ctx context.Context, client *ethclient.Client, logs chan
 	}
 	defer sub.Unsubscribe()
 
-	log.Printf("✅ Đã thiết lập subscription thành công, đang lắng nghe sự kiện...")
+	logger.Info("✅ Đã thiết lập subscription thành công, đang lắng nghe sự kiện...")
 
 	for {
 		select {
 		case err := <-sub.Err():
 			return fmt.Errorf("lỗi subscription: %v", err)
 		case <-ctx.Done():
-			log.Println("Context đã kết thúc, dừng subscription")
+			logger.Info("Context đã kết thúc, dừng subscription")
 			return ctx.Err()
 		}
 	}
 }
 
-func create_query(chainName string, fromBlock, toBlock *big.Int) ethereum.FilterQuery {
+func create_query(chainName string, fromBlock, toBlock *big.Int) (ethereum.FilterQuery, error) {
 	chainData := GetChainData(chainName)
 	if chainData == nil {
-		log.Fatalf("Không tìm thấy dữ liệu cho chain %s", chainName)
+		return ethereum.FilterQuery{}, fmt.Errorf("không tìm thấy dữ liệu cho chain %s", chainName)
+	}
+
+	if has_log_filters(chainData.Filters) {
+		return filters_to_query(chainData.Filters, fromBlock, toBlock), nil
 	}
 
 	chainData.Config.EthContractAddress = strings.ToLower(chainData.Config.EthContractAddress)
@@ -211,7 +299,6 @@ func create_query(chainName string, fromBlock, toBlock *big.Int) ethereum.Filter
 		common.HexToAddress(chainData.Config.WrappedBTCAddress),
 	}
 
-
 	// var topics [][]common.Hash
 	// if chainData.Config.TransferSignature != "" {
 	// 	topics = [][]common.Hash{
@@ -224,6 +311,22 @@ func create_query(chainName string, fromBlock, toBlock *big.Int) ethereum.Filter
 		ToBlock:   toBlock,
 		Addresses: addresses,
 		// Topics:    topics,
+	}, nil
+}
+
+// resolve_transaction_type tra tên event từ signature (gọi API bên ngoài nên thuộc stage enrich)
+func resolve_transaction_type(logMap map[string]interface{}) {
+	eventSignature, ok := logMap["event_signature"].(string)
+	if !ok {
+		return
+	}
+
+	if transactionType, err := Parse_event_signature_name(eventSignature); err == nil {
+		logMap["transaction_type"] = transactionType
+	} else {
+		chainName, _ := logMap["name_chain"].(string)
+		logging.Chain("get_chains", chainName).Debug("Không thể parse event signature", "signature", eventSignature, "tx", logMap["tx_hash"], "error", err)
+		logMap["transaction_type"] = "Unknown"
 	}
 }
 
@@ -242,15 +345,7 @@ func log_to_map(logData *types.Log, chainName string) map[string]interface{} {
 	logMap["raw_data"] = fmt.Sprintf("%x", logData.Data)
 
 	if len(logData.Topics) > 0 {
-		eventSignature := logData.Topics[0].Hex()
-		logMap["event_signature"] = eventSignature
-
-		if transactionType, err := Parse_event_signature_name(eventSignature); err == nil {
-			logMap["transaction_type"] = transactionType
-		} else {
-			log.Printf("Không thể parse event signature %s: %v", eventSignature, err)
-			logMap["transaction_type"] = "Unknown"
-		}
+		logMap["event_signature"] = logData.Topics[0].Hex()
 
 		for i, topic := range logData.Topics {
 			logMap[fmt.Sprintf("topic_%d", i)] = topic.Hex()
@@ -267,12 +362,5 @@ func log_to_map(logData *types.Log, chainName string) map[string]interface{} {
 		logMap["to_address"] = toAddr
 	}
 
-	chainData := GetChainData(chainName)
-	if chainData != nil {
-		chainData.LogData = logMap
-	} else {
-		log.Printf("Cảnh báo: Không tìm thấy dữ liệu cho chain %s", chainName)
-	}
-
 	return logMap
 }
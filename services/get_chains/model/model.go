@@ -2,6 +2,7 @@ package model
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,56 +37,65 @@ type ConfigStellar struct {
 	Network    string `json:"network"` // "public" hoặc "testnet"
 }
 
-// ChainDataTezos cho Tezos
+// ChainDataTezos cho Tezos. Cấu hình được thay nguyên khối khi file được nạp lại trong lúc
+// các vòng quét đang đọc nên giữ trong atomic.Pointer, đọc qua Config().
 type ChainDataTezos struct {
 	LastProcessedBlock int64
-	Config             ConfigTezos
+	config             atomic.Pointer[ConfigTezos]
 	LogData            map[string]interface{}
 }
 
+func (c *ChainDataTezos) Config() ConfigTezos                   { return loadConfig(&c.config) }
 func (c *ChainDataTezos) GetLastProcessedBlockVan() int64       { return c.LastProcessedBlock }
 func (c *ChainDataTezos) SetLastProcessedBlockVan(n int64)      { c.LastProcessedBlock = n }
-func (c *ChainDataTezos) GetConfigVan() interface{}             { return c.Config }
-func (c *ChainDataTezos) SetConfigVan(cfg interface{})          { c.Config = cfg.(ConfigTezos) }
+func (c *ChainDataTezos) GetConfigVan() interface{}             { return c.Config() }
+func (c *ChainDataTezos) SetConfigVan(cfg interface{})          { storeConfig(&c.config, cfg.(ConfigTezos)) }
 func (c *ChainDataTezos) GetLogDataVan() map[string]interface{} { return c.LogData }
 
 // ChainDataElrond cho Elrond
 type ChainDataElrond struct {
 	LastProcessedBlock int64
-	Config             ConfigElrond
+	config             atomic.Pointer[ConfigElrond]
 	LogData            map[string]interface{}
 }
 
+func (c *ChainDataElrond) Config() ConfigElrond                  { return loadConfig(&c.config) }
 func (c *ChainDataElrond) GetLastProcessedBlockVan() int64       { return c.LastProcessedBlock }
 func (c *ChainDataElrond) SetLastProcessedBlockVan(n int64)      { c.LastProcessedBlock = n }
-func (c *ChainDataElrond) GetConfigVan() interface{}             { return c.Config }
-func (c *ChainDataElrond) SetConfigVan(cfg interface{})          { c.Config = cfg.(ConfigElrond) }
+func (c *ChainDataElrond) GetConfigVan() interface{}             { return c.Config() }
+func (c *ChainDataElrond) SetConfigVan(cfg interface{})          { storeConfig(&c.config, cfg.(ConfigElrond)) }
 func (c *ChainDataElrond) GetLogDataVan() map[string]interface{} { return c.LogData }
 
 // ChainDataAlgorand cho Algorand
 type ChainDataAlgorand struct {
 	LastProcessedBlock int64
-	Config             ConfigAlgorand
+	config             atomic.Pointer[ConfigAlgorand]
 	LogData            map[string]interface{}
 }
 
-func (c *ChainDataAlgorand) GetLastProcessedBlockVan() int64       { return c.LastProcessedBlock }
-func (c *ChainDataAlgorand) SetLastProcessedBlockVan(n int64)      { c.LastProcessedBlock = n }
-func (c *ChainDataAlgorand) GetConfigVan() interface{}             { return c.Config }
-func (c *ChainDataAlgorand) SetConfigVan(cfg interface{})          { c.Config = cfg.(ConfigAlgorand) }
+func (c *ChainDataAlgorand) Config() ConfigAlgorand           { return loadConfig(&c.config) }
+func (c *ChainDataAlgorand) GetLastProcessedBlockVan() int64  { return c.LastProcessedBlock }
+func (c *ChainDataAlgorand) SetLastProcessedBlockVan(n int64) { c.LastProcessedBlock = n }
+func (c *ChainDataAlgorand) GetConfigVan() interface{}        { return c.Config() }
+func (c *ChainDataAlgorand) SetConfigVan(cfg interface{}) {
+	storeConfig(&c.config, cfg.(ConfigAlgorand))
+}
 func (c *ChainDataAlgorand) GetLogDataVan() map[string]interface{} { return c.LogData }
 
 // ChainDataStellar cho Stellar
 type ChainDataStellarVan struct {
 	LastProcessedLedger int64 // Sử dụng Ledger thay vì Block cho Stellar
-	Config              ConfigStellar
+	config              atomic.Pointer[ConfigStellar]
 	LogData             map[string]interface{}
 }
 
-func (c *ChainDataStellarVan) GetLastProcessedBlockVan() int64       { return c.LastProcessedLedger }
-func (c *ChainDataStellarVan) SetLastProcessedBlockVan(n int64)      { c.LastProcessedLedger = n }
-func (c *ChainDataStellarVan) GetConfigVan() interface{}             { return c.Config }
-func (c *ChainDataStellarVan) SetConfigVan(cfg interface{})          { c.Config = cfg.(ConfigStellar) }
+func (c *ChainDataStellarVan) Config() ConfigStellar            { return loadConfig(&c.config) }
+func (c *ChainDataStellarVan) GetLastProcessedBlockVan() int64  { return c.LastProcessedLedger }
+func (c *ChainDataStellarVan) SetLastProcessedBlockVan(n int64) { c.LastProcessedLedger = n }
+func (c *ChainDataStellarVan) GetConfigVan() interface{}        { return c.Config() }
+func (c *ChainDataStellarVan) SetConfigVan(cfg interface{}) {
+	storeConfig(&c.config, cfg.(ConfigStellar))
+}
 func (c *ChainDataStellarVan) GetLogDataVan() map[string]interface{} { return c.LogData }
 
 // TransactionRecordVan định nghĩa bản ghi giao dịch
@@ -167,8 +177,20 @@ type AlgorandBlockResponseVan struct {
 	Transactions      []AlgorandTransactionVan `json:"transactions"` // Thêm danh sách giao dịch
 }
 
+// loadConfig trả về cấu hình đang dùng, giá trị rỗng nếu chưa nạp
+func loadConfig[T any](p *atomic.Pointer[T]) T {
+	if cfg := p.Load(); cfg != nil {
+		return *cfg
+	}
+	var zero T
+	return zero
+}
+
+func storeConfig[T any](p *atomic.Pointer[T], cfg T) { p.Store(&cfg) }
+
 // Biến toàn cục
 var ChainDataMapVan = make(map[string]ChainDataVan) // Sử dụng interface
+var ChainDataMapVanLock sync.Mutex                  // giữ khi đọc/ghi ChainDataMapVan
 var LogMutexVan sync.Mutex
 var ProcessLockVan sync.Mutex
 
@@ -4,21 +4,43 @@ import (
 	"math/big"
 	"sync"
 	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"main/services/pipeline"
 )
 
 type Config struct {
-	RPC                 string `json:"rpc"`
-	WssRPC              string `json:"wssRpc"`
-	WssToken            string `json:"wssToken"`
-	TransferSignature   string `json:"transferSignature"`
-	Chain               string `json:"chain"`
-	EthContractAddress  string `json:"ethContractAddress"`
-	UsdtContractAddress string `json:"usdtContractAddress"`
-	UsdcContractAddress string `json:"usdcContractAddress"`
-	WrappedBTCAddress   string `json:"wrappedBTCAddress"`
-	TimeNeedToBlock     int    `json:"timeNeedToBlock"`
+	RPC                 string       `json:"rpc"`
+	WssRPC              string       `json:"wssRpc"`
+	WssToken            string       `json:"wssToken"`
+	TransferSignature   string       `json:"transferSignature"`
+	Chain               string       `json:"chain"`
+	EthContractAddress  string       `json:"ethContractAddress"`
+	UsdtContractAddress string       `json:"usdtContractAddress"`
+	UsdcContractAddress string       `json:"usdcContractAddress"`
+	WrappedBTCAddress   string       `json:"wrappedBTCAddress"`
+	TimeNeedToBlock     int          `json:"timeNeedToBlock"`
+	Filters             []FilterRule `json:"filters"`
+
+	// Budget của provider cho endpoint RPC (0 = không giới hạn)
+	RequestsPerSecond     float64            `json:"requestsPerSecond"`
+	ComputeUnitsPerSecond float64            `json:"computeUnitsPerSecond"`
+	ComputeUnitCosts      map[string]float64 `json:"computeUnitCosts"`
 }
 
+// FilterRule mô tả một bộ lọc sự kiện khai báo trong file cấu hình của chain.
+// Topics theo vị trí topic0..topic3, mỗi vị trí là danh sách giá trị chấp nhận (rỗng = bất kỳ).
+// Methods là các selector 4 byte (vd: 0xa9059cbb) dùng cho bộ quét HTTP theo giao dịch.
+type FilterRule struct {
+	Label     string     `json:"label"`
+	Contracts []string   `json:"contracts"`
+	Topics    [][]string `json:"topics"`
+	Methods   []string   `json:"methods"`
+	MinAmount string     `json:"minAmount"`
+	From      []string   `json:"from"`
+	To        []string   `json:"to"`
+}
 
 type ChainData struct {
 	Config              Config
@@ -27,6 +49,24 @@ type ChainData struct {
 	DisconnectedChannel chan struct{}
 	LogData             map[string]interface{}
 	IsProcessingReorg   bool
+	Filters             []*compiledFilter
+
+	// PendingReorgFrom là khối nhỏ nhất bị reorg đang chờ quét lại
+	PendingReorgFrom *big.Int
+	// Pipeline fetch → decode → enrich → sink riêng của chain
+	Pipeline *pipeline.Pipeline
+	// Client dùng cho stage enrich (lấy header khối qua cache dùng chung)
+	Client *ethclient.Client
+	// decodedBlock là khối mới nhất stage decode đã thấy; LastProcessedBlock chỉ dời khi
+	// log của khối đã qua sink
+	decodedBlock uint64
+	// checkpointHeight là khối cao nhất đã xử lý trọn theo chiều tiến, chỉ tăng; quét ngược
+	// hay quét lại không làm nó lùi nên checkpoint và cửa sổ dedup dựa vào nó
+	checkpointHeight uint64
+	// configLoaded cho biết Config và Filters đã được nạp; khởi động lại không đọc lại file
+	configLoaded bool
+	// mu bảo vệ trạng thái của riêng chain này, thay cho khóa toàn cục
+	mu sync.Mutex
 }
 
 type Transaction struct {
@@ -70,4 +110,3 @@ var chooseChain = map[string]string{
 	"base":      "./services/get_chains/config_chain/config-base.json",
 	"tron":      "./services/get_chains/config_chain/config-tron.json",
 }
-var processLock sync.Mutex
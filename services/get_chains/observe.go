@@ -0,0 +1,18 @@
+package get_chains
+
+import (
+	"main/services/health"
+	"main/services/metrics"
+)
+
+// observe_head ghi nhận đầu chuỗi cho metrics và health
+func observe_head(chainName string, head uint64) {
+	metrics.ObserveHead(chainName, head)
+	health.Chain(chainName).Head(head)
+}
+
+// observe_processed ghi nhận khối đã xử lý xong cho metrics và health
+func observe_processed(chainName string, processed uint64) {
+	metrics.ObserveProcessed(chainName, processed)
+	health.Chain(chainName).Progress(0, processed)
+}
@@ -0,0 +1,74 @@
+package get_chains
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"main/services/configwatch"
+	"main/services/get_chains/configs"
+	"main/services/get_chains/services"
+	"main/services/logging"
+)
+
+const (
+	vanConfigDir      = "./services/get_chains/configs"
+	binanceConfigPath = vanConfigDir + "/config-binance.json"
+)
+
+// Các chain đọc cấu hình qua configs.LoadConfig
+var vanChains = []string{"tezos", "elrond", "algorand", "stellar"}
+
+// watch_config_files đăng ký hot reload cho file cấu hình của các chain đang chạy
+func watch_config_files() {
+	for _, chain := range EVMChains() {
+		watch_evm_chain_config(chain)
+	}
+	for _, chain := range vanChains {
+		chain := chain
+		configwatch.Register(filepath.Join(vanConfigDir, "config-"+chain+".json"), func(path string, data []byte) error {
+			return configs.ReloadConfig(chain, data)
+		})
+	}
+	configwatch.Register(binanceConfigPath, reload_binance_config)
+}
+
+func watch_evm_chain_config(chain string) {
+	configwatch.Register(chain_config_path(chain), func(path string, data []byte) error {
+		return reload_evm_chain_config(chain, data)
+	})
+}
+
+// reload_evm_chain_config kiểm tra cấu hình mới, thay nó vào chain rồi kết nối lại. Ingest
+// tiếp tục từ checkpoint nên đổi endpoint, rule lọc hay ngưỡng đều không mất khối.
+func reload_evm_chain_config(chain string, data []byte) error {
+	cfg, filters, err := parse_chain_config(data)
+	if err != nil {
+		return fmt.Errorf("chain %s: %w", chain, err)
+	}
+	apply_chain_config(chain, cfg, filters)
+	if enabled_evm_chain(chain) {
+		logging.Chain("configwatch", chain).Info("🔄 Kết nối lại chain với cấu hình mới")
+		restart_evm_chain(chain)
+	}
+	return nil
+}
+
+// reload_binance_config áp dụng ngưỡng mới ngay; đổi ws_url hay danh sách token thì kết nối lại
+func reload_binance_config(path string, data []byte) error {
+	reconnect, err := services.ReloadBinanceConfig(data)
+	if err != nil {
+		return err
+	}
+	if !reconnect {
+		return nil
+	}
+
+	evmChainsLock.RLock()
+	sup := running
+	evmChainsLock.RUnlock()
+	if sup != nil {
+		logging.Service("configwatch").Info("🔄 Kết nối lại luồng Binance với danh sách token mới")
+		sup.Restart("btc-sol-ws")
+	}
+	return nil
+}
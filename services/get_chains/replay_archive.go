@@ -0,0 +1,122 @@
+package get_chains
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"main/services/archive"
+	"main/services/logging"
+	"main/services/pipeline"
+	"main/services/sink"
+)
+
+// unreplayable là các chain mà block ghi ra sink không đủ để chạy lại decoder: Cosmos lấy
+// giao dịch qua tx_search, VeChain chỉ ghi header còn giao dịch lấy từng cái qua REST.
+// Block của các chain này không được đưa vào kho lưu trữ.
+var unreplayable = map[string]bool{
+	"cosmos":  true,
+	"vechain": true,
+}
+
+// replay_decoders là decoder chạy lại của chain không phải EVM; chain khác dùng decoder EVM
+var replay_decoders = map[string]func(r sink.Record) error{
+	"tron": replay_tron_block,
+}
+
+// Replayable cho biết block của chain có chạy lại được từ kho hay không
+func Replayable(chainName string) bool {
+	return !unreplayable[chainName]
+}
+
+// ReplayArchivedBlocks chạy lại decoder của chain trên block đã lưu trong kho, không gọi
+// RPC. Với EVM, đây là phần giải mã giao dịch của processBlock: block và giao dịch thô
+// không được ghi lại vào sink, chỉ transfer và ranh giới khối đi qua pipeline như lúc
+// ingest; log theo topic cần receipt từ RPC nên không có trong lần chạy lại. Với Tron,
+// giao dịch được ghi lại vào sink như lúc ingest.
+func ReplayArchivedBlocks(chainName string, from, to uint64) (int, error) {
+	if !Replayable(chainName) {
+		return 0, fmt.Errorf("block của %s không chạy lại được từ kho", chainName)
+	}
+	decode, ok := replay_decoders[chainName]
+	if !ok {
+		InitChainData(chainName)
+		if err := load_config(chain_config_path(chainName), chainName); err != nil {
+			return 0, fmt.Errorf("không thể tải cấu hình cho %s: %w", chainName, err)
+		}
+		decode = func(r sink.Record) error {
+			block, ok := r.Data.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("block %d không phải block EVM", r.Block)
+			}
+			replay_block(chainName, r.Block, block)
+			return nil
+		}
+	}
+	logger := logging.Chain("get_chains", chainName)
+
+	count := 0
+	err := archive.Replay(archive.Dir(), chainName, from, to, func(r sink.Record) error {
+		if err := decode(r); err != nil {
+			return err
+		}
+		count++
+		if count%1000 == 0 {
+			logger.Info("🔁 Đang chạy lại block từ kho", "block", r.Block, "count", count)
+		}
+		return nil
+	})
+	return count, err
+}
+
+// replay_tron_block đọc lại TronBlock đã lưu rồi ghi giao dịch như processTronBlock
+func replay_tron_block(r sink.Record) error {
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	var block TronBlock
+	if err := json.Unmarshal(data, &block); err != nil {
+		return fmt.Errorf("block %d không phải block Tron: %w", r.Block, err)
+	}
+	processTronTransactions(&block)
+	pipeline.DispatchBlock(pipeline.Block{
+		Chain:     "tron",
+		Number:    block.Number,
+		Hash:      block.BlockID,
+		Timestamp: time.Unix(int64(block.Timestamp/1000), 0),
+		TxCount:   len(block.Transactions),
+	})
+	return nil
+}
+
+func replay_block(chainName string, blockNumber uint64, block map[string]interface{}) {
+	transactions, _ := block["transactions"].([]interface{})
+	blockTimeHex, _ := block["timestamp"].(string)
+	blockTimeInt := new(big.Int)
+	if len(blockTimeHex) > 2 {
+		blockTimeInt.SetString(blockTimeHex[2:], 16)
+	}
+
+	chainData := GetChainData(chainName)
+	if chainData != nil && has_transaction_filters(chainData.Filters) {
+		for _, tx := range transactions {
+			txMap, ok := tx.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			txMap["timestamp"] = blockTimeHex
+			processTransaction(txMap, chainName)
+		}
+	}
+
+	blockHash, _ := block["hash"].(string)
+	pipeline.DispatchBlock(pipeline.Block{
+		Chain:     chainName,
+		Number:    blockNumber,
+		Hash:      blockHash,
+		Timestamp: time.Unix(blockTimeInt.Int64(), 0),
+		TxCount:   len(transactions),
+	})
+}
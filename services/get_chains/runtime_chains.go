@@ -0,0 +1,292 @@
+package get_chains
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"main/services/configwatch"
+	"main/services/health"
+	"main/services/shard"
+	"main/services/supervisor"
+)
+
+// chainConfigDir chứa file cấu hình của các chain EVM (config-<chain>.json)
+const chainConfigDir = "./services/get_chains/config_chain"
+
+var chainNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// running là supervisor của get_chains khi đang chạy; dùng để thêm/bớt/khởi động lại
+// service của từng chain mà không đụng tới các chain khác. runningShards quyết định
+// chain nào được bật ở worker này. evmChainsLock bảo vệ evmChains, chooseChain,
+// running và runningShards.
+var (
+	evmChainsLock sync.RWMutex
+	running       *supervisor.Supervisor
+	runningShards *shard.Manager
+)
+
+func chain_config_path(chainName string) string {
+	evmChainsLock.RLock()
+	defer evmChainsLock.RUnlock()
+
+	if path, ok := chooseChain[chainName]; ok {
+		return path
+	}
+	return filepath.Join(chainConfigDir, "config-"+chainName+".json")
+}
+
+// EVMChains trả về danh sách chain EVM đang được ingest
+func EVMChains() []string {
+	evmChainsLock.RLock()
+	defer evmChainsLock.RUnlock()
+	return append([]string(nil), evmChains...)
+}
+
+// SetEVMChains thay danh sách chain EVM (dùng khi khôi phục cấu hình đã lưu, trước khi StartGetChains chạy)
+func SetEVMChains(names []string) error {
+	for _, name := range names {
+		if _, err := os.Stat(chain_config_path(name)); err != nil {
+			return fmt.Errorf("chain %s: %w", name, err)
+		}
+	}
+
+	evmChainsLock.Lock()
+	defer evmChainsLock.Unlock()
+	evmChains = append([]string(nil), names...)
+	return nil
+}
+
+// AddEVMChain bật ingest cho chain. Nếu raw khác rỗng, nó được ghi làm file cấu hình
+// của chain (cùng định dạng config-<chain>.json); nếu rỗng thì dùng file đã có.
+func AddEVMChain(name string, raw json.RawMessage) error {
+	if !chainNamePattern.MatchString(name) {
+		return fmt.Errorf("tên chain không hợp lệ: %q", name)
+	}
+
+	if enabled_evm_chain(name) {
+		return fmt.Errorf("chain %s đã được bật", name)
+	}
+
+	path := chain_config_path(name)
+	if len(raw) > 0 {
+		if _, _, err := parse_chain_config(raw); err != nil {
+			return fmt.Errorf("cấu hình không hợp lệ: %w", err)
+		}
+		if err := write_json_file(path, raw); err != nil {
+			return err
+		}
+	} else if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("chưa có cấu hình cho chain %s: %w", name, err)
+	}
+
+	evmChainsLock.Lock()
+	for _, chain := range evmChains {
+		if chain == name {
+			evmChainsLock.Unlock()
+			return fmt.Errorf("chain %s đã được bật", name)
+		}
+	}
+	chooseChain[name] = path
+	evmChains = append(evmChains, name)
+	sup, shards := running, runningShards
+	evmChainsLock.Unlock()
+
+	// Chain từng chạy trước khi bị gỡ: đọc lại file vì nó có thể đã đổi
+	unload_chain_config(name)
+
+	watch_evm_chain_config(name)
+	if sup != nil {
+		add_evm_chain_unit(shards, sup, name)
+	}
+	return nil
+}
+
+func enabled_evm_chain(name string) bool {
+	evmChainsLock.RLock()
+	defer evmChainsLock.RUnlock()
+
+	for _, chain := range evmChains {
+		if chain == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveEVMChain dừng ingest của chain; file cấu hình và checkpoint được giữ lại để bật lại sau
+func RemoveEVMChain(name string) error {
+	evmChainsLock.Lock()
+	index := -1
+	for i, chain := range evmChains {
+		if chain == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		evmChainsLock.Unlock()
+		return fmt.Errorf("chain %s chưa được bật", name)
+	}
+	evmChains = append(evmChains[:index], evmChains[index+1:]...)
+	shards := runningShards
+	evmChainsLock.Unlock()
+
+	if shards != nil {
+		shards.Remove(name)
+	}
+	return nil
+}
+
+// FilterRules trả về các rule lọc đang khai báo trong file cấu hình của chain
+func FilterRules(chain string) ([]FilterRule, error) {
+	fields, err := read_chain_config(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []FilterRule
+	if raw, ok := fields["filters"]; ok {
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// PutFilterRule thêm rule (hoặc thay rule cùng label), lưu vào file cấu hình
+// rồi khởi động lại riêng service của chain đó
+func PutFilterRule(chain string, rule FilterRule) error {
+	if strings.TrimSpace(rule.Label) == "" {
+		return fmt.Errorf("rule cần có label")
+	}
+
+	rules, err := FilterRules(chain)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range rules {
+		if rules[i].Label == rule.Label {
+			rules[i] = rule
+			replaced = true
+		}
+	}
+	if !replaced {
+		rules = append(rules, rule)
+	}
+	return save_filter_rules(chain, rules)
+}
+
+// RemoveFilterRule xóa rule theo label rồi khởi động lại service của chain
+func RemoveFilterRule(chain, label string) error {
+	rules, err := FilterRules(chain)
+	if err != nil {
+		return err
+	}
+
+	kept := rules[:0]
+	for _, rule := range rules {
+		if rule.Label != label {
+			kept = append(kept, rule)
+		}
+	}
+	if len(kept) == len(rules) {
+		return fmt.Errorf("chain %s không có rule %q", chain, label)
+	}
+	return save_filter_rules(chain, kept)
+}
+
+func save_filter_rules(chain string, rules []FilterRule) error {
+	if _, err := compile_filter_rules(rules); err != nil {
+		return err
+	}
+
+	fields, err := read_chain_config(chain)
+	if err != nil {
+		return err
+	}
+	if rules == nil {
+		rules = []FilterRule{}
+	}
+	if fields["filters"], err = json.Marshal(rules); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	cfg, filters, err := parse_chain_config(raw)
+	if err != nil {
+		return err
+	}
+	if err := write_json_file(chain_config_path(chain), raw); err != nil {
+		return err
+	}
+
+	apply_chain_config(chain, cfg, filters)
+	restart_evm_chain(chain)
+	return nil
+}
+
+// restart_evm_chain khởi động lại ingest của chain để dùng cấu hình vừa áp dụng (nếu chain đang chạy)
+func restart_evm_chain(chain string) {
+	evmChainsLock.RLock()
+	sup := running
+	evmChainsLock.RUnlock()
+
+	if sup != nil {
+		sup.Restart("evm-ws:" + chain)
+		sup.Restart("evm-http:" + chain)
+	}
+}
+
+// read_chain_config đọc file cấu hình dưới dạng map để giữ nguyên các khóa không có trong Config
+func read_chain_config(chain string) (map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(chain_config_path(chain))
+	if err != nil {
+		return nil, fmt.Errorf("không đọc được cấu hình của chain %s: %w", chain, err)
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("cấu hình của chain %s hỏng: %w", chain, err)
+	}
+	return fields, nil
+}
+
+// write_json_file ghi file qua file tạm rồi đổi tên để không để lại file ghi dở
+func write_json_file(path string, raw []byte) error {
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, raw, "", "  "); err != nil {
+		return err
+	}
+	pretty.WriteByte('\n')
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, pretty.Bytes(), 0o644); err != nil {
+		return err
+	}
+	// Thay đổi đã được áp dụng trực tiếp, watcher không cần nạp lại lần nữa
+	configwatch.MarkApplied(path, pretty.Bytes())
+	return os.Rename(tmp, path)
+}
+
+func add_evm_chain_services(sup *supervisor.Supervisor, chain string) {
+	sup.Add("evm-ws:"+chain, func(ctx context.Context) error {
+		err := handle_chain(ctx, chain)
+		if err != nil {
+			health.Chain(chain).Fail(err)
+		}
+		return err
+	}, supervisor.DefaultPolicy)
+	sup.Add("evm-http:"+chain, func(ctx context.Context) error {
+		return handle_chain_http(ctx, chain)
+	}, supervisor.DefaultPolicy)
+}
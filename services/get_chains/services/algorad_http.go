@@ -1,21 +1,27 @@
 package services
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"main/services/get_chains/configs"
 	"main/services/get_chains/model"
+	"main/services/poller"
 )
 
+// Chu kỳ round của Algorand, scheduler tự học lại từ dữ liệu thực
+const algorandRoundTime = 3 * time.Second
+
 // Khởi tạo dữ liệu cho chuỗi Algorand
 func InitAlgorandChainData(chainName string) *model.ChainDataAlgorand {
+	model.ChainDataMapVanLock.Lock()
+	defer model.ChainDataMapVanLock.Unlock()
 	if data, exists := model.ChainDataMapVan[chainName]; exists {
 		return data.(*model.ChainDataAlgorand)
 	}
@@ -177,26 +183,30 @@ func processAlgorandBlock(round int64, apiURL, indexerURL, chainName string) err
 }
 
 // Quét từ block hiện tại lùi về quá khứ
-func scanBackwardsFromCurrentBlock(chainName string, blocksToScan int, apiURL string, indexerURL string) {
+func scanBackwardsFromCurrentBlock(ctx context.Context, chainName string, blocksToScan int, apiURL string, indexerURL string) error {
 	// Lấy thông tin về block hiện tại
 	url := fmt.Sprintf("%s/v2/status", apiURL)
 	log.Printf("Fetching current block from: %s", url)
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Fatalf("Error fetching chain status: %v", err)
+		return fmt.Errorf("error fetching chain status: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf("Error reading response: %v", err)
+		return fmt.Errorf("error reading response: %w", err)
 	}
 
 	var status struct {
 		LastRound int64 `json:"last-round"`
 	}
 	if err := json.Unmarshal(body, &status); err != nil {
-		log.Fatalf("Error parsing JSON: %v", err)
+		return fmt.Errorf("error parsing JSON: %w", err)
 	}
 
 	currentBlock := status.LastRound
@@ -210,49 +220,57 @@ func scanBackwardsFromCurrentBlock(chainName string, blocksToScan int, apiURL st
 	}
 
 	log.Printf("Starting backward scan from block %d to block %d", startBlock, endBlock)
+	scheduler := newScheduler(chainName, apiURL, algorandRoundTime)
 
-	// Quét lùi từ block hiện tại
-	for blockNum := startBlock; blockNum >= endBlock; blockNum-- {
-		log.Printf("Processing block %d", blockNum)
-		err := processAlgorandBlock(blockNum, apiURL, indexerURL, chainName)
-		if err != nil {
-			log.Printf("Error processing block %d: %v, retrying...", blockNum, err)
-			// Thử lại sau 1 giây
-			time.Sleep(1 * time.Second)
-			err = processAlgorandBlock(blockNum, apiURL, indexerURL, chainName)
-			if err != nil {
-				log.Printf("Failed to process block %d after retry: %v, skipping", blockNum, err)
-				continue
-			}
+	// Quét lùi từ block hiện tại; là backfill nên chỉ bị giới hạn bởi budget của endpoint
+	for blockNum := startBlock; blockNum >= endBlock && ctx.Err() == nil; blockNum-- {
+		if !processAlgorandBlockRetry(ctx, scheduler, blockNum, apiURL, indexerURL, chainName) {
+			return nil
 		}
-
-		// Tạm dừng giữa các lần xử lý block để tránh quá tải API
-		time.Sleep(200 * time.Millisecond)
 	}
 
 	log.Printf("Completed backward scan from block %d to block %d", startBlock, endBlock)
+	return nil
 }
 
-// Hàm chính xử lý Algorand qua HTTP
-func Handle_algorand_http() {
-	chainName := "algorand"
-	apiURL := "https://mainnet-api.algonode.cloud"
-	indexerURL := "https://mainnet-idx.algonode.cloud"
-	blocksToScan := 1000 // Số block cần quét lùi
-
-	// Tạo thư mục log nếu chưa tồn tại
-	if _, err := os.Stat("./log"); os.IsNotExist(err) {
-		os.Mkdir("./log", 0755)
+// processAlgorandBlockRetry xử lý một block trong budget của scheduler, thử lại một lần sau
+// backoff nếu lỗi; trả về false khi ctx bị hủy
+func processAlgorandBlockRetry(ctx context.Context, scheduler *poller.Scheduler, blockNum int64, apiURL, indexerURL, chainName string) bool {
+	if err := scheduler.Acquire(ctx, "block"); err != nil {
+		return false
+	}
+	log.Printf("Processing block %d", blockNum)
+	err := processAlgorandBlock(blockNum, apiURL, indexerURL, chainName)
+	if err == nil {
+		scheduler.Success()
+		return true
 	}
 
-	// Mở file log
-	logFile, err := os.OpenFile("./services/get_chains/log/algorand_http.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatalf("Cannot open log file: %v", err)
+	if poller.IsRateLimited(err) {
+		scheduler.RateLimited()
+	}
+	retryIn := max(scheduler.Next(), time.Second)
+	log.Printf("Error processing block %d: %v, retrying in %v...", blockNum, err, retryIn)
+	if !sleepCtx(ctx, retryIn) {
+		return false
+	}
+	if err := scheduler.Acquire(ctx, "block"); err != nil {
+		return false
 	}
-	defer logFile.Close()
+	if err := processAlgorandBlock(blockNum, apiURL, indexerURL, chainName); err != nil {
+		log.Printf("Failed to process block %d after retry: %v, skipping", blockNum, err)
+		return true
+	}
+	scheduler.Success()
+	return true
+}
 
-	log.SetOutput(logFile)
+// Hàm chính xử lý Algorand qua HTTP; dừng sớm khi ctx bị hủy
+func Handle_algorand_http(ctx context.Context) error {
+	chainName := "algorand"
+	apiURL := "https://mainnet-api.algonode.cloud"
+	indexerURL := "https://mainnet-idx.algonode.cloud"
+	blocksToScan := 1000 // Số block cần quét lùi
 
 	// Khởi tạo dữ liệu cho chain
 	_ = InitAlgorandChainData(chainName)
@@ -271,29 +289,36 @@ func Handle_algorand_http() {
 	log.Printf("==================================")
 
 	// Bắt đầu quét lùi từ block hiện tại
-	scanBackwardsFromCurrentBlock(chainName, blocksToScan, apiURL, indexerURL)
+	if err := scanBackwardsFromCurrentBlock(ctx, chainName, blocksToScan, apiURL, indexerURL); err != nil {
+		return err
+	}
 
 	// Ghi log kết thúc quá trình
 	log.Printf("======= HOÀN THÀNH QUÉT NGƯỢC BLOCKCHAIN ALGORAND %s =======", chainName)
 	log.Printf("Đã quét lùi %d blocks từ block hiện tại", blocksToScan)
 	log.Printf("==================================")
+	return nil
 }
 
 // Hàm theo dõi các block mới (có thể thêm vào sau khi quét lùi hoàn tất)
-func monitorNewBlocks(chainName string, apiURL string, indexerURL string) {
+func monitorNewBlocks(ctx context.Context, chainName string, apiURL string, indexerURL string) error {
 	log.Printf("Bắt đầu theo dõi các block mới trên blockchain Algorand")
 
 	// Lấy thông tin về block hiện tại
 	url := fmt.Sprintf("%s/v2/status", apiURL)
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		log.Fatalf("Error fetching chain status: %v", err)
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching chain status: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf("Error reading response: %v", err)
+		return fmt.Errorf("error reading response: %w", err)
 	}
 	log.Printf("API response: %s", string(body))
 
@@ -301,27 +326,35 @@ func monitorNewBlocks(chainName string, apiURL string, indexerURL string) {
 		LastRound int64 `json:"last-round"`
 	}
 	if err := json.Unmarshal(body, &status); err != nil {
-		log.Fatalf("Error parsing JSON: %v", err)
+		return fmt.Errorf("error parsing JSON: %w", err)
 	}
 
 	currentBlock := status.LastRound
 	log.Printf("Bắt đầu theo dõi từ block: %d", currentBlock)
 
-	// Theo dõi liên tục các block mới
-	for {
+	scheduler := newScheduler(chainName, apiURL, algorandRoundTime)
+	scheduler.ObserveHead(uint64(currentBlock))
+	scheduler.ObserveProcessed(uint64(currentBlock))
+
+	// Theo dõi liên tục các block mới, nhịp poll theo chu kỳ round scheduler học được
+	for ctx.Err() == nil {
+		if err := scheduler.Wait(ctx, "status"); err != nil {
+			return nil
+		}
+
 		// Kiểm tra block mới nhất
 		resp, err := http.Get(url)
 		if err != nil {
-			log.Printf("Error fetching chain status: %v, retrying...", err)
-			time.Sleep(5 * time.Second)
+			scheduler.RateLimited()
+			log.Printf("Error fetching chain status: %v, retrying in %v...", err, scheduler.Next())
 			continue
 		}
 
 		body, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
 		if err != nil {
-			log.Printf("Error reading response: %v, retrying...", err)
-			time.Sleep(5 * time.Second)
+			scheduler.RateLimited()
+			log.Printf("Error reading response: %v, retrying in %v...", err, scheduler.Next())
 			continue
 		}
 
@@ -329,10 +362,12 @@ func monitorNewBlocks(chainName string, apiURL string, indexerURL string) {
 			LastRound int64 `json:"last-round"`
 		}
 		if err := json.Unmarshal(body, &newStatus); err != nil {
-			log.Printf("Error parsing JSON: %v, retrying...", err)
-			time.Sleep(5 * time.Second)
+			scheduler.RateLimited()
+			log.Printf("Error parsing JSON: %v, retrying in %v...", err, scheduler.Next())
 			continue
 		}
+		scheduler.Success()
+		scheduler.ObserveHead(uint64(newStatus.LastRound))
 
 		// Nếu có block mới
 		if newStatus.LastRound > currentBlock {
@@ -340,53 +375,26 @@ func monitorNewBlocks(chainName string, apiURL string, indexerURL string) {
 
 			// Xử lý các block mới
 			for blockNum := currentBlock + 1; blockNum <= newStatus.LastRound; blockNum++ {
-				log.Printf("Xử lý block mới: %d", blockNum)
-				err := processAlgorandBlock(blockNum, apiURL, indexerURL, chainName)
-				if err != nil {
-					log.Printf("Lỗi khi xử lý block %d: %v, thử lại...", blockNum, err)
-					// Thử lại sau 1 giây
-					time.Sleep(1 * time.Second)
-					err = processAlgorandBlock(blockNum, apiURL, indexerURL, chainName)
-					if err != nil {
-						log.Printf("Không thể xử lý block %d sau khi thử lại: %v, bỏ qua", blockNum, err)
-						continue
-					}
+				if !processAlgorandBlockRetry(ctx, scheduler, blockNum, apiURL, indexerURL, chainName) {
+					return nil
 				}
 
 				// Cập nhật block hiện tại
 				currentBlock = blockNum
-
-				// Tạm dừng giữa các lần xử lý block để tránh quá tải API
-				time.Sleep(200 * time.Millisecond)
+				scheduler.ObserveProcessed(uint64(blockNum))
 			}
 		}
-
-		// Đợi một khoảng thời gian trước khi kiểm tra lại
-		time.Sleep(5 * time.Second)
 	}
+	return nil
 }
 
-// Hàm chính mở rộng để vừa quét lùi vừa theo dõi block mới
-func Handle_algorand_http_extended() {
+// Hàm chính mở rộng để vừa quét lùi vừa theo dõi block mới; chạy tới khi ctx bị hủy
+func Handle_algorand_http_extended(ctx context.Context) error {
 	chainName := "algorand"
 	apiURL := "https://mainnet-api.algonode.cloud"
 	indexerURL := "https://mainnet-idx.algonode.cloud"
 	blocksToScan := 1000 // Số block cần quét lùi
 
-	// Tạo thư mục log nếu chưa tồn tại
-	if _, err := os.Stat("./services/get_chains/log"); os.IsNotExist(err) {
-		os.Mkdir("./services/get_chains/log", 0755)
-	}
-
-	// Mở file log
-	logFile, err := os.OpenFile("./services/get_chains/log/algorand_http.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatalf("Cannot open log file: %v", err)
-	}
-	defer logFile.Close()
-
-	log.SetOutput(logFile)
-
 	// Khởi tạo dữ liệu cho chain
 	_ = InitAlgorandChainData(chainName)
 	log.Printf("Initialized data for chain %s", chainName)
@@ -404,10 +412,12 @@ func Handle_algorand_http_extended() {
 	log.Printf("==================================")
 
 	// Bước 1: Quét lùi từ block hiện tại
-	scanBackwardsFromCurrentBlock(chainName, blocksToScan, apiURL, indexerURL)
+	if err := scanBackwardsFromCurrentBlock(ctx, chainName, blocksToScan, apiURL, indexerURL); err != nil {
+		return err
+	}
 
 	log.Printf("Bước 2: Bắt đầu theo dõi các block mới")
 
 	// Bước 2: Theo dõi các block mới
-	monitorNewBlocks(chainName, apiURL, indexerURL)
+	return monitorNewBlocks(ctx, chainName, apiURL, indexerURL)
 }
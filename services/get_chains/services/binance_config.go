@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+// binanceConfig là cấu hình Binance đang chạy. Các luồng giao dịch/kline đọc ngưỡng
+// qua đây cho mỗi message nên ngưỡng mới có hiệu lực ngay khi file được nạp lại.
+var binanceConfig atomic.Pointer[BinanceConfig]
+
+func validateBinanceConfig(cfg BinanceConfig) error {
+	if len(cfg.Tokens) == 0 {
+		return fmt.Errorf("cấu hình cần ít nhất một token")
+	}
+	if cfg.PriceChangeThreshold < 0 {
+		return fmt.Errorf("price_change_threshold không được âm: %v", cfg.PriceChangeThreshold)
+	}
+	seen := make(map[string]bool)
+	for _, token := range cfg.Tokens {
+		if token.Symbol == "" {
+			return fmt.Errorf("token thiếu symbol")
+		}
+		if seen[token.Symbol] {
+			return fmt.Errorf("token %s bị khai báo hai lần", token.Symbol)
+		}
+		seen[token.Symbol] = true
+		if token.LargeOrderAmount < 0 || token.HugeOrderAmount < 0 {
+			return fmt.Errorf("token %s: ngưỡng giao dịch không được âm", token.Symbol)
+		}
+		if token.HugeOrderAmount < token.LargeOrderAmount {
+			return fmt.Errorf("token %s: huge_order_amount nhỏ hơn large_order_amount", token.Symbol)
+		}
+	}
+	return nil
+}
+
+// ReloadBinanceConfig kiểm tra nội dung file cấu hình Binance mới rồi thay cấu hình đang chạy.
+// reconnect là true khi ws_url hoặc danh sách token đổi: phải mở lại kết nối để áp dụng.
+// Nội dung không hợp lệ thì trả lỗi và giữ nguyên cấu hình cũ.
+func ReloadBinanceConfig(data []byte) (reconnect bool, err error) {
+	var cfg BinanceConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return false, err
+	}
+	if err := validateBinanceConfig(cfg); err != nil {
+		return false, err
+	}
+
+	old := binanceConfig.Swap(&cfg)
+	if old == nil {
+		return false, nil
+	}
+	return old.WSURL != cfg.WSURL || !reflect.DeepEqual(tokenSymbols(*old), tokenSymbols(cfg)), nil
+}
+
+func tokenSymbols(cfg BinanceConfig) []string {
+	symbols := make([]string, len(cfg.Tokens))
+	for i, token := range cfg.Tokens {
+		symbols[i] = token.Symbol
+	}
+	return symbols
+}
+
+// currentToken trả về ngưỡng hiện tại của token, hoặc fallback nếu token không còn trong cấu hình
+func currentToken(fallback TokenConfig) TokenConfig {
+	if cfg := binanceConfig.Load(); cfg != nil {
+		for _, token := range cfg.Tokens {
+			if token.Symbol == fallback.Symbol {
+				return token
+			}
+		}
+	}
+	return fallback
+}
+
+func currentPriceChangeThreshold(fallback float64) float64 {
+	if cfg := binanceConfig.Load(); cfg != nil {
+		return cfg.PriceChangeThreshold
+	}
+	return fallback
+}
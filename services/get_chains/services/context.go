@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"main/services/poller"
+)
+
+// Số request/giây mặc định tới một endpoint HTTP khi cấu hình chain không khai báo budget
+const defaultRequestsPerSecond = 10
+
+// sleepCtx ngủ d hoặc tới khi ctx bị hủy; trả về false nếu ctx đã bị hủy
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// newScheduler tạo scheduler poll cho chain HTTP: học chu kỳ khối từ blockTime, giới hạn
+// request theo budget của endpoint và giãn ra khi bị rate limit
+func newScheduler(chainName, endpoint string, blockTime time.Duration) *poller.Scheduler {
+	return poller.NewScheduler(chainName, poller.Config{
+		InitialInterval:   blockTime,
+		Endpoint:          endpoint,
+		RequestsPerSecond: defaultRequestsPerSecond,
+	})
+}
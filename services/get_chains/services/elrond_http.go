@@ -1,21 +1,27 @@
 package services
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"main/services/get_chains/configs"
 	"main/services/get_chains/model"
+	"main/services/poller"
 )
 
+// Chu kỳ khối của MultiversX, scheduler tự học lại từ dữ liệu thực
+const elrondBlockTime = 6 * time.Second
+
 // Khởi tạo dữ liệu cho chuỗi MultiversX
 func InitElrondChainData(chainName string) *model.ChainDataElrond {
+	model.ChainDataMapVanLock.Lock()
+	defer model.ChainDataMapVanLock.Unlock()
 	if data, exists := model.ChainDataMapVan[chainName]; exists {
 		return data.(*model.ChainDataElrond)
 	}
@@ -159,10 +165,10 @@ func processElrondBlock(nonce int64, apiURL string) error {
 }
 
 // Quét từ quá khứ đến hiện tại
-func continueHandleElrondHTTP(chainName string) {
+func continueHandleElrondHTTP(ctx context.Context, chainName string) error {
 	chainDataGeneric := configs.GetChainData(chainName)
 	if chainDataGeneric == nil {
-		log.Fatalf("Data not found for chain %s", chainName)
+		return fmt.Errorf("data not found for chain %s", chainName)
 	}
 	chainData := chainDataGeneric.(*model.ChainDataElrond)
 
@@ -178,12 +184,16 @@ func continueHandleElrondHTTP(chainName string) {
 
 	blockCounter := 0
 	currentBlock := startBlockNumber
+	scheduler := newScheduler(chainName, chainData.Config().API, elrondBlockTime)
 
-	for {
+	for ctx.Err() == nil {
 		blockCounter++
 
 		if blockCounter%50 == 0 {
-			url := fmt.Sprintf("%s/network/status/4294967295", chainData.Config.API)
+			if err := scheduler.Acquire(ctx, "head"); err != nil {
+				return nil
+			}
+			url := fmt.Sprintf("%s/network/status/4294967295", chainData.Config().API)
 			resp, err := http.Get(url)
 			if err == nil {
 				body, err := io.ReadAll(resp.Body)
@@ -197,6 +207,7 @@ func continueHandleElrondHTTP(chainName string) {
 					}
 					if json.Unmarshal(body, &status) == nil {
 						latestBlock := status.Data.Status.HighestFinalNonce
+						scheduler.ObserveHead(uint64(latestBlock))
 						gap := latestBlock - currentBlock
 						if gap > 20 {
 							nextBatchEnd := currentBlock + 100
@@ -210,14 +221,24 @@ func continueHandleElrondHTTP(chainName string) {
 							model.LogMutexVan.Unlock()
 
 							for i := currentBlock; i <= nextBatchEnd; i++ {
-								if err := processElrondBlock(i, chainData.Config.API); err != nil {
+								// Đang tụt lại nên chỉ giới hạn bởi budget của endpoint
+								if err := scheduler.Acquire(ctx, "block"); err != nil {
+									return nil
+								}
+								if err := processElrondBlock(i, chainData.Config().API); err != nil {
 									model.LogMutexVan.Lock()
 									log.Printf("Error processing block %d: %v, continuing...", i, err)
 									model.LogMutexVan.Unlock()
+									if poller.IsRateLimited(err) {
+										scheduler.RateLimited()
+										if !sleepCtx(ctx, scheduler.Next()) {
+											return nil
+										}
+									}
 								}
-								time.Sleep(50 * time.Millisecond)
 							}
 
+							scheduler.ObserveProcessed(uint64(nextBatchEnd))
 							currentBlock = nextBatchEnd + 1
 							model.ProcessLockVan.Lock()
 							chainData.SetLastProcessedBlockVan(nextBatchEnd)
@@ -233,43 +254,58 @@ func continueHandleElrondHTTP(chainName string) {
 			}
 		}
 
-		err := processElrondBlock(currentBlock, chainData.Config.API)
+		err := processElrondBlock(currentBlock, chainData.Config().API)
 		if err == nil {
+			scheduler.Success()
+			scheduler.ObserveProcessed(uint64(currentBlock))
 			currentBlock++
 			model.ProcessLockVan.Lock()
 			if currentBlock-1 > chainData.GetLastProcessedBlockVan() {
 				chainData.SetLastProcessedBlockVan(currentBlock - 1)
 			}
 			model.ProcessLockVan.Unlock()
-			time.Sleep(200 * time.Millisecond)
+		} else if poller.IsRateLimited(err) {
+			scheduler.RateLimited()
+			model.LogMutexVan.Lock()
+			log.Printf("⏳ Rate limited fetching block %d, next poll in %v", currentBlock, scheduler.Next())
+			model.LogMutexVan.Unlock()
 		} else {
-			sleepTime := 500 * time.Millisecond
+			// Chưa có block này nghĩa là đã bắt kịp đầu chuỗi
+			scheduler.ObserveHead(uint64(currentBlock - 1))
 			model.LogMutexVan.Lock()
-			log.Printf("Waiting %v before retrying block %d", sleepTime, currentBlock)
+			log.Printf("Waiting %v before retrying block %d", scheduler.Next(), currentBlock)
 			model.LogMutexVan.Unlock()
-			time.Sleep(sleepTime)
+		}
+
+		if err := scheduler.Wait(ctx, "block"); err != nil {
+			return nil
 		}
 	}
+	return nil
 }
 
 // Quét từ hiện tại ngược về quá khứ
-func reverseHandleElrondHTTP(chainName string, pastDuration time.Duration) {
+func reverseHandleElrondHTTP(ctx context.Context, chainName string, pastDuration time.Duration) error {
 	chainDataGeneric := configs.GetChainData(chainName)
 	if chainDataGeneric == nil {
-		log.Fatalf("Data not found for chain %s", chainName)
+		return fmt.Errorf("data not found for chain %s", chainName)
 	}
 	chainData := chainDataGeneric.(*model.ChainDataElrond)
 
-	url := fmt.Sprintf("%s/network/status/4294967295", chainData.Config.API)
-	resp, err := http.Get(url)
+	url := fmt.Sprintf("%s/network/status/4294967295", chainData.Config().API)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		log.Fatalf("Error fetching chain status: %v", err)
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching chain status: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf("Error reading response: %v", err)
+		return fmt.Errorf("error reading response: %w", err)
 	}
 
 	var status struct {
@@ -280,13 +316,13 @@ func reverseHandleElrondHTTP(chainName string, pastDuration time.Duration) {
 		} `json:"data"`
 	}
 	if err := json.Unmarshal(body, &status); err != nil {
-		log.Fatalf("Error parsing JSON: %v", err)
+		return fmt.Errorf("error parsing JSON: %w", err)
 	}
 
 	latestBlock := status.Data.Status.HighestFinalNonce
-	latestBlockInfo, err := getElrondBlock(latestBlock, chainData.Config.API)
+	latestBlockInfo, err := getElrondBlock(latestBlock, chainData.Config().API)
 	if err != nil {
-		log.Fatalf("Error fetching latest block: %v", err)
+		return fmt.Errorf("error fetching latest block: %w", err)
 	}
 	latestBlockTime := time.Unix(latestBlockInfo.Timestamp, 0).UTC()
 
@@ -301,13 +337,24 @@ func reverseHandleElrondHTTP(chainName string, pastDuration time.Duration) {
 
 	blockCounter := 0
 	currentBlock := latestBlock
+	scheduler := newScheduler(chainName, chainData.Config().API, elrondBlockTime)
 
-	for {
+	for ctx.Err() == nil {
 		blockCounter++
 
-		err := processElrondBlock(currentBlock, chainData.Config.API)
+		// Quét ngược là backfill nên chỉ bị giới hạn bởi budget của endpoint
+		if err := scheduler.Acquire(ctx, "block"); err != nil {
+			return nil
+		}
+
+		err := processElrondBlock(currentBlock, chainData.Config().API)
 		if err == nil {
-			block, err := getElrondBlock(currentBlock, chainData.Config.API)
+			scheduler.Success()
+
+			if err := scheduler.Acquire(ctx, "block"); err != nil {
+				return nil
+			}
+			block, err := getElrondBlock(currentBlock, chainData.Config().API)
 			if err == nil {
 				blockTime := time.Unix(block.Timestamp, 0).UTC()
 				if blockTime.Before(targetTime) || blockTime.Equal(targetTime) {
@@ -328,14 +375,17 @@ func reverseHandleElrondHTTP(chainName string, pastDuration time.Duration) {
 				log.Printf("🔄 Scanned %d blocks backwards, currently at block %d", blockCounter, currentBlock)
 				model.LogMutexVan.Unlock()
 			}
-
-			time.Sleep(200 * time.Millisecond)
 		} else {
-			sleepTime := 500 * time.Millisecond
+			if poller.IsRateLimited(err) {
+				scheduler.RateLimited()
+			}
+			sleepTime := max(scheduler.Next(), 500*time.Millisecond)
 			model.LogMutexVan.Lock()
 			log.Printf("Waiting %v before retrying block %d", sleepTime, currentBlock)
 			model.LogMutexVan.Unlock()
-			time.Sleep(sleepTime)
+			if !sleepCtx(ctx, sleepTime) {
+				return nil
+			}
 		}
 
 		if currentBlock <= 1 {
@@ -349,25 +399,18 @@ func reverseHandleElrondHTTP(chainName string, pastDuration time.Duration) {
 	model.LogMutexVan.Lock()
 	log.Printf("✅ Completed processing blocks backwards for chain %s", chainName)
 	model.LogMutexVan.Unlock()
+	return nil
 }
 
-// Hàm chính xử lý MultiversX qua HTTP
-func Handle_elrond_http() {
+// Hàm chính xử lý MultiversX qua HTTP; chạy tới khi ctx bị hủy
+func Handle_elrond_http(ctx context.Context) error {
 	chainName := "elrond"
-	logFile, err := os.OpenFile("./services/get_chains/log/elrond_http.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatalf("Cannot open log file: %v", err)
-	}
-	defer logFile.Close()
-
-	log.SetOutput(logFile)
-
 	chainData := InitElrondChainData(chainName)
 	log.Printf("Initialized data for chain %s, last processed block: %d",
 		chainName, chainData.GetLastProcessedBlockVan())
 
 	if err := configs.LoadConfig("./services/get_chains/configs/config-elrond.json", chainName); err != nil {
-		log.Fatalf("Cannot load config: %v", err)
+		return fmt.Errorf("cannot load config: %w", err)
 	}
 
 	pastDuration := 1 * time.Hour
@@ -377,11 +420,13 @@ func Handle_elrond_http() {
 	log.Printf("Step 1: Scanning backwards into the past (%v)", pastDuration)
 	model.LogMutexVan.Unlock()
 
-	reverseHandleElrondHTTP(chainName, pastDuration)
+	if err := reverseHandleElrondHTTP(ctx, chainName, pastDuration); err != nil {
+		return err
+	}
 
 	model.LogMutexVan.Lock()
 	log.Printf("Step 2: Continuing scan from past to present and monitoring new blocks")
 	model.LogMutexVan.Unlock()
 
-	continueHandleElrondHTTP(chainName)
+	return continueHandleElrondHTTP(ctx, chainName)
 }
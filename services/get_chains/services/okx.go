@@ -14,6 +14,9 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"main/services/health"
+	"main/services/metrics"
 )
 
 type OKXConfig struct {
@@ -577,6 +580,7 @@ func ProcessOKXAccountStream(cfg OKXConfig, db *sql.DB, stopChan <-chan struct{}
 		}
 	}
 
+	connected := false
 	for {
 		select {
 		case <-stopChan:
@@ -595,6 +599,11 @@ func ProcessOKXAccountStream(cfg OKXConfig, db *sql.DB, stopChan <-chan struct{}
 					continue
 				}
 				logger.Printf("[OKX] WebSocket connection established")
+				health.Stream("okx").Event()
+				if connected {
+					metrics.WSReconnects.WithLabelValues("okx").Inc()
+				}
+				connected = true
 			}
 
 			// Authenticate
@@ -824,6 +833,7 @@ func ProcessOKXAccountStream(cfg OKXConfig, db *sql.DB, stopChan <-chan struct{}
 					_, message, err := conn.ReadMessage()
 					if err != nil {
 						logger.Printf("[OKX] WebSocket read error: %v", err)
+						health.Stream("okx").Fail(err)
 						reconnect()
 						<-pongDone
 						<-pingDone
@@ -838,6 +848,7 @@ func ProcessOKXAccountStream(cfg OKXConfig, db *sql.DB, stopChan <-chan struct{}
 						continue
 					}
 					logger.Printf("[OKX] Received message: %s", string(message))
+					health.Stream("okx").Event()
 					handler.handleMessage(msgMap, db)
 				}
 			}
@@ -9,6 +9,9 @@ import (
 	"github.com/blocto/solana-go-sdk/client"
 
 	"main/services/get_chains/configs"
+	"main/services/health"
+	"main/services/metrics"
+	"main/services/poller"
 )
 
 type Transaction struct {
@@ -29,21 +32,45 @@ func HandleChainSolana(fileConfig string, stopChan <-chan struct{}, logger *log.
 
 	c := client.NewClient(config.RPC)
 
+	// Chu kỳ poll học theo thời gian slot thực tế thay vì ngủ cố định TimeNeedToBlock
+	scheduler := poller.NewScheduler("solana", poller.Config{
+		InitialInterval:       time.Duration(config.TimeNeedToBlock) * time.Millisecond,
+		Endpoint:              config.RPC,
+		RequestsPerSecond:     config.RequestsPerSecond,
+		ComputeUnitsPerSecond: config.ComputeUnitsPerSecond,
+		ComputeUnitCosts:      config.ComputeUnitCosts,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopChan
+		cancel()
+	}()
+
 	for {
-		select {
-		case <-stopChan:
+		if err := scheduler.Wait(ctx, "getSlot"); err != nil {
 			logger.Println("Stopping Solana chain")
 			return
-		default:
-			latestSlot, err := c.GetSlot(context.Background())
-			if err != nil {
-				logger.Printf("Failed to get latest slot: %v", err)
-				time.Sleep(1 * time.Second)
-				continue
-			}
+		}
 
-			logger.Printf("Latest slot: %d", latestSlot)
-			time.Sleep(time.Duration(config.TimeNeedToBlock) * time.Millisecond)
+		start := time.Now()
+		latestSlot, err := c.GetSlot(ctx)
+		metrics.ObserveRPC(config.RPC, "getSlot", start, err)
+		if err != nil {
+			if poller.IsRateLimited(err) {
+				scheduler.RateLimited()
+			}
+			logger.Printf("Failed to get latest slot: %v (next poll in %v)", err, scheduler.Next())
+			health.Chain("solana").Fail(err)
+			continue
 		}
+
+		scheduler.Success()
+		scheduler.ObserveHead(latestSlot)
+		scheduler.ObserveProcessed(latestSlot)
+		metrics.ObserveProcessed("solana", latestSlot)
+		health.Chain("solana").Progress(latestSlot, latestSlot)
+		logger.Printf("Latest slot: %d", latestSlot)
 	}
 }
\ No newline at end of file
@@ -1,18 +1,22 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math"
 	"os"
-	"os/signal"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"main/services/health"
+	"main/services/leader"
+	"main/services/logging"
+	"main/services/metrics"
 )
 
 // BTCOrder cấu trúc dữ liệu chung cho cả dữ liệu lịch sử và thời gian thực
@@ -69,45 +73,12 @@ var (
 	mainLogger *log.Logger
 )
 
-// Thiết lập logger cho từng loại tiền
+// Thiết lập logger cho từng loại tiền, các file nằm trong LOG_DIR
 func setupLoggers() error {
-	// Tạo thư mục log nếu chưa tồn tại
-	if _, err := os.Stat("./log"); os.IsNotExist(err) {
-		if err := os.Mkdir("./log", 0755); err != nil {
-			return fmt.Errorf("không thể tạo thư mục log: %v", err)
-		}
-	}
-
-	// Mở file log chung
-	mainLogFile, err := os.OpenFile("./services/get_chains/log/crypto_market.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		return fmt.Errorf("không thể mở file log chính: %v", err)
-	}
-
-	// Mở file log cho BTC
-	btcLogFile, err := os.OpenFile("./services/get_chains/log/btc_market.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		return fmt.Errorf("không thể mở file log BTC: %v", err)
-	}
-
-	// Mở file log cho ETH
-	ethLogFile, err := os.OpenFile("./services/get_chains/log/eth_market.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		return fmt.Errorf("không thể mở file log ETH: %v", err)
-	}
-
-	// Mở file log cho SOL
-	solLogFile, err := os.OpenFile("./services/get_chains/log/sol_market.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		return fmt.Errorf("không thể mở file log SOL: %v", err)
-	}
-
-	// Khởi tạo các logger
-	mainLogger = log.New(mainLogFile, "", log.LstdFlags)
-	btcLogger = log.New(btcLogFile, "[BTC] ", log.LstdFlags)
-	ethLogger = log.New(ethLogFile, "[ETH] ", log.LstdFlags)
-	solLogger = log.New(solLogFile, "[SOL] ", log.LstdFlags)
-
+	mainLogger = logging.Logger("crypto_market.log", "")
+	btcLogger = logging.Logger("btc_market.log", "[BTC] ")
+	ethLogger = logging.Logger("eth_market.log", "[ETH] ")
+	solLogger = logging.Logger("sol_market.log", "[SOL] ")
 	return nil
 }
 
@@ -175,7 +146,9 @@ func ProcessRealTimeMarketStream(cfg BinanceConfig, stopChan <-chan struct{}) {
 
 				mainLogger.Printf("Đã kết nối thành công đến WebSocket cho %s", token.Symbol)
 				go Heartbeat(conn, "market-"+token.Symbol)
+				stream := health.Stream("binance:" + strings.ToLower(token.Symbol) + "@trade")
 
+			read:
 				for {
 					select {
 					case <-stopChan:
@@ -186,9 +159,12 @@ func ProcessRealTimeMarketStream(cfg BinanceConfig, stopChan <-chan struct{}) {
 						_, message, err := conn.ReadMessage()
 						if err != nil {
 							mainLogger.Printf("Lỗi đọc tin nhắn WebSocket cho %s: %v, kết nối lại", token.Symbol, err)
+							metrics.WSReconnects.WithLabelValues("binance").Inc()
+							stream.Fail(err)
 							conn.Close()
-							break
+							break read
 						}
+						stream.Event()
 
 						var trade TradeEvent
 						if err := json.Unmarshal(message, &trade); err != nil {
@@ -211,7 +187,8 @@ func ProcessRealTimeMarketStream(cfg BinanceConfig, stopChan <-chan struct{}) {
 							orderType, quantity, price, quantity*price)
 
 						// Chỉ xử lý giao dịch lớn
-						if quantity > token.LargeOrderAmount {
+						thresholds := currentToken(token)
+						if quantity > thresholds.LargeOrderAmount {
 							// Tạo Order mới
 							newOrder := BTCOrder{
 								Timestamp: time.UnixMilli(trade.Timestamp),
@@ -229,12 +206,14 @@ func ProcessRealTimeMarketStream(cfg BinanceConfig, stopChan <-chan struct{}) {
 
 							// Log giao dịch lớn
 							orderSize := "LỚN"
-							if quantity >= token.HugeOrderAmount {
+							if quantity >= thresholds.HugeOrderAmount {
 								orderSize = "RẤT LỚN"
 							}
 
-							mainLogger.Printf("[GIAO DỊCH %s] %s | %s | Số lượng: %.8f | Giá: %.2f USDT | Tổng: %.2f USDT",
-								orderSize, asset, orderType, quantity, price, quantity*price)
+							if leader.Allow("binance-large-order") {
+								mainLogger.Printf("[GIAO DỊCH %s] %s | %s | Số lượng: %.8f | Giá: %.2f USDT | Tổng: %.2f USDT",
+									orderSize, asset, orderType, quantity, price, quantity*price)
+							}
 
 							realTimeOrdersMutex.Unlock()
 						}
@@ -263,7 +242,9 @@ func ProcessRealTimeKlineStream(cfg BinanceConfig, stopChan <-chan struct{}) {
 
 				mainLogger.Printf("Đã kết nối thành công đến WebSocket kline cho %s", token.Symbol)
 				go Heartbeat(conn, "kline-"+token.Symbol)
+				stream := health.Stream("binance:" + strings.ToLower(token.Symbol) + "@kline_1m")
 
+			read:
 				for {
 					select {
 					case <-stopChan:
@@ -274,9 +255,12 @@ func ProcessRealTimeKlineStream(cfg BinanceConfig, stopChan <-chan struct{}) {
 						_, message, err := conn.ReadMessage()
 						if err != nil {
 							mainLogger.Printf("Lỗi đọc tin nhắn WebSocket kline cho %s: %v, kết nối lại", token.Symbol, err)
+							metrics.WSReconnects.WithLabelValues("binance").Inc()
+							stream.Fail(err)
 							conn.Close()
-							break
+							break read
 						}
+						stream.Event()
 
 						var kline KlineEvent
 						if err := json.Unmarshal(message, &kline); err != nil {
@@ -294,7 +278,8 @@ func ProcessRealTimeKlineStream(cfg BinanceConfig, stopChan <-chan struct{}) {
 							openPrice, closePrice, priceChange)
 
 						// Xử lý thay đổi giá đáng kể
-						if math.Abs(priceChange) >= cfg.PriceChangeThreshold {
+						// Cảnh báo chỉ phát từ leader để các replica không báo trùng
+						if math.Abs(priceChange) >= currentPriceChangeThreshold(cfg.PriceChangeThreshold) && leader.Allow("binance-price-alert") {
 							asset := strings.TrimSuffix(kline.Symbol, "USDT")
 							changeType := "TĂNG"
 							if priceChange < 0 {
@@ -318,7 +303,7 @@ func ProcessRealTimeKlineStream(cfg BinanceConfig, stopChan <-chan struct{}) {
 func HandleRealTimeCrypto(configFile string, stopChan <-chan struct{}) {
 	// Thiết lập logger
 	if err := setupLoggers(); err != nil {
-		fmt.Printf("Lỗi khi thiết lập logger: %v\n", err)
+		log.Printf("Lỗi khi thiết lập logger: %v", err)
 		return
 	}
 
@@ -329,6 +314,7 @@ func HandleRealTimeCrypto(configFile string, stopChan <-chan struct{}) {
 		mainLogger.Printf("Lỗi khi đọc file cấu hình: %v", err)
 		return
 	}
+	binanceConfig.Store(&cfg)
 
 	mainLogger.Printf("Đã tải cấu hình từ file: %s", configFile)
 	mainLogger.Printf("WebSocket URL: %s", cfg.WSURL)
@@ -443,11 +429,11 @@ func logOrderSummary(logger *log.Logger, orders []BTCOrder, symbol string) {
 		netFlow, symbol, netFlowValue, flowDirection)
 }
 
-// HandleCryptoData xử lý dữ liệu từ cả BTC, ETH và SOL
-func HandleCryptoData(configFile string) {
+// HandleCryptoData xử lý dữ liệu từ cả BTC, ETH và SOL cho tới khi ctx bị hủy
+func HandleCryptoData(ctx context.Context, configFile string) {
 	// Thiết lập logger
 	if err := setupLoggers(); err != nil {
-		fmt.Printf("Lỗi khi thiết lập logger: %v\n", err)
+		log.Printf("Lỗi khi thiết lập logger: %v", err)
 		return
 	}
 
@@ -459,6 +445,7 @@ func HandleCryptoData(configFile string) {
 		mainLogger.Printf("Lỗi khi đọc file cấu hình: %v", err)
 		return
 	}
+	binanceConfig.Store(&cfg)
 
 	mainLogger.Printf("Đã tải cấu hình từ file: %s", configFile)
 	mainLogger.Printf("WebSocket URL: %s", cfg.WSURL)
@@ -478,10 +465,8 @@ func HandleCryptoData(configFile string) {
 	// Khởi động tổng hợp dữ liệu định kỳ
 	go SummarizeOrderData(5 * time.Minute)
 
-	// Xử lý tín hiệu để dừng chương trình một cách an toàn
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	// Tín hiệu dừng được xử lý tập trung ở main và truyền qua ctx
+	<-ctx.Done()
 
 	mainLogger.Println("Nhận tín hiệu dừng, đang kết thúc xử lý...")
 	close(stopChan)
@@ -490,7 +475,7 @@ func HandleCryptoData(configFile string) {
 }
 
 // Hàm chính để chạy ứng dụng
-func RunCryptoDataProcessor(configPath string) {
-	fmt.Println("Bắt đầu xử lý dữ liệu tiền điện tử...")
-	HandleCryptoData(configPath)
+func RunCryptoDataProcessor(ctx context.Context, configPath string) {
+	log.Println("Bắt đầu xử lý dữ liệu tiền điện tử...")
+	HandleCryptoData(ctx, configPath)
 }
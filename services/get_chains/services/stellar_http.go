@@ -1,21 +1,27 @@
 package services
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"main/services/get_chains/configs"
 	"main/services/get_chains/model"
+	"main/services/poller"
 )
 
+// Chu kỳ đóng ledger của Stellar, scheduler tự học lại từ dữ liệu thực
+const stellarLedgerTime = 5 * time.Second
+
 // Khởi tạo dữ liệu cho chuỗi Stellar
 func InitChainDataStellar(chainName string) *model.ChainDataStellarVan {
+	model.ChainDataMapVanLock.Lock()
+	defer model.ChainDataMapVanLock.Unlock()
 	if data, exists := model.ChainDataMapVan[chainName]; exists {
 		return data.(*model.ChainDataStellarVan)
 	}
@@ -175,12 +181,12 @@ func processStellarLedger(ledger int64, horizonURL string) error {
 }
 
 // Quét từ quá khứ đến hiện tại
-func continueHandleStellarHTTP(chainName string) {
+func continueHandleStellarHTTP(ctx context.Context, chainName string) error {
 	chainDataGeneric := configs.GetChainData(chainName)
 	if chainDataGeneric == nil {
-		log.Fatalf("Data not found for chain %s", chainName)
+		return fmt.Errorf("data not found for chain %s", chainName)
 	}
-	chainData := chainDataGeneric.GetConfigVan().(*model.ChainDataStellarVan)
+	chainData := chainDataGeneric.(*model.ChainDataStellarVan)
 
 	startLedgerNumber := int64(1)
 	chainData.SetLastProcessedBlockVan(startLedgerNumber - 1)
@@ -194,12 +200,16 @@ func continueHandleStellarHTTP(chainName string) {
 
 	ledgerCounter := 0
 	currentLedger := startLedgerNumber
+	scheduler := newScheduler(chainName, chainData.Config().HorizonURL, stellarLedgerTime)
 
-	for {
+	for ctx.Err() == nil {
 		ledgerCounter++
 
 		if ledgerCounter%50 == 0 {
-			url := fmt.Sprintf("%s/ledgers?order=desc&limit=1", chainData.Config.HorizonURL)
+			if err := scheduler.Acquire(ctx, "head"); err != nil {
+				return nil
+			}
+			url := fmt.Sprintf("%s/ledgers?order=desc&limit=1", chainData.Config().HorizonURL)
 			resp, err := http.Get(url)
 			if err == nil {
 				body, err := io.ReadAll(resp.Body)
@@ -211,6 +221,7 @@ func continueHandleStellarHTTP(chainName string) {
 					}
 					if json.Unmarshal(body, &latestResp) == nil && len(latestResp.Embedded.Records) > 0 {
 						latestLedger := latestResp.Embedded.Records[0].Sequence
+						scheduler.ObserveHead(uint64(latestLedger))
 						gap := latestLedger - currentLedger
 						if gap > 20 {
 							nextBatchEnd := currentLedger + 100
@@ -224,14 +235,24 @@ func continueHandleStellarHTTP(chainName string) {
 							model.LogMutexVan.Unlock()
 
 							for i := currentLedger; i <= nextBatchEnd; i++ {
-								if err := processStellarLedger(i, chainData.Config.HorizonURL); err != nil {
+								// Đang tụt lại nên chỉ giới hạn bởi budget của endpoint
+								if err := scheduler.Acquire(ctx, "ledger"); err != nil {
+									return nil
+								}
+								if err := processStellarLedger(i, chainData.Config().HorizonURL); err != nil {
 									model.LogMutexVan.Lock()
 									log.Printf("Error processing ledger %d: %v, continuing...", i, err)
 									model.LogMutexVan.Unlock()
+									if poller.IsRateLimited(err) {
+										scheduler.RateLimited()
+										if !sleepCtx(ctx, scheduler.Next()) {
+											return nil
+										}
+									}
 								}
-								time.Sleep(50 * time.Millisecond)
 							}
 
+							scheduler.ObserveProcessed(uint64(nextBatchEnd))
 							currentLedger = nextBatchEnd + 1
 							model.ProcessLockVan.Lock()
 							chainData.SetLastProcessedBlockVan(nextBatchEnd)
@@ -247,49 +268,64 @@ func continueHandleStellarHTTP(chainName string) {
 			}
 		}
 
-		err := processStellarLedger(currentLedger, chainData.Config.HorizonURL)
+		err := processStellarLedger(currentLedger, chainData.Config().HorizonURL)
 		if err == nil {
+			scheduler.Success()
+			scheduler.ObserveProcessed(uint64(currentLedger))
 			currentLedger++
 			model.ProcessLockVan.Lock()
 			if currentLedger-1 > chainData.GetLastProcessedBlockVan() {
 				chainData.SetLastProcessedBlockVan(currentLedger - 1)
 			}
 			model.ProcessLockVan.Unlock()
-			time.Sleep(200 * time.Millisecond)
+		} else if poller.IsRateLimited(err) {
+			scheduler.RateLimited()
+			model.LogMutexVan.Lock()
+			log.Printf("⏳ Rate limited fetching ledger %d, next poll in %v", currentLedger, scheduler.Next())
+			model.LogMutexVan.Unlock()
 		} else {
-			sleepTime := 500 * time.Millisecond
+			// Chưa có ledger này nghĩa là đã bắt kịp đầu chuỗi
+			scheduler.ObserveHead(uint64(currentLedger - 1))
 			model.LogMutexVan.Lock()
-			log.Printf("Waiting %v before retrying ledger %d", sleepTime, currentLedger)
+			log.Printf("Waiting %v before retrying ledger %d", scheduler.Next(), currentLedger)
 			model.LogMutexVan.Unlock()
-			time.Sleep(sleepTime)
+		}
+
+		if err := scheduler.Wait(ctx, "ledger"); err != nil {
+			return nil
 		}
 	}
+	return nil
 }
 
 // Quét từ hiện tại ngược về quá khứ// Quét từ hiện tại ngược về quá khứ
-func reverseHandleStellarHTTP(chainName string, pastDuration time.Duration) {
+func reverseHandleStellarHTTP(ctx context.Context, chainName string, pastDuration time.Duration) error {
 	chainDataGeneric := configs.GetChainData(chainName)
 	if chainDataGeneric == nil {
-		log.Fatalf("Data not found for chain %s", chainName)
+		return fmt.Errorf("data not found for chain %s", chainName)
 	}
-	chainData := chainDataGeneric.GetConfigVan().(*model.ChainDataStellarVan)
+	chainData := chainDataGeneric.(*model.ChainDataStellarVan)
 
 	// Kiểm tra xem HorizonURL đã được cấu hình chưa
-	if chainData.Config.HorizonURL == "" {
-		log.Fatalf("HorizonURL is not configured for chain %s", chainName)
+	if chainData.Config().HorizonURL == "" {
+		return fmt.Errorf("HorizonURL is not configured for chain %s", chainName)
 	}
 
 	// Lấy thông tin ledger mới nhất từ Stellar Horizon API
-	url := fmt.Sprintf("%s/ledgers?order=desc&limit=1", chainData.Config.HorizonURL)
-	resp, err := http.Get(url)
+	url := fmt.Sprintf("%s/ledgers?order=desc&limit=1", chainData.Config().HorizonURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		log.Fatalf("Error fetching chain status: %v", err)
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching chain status: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf("Error reading response: %v", err)
+		return fmt.Errorf("error reading response: %w", err)
 	}
 
 	var latestResp struct {
@@ -298,11 +334,11 @@ func reverseHandleStellarHTTP(chainName string, pastDuration time.Duration) {
 		} `json:"_embedded"`
 	}
 	if err := json.Unmarshal(body, &latestResp); err != nil {
-		log.Fatalf("Error parsing JSON: %v", err)
+		return fmt.Errorf("error parsing JSON: %w", err)
 	}
 
 	if len(latestResp.Embedded.Records) == 0 {
-		log.Fatalf("No latest ledger found")
+		return fmt.Errorf("no latest ledger found")
 	}
 
 	latestLedger := latestResp.Embedded.Records[0].Sequence
@@ -319,14 +355,25 @@ func reverseHandleStellarHTTP(chainName string, pastDuration time.Duration) {
 
 	ledgerCounter := 0
 	currentLedger := latestLedger
+	scheduler := newScheduler(chainName, chainData.Config().HorizonURL, stellarLedgerTime)
 
 	// Bắt đầu quét ngược
-	for {
+	for ctx.Err() == nil {
 		ledgerCounter++
 
-		err := processStellarLedger(currentLedger, chainData.Config.HorizonURL)
+		// Quét ngược là backfill nên chỉ bị giới hạn bởi budget của endpoint
+		if err := scheduler.Acquire(ctx, "ledger"); err != nil {
+			return nil
+		}
+
+		err := processStellarLedger(currentLedger, chainData.Config().HorizonURL)
 		if err == nil {
-			ledgerData, err := getStellarLedger(currentLedger, chainData.Config.HorizonURL)
+			scheduler.Success()
+
+			if err := scheduler.Acquire(ctx, "ledger"); err != nil {
+				return nil
+			}
+			ledgerData, err := getStellarLedger(currentLedger, chainData.Config().HorizonURL)
 			if err == nil {
 				ledgerTime := ledgerData.Timestamp
 				// Kiểm tra xem đã đạt đến thời gian mục tiêu chưa
@@ -353,16 +400,19 @@ func reverseHandleStellarHTTP(chainName string, pastDuration time.Duration) {
 				log.Printf("🔄 Scanned %d ledgers backwards, currently at ledger %d", ledgerCounter, currentLedger)
 				model.LogMutexVan.Unlock()
 			}
-
-			time.Sleep(200 * time.Millisecond)
 		} else {
 			// Xử lý lỗi khi không lấy được ledger, thử lại sau một khoảng thời gian
-			sleepTime := 500 * time.Millisecond
+			if poller.IsRateLimited(err) {
+				scheduler.RateLimited()
+			}
+			sleepTime := max(scheduler.Next(), 500*time.Millisecond)
 			model.LogMutexVan.Lock()
 			log.Printf("⚠️ Error processing ledger %d: %v", currentLedger, err)
 			log.Printf("Waiting %v before retrying ledger %d", sleepTime, currentLedger)
 			model.LogMutexVan.Unlock()
-			time.Sleep(sleepTime)
+			if !sleepCtx(ctx, sleepTime) {
+				return nil
+			}
 		}
 
 		// Kiểm tra xem đã đến ledger đầu tiên chưa
@@ -378,29 +428,22 @@ func reverseHandleStellarHTTP(chainName string, pastDuration time.Duration) {
 	model.LogMutexVan.Lock()
 	log.Printf("✅ Completed processing ledgers backwards for chain %s", chainName)
 	model.LogMutexVan.Unlock()
+	return nil
 }
 
-// Hàm chính xử lý Stellar qua HTTP
-func Handle_stellar_http() {
+// Hàm chính xử lý Stellar qua HTTP; chạy tới khi ctx bị hủy
+func Handle_stellar_http(ctx context.Context) error {
 	chainName := "stellar"
-	logFile, err := os.OpenFile("./services/get_chains/log/stellar_http.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatalf("Cannot open log file: %v", err)
-	}
-	defer logFile.Close()
-
-	log.SetOutput(logFile)
-
 	chainData := InitChainDataStellar(chainName)
 	log.Printf("Initialized data for chain %s, last processed ledger: %d",
 		chainName, chainData.GetLastProcessedBlockVan())
 
 	if err := configs.LoadConfig("./services/get_chains/configs/config-stellar.json", chainName); err != nil {
-		log.Fatalf("Cannot load config: %v", err)
+		return fmt.Errorf("cannot load config: %w", err)
 	}
 
-	if chainData.Config.HorizonURL == "" {
-		log.Fatalf("HorizonURL is not set in config for chain %s", chainName)
+	if chainData.Config().HorizonURL == "" {
+		return fmt.Errorf("HorizonURL is not set in config for chain %s", chainName)
 	}
 
 	pastDuration := 1 * time.Hour
@@ -410,11 +453,13 @@ func Handle_stellar_http() {
 	log.Printf("Step 1: Scanning backwards into the past (%v)", pastDuration)
 	model.LogMutexVan.Unlock()
 
-	reverseHandleStellarHTTP(chainName, pastDuration)
+	if err := reverseHandleStellarHTTP(ctx, chainName, pastDuration); err != nil {
+		return err
+	}
 
 	model.LogMutexVan.Lock()
 	log.Printf("Step 2: Continuing scan from past to present and monitoring new ledgers")
 	model.LogMutexVan.Unlock()
 
-	continueHandleStellarHTTP(chainName)
+	return continueHandleStellarHTTP(ctx, chainName)
 }
@@ -1,53 +1,50 @@
 package services
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"main/services/get_chains/configs"
 	"main/services/get_chains/model"
 )
 
-// Hàm chính xử lý Stellar qua HTTP, bắt đầu từ ledger mới nhất tại thời điểm hiện tại
-func Handle_stellar_ws() {
+// Hàm chính xử lý Stellar qua HTTP, bắt đầu từ ledger mới nhất tại thời điểm hiện tại;
+// chạy tới khi ctx bị hủy
+func Handle_stellar_ws(ctx context.Context) error {
 	chainName := "stellar"
-	logFile, err := os.OpenFile("./services/get_chains/log/stellar_ws.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatalf("Cannot open log file: %v", err)
-	}
-	defer logFile.Close()
-
-	log.SetOutput(logFile)
-
 	// Khởi tạo dữ liệu chain
 	chainData := InitChainDataStellar(chainName)
 
 	// Tải cấu hình
 	if err := configs.LoadConfig("./services/get_chains/configs/config-stellar.json", chainName); err != nil {
-		log.Fatalf("Cannot load config: %v", err)
+		return fmt.Errorf("cannot load config: %w", err)
 	}
 
-	if chainData.Config.HorizonURL == "" {
-		log.Fatalf("HorizonURL is not set in config for chain %s", chainName)
+	if chainData.Config().HorizonURL == "" {
+		return fmt.Errorf("HorizonURL is not set in config for chain %s", chainName)
 	}
 
 	// Lấy ledger mới nhất từ Stellar Horizon API để bắt đầu
-	url := fmt.Sprintf("%s/ledgers?order=desc&limit=1", chainData.Config.HorizonURL)
-	resp, err := http.Get(url)
+	url := fmt.Sprintf("%s/ledgers?order=desc&limit=1", chainData.Config().HorizonURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Fatalf("❌ Error fetching initial latest ledger: %v", err)
+		return fmt.Errorf("error fetching initial latest ledger: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf("❌ Error reading response: %v", err)
+		return fmt.Errorf("error reading response: %w", err)
 	}
 
 	var latestResp struct {
@@ -56,11 +53,11 @@ func Handle_stellar_ws() {
 		} `json:"_embedded"`
 	}
 	if err := json.Unmarshal(body, &latestResp); err != nil {
-		log.Fatalf("❌ Error parsing JSON: %v", err)
+		return fmt.Errorf("error parsing JSON: %w", err)
 	}
 
 	if len(latestResp.Embedded.Records) == 0 {
-		log.Fatalf("❌ No latest ledger found")
+		return fmt.Errorf("no latest ledger found")
 	}
 
 	// Gán ledger mới nhất làm điểm bắt đầu
@@ -74,14 +71,16 @@ func Handle_stellar_ws() {
 	model.LogMutexVan.Unlock()
 
 	// Vòng lặp quét ledger mới nhất và xử lý ledger bị bỏ sót
-	for {
+	for ctx.Err() == nil {
 		// Lấy ledger mới nhất từ Stellar Horizon API
 		resp, err := http.Get(url)
 		if err != nil {
 			model.LogMutexVan.Lock()
 			log.Printf("❌ Error fetching latest ledger: %v. Retrying in 5 seconds...", err)
 			model.LogMutexVan.Unlock()
-			time.Sleep(5 * time.Second)
+			if !sleepCtx(ctx, 5*time.Second) {
+				return nil
+			}
 			continue
 		}
 
@@ -90,7 +89,9 @@ func Handle_stellar_ws() {
 			model.LogMutexVan.Lock()
 			log.Printf("❌ Error reading response: %v. Retrying in 5 seconds...", err)
 			model.LogMutexVan.Unlock()
-			time.Sleep(5 * time.Second)
+			if !sleepCtx(ctx, 5*time.Second) {
+				return nil
+			}
 			continue
 		}
 
@@ -98,7 +99,9 @@ func Handle_stellar_ws() {
 			model.LogMutexVan.Lock()
 			log.Printf("❌ Error parsing JSON: %v. Retrying in 5 seconds...", err)
 			model.LogMutexVan.Unlock()
-			time.Sleep(5 * time.Second)
+			if !sleepCtx(ctx, 5*time.Second) {
+				return nil
+			}
 			continue
 		}
 
@@ -106,7 +109,9 @@ func Handle_stellar_ws() {
 			model.LogMutexVan.Lock()
 			log.Printf("❌ No latest ledger found. Retrying in 5 seconds...")
 			model.LogMutexVan.Unlock()
-			time.Sleep(5 * time.Second)
+			if !sleepCtx(ctx, 5*time.Second) {
+				return nil
+			}
 			continue
 		}
 
@@ -129,7 +134,7 @@ func Handle_stellar_ws() {
 
 			// Xử lý tất cả ledger từ lastProcessedLedger + 1 đến latestLedger
 			for ledger := lastProcessedLedger + 1; ledger <= latestLedger; ledger++ {
-				err := processStellarLedger(ledger, chainData.Config.HorizonURL)
+				err := processStellarLedger(ledger, chainData.Config().HorizonURL)
 				if err != nil {
 					model.LogMutexVan.Lock()
 					log.Printf("❌ Error processing ledger %d: %v. Skipping...", ledger, err)
@@ -153,12 +158,17 @@ func Handle_stellar_ws() {
 		}
 
 		// Nghỉ 5 giây trước khi kiểm tra lại
-		time.Sleep(5 * time.Second)
+		if !sleepCtx(ctx, 5*time.Second) {
+			return nil
+		}
 	}
+	return nil
 }
 
 // Các hàm hỗ trợ giữ nguyên
 func InitChainDataStellarws(chainName string) *model.ChainDataStellarVan {
+	model.ChainDataMapVanLock.Lock()
+	defer model.ChainDataMapVanLock.Unlock()
 	if data, exists := model.ChainDataMapVan[chainName]; exists {
 		return data.(*model.ChainDataStellarVan)
 	}
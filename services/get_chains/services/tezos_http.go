@@ -1,21 +1,27 @@
 package services
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"main/services/get_chains/configs"
 	"main/services/get_chains/model"
+	"main/services/poller"
 )
 
+// Chu kỳ khối của Tezos, scheduler tự học lại từ dữ liệu thực
+const tezosBlockTime = 8 * time.Second
+
 // Khởi tạo dữ liệu cho chuỗi Tezos
 func InitChainData(chainName string) *model.ChainDataTezos {
+	model.ChainDataMapVanLock.Lock()
+	defer model.ChainDataMapVanLock.Unlock()
 	if data, exists := model.ChainDataMapVan[chainName]; exists {
 		return data.(*model.ChainDataTezos)
 	}
@@ -126,10 +132,10 @@ func processTezosBlock(height int64, rpcURL string, chainName string) error {
 }
 
 // Quét từ quá khứ đến hiện tại
-func continueHandleTezosHTTP(chainName string) {
+func continueHandleTezosHTTP(ctx context.Context, chainName string) error {
 	chainDataGeneric := configs.GetChainData(chainName)
 	if chainDataGeneric == nil {
-		log.Fatalf("Data not found for chain %s", chainName)
+		return fmt.Errorf("data not found for chain %s", chainName)
 	}
 	chainData := chainDataGeneric.(*model.ChainDataTezos)
 
@@ -145,12 +151,16 @@ func continueHandleTezosHTTP(chainName string) {
 
 	blockCounter := 0
 	currentBlock := startBlockNumber
+	scheduler := newScheduler(chainName, chainData.Config().RPC, tezosBlockTime)
 
-	for {
+	for ctx.Err() == nil {
 		blockCounter++
 
 		if blockCounter%50 == 0 {
-			url := fmt.Sprintf("%s/chains/main/blocks/head/header", chainData.Config.RPC)
+			if err := scheduler.Acquire(ctx, "head"); err != nil {
+				return nil
+			}
+			url := fmt.Sprintf("%s/chains/main/blocks/head/header", chainData.Config().RPC)
 			resp, err := http.Get(url)
 			if err == nil {
 				body, err := io.ReadAll(resp.Body)
@@ -160,6 +170,7 @@ func continueHandleTezosHTTP(chainName string) {
 					}
 					if json.Unmarshal(body, &header) == nil {
 						latestBlock := header.Level
+						scheduler.ObserveHead(uint64(latestBlock))
 						gap := latestBlock - currentBlock
 						if gap > 20 {
 							nextBatchEnd := currentBlock + 100
@@ -173,14 +184,24 @@ func continueHandleTezosHTTP(chainName string) {
 							model.LogMutexVan.Unlock()
 
 							for i := currentBlock; i <= nextBatchEnd; i++ {
-								if err := processTezosBlock(i, chainData.Config.RPC, chainName); err != nil {
+								// Đang tụt lại nên chỉ giới hạn bởi budget của endpoint
+								if err := scheduler.Acquire(ctx, "block"); err != nil {
+									return nil
+								}
+								if err := processTezosBlock(i, chainData.Config().RPC, chainName); err != nil {
 									model.LogMutexVan.Lock()
 									log.Printf("Error processing block %d: %v, continuing...", i, err)
 									model.LogMutexVan.Unlock()
+									if poller.IsRateLimited(err) {
+										scheduler.RateLimited()
+										if !sleepCtx(ctx, scheduler.Next()) {
+											return nil
+										}
+									}
 								}
-								time.Sleep(50 * time.Millisecond)
 							}
 
+							scheduler.ObserveProcessed(uint64(nextBatchEnd))
 							currentBlock = nextBatchEnd + 1
 							model.ProcessLockVan.Lock()
 							chainData.SetLastProcessedBlockVan(nextBatchEnd)
@@ -197,43 +218,58 @@ func continueHandleTezosHTTP(chainName string) {
 			}
 		}
 
-		err := processTezosBlock(currentBlock, chainData.Config.RPC, chainName)
+		err := processTezosBlock(currentBlock, chainData.Config().RPC, chainName)
 		if err == nil {
+			scheduler.Success()
+			scheduler.ObserveProcessed(uint64(currentBlock))
 			currentBlock++
 			model.ProcessLockVan.Lock()
 			if currentBlock-1 > chainData.GetLastProcessedBlockVan() {
 				chainData.SetLastProcessedBlockVan(currentBlock - 1)
 			}
 			model.ProcessLockVan.Unlock()
-			time.Sleep(200 * time.Millisecond)
+		} else if poller.IsRateLimited(err) {
+			scheduler.RateLimited()
+			model.LogMutexVan.Lock()
+			log.Printf("⏳ Rate limited fetching block %d, next poll in %v", currentBlock, scheduler.Next())
+			model.LogMutexVan.Unlock()
 		} else {
-			sleepTime := 500 * time.Millisecond
+			// Chưa có block này nghĩa là đã bắt kịp đầu chuỗi
+			scheduler.ObserveHead(uint64(currentBlock - 1))
 			model.LogMutexVan.Lock()
-			log.Printf("Waiting %v before retrying block %d", sleepTime, currentBlock)
+			log.Printf("Waiting %v before retrying block %d", scheduler.Next(), currentBlock)
 			model.LogMutexVan.Unlock()
-			time.Sleep(sleepTime)
+		}
+
+		if err := scheduler.Wait(ctx, "block"); err != nil {
+			return nil
 		}
 	}
+	return nil
 }
 
 // Quét từ hiện tại ngược về quá khứ
-func reverseHandleTezosHTTP(chainName string, pastDuration time.Duration) {
+func reverseHandleTezosHTTP(ctx context.Context, chainName string, pastDuration time.Duration) error {
 	chainDataGeneric := configs.GetChainData(chainName)
 	if chainDataGeneric == nil {
-		log.Fatalf("Data not found for chain %s", chainName)
+		return fmt.Errorf("data not found for chain %s", chainName)
 	}
 	chainData := chainDataGeneric.(*model.ChainDataTezos)
 
-	url := fmt.Sprintf("%s/chains/main/blocks/head/header", chainData.Config.RPC)
-	resp, err := http.Get(url)
+	url := fmt.Sprintf("%s/chains/main/blocks/head/header", chainData.Config().RPC)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		log.Fatalf("Error fetching chain status: %v", err)
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching chain status: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf("Error reading response: %v", err)
+		return fmt.Errorf("error reading response: %w", err)
 	}
 
 	var header struct {
@@ -241,7 +277,7 @@ func reverseHandleTezosHTTP(chainName string, pastDuration time.Duration) {
 		Timestamp time.Time `json:"timestamp"`
 	}
 	if err := json.Unmarshal(body, &header); err != nil {
-		log.Fatalf("Error parsing JSON: %v", err)
+		return fmt.Errorf("error parsing JSON: %w", err)
 	}
 
 	latestBlock := header.Level
@@ -257,13 +293,24 @@ func reverseHandleTezosHTTP(chainName string, pastDuration time.Duration) {
 
 	blockCounter := 0
 	currentBlock := latestBlock
+	scheduler := newScheduler(chainName, chainData.Config().RPC, tezosBlockTime)
 
-	for {
+	for ctx.Err() == nil {
 		blockCounter++
 
-		err := processTezosBlock(currentBlock, chainData.Config.RPC, chainName)
+		// Quét ngược là backfill nên chỉ bị giới hạn bởi budget của endpoint
+		if err := scheduler.Acquire(ctx, "block"); err != nil {
+			return nil
+		}
+
+		err := processTezosBlock(currentBlock, chainData.Config().RPC, chainName)
 		if err == nil {
-			block, err := getTezosBlock(currentBlock, chainData.Config.RPC)
+			scheduler.Success()
+
+			if err := scheduler.Acquire(ctx, "block"); err != nil {
+				return nil
+			}
+			block, err := getTezosBlock(currentBlock, chainData.Config().RPC)
 			if err == nil {
 				blockTime := block.Header.Timestamp
 				if blockTime.Before(targetTime) || blockTime.Equal(targetTime) {
@@ -284,14 +331,17 @@ func reverseHandleTezosHTTP(chainName string, pastDuration time.Duration) {
 				log.Printf("🔄 Scanned %d blocks backwards, currently at block %d", blockCounter, currentBlock)
 				model.LogMutexVan.Unlock()
 			}
-
-			time.Sleep(200 * time.Millisecond)
 		} else {
-			sleepTime := 500 * time.Millisecond
+			if poller.IsRateLimited(err) {
+				scheduler.RateLimited()
+			}
+			sleepTime := max(scheduler.Next(), 500*time.Millisecond)
 			model.LogMutexVan.Lock()
 			log.Printf("Waiting %v before retrying block %d", sleepTime, currentBlock)
 			model.LogMutexVan.Unlock()
-			time.Sleep(sleepTime)
+			if !sleepCtx(ctx, sleepTime) {
+				return nil
+			}
 		}
 
 		if currentBlock <= 1 {
@@ -305,31 +355,19 @@ func reverseHandleTezosHTTP(chainName string, pastDuration time.Duration) {
 	model.LogMutexVan.Lock()
 	log.Printf("✅ Completed processing blocks backwards for chain %s", chainName)
 	model.LogMutexVan.Unlock()
+	return nil
 }
 
-// Hàm chính xử lý Tezos qua HTTP
-func Handle_tezos_http() {
+// Hàm chính xử lý Tezos qua HTTP; chạy tới khi ctx bị hủy
+func Handle_tezos_http(ctx context.Context) error {
 	chainName := "tezos"
 
-	// Tạo thư mục log nếu chưa tồn tại
-	if _, err := os.Stat("./log"); os.IsNotExist(err) {
-		os.Mkdir("./log", 0755)
-	}
-
-	logFile, err := os.OpenFile("./services/get_chains/log/tezos_http.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatalf("Cannot open log file: %v", err)
-	}
-	defer logFile.Close()
-
-	log.SetOutput(logFile)
-
 	chainData := InitChainData(chainName)
 	log.Printf("Initialized data for chain %s, last processed block: %d",
 		chainName, chainData.GetLastProcessedBlockVan())
 
 	if err := configs.LoadConfig("./services/get_chains/configs/config-tezos.json", chainName); err != nil {
-		log.Fatalf("Cannot load config: %v", err)
+		return fmt.Errorf("cannot load config: %w", err)
 	}
 
 	pastDuration := 1 * time.Hour
@@ -339,11 +377,13 @@ func Handle_tezos_http() {
 	log.Printf("Step 1: Scanning backwards into the past (%v)", pastDuration)
 	model.LogMutexVan.Unlock()
 
-	reverseHandleTezosHTTP(chainName, pastDuration)
+	if err := reverseHandleTezosHTTP(ctx, chainName, pastDuration); err != nil {
+		return err
+	}
 
 	model.LogMutexVan.Lock()
 	log.Printf("Step 2: Continuing scan from past to present and monitoring new blocks")
 	model.LogMutexVan.Unlock()
 
-	continueHandleTezosHTTP(chainName)
+	return continueHandleTezosHTTP(ctx, chainName)
 }
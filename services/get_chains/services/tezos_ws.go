@@ -8,18 +8,11 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 	"time"
-)
 
-// Config cấu trúc cho cấu hình RPC
-type Config struct {
-	RPCURL string `json:"rpc"`
-	Chain  string `json:"chain"`
-}
+	"main/services/get_chains/configs"
+)
 
 // TransactionRecord chứa thông tin của giao dịch
 type TransactionRecord struct {
@@ -64,59 +57,16 @@ type Operation struct {
 
 var logMutex sync.Mutex
 
-// Handle_tezos_ws là hàm chính để xử lý Tezos qua giám sát realtime
-func Handle_tezos_ws() {
-	// Tạo thư mục log nếu chưa tồn tại
-	if _, err := os.Stat("./log"); os.IsNotExist(err) {
-		os.Mkdir("./log", 0755)
-	}
-
-	// Mở file log
-	logFile, err := os.OpenFile("./services/get_chains/log/tezos_ws.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatalf("Không thể mở file log: %v", err)
+// Handle_tezos_ws là hàm chính để xử lý Tezos qua giám sát realtime; chạy tới khi ctx bị hủy
+func Handle_tezos_ws(ctx context.Context) error {
+	// Dùng chung cấu hình với Handle_tezos_http để file được nạp lại thì cả hai cùng đổi
+	chainData := InitChainData("tezos")
+	if err := configs.LoadConfig("./services/get_chains/configs/config-tezos.json", "tezos"); err != nil {
+		return fmt.Errorf("không thể tải cấu hình: %w", err)
 	}
-	defer logFile.Close()
-	log.SetOutput(logFile)
-
-	// Đọc file cấu hình
-	configFile, err := os.ReadFile("./services/get_chains/configs/config-tezos.json")
-	if err != nil {
-		log.Fatalf("Không thể đọc file cấu hình: %v", err)
-	}
-
-	var config Config
-	if err := json.Unmarshal(configFile, &config); err != nil {
-		log.Fatalf("Không thể parse file cấu hình: %v", err)
-	}
-
-	log.Printf("Bắt đầu giám sát blockchain %s qua RPC: %s\n", config.Chain, config.RPCURL)
+	config := chainData.Config()
 
-	// Xử lý tín hiệu thoát
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		<-sigCh
-		log.Println("Nhận tín hiệu thoát, đang dừng chương trình...")
-
-		// Khởi tạo bộ đếm thời gian 30 giây
-		timeout := time.After(30 * time.Second)
-
-		// Hủy ngữ cảnh (context) để ngừng các hoạt động liên quan
-		cancel()
-
-		select {
-		case <-timeout:
-			log.Println("Chương trình đã thoát sau 30 giây.")
-			os.Exit(0)
-		case <-ctx.Done():
-			log.Println("Chương trình đã thoát ngay lập tức.")
-		}
-	}()
+	log.Printf("Bắt đầu giám sát blockchain %s qua RPC: %s\n", config.Chain, config.RPC)
 
 	// Poll block mới mỗi 10 giây
 	lastHeight := 0
@@ -127,9 +77,15 @@ func Handle_tezos_ws() {
 		select {
 		case <-ctx.Done():
 			log.Println("Chương trình đã kết thúc")
-			return
+			return nil
 		case <-ticker.C:
-			latestBlock, err := getLatestBlock(config.RPCURL)
+			// Endpoint đổi khi file cấu hình được nạp lại: chuyển sang RPC mới và bắt đầu lại từ head
+			if current := chainData.Config(); current != config {
+				log.Printf("🔄 Cấu hình Tezos đã đổi, chuyển RPC %s -> %s", config.RPC, current.RPC)
+				config = current
+				lastHeight = 0
+			}
+			latestBlock, err := getLatestBlock(config.RPC)
 			if err != nil {
 				log.Printf("Lỗi khi lấy block mới: %v", err)
 				continue
@@ -140,17 +96,17 @@ func Handle_tezos_ws() {
 			if lastHeight == 0 {
 				lastHeight = latestHeight
 				log.Printf("Khởi tạo với block #%d", lastHeight)
-				processBlock(config.RPCURL, latestBlock, config.Chain)
+				processBlock(config.RPC, latestBlock, config.Chain)
 			} else if latestHeight > lastHeight {
 				maxMissed := 10
 				for height := lastHeight + 1; height <= latestHeight && height <= lastHeight+maxMissed; height++ {
-					missedBlock, err := getBlockByHeight(config.RPCURL, height)
+					missedBlock, err := getBlockByHeight(config.RPC, height)
 					if err != nil {
 						log.Printf("Lỗi khi lấy block #%d: %v", height, err)
 						continue
 					}
 					log.Printf("Xử lý block #%d (bị bỏ sót hoặc mới)", height)
-					processBlock(config.RPCURL, missedBlock, config.Chain)
+					processBlock(config.RPC, missedBlock, config.Chain)
 				}
 				if latestHeight > lastHeight+maxMissed {
 					log.Printf("Bỏ qua các block từ #%d đến #%d", lastHeight+maxMissed+1, latestHeight)
@@ -0,0 +1,36 @@
+package get_chains
+
+import (
+	"main/services/shard"
+	"main/services/supervisor"
+)
+
+// chainService là một service thuộc về chain, bật/tắt cùng các service khác của chain
+type chainService struct {
+	name    string
+	service supervisor.Service
+	policy  supervisor.Policy
+}
+
+// add_chain_unit giao các service của chain cho shard manager: chúng chỉ chạy khi chain
+// được chia cho worker này. sticky cho service cũ không dừng được.
+func add_chain_unit(shards *shard.Manager, sup *supervisor.Supervisor, chain string, sticky bool, list ...chainService) {
+	shards.Add(chain, sticky, func() {
+		for _, s := range list {
+			sup.Add(s.name, s.service, s.policy)
+		}
+	}, func() {
+		for _, s := range list {
+			sup.Remove(s.name)
+		}
+	})
+}
+
+func add_evm_chain_unit(shards *shard.Manager, sup *supervisor.Supervisor, chain string) {
+	shards.Add(chain, false, func() {
+		add_evm_chain_services(sup, chain)
+	}, func() {
+		sup.Remove("evm-ws:" + chain)
+		sup.Remove("evm-http:" + chain)
+	})
+}
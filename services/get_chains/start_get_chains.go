@@ -1,89 +1,123 @@
 package get_chains
 
 import (
+	"context"
 	"log"
 	"os"
-	"sync"
 
 	"main/services/get_chains/services"
+	"main/services/shard"
+	"main/services/supervisor"
 )
 
-func handle_ws() {
-	chains := []string{
-		"ethereum",
-		"bsc",
-		"avalanche",
-		"polygon",
-		"arbitrum",
-		"optimism",
-		"fantom",
-		"base",
-	}
-
-	for _, chain := range chains {
-		go handle_chain(chain)
-	}
+// Các chain EVM chạy cả ingest WebSocket (thời gian thực) và HTTP (quét ngược)
+var evmChains = []string{
+	"ethereum",
+	"bsc",
+	"avalanche",
+	"polygon",
+	"arbitrum",
+	"optimism",
+	"fantom",
+	"base",
 }
 
 // Hàm mới để xử lý Solana HTTP
-func handle_solana_http() {
+func handle_solana_http(ctx context.Context) error {
 	logger := log.New(os.Stdout, "[SOLANA-HTTP] ", log.LstdFlags)
 	stopChan := make(chan struct{})
-	txChan := make(chan interface{})
-	
+	// Hàng đợi có giới hạn: khi bên xử lý chậm, HandleChainSolana bị chặn thay vì dồn bộ nhớ
+	txChan := make(chan interface{}, 256)
+
 	// Xử lý các giao dịch nhận được (có thể thêm logic xử lý ở đây)
 	go func() {
 		for tx := range txChan {
 			logger.Printf("Received transaction: %v", tx)
 		}
 	}()
-	
+	defer close(txChan)
+
+	go func() {
+		<-ctx.Done()
+		close(stopChan)
+	}()
+
 	// Gọi hàm xử lý Solana HTTP
 	services.HandleChainSolana("./services/get_chains/configs/config-sol.json", stopChan, logger, txChan)
+	return nil
 }
 
 // Hàm mới để xử lý Bitcoin và Solana qua WebSocket
-func handle_btc_sol_ws() {
+func handle_btc_sol_ws(ctx context.Context) error {
+	stopChan := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(stopChan)
+	}()
+
 	// Gọi hàm xử lý dữ liệu tiền điện tử
-	services.HandleRealTimeCrypto("./services/get_chains/configs/config-binance.json", make(chan struct{}))
+	services.HandleRealTimeCrypto("./services/get_chains/configs/config-binance.json", stopChan)
+	return nil
 }
 
-func StartGetChains() {
-	var wg sync.WaitGroup
-	
-	// Danh sách tất cả các dịch vụ cần chạy
-	services := []func(){
-		handle_cosmos_http,
-		handle_cosmos_ws,
-		handle_wss_vechain,
-		handle_http_vechain,
-		HandleTronHTTP,
-		handle_tron_ws,
-		services.Handle_stellar_http,
-		services.Handle_stellar_ws,
-		services.Handle_tezos_http,
-		services.Handle_tezos_ws,
-		services.Handle_algorand_http,
-		handle_http,
-		handle_ws,
-		func() { services.RunCryptoDataProcessor("./configs/config-binance.json") },
-		// Thêm các hàm mới vào danh sách dịch vụ
-		handle_solana_http,
-		handle_btc_sol_ws,
-	}
+// StartGetChains chạy mọi service lấy dữ liệu chain dưới một supervisor cho tới khi ctx bị hủy.
+// Service lỗi hoặc panic được khởi động lại với backoff; khi tắt, checkpoint được ghi lần cuối.
+// Khi chạy nhiều worker, mỗi worker chỉ bật các chain được chia cho nó (xem services/shard).
+func StartGetChains(ctx context.Context) error {
+	sup := supervisor.New("get_chains")
+	shards := shard.New()
 
-	for _, service := range services {
-		wg.Add(1)
-		go func(serviceFunc func()) {
-			defer wg.Done()
-			defer func() {
-				if r := recover(); r != nil {
-					log.Printf("Recovered from panic in service: %v", r)
-				}
-			}()
-			serviceFunc()
-		}(service)
+	// Danh sách chain EVM có thể đổi lúc chạy (AddEVMChain / RemoveEVMChain)
+	evmChainsLock.Lock()
+	for _, chain := range evmChains {
+		add_evm_chain_unit(shards, sup, chain)
 	}
+	running, runningShards = sup, shards
+	evmChainsLock.Unlock()
+	defer func() {
+		evmChainsLock.Lock()
+		running, runningShards = nil, nil
+		evmChainsLock.Unlock()
+	}()
+
+	// Các WebSocket kết thúc khi mất kết nối, supervisor kết nối lại với backoff
+	reconnect := supervisor.DefaultPolicy
+	reconnect.Mode = supervisor.RestartAlways
+	reconnect.MaxRestarts = 0
+	add_chain_unit(shards, sup, "tron", true,
+		chainService{"tron-http", HandleTronHTTP, supervisor.DefaultPolicy},
+		chainService{"tron-ws", handle_tron_ws, supervisor.DefaultPolicy})
+	add_chain_unit(shards, sup, "stellar", true,
+		chainService{"stellar-http", services.Handle_stellar_http, supervisor.DefaultPolicy},
+		chainService{"stellar-ws", services.Handle_stellar_ws, reconnect})
+	add_chain_unit(shards, sup, "tezos", true,
+		chainService{"tezos-http", services.Handle_tezos_http, supervisor.DefaultPolicy},
+		chainService{"tezos-ws", services.Handle_tezos_ws, reconnect})
+	add_chain_unit(shards, sup, "algorand", true,
+		chainService{"algorand-http", services.Handle_algorand_http, supervisor.DefaultPolicy})
+	add_chain_unit(shards, sup, "cosmos", true,
+		chainService{"cosmos-http", handle_cosmos_http, supervisor.DefaultPolicy},
+		chainService{"cosmos-ws", handle_cosmos_ws, reconnect})
+	add_chain_unit(shards, sup, "vechain", true,
+		chainService{"vechain-http", handle_http_vechain, supervisor.DefaultPolicy},
+		chainService{"vechain-ws", handle_wss_vechain, reconnect})
+
+	add_chain_unit(shards, sup, "binance", false,
+		chainService{"crypto-processor", func(ctx context.Context) error {
+			services.RunCryptoDataProcessor(ctx, "./configs/config-binance.json")
+			return nil
+		}, supervisor.DefaultPolicy},
+		chainService{"btc-sol-ws", handle_btc_sol_ws, supervisor.DefaultPolicy})
+	add_chain_unit(shards, sup, "solana", false,
+		chainService{"solana-http", handle_solana_http, supervisor.DefaultPolicy})
+
+	sup.Add("shard", shards.Run, supervisor.DefaultPolicy)
+	// Hook chạy ngược thứ tự: heartbeat chỉ bị xóa sau khi checkpoint đã được ghi
+	sup.OnShutdown("shard", shards.Leave)
+	sup.OnShutdown("checkpoints", flush_checkpoints)
+
+	// Sửa file cấu hình khi đang chạy: endpoint đổi thì kết nối lại, ngưỡng đổi thì áp dụng ngay
+	watch_config_files()
 
-	wg.Wait()
+	return sup.Run(ctx)
 }
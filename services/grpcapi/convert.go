@@ -0,0 +1,153 @@
+package grpcapi
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"main/services/grpcapi/dseapb"
+	"main/services/pipeline"
+	"main/services/store"
+)
+
+func transferToProto(ev pipeline.Event, usd float64, hasUSD bool) *dseapb.Transfer {
+	t := &dseapb.Transfer{
+		Chain:           ev.Chain,
+		Rule:            ev.Rule,
+		BlockNumber:     ev.BlockNumber,
+		BlockHash:       ev.BlockHash,
+		TxHash:          ev.TxHash,
+		LogIndex:        uint32(ev.LogIndex),
+		Contract:        ev.Contract,
+		FromAddress:     ev.From,
+		ToAddress:       ev.To,
+		EventSignature:  ev.EventSignature,
+		TransactionType: ev.TransactionType,
+		Timestamp:       timestamppb.New(ev.Timestamp),
+		Removed:         ev.Removed,
+	}
+	if ev.Amount != nil {
+		t.Amount = ev.Amount.String()
+	}
+	if hasUSD {
+		t.UsdValue = &usd
+	}
+	return t
+}
+
+// candleJSON khớp với ohlcv.ResponseOHLCV mà sink ghi ra
+type candleJSON struct {
+	Symbol           string
+	OpenTime         *big.Int
+	Open             *big.Int
+	High             *big.Int
+	Low              *big.Int
+	Close            *big.Int
+	Volume           string
+	CloseTime        *big.Int
+	QuoteAssetVolume string
+	NumberOfTrades   *big.Int
+	TakerBuyBaseVol  string
+	TakerBuyQuoteVol string
+}
+
+func candleToProto(raw json.RawMessage, interval string) (*dseapb.Candle, error) {
+	var c candleJSON
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	return &dseapb.Candle{
+		Symbol:              strings.ToUpper(c.Symbol),
+		Interval:            interval,
+		OpenTime:            int64Of(c.OpenTime),
+		CloseTime:           int64Of(c.CloseTime),
+		Open:                stringOf(c.Open),
+		High:                stringOf(c.High),
+		Low:                 stringOf(c.Low),
+		Close:               stringOf(c.Close),
+		Volume:              c.Volume,
+		QuoteAssetVolume:    c.QuoteAssetVolume,
+		NumberOfTrades:      int64Of(c.NumberOfTrades),
+		TakerBuyBaseVolume:  c.TakerBuyBaseVol,
+		TakerBuyQuoteVolume: c.TakerBuyQuoteVol,
+	}, nil
+}
+
+func stablecoinFlowToProto(e store.Entry) (*dseapb.StablecoinFlow, error) {
+	var f struct {
+		NameCoin  string  `json:"NameCoin"`
+		Symbol    string  `json:"Symbol"`
+		StartTime string  `json:"StartTime"`
+		Incoming  float64 `json:"Incoming"`
+		Outgoing  float64 `json:"Outgoing"`
+		NetFlow   float64 `json:"NetFlow"`
+		Balance   float64 `json:"Balance"`
+		Duration  string  `json:"Duration"`
+	}
+	if err := json.Unmarshal(e.Data, &f); err != nil {
+		return nil, err
+	}
+	return &dseapb.StablecoinFlow{
+		Time:      timestamppb.New(e.Time),
+		NameCoin:  f.NameCoin,
+		Symbol:    f.Symbol,
+		StartTime: f.StartTime,
+		Incoming:  f.Incoming,
+		Outgoing:  f.Outgoing,
+		NetFlow:   f.NetFlow,
+		Balance:   f.Balance,
+		Duration:  f.Duration,
+	}, nil
+}
+
+func fearGreedToProto(e store.Entry) (*dseapb.FearGreed, error) {
+	var f struct {
+		Value               *big.Int `json:"value"`
+		ValueClassification string   `json:"value_classification"`
+	}
+	if err := json.Unmarshal(e.Data, &f); err != nil {
+		return nil, err
+	}
+	return &dseapb.FearGreed{
+		Time:                timestamppb.New(e.Time),
+		Value:               int64Of(f.Value),
+		ValueClassification: f.ValueClassification,
+	}, nil
+}
+
+func btcNetFlowToProto(e store.Entry) (*dseapb.BTCNetFlow, error) {
+	var f struct {
+		Period   string             `json:"period"`
+		Source   string             `json:"source"`
+		Incoming map[string]float64 `json:"incoming"`
+		Outgoing map[string]float64 `json:"outgoing"`
+		Balance  map[string]float64 `json:"balance"`
+	}
+	if err := json.Unmarshal(e.Data, &f); err != nil {
+		return nil, err
+	}
+	return &dseapb.BTCNetFlow{
+		Time:     timestamppb.New(e.Time),
+		Period:   f.Period,
+		Source:   f.Source,
+		Incoming: f.Incoming,
+		Outgoing: f.Outgoing,
+		Balance:  f.Balance,
+	}, nil
+}
+
+func int64Of(n *big.Int) int64 {
+	if n == nil {
+		return 0
+	}
+	return n.Int64()
+}
+
+func stringOf(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return n.String()
+}
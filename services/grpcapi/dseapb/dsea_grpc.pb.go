@@ -0,0 +1,359 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: dsea.proto
+
+package dseapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Dsea_StreamTransfers_FullMethodName    = "/dsea.v1.Dsea/StreamTransfers"
+	Dsea_ListTransfers_FullMethodName      = "/dsea.v1.Dsea/ListTransfers"
+	Dsea_GetBlock_FullMethodName           = "/dsea.v1.Dsea/GetBlock"
+	Dsea_GetCandles_FullMethodName         = "/dsea.v1.Dsea/GetCandles"
+	Dsea_GetStablecoinFlows_FullMethodName = "/dsea.v1.Dsea/GetStablecoinFlows"
+	Dsea_GetFearGreed_FullMethodName       = "/dsea.v1.Dsea/GetFearGreed"
+	Dsea_GetBTCNetFlow_FullMethodName      = "/dsea.v1.Dsea/GetBTCNetFlow"
+)
+
+// DseaClient is the client API for Dsea service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Dsea cung cấp luồng event và các truy vấn giống REST API cho service nội bộ.
+type DseaClient interface {
+	// StreamTransfers phát lại transfer đã lưu sau cursor (nếu có) rồi đẩy transfer mới theo thời gian thực.
+	StreamTransfers(ctx context.Context, in *TransferFilter, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transfer], error)
+	ListTransfers(ctx context.Context, in *ListTransfersRequest, opts ...grpc.CallOption) (*ListTransfersResponse, error)
+	GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Block, error)
+	GetCandles(ctx context.Context, in *GetCandlesRequest, opts ...grpc.CallOption) (*CandleList, error)
+	GetStablecoinFlows(ctx context.Context, in *PeriodRequest, opts ...grpc.CallOption) (*StablecoinFlowList, error)
+	GetFearGreed(ctx context.Context, in *LimitRequest, opts ...grpc.CallOption) (*FearGreedList, error)
+	GetBTCNetFlow(ctx context.Context, in *PeriodRequest, opts ...grpc.CallOption) (*BTCNetFlowList, error)
+}
+
+type dseaClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDseaClient(cc grpc.ClientConnInterface) DseaClient {
+	return &dseaClient{cc}
+}
+
+func (c *dseaClient) StreamTransfers(ctx context.Context, in *TransferFilter, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transfer], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Dsea_ServiceDesc.Streams[0], Dsea_StreamTransfers_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TransferFilter, Transfer]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dsea_StreamTransfersClient = grpc.ServerStreamingClient[Transfer]
+
+func (c *dseaClient) ListTransfers(ctx context.Context, in *ListTransfersRequest, opts ...grpc.CallOption) (*ListTransfersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTransfersResponse)
+	err := c.cc.Invoke(ctx, Dsea_ListTransfers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dseaClient) GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Block, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Block)
+	err := c.cc.Invoke(ctx, Dsea_GetBlock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dseaClient) GetCandles(ctx context.Context, in *GetCandlesRequest, opts ...grpc.CallOption) (*CandleList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CandleList)
+	err := c.cc.Invoke(ctx, Dsea_GetCandles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dseaClient) GetStablecoinFlows(ctx context.Context, in *PeriodRequest, opts ...grpc.CallOption) (*StablecoinFlowList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StablecoinFlowList)
+	err := c.cc.Invoke(ctx, Dsea_GetStablecoinFlows_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dseaClient) GetFearGreed(ctx context.Context, in *LimitRequest, opts ...grpc.CallOption) (*FearGreedList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FearGreedList)
+	err := c.cc.Invoke(ctx, Dsea_GetFearGreed_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dseaClient) GetBTCNetFlow(ctx context.Context, in *PeriodRequest, opts ...grpc.CallOption) (*BTCNetFlowList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BTCNetFlowList)
+	err := c.cc.Invoke(ctx, Dsea_GetBTCNetFlow_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DseaServer is the server API for Dsea service.
+// All implementations must embed UnimplementedDseaServer
+// for forward compatibility.
+//
+// Dsea cung cấp luồng event và các truy vấn giống REST API cho service nội bộ.
+type DseaServer interface {
+	// StreamTransfers phát lại transfer đã lưu sau cursor (nếu có) rồi đẩy transfer mới theo thời gian thực.
+	StreamTransfers(*TransferFilter, grpc.ServerStreamingServer[Transfer]) error
+	ListTransfers(context.Context, *ListTransfersRequest) (*ListTransfersResponse, error)
+	GetBlock(context.Context, *GetBlockRequest) (*Block, error)
+	GetCandles(context.Context, *GetCandlesRequest) (*CandleList, error)
+	GetStablecoinFlows(context.Context, *PeriodRequest) (*StablecoinFlowList, error)
+	GetFearGreed(context.Context, *LimitRequest) (*FearGreedList, error)
+	GetBTCNetFlow(context.Context, *PeriodRequest) (*BTCNetFlowList, error)
+	mustEmbedUnimplementedDseaServer()
+}
+
+// UnimplementedDseaServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDseaServer struct{}
+
+func (UnimplementedDseaServer) StreamTransfers(*TransferFilter, grpc.ServerStreamingServer[Transfer]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTransfers not implemented")
+}
+func (UnimplementedDseaServer) ListTransfers(context.Context, *ListTransfersRequest) (*ListTransfersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTransfers not implemented")
+}
+func (UnimplementedDseaServer) GetBlock(context.Context, *GetBlockRequest) (*Block, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlock not implemented")
+}
+func (UnimplementedDseaServer) GetCandles(context.Context, *GetCandlesRequest) (*CandleList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCandles not implemented")
+}
+func (UnimplementedDseaServer) GetStablecoinFlows(context.Context, *PeriodRequest) (*StablecoinFlowList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStablecoinFlows not implemented")
+}
+func (UnimplementedDseaServer) GetFearGreed(context.Context, *LimitRequest) (*FearGreedList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFearGreed not implemented")
+}
+func (UnimplementedDseaServer) GetBTCNetFlow(context.Context, *PeriodRequest) (*BTCNetFlowList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBTCNetFlow not implemented")
+}
+func (UnimplementedDseaServer) mustEmbedUnimplementedDseaServer() {}
+func (UnimplementedDseaServer) testEmbeddedByValue()              {}
+
+// UnsafeDseaServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DseaServer will
+// result in compilation errors.
+type UnsafeDseaServer interface {
+	mustEmbedUnimplementedDseaServer()
+}
+
+func RegisterDseaServer(s grpc.ServiceRegistrar, srv DseaServer) {
+	// If the following call pancis, it indicates UnimplementedDseaServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Dsea_ServiceDesc, srv)
+}
+
+func _Dsea_StreamTransfers_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TransferFilter)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DseaServer).StreamTransfers(m, &grpc.GenericServerStream[TransferFilter, Transfer]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dsea_StreamTransfersServer = grpc.ServerStreamingServer[Transfer]
+
+func _Dsea_ListTransfers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTransfersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DseaServer).ListTransfers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dsea_ListTransfers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DseaServer).ListTransfers(ctx, req.(*ListTransfersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dsea_GetBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DseaServer).GetBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dsea_GetBlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DseaServer).GetBlock(ctx, req.(*GetBlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dsea_GetCandles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCandlesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DseaServer).GetCandles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dsea_GetCandles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DseaServer).GetCandles(ctx, req.(*GetCandlesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dsea_GetStablecoinFlows_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PeriodRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DseaServer).GetStablecoinFlows(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dsea_GetStablecoinFlows_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DseaServer).GetStablecoinFlows(ctx, req.(*PeriodRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dsea_GetFearGreed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LimitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DseaServer).GetFearGreed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dsea_GetFearGreed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DseaServer).GetFearGreed(ctx, req.(*LimitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dsea_GetBTCNetFlow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PeriodRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DseaServer).GetBTCNetFlow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dsea_GetBTCNetFlow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DseaServer).GetBTCNetFlow(ctx, req.(*PeriodRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Dsea_ServiceDesc is the grpc.ServiceDesc for Dsea service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Dsea_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dsea.v1.Dsea",
+	HandlerType: (*DseaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListTransfers",
+			Handler:    _Dsea_ListTransfers_Handler,
+		},
+		{
+			MethodName: "GetBlock",
+			Handler:    _Dsea_GetBlock_Handler,
+		},
+		{
+			MethodName: "GetCandles",
+			Handler:    _Dsea_GetCandles_Handler,
+		},
+		{
+			MethodName: "GetStablecoinFlows",
+			Handler:    _Dsea_GetStablecoinFlows_Handler,
+		},
+		{
+			MethodName: "GetFearGreed",
+			Handler:    _Dsea_GetFearGreed_Handler,
+		},
+		{
+			MethodName: "GetBTCNetFlow",
+			Handler:    _Dsea_GetBTCNetFlow_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTransfers",
+			Handler:       _Dsea_StreamTransfers_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "dsea.proto",
+}
@@ -0,0 +1,4 @@
+// Package dseapb chứa mã sinh từ dsea.proto; không sửa tay các file *.pb.go.
+package dseapb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative dsea.proto
@@ -0,0 +1,248 @@
+package grpcapi
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	ohlcvConfig "main/config/ohlcv"
+	"main/services/grpcapi/dseapb"
+	"main/services/store"
+)
+
+// DefaultAddr là địa chỉ lắng nghe mặc định, có thể đổi bằng biến môi trường GRPC_ADDR
+const DefaultAddr = ":9200"
+
+// DefaultBuffer là số transfer chờ gửi tối đa cho mỗi stream, đổi bằng GRPC_STREAM_BUFFER
+const DefaultBuffer = 1024
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Server phục vụ dịch vụ Dsea: truy vấn đọc từ store, còn StreamTransfers nhận transfer
+// mới vì Server cũng là processor của pipeline.
+type Server struct {
+	dseapb.UnimplementedDseaServer
+
+	store  *store.Store
+	buffer int
+
+	mu      sync.RWMutex
+	streams map[*stream]struct{}
+	done    chan struct{} // đóng khi lần Serve hiện tại tắt; mỗi lần Serve tạo kênh mới
+}
+
+// New tạo server đọc từ store; buffer lấy từ GRPC_STREAM_BUFFER hoặc DefaultBuffer
+func New(s *store.Store) *Server {
+	buffer, _ := strconv.Atoi(os.Getenv("GRPC_STREAM_BUFFER"))
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Server{
+		store:   s,
+		buffer:  buffer,
+		streams: make(map[*stream]struct{}),
+	}
+}
+
+// Addr trả về địa chỉ lắng nghe đã cấu hình
+func Addr() string {
+	if addr := os.Getenv("GRPC_ADDR"); addr != "" {
+		return addr
+	}
+	return DefaultAddr
+}
+
+// Serve chạy gRPC server cho tới khi ctx bị hủy. Các stream đang mở được đóng trước
+// để GracefulStop không phải chờ chúng.
+func (s *Server) Serve(ctx context.Context, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer()
+	dseapb.RegisterDseaServer(server, s)
+
+	// Supervisor có thể gọi lại Serve sau khi khởi động lại nên kênh tắt là của từng lần chạy
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.done = done
+	s.mu.Unlock()
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("🌐 gRPC server lắng nghe tại %s", addr)
+		errCh <- server.Serve(lis)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		close(done)
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(10 * time.Second):
+			server.Stop()
+		}
+		return nil
+	}
+}
+
+func (s *Server) ListTransfers(ctx context.Context, req *dseapb.ListTransfersRequest) (*dseapb.ListTransfersResponse, error) {
+	limit, err := parseLimit(req.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+	query := store.TransferQuery{
+		Chain:   req.GetChain(),
+		Address: req.GetAddress(),
+		Token:   req.GetToken(),
+		Cursor:  req.GetCursor(),
+		Limit:   limit,
+	}
+	if req.GetFrom() != nil {
+		query.From = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		query.To = req.GetTo().AsTime()
+	}
+	if v := req.GetMinAmount(); v != "" {
+		amount, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "min_amount không hợp lệ: %s", v)
+		}
+		query.MinAmount = amount
+	}
+
+	events, next := s.store.TransferEvents(query)
+	resp := &dseapb.ListTransfersResponse{NextCursor: next}
+	for _, ev := range events {
+		resp.Transfers = append(resp.Transfers, transferToProto(ev, 0, false))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetBlock(ctx context.Context, req *dseapb.GetBlockRequest) (*dseapb.Block, error) {
+	raw, ok := s.store.Block(req.GetChain(), req.GetHeight())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "không tìm thấy khối %d của chain %s", req.GetHeight(), req.GetChain())
+	}
+	return &dseapb.Block{Chain: req.GetChain(), Height: req.GetHeight(), Json: raw}, nil
+}
+
+func (s *Server) GetCandles(ctx context.Context, req *dseapb.GetCandlesRequest) (*dseapb.CandleList, error) {
+	interval, ok := ohlcvConfig.Lookup(defaultString(req.GetInterval(), "1d"))
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "interval không hợp lệ: %s (hỗ trợ %s)", req.GetInterval(), strings.Join(ohlcvConfig.IntervalNames(), ", "))
+	}
+	limit, err := parseLimit(req.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dseapb.CandleList{}
+	for _, raw := range s.store.Candles(req.GetSymbol(), interval, req.GetFrom(), req.GetTo(), limit) {
+		candle, err := candleToProto(raw, interval)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "nến hỏng: %v", err)
+		}
+		resp.Candles = append(resp.Candles, candle)
+	}
+	return resp, nil
+}
+
+func (s *Server) GetStablecoinFlows(ctx context.Context, req *dseapb.PeriodRequest) (*dseapb.StablecoinFlowList, error) {
+	period, ok := store.FlowPeriod(defaultString(req.GetPeriod(), "1d"))
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "period không hợp lệ: %s (hỗ trợ 1d, 1w, 1m)", req.GetPeriod())
+	}
+	limit, err := parseLimit(req.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dseapb.StablecoinFlowList{}
+	for _, e := range s.store.StablecoinFlows(period, limit) {
+		flow, err := stablecoinFlowToProto(e)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "bản ghi dòng tiền hỏng: %v", err)
+		}
+		resp.Flows = append(resp.Flows, flow)
+	}
+	return resp, nil
+}
+
+func (s *Server) GetFearGreed(ctx context.Context, req *dseapb.LimitRequest) (*dseapb.FearGreedList, error) {
+	limit, err := parseLimit(req.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dseapb.FearGreedList{}
+	for _, e := range s.store.FearGreed(limit) {
+		value, err := fearGreedToProto(e)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "bản ghi fear & greed hỏng: %v", err)
+		}
+		resp.Values = append(resp.Values, value)
+	}
+	return resp, nil
+}
+
+func (s *Server) GetBTCNetFlow(ctx context.Context, req *dseapb.PeriodRequest) (*dseapb.BTCNetFlowList, error) {
+	period, ok := store.NetFlowPeriod(defaultString(req.GetPeriod(), "daily"))
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "period không hợp lệ: %s (hỗ trợ daily, weekly, monthly)", req.GetPeriod())
+	}
+	limit, err := parseLimit(req.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dseapb.BTCNetFlowList{}
+	for _, e := range s.store.BTCNetFlow(period, limit) {
+		flow, err := btcNetFlowToProto(e)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "bản ghi netflow hỏng: %v", err)
+		}
+		resp.Flows = append(resp.Flows, flow)
+	}
+	return resp, nil
+}
+
+func parseLimit(limit int32) (int, error) {
+	switch {
+	case limit == 0:
+		return defaultLimit, nil
+	case limit < 0:
+		return 0, status.Errorf(codes.InvalidArgument, "limit không hợp lệ: %d", limit)
+	case limit > maxLimit:
+		return maxLimit, nil
+	}
+	return int(limit), nil
+}
+
+func defaultString(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
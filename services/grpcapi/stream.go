@@ -0,0 +1,206 @@
+package grpcapi
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"main/services/grpcapi/dseapb"
+	"main/services/metrics"
+	"main/services/pipeline"
+	"main/services/prices"
+)
+
+// replayBatch là số transfer đọc từ store mỗi lần khi phát lại từ cursor
+const replayBatch = 1000
+
+// stream là một lời gọi StreamTransfers đang mở. Transfer mới đi qua buffer ch;
+// buffer đầy thì stream bị hủy để client chậm không làm nghẽn pipeline.
+type stream struct {
+	chain, address, token, eventType string
+	minAmount                        *big.Int
+	minUSD                           float64
+
+	ch       chan *dseapb.Transfer
+	slow     chan struct{}
+	slowOnce sync.Once
+}
+
+func newStream(f *dseapb.TransferFilter, buffer int) (*stream, error) {
+	st := &stream{
+		chain:     f.GetChain(),
+		address:   strings.ToLower(f.GetAddress()),
+		token:     strings.ToLower(f.GetToken()),
+		eventType: f.GetEventType(),
+		minUSD:    f.GetMinUsd(),
+		ch:        make(chan *dseapb.Transfer, buffer),
+		slow:      make(chan struct{}),
+	}
+	if v := f.GetMinAmount(); v != "" {
+		amount, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			return nil, fmt.Errorf("min_amount không hợp lệ: %s", v)
+		}
+		st.minAmount = amount
+	}
+	if c := f.GetCursor(); c != nil {
+		if c.GetChain() == "" {
+			return nil, fmt.Errorf("cursor thiếu chain")
+		}
+		if st.chain != "" && st.chain != c.GetChain() {
+			return nil, fmt.Errorf("cursor.chain (%s) khác chain của bộ lọc (%s)", c.GetChain(), st.chain)
+		}
+	}
+	return st, nil
+}
+
+// match áp bộ lọc cho event; khi có min_usd mà không quy đổi được USD thì bỏ qua event
+func (st *stream) match(ev pipeline.Event, usd float64, hasUSD bool) bool {
+	if st.chain != "" && st.chain != ev.Chain {
+		return false
+	}
+	if st.address != "" && strings.ToLower(ev.From) != st.address && strings.ToLower(ev.To) != st.address {
+		return false
+	}
+	if st.token != "" && strings.ToLower(ev.Contract) != st.token {
+		return false
+	}
+	if st.eventType != "" && !strings.EqualFold(st.eventType, ev.TransactionType) &&
+		!strings.EqualFold(st.eventType, ev.EventSignature) && !strings.EqualFold(st.eventType, ev.Rule) {
+		return false
+	}
+	if st.minAmount != nil && (ev.Amount == nil || ev.Amount.Cmp(st.minAmount) < 0) {
+		return false
+	}
+	if st.minUSD > 0 && (!hasUSD || usd < st.minUSD) {
+		return false
+	}
+	return true
+}
+
+// offer đưa transfer vào buffer mà không chặn
+func (st *stream) offer(t *dseapb.Transfer) {
+	select {
+	case st.ch <- t:
+	default:
+		st.slowOnce.Do(func() {
+			metrics.GRPCSlowStreams.Inc()
+			close(st.slow)
+		})
+	}
+}
+
+// addStream đăng ký stream và trả về kênh báo lần Serve hiện tại đang tắt
+func (s *Server) addStream(st *stream) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams[st] = struct{}{}
+	metrics.GRPCStreams.Set(float64(len(s.streams)))
+	return s.done
+}
+
+func (s *Server) removeStream(st *stream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.streams, st)
+	metrics.GRPCStreams.Set(float64(len(s.streams)))
+}
+
+func (s *Server) Name() string {
+	return "grpcapi"
+}
+
+func (s *Server) ProcessEvent(ev pipeline.Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.streams) == 0 {
+		return nil
+	}
+
+	usd, hasUSD := prices.USDValue(ev)
+	var msg *dseapb.Transfer
+	for st := range s.streams {
+		if !st.match(ev, usd, hasUSD) {
+			continue
+		}
+		if msg == nil {
+			msg = transferToProto(ev, usd, hasUSD)
+		}
+		st.offer(msg)
+	}
+	return nil
+}
+
+func (s *Server) ProcessBlock(b pipeline.Block) error {
+	return nil
+}
+
+// StreamTransfers đăng ký nhận transfer mới trước, rồi phát lại từ cursor, để không hụt
+// transfer đến trong lúc phát lại; transfer đã phát lại sẽ không bị gửi trùng.
+func (s *Server) StreamTransfers(f *dseapb.TransferFilter, out dseapb.Dsea_StreamTransfersServer) error {
+	st, err := newStream(f, s.buffer)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	done := s.addStream(st)
+	defer s.removeStream(st)
+
+	send := func(t *dseapb.Transfer) error {
+		if err := out.Send(t); err != nil {
+			return err
+		}
+		metrics.GRPCTransfersSent.Inc()
+		return nil
+	}
+
+	cursor := f.GetCursor()
+	if cursor != nil {
+		block, logIndex := cursor.GetBlockNumber(), uint(cursor.GetLogIndex())
+		for {
+			events := s.store.TransfersAfter(cursor.GetChain(), block, logIndex, replayBatch)
+			for _, ev := range events {
+				block, logIndex = ev.BlockNumber, ev.LogIndex
+				usd, hasUSD := prices.USDValue(ev)
+				if !st.match(ev, usd, hasUSD) {
+					continue
+				}
+				if err := send(transferToProto(ev, usd, hasUSD)); err != nil {
+					return err
+				}
+			}
+			if len(events) < replayBatch {
+				break
+			}
+		}
+		cursor = &dseapb.Cursor{Chain: cursor.GetChain(), BlockNumber: block, LogIndex: uint32(logIndex)}
+	}
+
+	for {
+		select {
+		case <-out.Context().Done():
+			return out.Context().Err()
+		case <-done:
+			return status.Error(codes.Unavailable, "server đang tắt")
+		case <-st.slow:
+			return status.Error(codes.ResourceExhausted, "client nhận không kịp, hãy kết nối lại với cursor của transfer cuối cùng")
+		case t := <-st.ch:
+			if cursor != nil && !t.Removed && t.Chain == cursor.Chain && !after(t, cursor) {
+				continue
+			}
+			if err := send(t); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func after(t *dseapb.Transfer, c *dseapb.Cursor) bool {
+	if t.BlockNumber != c.BlockNumber {
+		return t.BlockNumber > c.BlockNumber
+	}
+	return t.LogIndex > c.LogIndex
+}
@@ -0,0 +1,474 @@
+package headercache
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// DefaultCapacity là số header giữ trong bộ nhớ cho mỗi chain
+const DefaultCapacity = 4096
+
+// DiskRetention là số khối gần nhất giữ trong file trên đĩa; file được viết lại khi số
+// header vượt quá DiskRetention thêm một phần tư để không lớn mãi
+const DiskRetention = 200000
+
+// Header là phần thông tin của khối mà các module phân tích cần (timestamp, hash, base fee)
+type Header struct {
+	Chain      string `json:"chain"`
+	Number     uint64 `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parent_hash"`
+	Time       uint64 `json:"time"`
+	BaseFee    string `json:"base_fee,omitempty"`
+}
+
+// Timestamp trả về thời gian của khối
+func (h Header) Timestamp() time.Time {
+	return time.Unix(int64(h.Time), 0)
+}
+
+// Fetcher lấy header từ RPC khi cache không có
+type Fetcher func(ctx context.Context, number uint64) (Header, error)
+
+// Stats là số liệu của cache một chain
+type Stats struct {
+	Size      int    `json:"size"`
+	Hits      uint64 `json:"hits"`
+	DiskHits  uint64 `json:"disk_hits"`
+	Misses    uint64 `json:"misses"`
+	FetchErrs uint64 `json:"fetch_errors"`
+}
+
+type diskEntry struct {
+	offset int64
+	length int
+}
+
+type inflight struct {
+	done   chan struct{}
+	header Header
+	err    error
+}
+
+// Cache là LRU header của một chain, có thể kèm file trên đĩa để giữ qua các lần khởi động
+type Cache struct {
+	chain    string
+	capacity int
+
+	mu       sync.Mutex
+	items    map[uint64]*list.Element
+	order    *list.List
+	pending  map[uint64]*inflight
+	disk     *os.File
+	diskPath string
+	diskSize int64
+	diskIdx  map[uint64]diskEntry
+	diskMax  uint64 // số khối lớn nhất trên đĩa
+
+	hits      atomic.Uint64
+	diskHits  atomic.Uint64
+	misses    atomic.Uint64
+	fetchErrs atomic.Uint64
+}
+
+var (
+	caches     = make(map[string]*Cache)
+	cachesLock sync.Mutex
+	diskDir    string
+)
+
+// SetDiskDir bật lưu header xuống đĩa cho các cache được tạo sau lời gọi này
+func SetDiskDir(dir string) {
+	cachesLock.Lock()
+	defer cachesLock.Unlock()
+	diskDir = dir
+}
+
+// ForChain trả về cache dùng chung của chain, tạo mới nếu chưa có
+func ForChain(chain string) *Cache {
+	cachesLock.Lock()
+	defer cachesLock.Unlock()
+
+	if c, ok := caches[chain]; ok {
+		return c
+	}
+
+	c := newCache(chain, DefaultCapacity)
+	if diskDir != "" {
+		if err := c.openDisk(filepath.Join(diskDir, chain+".headers.jsonl")); err != nil {
+			log.Printf("⚠️ Không thể mở header cache trên đĩa cho %s: %v", chain, err)
+		}
+	}
+	caches[chain] = c
+	return c
+}
+
+// AllStats trả về số liệu cache của mọi chain
+func AllStats() map[string]Stats {
+	cachesLock.Lock()
+	defer cachesLock.Unlock()
+
+	result := make(map[string]Stats, len(caches))
+	for chain, c := range caches {
+		result[chain] = c.Stats()
+	}
+	return result
+}
+
+func newCache(chain string, capacity int) *Cache {
+	return &Cache{
+		chain:    chain,
+		capacity: capacity,
+		items:    make(map[uint64]*list.Element),
+		order:    list.New(),
+		pending:  make(map[uint64]*inflight),
+		diskIdx:  make(map[uint64]diskEntry),
+	}
+}
+
+func (c *Cache) openDisk(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	// Dựng lại chỉ mục số khối → vị trí dòng trong file
+	idx := make(map[uint64]diskEntry)
+	var max uint64
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var h Header
+			if json.Unmarshal(line, &h) == nil {
+				idx[h.Number] = diskEntry{offset: offset, length: len(line)}
+				max = maxUint(max, h.Number)
+			}
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	c.disk = file
+	c.diskPath = path
+	c.diskSize = offset
+	c.diskIdx = idx
+	c.diskMax = max
+	c.maybeCompactLocked()
+	return nil
+}
+
+// maybeCompactLocked viết lại file chỉ còn DiskRetention khối gần nhất khi file đã vượt ngưỡng
+func (c *Cache) maybeCompactLocked() {
+	if len(c.diskIdx) <= DiskRetention+DiskRetention/4 {
+		return
+	}
+	if err := c.compactLocked(); err != nil {
+		log.Printf("⚠️ Không thể thu gọn header cache của %s: %v", c.chain, err)
+	}
+}
+
+func (c *Cache) compactLocked() error {
+	var cutoff uint64
+	if c.diskMax > DiskRetention {
+		cutoff = c.diskMax - DiskRetention
+	}
+	numbers := make([]uint64, 0, DiskRetention)
+	for number := range c.diskIdx {
+		if number > cutoff {
+			numbers = append(numbers, number)
+		}
+	}
+	slices.Sort(numbers)
+
+	tmp := c.diskPath + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	idx := make(map[uint64]diskEntry, len(numbers))
+	var offset int64
+	for _, number := range numbers {
+		entry := c.diskIdx[number]
+		buf := make([]byte, entry.length)
+		if _, err := c.disk.ReadAt(buf, entry.offset); err != nil {
+			out.Close()
+			os.Remove(tmp)
+			return err
+		}
+		w.Write(buf)
+		idx[number] = diskEntry{offset: offset, length: entry.length}
+		offset += int64(entry.length)
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.diskPath); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(c.diskPath, os.O_RDWR, 0644)
+	if err != nil {
+		c.disk.Close()
+		c.disk = nil
+		return err
+	}
+	c.disk.Close()
+	c.disk = file
+	c.diskIdx = idx
+	c.diskSize = offset
+	return nil
+}
+
+func maxUint(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Put thêm header vào cache (pipeline gọi khi đã có sẵn dữ liệu khối)
+func (c *Cache) Put(h Header) {
+	h.Chain = c.chain
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.putLocked(h)
+	c.writeDiskLocked(h)
+}
+
+func (c *Cache) putLocked(h Header) {
+	if el, ok := c.items[h.Number]; ok {
+		el.Value = h
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[h.Number] = c.order.PushFront(h)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(Header).Number)
+	}
+}
+
+func (c *Cache) writeDiskLocked(h Header) {
+	if c.disk == nil {
+		return
+	}
+	if existing, ok := c.diskIdx[h.Number]; ok {
+		if stored, err := c.readDiskLocked(existing); err == nil && stored.Hash == h.Hash {
+			return
+		}
+	}
+
+	line, err := json.Marshal(h)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	if _, err := c.disk.WriteAt(line, c.diskSize); err != nil {
+		log.Printf("⚠️ Không thể ghi header %d của %s xuống đĩa: %v", h.Number, c.chain, err)
+		return
+	}
+	c.diskIdx[h.Number] = diskEntry{offset: c.diskSize, length: len(line)}
+	c.diskSize += int64(len(line))
+	c.diskMax = maxUint(c.diskMax, h.Number)
+	c.maybeCompactLocked()
+}
+
+func (c *Cache) readDiskLocked(entry diskEntry) (Header, error) {
+	buf := make([]byte, entry.length)
+	if _, err := c.disk.ReadAt(buf, entry.offset); err != nil {
+		return Header{}, err
+	}
+	var h Header
+	err := json.Unmarshal(buf, &h)
+	return h, err
+}
+
+// Lookup chỉ tra trong bộ nhớ và trên đĩa, không gọi RPC
+func (c *Cache) Lookup(number uint64) (Header, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[number]; ok {
+		c.order.MoveToFront(el)
+		c.hits.Add(1)
+		return el.Value.(Header), true
+	}
+
+	if entry, ok := c.diskIdx[number]; ok && c.disk != nil {
+		if h, err := c.readDiskLocked(entry); err == nil {
+			c.putLocked(h)
+			c.diskHits.Add(1)
+			return h, true
+		}
+	}
+
+	return Header{}, false
+}
+
+// Get trả về header từ cache; nếu không có thì gọi fetch đúng một lần
+// cho mọi goroutine đang chờ cùng một khối.
+func (c *Cache) Get(ctx context.Context, number uint64, fetch Fetcher) (Header, error) {
+	if h, ok := c.Lookup(number); ok {
+		return h, nil
+	}
+	if fetch == nil {
+		return Header{}, fmt.Errorf("header %d của %s không có trong cache", number, c.chain)
+	}
+
+	c.mu.Lock()
+	if call, ok := c.pending[number]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.header, call.err
+		case <-ctx.Done():
+			return Header{}, ctx.Err()
+		}
+	}
+	call := &inflight{done: make(chan struct{})}
+	c.pending[number] = call
+	c.mu.Unlock()
+
+	c.misses.Add(1)
+	call.header, call.err = fetch(ctx, number)
+
+	c.mu.Lock()
+	delete(c.pending, number)
+	if call.err == nil {
+		call.header.Chain = c.chain
+		c.putLocked(call.header)
+		c.writeDiskLocked(call.header)
+	} else {
+		c.fetchErrs.Add(1)
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.header, call.err
+}
+
+// Timestamp là tiện ích cho các module chỉ cần thời gian của khối
+func (c *Cache) Timestamp(ctx context.Context, number uint64, fetch Fetcher) (time.Time, error) {
+	h, err := c.Get(ctx, number, fetch)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return h.Timestamp(), nil
+}
+
+// Stats trả về số liệu hiện tại của cache
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Size:      size,
+		Hits:      c.hits.Load(),
+		DiskHits:  c.diskHits.Load(),
+		Misses:    c.misses.Load(),
+		FetchErrs: c.fetchErrs.Load(),
+	}
+}
+
+// Close đóng file trên đĩa
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.disk == nil {
+		return nil
+	}
+	err := c.disk.Close()
+	c.disk = nil
+	return err
+}
+
+// EthFetcher lấy header qua HeaderByNumber (không tải cả khối như BlockByNumber)
+func EthFetcher(client *ethclient.Client) Fetcher {
+	return func(ctx context.Context, number uint64) (Header, error) {
+		header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+		if err != nil {
+			return Header{}, err
+		}
+
+		h := Header{
+			Number:     number,
+			Hash:       header.Hash().Hex(),
+			ParentHash: header.ParentHash.Hex(),
+			Time:       header.Time,
+		}
+		if header.BaseFee != nil {
+			h.BaseFee = header.BaseFee.String()
+		}
+		return h, nil
+	}
+}
+
+// FromBlockMap dựng header từ kết quả eth_getBlockByNumber dạng map (bộ quét HTTP)
+func FromBlockMap(block map[string]interface{}) (Header, bool) {
+	number, ok := hexToUint64(block["number"])
+	if !ok {
+		return Header{}, false
+	}
+
+	h := Header{Number: number}
+	h.Hash, _ = block["hash"].(string)
+	h.ParentHash, _ = block["parentHash"].(string)
+	h.Time, _ = hexToUint64(block["timestamp"])
+
+	if baseFeeHex, ok := block["baseFeePerGas"].(string); ok && len(baseFeeHex) > 2 {
+		if baseFee, ok := new(big.Int).SetString(baseFeeHex[2:], 16); ok {
+			h.BaseFee = baseFee.String()
+		}
+	}
+	return h, true
+}
+
+func hexToUint64(value interface{}) (uint64, bool) {
+	hex, ok := value.(string)
+	if !ok || len(hex) <= 2 {
+		return 0, false
+	}
+	n, ok := new(big.Int).SetString(hex[2:], 16)
+	if !ok {
+		return 0, false
+	}
+	return n.Uint64(), true
+}
@@ -0,0 +1,289 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State là trạng thái của một thành phần (stream, chain, publisher, service)
+type State string
+
+const (
+	StateConnected State = "connected"
+	StateSyncing   State = "syncing"
+	StateCaughtUp  State = "caught_up"
+	StateStale     State = "stale"
+	StateFailed    State = "failed"
+)
+
+// Loại thành phần
+const (
+	KindStream    = "stream"
+	KindChain     = "chain"
+	KindPublisher = "publisher"
+	KindService   = "service"
+)
+
+// Số khối tụt lại tối đa vẫn được coi là đã bắt kịp
+const caughtUpLag = 5
+
+// Component là một dòng trong báo cáo /healthz, /readyz
+type Component struct {
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"`
+	State      State     `json:"state"`
+	LastEvent  time.Time `json:"last_event,omitempty"`
+	StaleAfter string    `json:"stale_after,omitempty"`
+	Head       uint64    `json:"head,omitempty"`
+	Processed  uint64    `json:"processed,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Tracker ghi nhận hoạt động của một thành phần
+type Tracker struct {
+	mu         sync.Mutex
+	name       string
+	kind       string
+	staleAfter time.Duration
+	state      State
+	lastEvent  time.Time
+	head       uint64
+	processed  uint64
+	err        string
+}
+
+// Source cung cấp thêm thành phần từ nơi khác (vd: trạng thái supervisor)
+type Source func() []Component
+
+var (
+	trackers     = make(map[string]*Tracker)
+	sources      []Source
+	trackersLock sync.Mutex
+)
+
+// DefaultStaleAfter là ngưỡng không có dữ liệu mặc định, đổi bằng HEALTH_STALE_AFTER (vd: "2m")
+func DefaultStaleAfter() time.Duration {
+	if v := os.Getenv("HEALTH_STALE_AFTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return 2 * time.Minute
+}
+
+// Register trả về tracker theo tên (tạo mới nếu chưa có).
+// staleAfter <= 0 nghĩa là thành phần không bao giờ bị coi là stale (vd: publisher chạy theo ngày).
+func Register(name, kind string, staleAfter time.Duration) *Tracker {
+	trackersLock.Lock()
+	defer trackersLock.Unlock()
+
+	if t, ok := trackers[name]; ok {
+		return t
+	}
+	t := &Tracker{name: name, kind: kind, staleAfter: staleAfter, state: StateConnected}
+	trackers[name] = t
+	return t
+}
+
+// Stream trả về tracker của một stream WebSocket với ngưỡng mặc định
+func Stream(name string) *Tracker {
+	return Register(KindStream+":"+name, KindStream, DefaultStaleAfter())
+}
+
+// Chain trả về tracker của một chain với ngưỡng mặc định
+func Chain(name string) *Tracker {
+	return Register(KindChain+":"+name, KindChain, DefaultStaleAfter())
+}
+
+// Publisher trả về tracker của một publisher (không có ngưỡng stale)
+func Publisher(name string) *Tracker {
+	return Register(KindPublisher+":"+name, KindPublisher, 0)
+}
+
+// AddSource thêm nguồn thành phần bên ngoài vào báo cáo
+func AddSource(source Source) {
+	trackersLock.Lock()
+	defer trackersLock.Unlock()
+	sources = append(sources, source)
+}
+
+// Event ghi nhận vừa nhận được dữ liệu
+func (t *Tracker) Event() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastEvent = time.Now()
+	t.err = ""
+	if t.state == StateFailed || t.state == StateStale {
+		t.state = StateConnected
+	}
+}
+
+// Progress ghi nhận đầu chuỗi và khối đã xử lý; trạng thái là syncing hoặc caught_up theo độ trễ
+func (t *Tracker) Progress(head, processed uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastEvent = time.Now()
+	t.err = ""
+	if processed > 0 {
+		t.processed = processed
+	}
+	t.updateHeadLocked(head)
+}
+
+// Head chỉ cập nhật đầu chuỗi (không tính là có dữ liệu mới)
+func (t *Tracker) Head(head uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.updateHeadLocked(head)
+}
+
+func (t *Tracker) updateHeadLocked(head uint64) {
+	if head > t.head {
+		t.head = head
+	}
+	if t.processed > t.head {
+		t.head = t.processed
+	}
+	if t.state == StateFailed && t.err != "" {
+		return
+	}
+	if t.processed > 0 && t.head > t.processed+caughtUpLag {
+		t.state = StateSyncing
+	} else if t.processed > 0 {
+		t.state = StateCaughtUp
+	}
+}
+
+// Done ghi nhận kết quả của một lần gửi (publisher)
+func (t *Tracker) Done(err error) {
+	if err != nil {
+		t.Fail(err)
+		return
+	}
+	t.Event()
+}
+
+// Fail đánh dấu thành phần lỗi
+func (t *Tracker) Fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.state = StateFailed
+	if err != nil {
+		t.err = err.Error()
+	}
+}
+
+func (t *Tracker) snapshot(now time.Time) Component {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := Component{
+		Name:      t.name,
+		Kind:      t.kind,
+		State:     t.state,
+		LastEvent: t.lastEvent,
+		Head:      t.head,
+		Processed: t.processed,
+		Error:     t.err,
+	}
+	if t.staleAfter > 0 {
+		c.StaleAfter = t.staleAfter.String()
+		if c.State != StateFailed && !t.lastEvent.IsZero() && now.Sub(t.lastEvent) > t.staleAfter {
+			c.State = StateStale
+		}
+	}
+	return c
+}
+
+// Snapshot trả về trạng thái mọi thành phần, sắp theo tên
+func Snapshot() []Component {
+	trackersLock.Lock()
+	list := make([]*Tracker, 0, len(trackers))
+	for _, t := range trackers {
+		list = append(list, t)
+	}
+	extra := append([]Source(nil), sources...)
+	trackersLock.Unlock()
+
+	now := time.Now()
+	components := make([]Component, 0, len(list))
+	for _, t := range list {
+		components = append(components, t.snapshot(now))
+	}
+	for _, source := range extra {
+		components = append(components, source()...)
+	}
+
+	sort.Slice(components, func(i, j int) bool { return components[i].Name < components[j].Name })
+	return components
+}
+
+// Report là phản hồi của /healthz và /readyz
+type Report struct {
+	Status     string      `json:"status"`
+	Components []Component `json:"components"`
+}
+
+// filter giữ các thành phần có tên bắt đầu bằng ?component= (có thể lặp lại)
+func filter(components []Component, r *http.Request) []Component {
+	prefixes := r.URL.Query()["component"]
+	if len(prefixes) == 0 {
+		return components
+	}
+
+	result := make([]Component, 0, len(components))
+	for _, c := range components {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(c.Name, prefix) {
+				result = append(result, c)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func write(w http.ResponseWriter, ok bool, components []Component) {
+	report := Report{Status: "ok", Components: components}
+	status := http.StatusOK
+	if !ok {
+		report.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// HealthzHandler: liveness, lỗi khi có thành phần failed
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	components := filter(Snapshot(), r)
+	ok := true
+	for _, c := range components {
+		if c.State == StateFailed {
+			ok = false
+		}
+	}
+	write(w, ok, components)
+}
+
+// ReadyzHandler: readiness, lỗi khi có thành phần failed hoặc stale
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	components := filter(Snapshot(), r)
+	ok := true
+	for _, c := range components {
+		if c.State == StateFailed || c.State == StateStale {
+			ok = false
+		}
+	}
+	write(w, ok, components)
+}
@@ -0,0 +1,30 @@
+package health
+
+import "main/services/supervisor"
+
+// SupervisorSource đưa trạng thái các service của supervisor vào báo cáo health
+func SupervisorSource() []Component {
+	var components []Component
+	for name, states := range supervisor.AllStates() {
+		for _, st := range states {
+			c := Component{
+				Name:      KindService + ":" + name + "/" + st.Name,
+				Kind:      KindService,
+				LastEvent: st.StartedAt,
+				Error:     st.LastError,
+			}
+			switch st.Status {
+			case supervisor.StatusRunning, supervisor.StatusCompleted:
+				c.State = StateConnected
+			case supervisor.StatusStarting, supervisor.StatusRestarting:
+				c.State = StateSyncing
+			case supervisor.StatusStopped:
+				c.State = StateStale
+			default:
+				c.State = StateFailed
+			}
+			components = append(components, c)
+		}
+	}
+	return components
+}
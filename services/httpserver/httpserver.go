@@ -0,0 +1,60 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultAddr là địa chỉ lắng nghe mặc định, có thể đổi bằng biến môi trường HTTP_ADDR
+const DefaultAddr = ":9100"
+
+var mux = http.NewServeMux()
+
+// Handle đăng ký handler vào mux dùng chung (metrics, health, API, ...)
+func Handle(pattern string, handler http.Handler) {
+	mux.Handle(pattern, handler)
+}
+
+// HandleFunc đăng ký hàm xử lý vào mux dùng chung
+func HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	mux.HandleFunc(pattern, handler)
+}
+
+// Addr trả về địa chỉ lắng nghe đã cấu hình
+func Addr() string {
+	if addr := os.Getenv("HTTP_ADDR"); addr != "" {
+		return addr
+	}
+	return DefaultAddr
+}
+
+// Serve chạy HTTP server cho tới khi ctx bị hủy rồi tắt nhẹ nhàng
+func Serve(ctx context.Context, addr string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("🌐 HTTP server lắng nghe tại %s", addr)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
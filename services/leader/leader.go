@@ -0,0 +1,177 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"main/services/filelock"
+	"main/services/metrics"
+)
+
+// DefaultLeaseFile là file lease dùng chung giữa các replica, đổi bằng LEADER_LEASE_FILE.
+// Các replica phải thấy cùng một file (cùng máy hoặc volume chung có hỗ trợ flock).
+const DefaultLeaseFile = "./data/leader.lease"
+
+// DefaultTTL là thời hạn lease, đổi bằng LEADER_LEASE_TTL (vd: "10s"). Leader gia hạn
+// mỗi TTL/3; replica khác chỉ lên thay khi lease hết hạn hoặc leader trả lease khi tắt.
+const DefaultTTL = 15 * time.Second
+
+type lease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+var (
+	leader atomic.Bool
+
+	idOnce sync.Once
+	id     string
+)
+
+// ID là định danh của replica trong lease: LEADER_ID hoặc <hostname>-<pid>
+func ID() string {
+	idOnce.Do(func() {
+		id = os.Getenv("LEADER_ID")
+		if id == "" {
+			host, _ := os.Hostname()
+			id = fmt.Sprintf("%s-%d", host, os.Getpid())
+		}
+	})
+	return id
+}
+
+// IsLeader cho biết replica này đang giữ lease
+func IsLeader() bool {
+	return leader.Load()
+}
+
+// Allow trả về true nếu replica được phép làm việc chỉ leader mới làm (gửi giao dịch lên
+// contract, cảnh báo). Lần bị bỏ qua được đếm theo what.
+func Allow(what string) bool {
+	if leader.Load() {
+		return true
+	}
+	metrics.LeaderSkipped.WithLabelValues(what).Inc()
+	return false
+}
+
+func leaseConfig() (string, time.Duration) {
+	path := os.Getenv("LEADER_LEASE_FILE")
+	if path == "" {
+		path = DefaultLeaseFile
+	}
+	ttl := DefaultTTL
+	if v := os.Getenv("LEADER_LEASE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			ttl = d
+		}
+	}
+	return path, ttl
+}
+
+// Run tranh và gia hạn lease cho tới khi ctx bị hủy; khi dừng, lease được trả ngay để
+// replica khác lên thay mà không phải chờ hết hạn
+func Run(ctx context.Context) error {
+	path, ttl := leaseConfig()
+	log.Printf("🗳️ [LEADER] %s tranh lease tại %s (ttl %s)", ID(), path, ttl)
+
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		held, err := acquire(path, ID(), ttl)
+		if err != nil {
+			// Không gia hạn được thì thôi làm leader, tránh hai replica cùng gửi
+			log.Printf("⚠️ [LEADER] Không cập nhật được lease: %v", err)
+		}
+		setLeader(held)
+
+		select {
+		case <-ctx.Done():
+			if leader.Load() {
+				if err := release(path, ID()); err != nil {
+					log.Printf("⚠️ [LEADER] Không trả được lease: %v", err)
+				}
+				setLeader(false)
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func setLeader(held bool) {
+	if leader.Swap(held) == held {
+		return
+	}
+	metrics.LeaderTransitions.Inc()
+	if held {
+		metrics.IsLeader.Set(1)
+		log.Printf("👑 [LEADER] %s trở thành leader", ID())
+	} else {
+		metrics.IsLeader.Set(0)
+		log.Printf("⏸️ [LEADER] %s không còn là leader", ID())
+	}
+}
+
+// acquire lấy hoặc gia hạn lease cho holder nếu lease đang trống, đã hết hạn hoặc đang là
+// của holder
+func acquire(path, holder string, ttl time.Duration) (bool, error) {
+	held := false
+	err := withLease(path, func(current lease) (*lease, error) {
+		if current.Holder != holder && time.Now().Before(current.Expires) {
+			return nil, nil
+		}
+		held = true
+		return &lease{Holder: holder, Expires: time.Now().Add(ttl)}, nil
+	})
+	return held && err == nil, err
+}
+
+func release(path, holder string) error {
+	return withLease(path, func(current lease) (*lease, error) {
+		if current.Holder != holder {
+			return nil, nil
+		}
+		return &lease{Holder: holder}, nil
+	})
+}
+
+// withLease đọc lease dưới khóa file rồi ghi lại lease mà update trả về (nil: giữ nguyên)
+func withLease(path string, update func(lease) (*lease, error)) error {
+	f, unlock, err := filelock.Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var current lease
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		if err := json.NewDecoder(f).Decode(&current); err != nil {
+			// File lease hỏng thì coi như trống
+			current = lease{}
+		}
+	}
+
+	next, err := update(current)
+	if err != nil || next == nil {
+		return err
+	}
+	data, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(data, 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,65 @@
+package leader
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLeaseConfigFromEnv(t *testing.T) {
+	t.Setenv("LEADER_LEASE_FILE", "/tmp/x.lease")
+	t.Setenv("LEADER_LEASE_TTL", "3s")
+	path, ttl := leaseConfig()
+	if path != "/tmp/x.lease" || ttl != 3*time.Second {
+		t.Fatalf("leaseConfig = %s, %s", path, ttl)
+	}
+
+	// TTL sai định dạng thì dùng mặc định
+	t.Setenv("LEADER_LEASE_TTL", "abc")
+	if _, ttl := leaseConfig(); ttl != DefaultTTL {
+		t.Fatalf("ttl = %s, muốn %s", ttl, DefaultTTL)
+	}
+}
+
+func TestIDFromEnv(t *testing.T) {
+	t.Setenv("LEADER_ID", "replica-a")
+	if got := ID(); got != "replica-a" {
+		t.Fatalf("ID = %q", got)
+	}
+}
+
+func mustAcquire(t *testing.T, path, holder string, ttl time.Duration, want bool) {
+	t.Helper()
+	held, err := acquire(path, holder, ttl)
+	if err != nil {
+		t.Fatalf("acquire %s: %v", holder, err)
+	}
+	if held != want {
+		t.Fatalf("acquire %s = %v, muốn %v", holder, held, want)
+	}
+}
+
+func TestLeaseAcquireExpireRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.lease")
+	ttl := 200 * time.Millisecond
+
+	mustAcquire(t, path, "a", ttl, true)
+	// Lease còn hạn thì replica khác không lấy được, chủ lease gia hạn được
+	mustAcquire(t, path, "b", ttl, false)
+	mustAcquire(t, path, "a", ttl, true)
+
+	// a ngừng gia hạn: hết hạn thì b lên thay và a mất lease
+	time.Sleep(ttl + 50*time.Millisecond)
+	mustAcquire(t, path, "b", ttl, true)
+	mustAcquire(t, path, "a", ttl, false)
+
+	// Chỉ chủ lease trả được lease; trả xong thì a lấy ngay không phải chờ hết hạn
+	if err := release(path, "a"); err != nil {
+		t.Fatalf("release a: %v", err)
+	}
+	mustAcquire(t, path, "a", ttl, false)
+	if err := release(path, "b"); err != nil {
+		t.Fatalf("release b: %v", err)
+	}
+	mustAcquire(t, path, "a", ttl, true)
+}
@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Config cấu hình logging, đọc từ biến môi trường bằng ConfigFromEnv
+type Config struct {
+	Level      slog.Level
+	Format     string // "text" hoặc "json"
+	Dir        string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+var (
+	current = Config{Level: slog.LevelInfo, Format: "text", Dir: "./logs", MaxSizeMB: 100, MaxBackups: 5}
+	level   = new(slog.LevelVar)
+
+	files     = make(map[string]*RotatingFile)
+	filesLock sync.Mutex
+)
+
+// ConfigFromEnv đọc LOG_LEVEL, LOG_FORMAT, LOG_DIR, LOG_MAX_SIZE_MB, LOG_MAX_BACKUPS
+func ConfigFromEnv() Config {
+	cfg := current
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(v)); err == nil {
+			cfg.Level = l
+		}
+	}
+	if v := strings.ToLower(os.Getenv("LOG_FORMAT")); v == "json" || v == "text" {
+		cfg.Format = v
+	}
+	if v := os.Getenv("LOG_DIR"); v != "" {
+		cfg.Dir = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("LOG_MAX_SIZE_MB")); err == nil && v > 0 {
+		cfg.MaxSizeMB = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("LOG_MAX_BACKUPS")); err == nil && v >= 0 {
+		cfg.MaxBackups = v
+	}
+	return cfg
+}
+
+// Setup đặt slog mặc định ghi ra stdout và <Dir>/dsea.log (xoay vòng).
+// slog.SetDefault cũng chuyển các lệnh log.Printf cũ qua cùng handler.
+func Setup(cfg Config) error {
+	filesLock.Lock()
+	current = cfg
+	filesLock.Unlock()
+	level.Set(cfg.Level)
+
+	file, err := File("dsea.log")
+	if err != nil {
+		return err
+	}
+
+	out := io.MultiWriter(os.Stdout, file)
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = slog.NewTextHandler(out, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// SetLevel đổi mức log khi đang chạy
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// Dir trả về thư mục chứa mọi file log
+func Dir() string {
+	filesLock.Lock()
+	defer filesLock.Unlock()
+	return current.Dir
+}
+
+// File trả về file log xoay vòng dùng chung theo tên, nằm trong thư mục log đã cấu hình
+func File(name string) (*RotatingFile, error) {
+	filesLock.Lock()
+	defer filesLock.Unlock()
+
+	if f, ok := files[name]; ok {
+		return f, nil
+	}
+	f, err := OpenRotating(filepath.Join(current.Dir, name), int64(current.MaxSizeMB)<<20, current.MaxBackups)
+	if err != nil {
+		return nil, err
+	}
+	files[name] = f
+	return f, nil
+}
+
+// Logger trả về *log.Logger ghi vào file riêng <Dir>/<name> cho các module cũ.
+// Nếu không mở được file thì ghi qua slog mặc định để không mất log.
+func Logger(name, prefix string) *log.Logger {
+	f, err := File(name)
+	if err != nil {
+		slog.Error("không thể mở file log", "file", name, "error", err)
+		return log.New(log.Writer(), prefix, log.LstdFlags)
+	}
+	return log.New(f, prefix, log.LstdFlags)
+}
+
+// Service trả về logger gắn trường service
+func Service(name string) *slog.Logger {
+	return slog.Default().With("service", name)
+}
+
+// Chain trả về logger gắn trường service và chain
+func Chain(service, chain string) *slog.Logger {
+	return slog.Default().With("service", service, "chain", chain)
+}
+
+// Close đóng mọi file log (shutdown hook)
+func Close() error {
+	filesLock.Lock()
+	defer filesLock.Unlock()
+
+	var firstErr error
+	for name, f := range files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(files, name)
+	}
+	return firstErr
+}
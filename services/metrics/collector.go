@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"main/services/pipeline"
+	"main/services/poller"
+)
+
+// runtimeCollector đọc số liệu sẵn có của pipeline và poller tại thời điểm scrape
+type runtimeCollector struct {
+	stageDepth     *prometheus.Desc
+	stageBlocked   *prometheus.Desc
+	stageErrors    *prometheus.Desc
+	processorCalls *prometheus.Desc
+	processorFails *prometheus.Desc
+	pollDelay      *prometheus.Desc
+	rateLimited    *prometheus.Desc
+}
+
+func newRuntimeCollector() *runtimeCollector {
+	return &runtimeCollector{
+		stageDepth: prometheus.NewDesc(namespace+"_pipeline_queue_depth",
+			"Số phần tử đang chờ trong hàng đợi của stage.", []string{"pipeline", "stage"}, nil),
+		stageBlocked: prometheus.NewDesc(namespace+"_pipeline_blocked_total",
+			"Số lần stage phía trước bị chặn vì hàng đợi đầy.", []string{"pipeline", "stage"}, nil),
+		stageErrors: prometheus.NewDesc(namespace+"_pipeline_errors_total",
+			"Số lỗi của stage.", []string{"pipeline", "stage"}, nil),
+		processorCalls: prometheus.NewDesc(namespace+"_processor_events_total",
+			"Số event đã giao cho processor.", []string{"processor"}, nil),
+		processorFails: prometheus.NewDesc(namespace+"_processor_failures_total",
+			"Số lần processor lỗi hoặc panic.", []string{"processor"}, nil),
+		pollDelay: prometheus.NewDesc(namespace+"_poll_next_delay_seconds",
+			"Khoảng chờ trước lần poll tiếp theo.", []string{"scheduler"}, nil),
+		rateLimited: prometheus.NewDesc(namespace+"_rate_limited_total",
+			"Số lần bị provider giới hạn tốc độ.", []string{"scheduler"}, nil),
+	}
+}
+
+func (c *runtimeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.stageDepth
+	ch <- c.stageBlocked
+	ch <- c.stageErrors
+	ch <- c.processorCalls
+	ch <- c.processorFails
+	ch <- c.pollDelay
+	ch <- c.rateLimited
+}
+
+func (c *runtimeCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, st := range pipeline.AllStageStats() {
+		ch <- prometheus.MustNewConstMetric(c.stageDepth, prometheus.GaugeValue, float64(st.Depth), st.Pipeline, st.Stage)
+		ch <- prometheus.MustNewConstMetric(c.stageBlocked, prometheus.CounterValue, float64(st.Blocked), st.Pipeline, st.Stage)
+		ch <- prometheus.MustNewConstMetric(c.stageErrors, prometheus.CounterValue, float64(st.Errors), st.Pipeline, st.Stage)
+	}
+	for name, st := range pipeline.Stats() {
+		ch <- prometheus.MustNewConstMetric(c.processorCalls, prometheus.CounterValue, float64(st.Events), name)
+		ch <- prometheus.MustNewConstMetric(c.processorFails, prometheus.CounterValue, float64(st.Errors), name)
+	}
+	for name, st := range poller.AllStats() {
+		ch <- prometheus.MustNewConstMetric(c.pollDelay, prometheus.GaugeValue, st.NextDelay.Seconds(), name)
+		ch <- prometheus.MustNewConstMetric(c.rateLimited, prometheus.CounterValue, float64(st.RateLimited), name)
+	}
+}
+
+func init() {
+	prometheus.MustRegister(newRuntimeCollector())
+}
@@ -0,0 +1,307 @@
+package metrics
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dsea"
+
+var (
+	// Độ cao khối theo chain
+	HeadHeight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chain_head_height",
+		Help:      "Khối mới nhất quan sát được trên chain.",
+	}, []string{"chain"})
+	ProcessedHeight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chain_processed_height",
+		Help:      "Khối cuối cùng đã xử lý xong.",
+	}, []string{"chain"})
+	Lag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chain_lag_blocks",
+		Help:      "Số khối đang tụt lại so với đầu chuỗi.",
+	}, []string{"chain"})
+
+	// Thông lượng; dùng rate() để ra số khối / giao dịch mỗi giây
+	BlocksProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocks_processed_total",
+		Help:      "Số khối đã xử lý.",
+	}, []string{"chain"})
+	TransfersProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_processed_total",
+		Help:      "Số giao dịch / event đã xử lý.",
+	}, []string{"chain"})
+
+	// RPC theo endpoint (chỉ host, không lộ API key trong đường dẫn)
+	RPCLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_request_duration_seconds",
+		Help:      "Thời gian gọi RPC.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"endpoint", "method"})
+	RPCErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_errors_total",
+		Help:      "Số lần gọi RPC lỗi.",
+	}, []string{"endpoint", "method"})
+
+	ReorgsDetected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reorgs_detected_total",
+		Help:      "Số lần phát hiện reorg.",
+	}, []string{"chain"})
+	DedupHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dedup_hits_total",
+		Help:      "Số log / giao dịch bị bỏ qua vì đã xử lý.",
+	}, []string{"chain"})
+
+	// Kết nối lại WebSocket: binance, okx, cosmos, vechain
+	WSReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_reconnects_total",
+		Help:      "Số lần kết nối lại WebSocket.",
+	}, []string{"stream"})
+
+	// Hàng đợi chờ gửi lên smart contract và kết quả gửi
+	OutboxDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outbox_depth",
+		Help:      "Số phần tử đang chờ gửi lên contract.",
+	}, []string{"contract"})
+	Publishes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "publish_total",
+		Help:      "Số giao dịch gửi lên contract theo kết quả (success / failure / duplicate).",
+	}, []string{"contract", "result"})
+
+	// WebSocket server đẩy dữ liệu cho client
+	WSClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ws_clients",
+		Help:      "Số client đang kết nối tới WebSocket server.",
+	})
+	WSMessagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_messages_sent_total",
+		Help:      "Số message đã đẩy cho client theo loại (event / candle).",
+	}, []string{"type"})
+	WSSlowConsumers = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_slow_consumer_disconnects_total",
+		Help:      "Số client bị ngắt vì không đọc kịp (đầy buffer).",
+	})
+
+	// gRPC StreamTransfers cho service nội bộ
+	GRPCStreams = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "grpc_streams",
+		Help:      "Số stream StreamTransfers đang mở.",
+	})
+	GRPCTransfersSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_transfers_sent_total",
+		Help:      "Số transfer đã gửi qua StreamTransfers (kể cả phát lại).",
+	})
+	GRPCSlowStreams = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_slow_stream_aborts_total",
+		Help:      "Số stream bị hủy vì client nhận không kịp.",
+	})
+
+	// Hot reload file cấu hình
+	ConfigReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "Số lần nạp lại file cấu hình theo kết quả (applied / rejected).",
+	}, []string{"file", "result"})
+
+	// Bầu leader giữa các replica
+	IsLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "1 nếu replica đang giữ lease leader.",
+	})
+	LeaderTransitions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leader_transitions_total",
+		Help:      "Số lần replica lên hoặc xuống leader.",
+	})
+	LeaderSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leader_skipped_total",
+		Help:      "Số lần bỏ qua việc chỉ leader mới làm (gửi contract, cảnh báo) vì không phải leader.",
+	}, []string{"what"})
+
+	// Chia chain giữa các worker
+	ShardChains = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "shard_owned_chains",
+		Help:      "Số chain worker này đang chạy.",
+	})
+	ShardWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "shard_live_workers",
+		Help:      "Số worker còn sống trong cụm theo heartbeat.",
+	})
+	ShardMoves = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "shard_moves_total",
+		Help:      "Số chain worker này đã nhả cho worker khác.",
+	})
+
+	// Sink message bus (NATS)
+	BusPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bus_published_total",
+		Help:      "Số bản ghi đã gửi lên message bus theo loại.",
+	}, []string{"kind"})
+	BusErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bus_publish_errors_total",
+		Help:      "Số bản ghi không gửi được sau khi đã thử lại.",
+	}, []string{"kind"})
+	BusDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bus_dropped_total",
+		Help:      "Số bản ghi bị bỏ vì hàng đợi gửi đầy.",
+	}, []string{"kind"})
+
+	// Kho lưu block thô
+	ArchiveBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "archive_blocks_total",
+		Help:      "Số block thô đã ghi vào kho lưu trữ.",
+	}, []string{"chain"})
+	ArchiveErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "archive_errors_total",
+		Help:      "Số lần ghi segment hoặc index của kho lưu trữ thất bại.",
+	}, []string{"chain"})
+
+	// Xuất Parquet/CSV
+	ExportRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_rows_total",
+		Help:      "Số dòng đã ghi ra file xuất theo dataset.",
+	}, []string{"dataset"})
+	ExportErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_errors_total",
+		Help:      "Số lần xuất dataset thất bại.",
+	}, []string{"dataset"})
+
+	// Ghi time series (InfluxDB, Prometheus remote-write)
+	TSDBPoints = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tsdb_points_total",
+		Help:      "Số điểm đã gửi tới cơ sở dữ liệu time series.",
+	}, []string{"backend"})
+	TSDBErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tsdb_errors_total",
+		Help:      "Số lô điểm gửi thất bại sau khi thử lại.",
+	}, []string{"backend"})
+	TSDBDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tsdb_dropped_total",
+		Help:      "Số điểm bị bỏ vì hàng đợi đầy.",
+	})
+)
+
+var (
+	heights     = make(map[string]*height)
+	heightsLock sync.Mutex
+)
+
+type height struct {
+	head      uint64
+	processed uint64
+}
+
+func heightFor(chain string) *height {
+	h, ok := heights[chain]
+	if !ok {
+		h = &height{}
+		heights[chain] = h
+	}
+	return h
+}
+
+// ObserveHead ghi nhận đầu chuỗi (chỉ tăng) và cập nhật lag
+func ObserveHead(chain string, head uint64) {
+	heightsLock.Lock()
+	defer heightsLock.Unlock()
+
+	h := heightFor(chain)
+	if head > h.head {
+		h.head = head
+	}
+	updateHeights(chain, h)
+}
+
+// ObserveProcessed ghi nhận khối đã xử lý xong và cập nhật lag
+func ObserveProcessed(chain string, processed uint64) {
+	heightsLock.Lock()
+	defer heightsLock.Unlock()
+
+	h := heightFor(chain)
+	h.processed = processed
+	if processed > h.head {
+		h.head = processed
+	}
+	updateHeights(chain, h)
+}
+
+func updateHeights(chain string, h *height) {
+	HeadHeight.WithLabelValues(chain).Set(float64(h.head))
+	ProcessedHeight.WithLabelValues(chain).Set(float64(h.processed))
+	lag := uint64(0)
+	if h.processed > 0 && h.head > h.processed {
+		lag = h.head - h.processed
+	}
+	Lag.WithLabelValues(chain).Set(float64(lag))
+}
+
+// ObserveRPC ghi nhận thời gian và lỗi của một lần gọi RPC bắt đầu tại start
+func ObserveRPC(endpoint, method string, start time.Time, err error) {
+	endpoint = EndpointLabel(endpoint)
+	RPCLatency.WithLabelValues(endpoint, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		RPCErrors.WithLabelValues(endpoint, method).Inc()
+	}
+}
+
+// ObservePublish ghi nhận kết quả gửi dữ liệu lên contract
+func ObservePublish(contract string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	Publishes.WithLabelValues(contract, result).Inc()
+}
+
+// EndpointLabel chỉ giữ host của URL để nhãn ổn định và không chứa API key
+func EndpointLabel(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return endpoint
+	}
+	return u.Host
+}
+
+// Handler trả về handler cho endpoint /metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
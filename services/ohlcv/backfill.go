@@ -0,0 +1,218 @@
+package ohlcv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"main/services/candles"
+)
+
+const (
+	// initialCandles là số nến lấy khi chuỗi chưa có trong candle store
+	initialCandles = 500
+	// pageSize là số nến tối đa mỗi request /api/v3/klines
+	pageSize = 1000
+	// pageDelay giãn các request khi lật trang để không chạm giới hạn weight của Binance
+	pageDelay = 100 * time.Millisecond
+)
+
+// emptyGaps ghi nhớ khoảng trống sàn trả về rỗng (sàn bảo trì, chưa niêm yết) để không
+// hỏi lại ở mỗi lần kết nối lại; chỉ giữ trong bộ nhớ
+var (
+	emptyGaps     = make(map[string]bool)
+	emptyGapsLock sync.Mutex
+)
+
+// backfill đối chiếu candle store với thời điểm hiện tại cho từng stream rồi lấy từ REST
+// những nến còn thiếu: khoảng trống giữa các nến đã lưu, và từ nến cuối đã lưu tới nay
+// (nến cuối có thể đã lưu khi còn đang chạy và được chốt ở đây nếu nay đã đóng). Chuỗi chưa có gì thì lấy 500 nến gần nhất.
+func backfill(ctx context.Context, streams []string) {
+	for _, name := range streams {
+		if ctx.Err() != nil {
+			return
+		}
+		key := candleKey(name)
+		iv, ok := intervalOf(key.Interval)
+		if !ok {
+			continue
+		}
+
+		first, last, ok, err := candles.Default().Bounds(key)
+		if err != nil {
+			log.Printf("⚠️ Không đọc được candle store %s: %v", key, err)
+			continue
+		}
+		if !ok {
+			batch, err := fetchKlines(ctx, key.Symbol, key.Interval, 0, 0, initialCandles)
+			if err != nil {
+				log.Printf("Error fetching historical data for %s: %v", name, err)
+				continue
+			}
+			record(iv, name, batch, false)
+			continue
+		}
+
+		// Nến đã chốt trước lần khởi động này không cần chốt lại
+		closed, ok, err := candles.Default().LastClosed(key)
+		if err != nil {
+			log.Printf("⚠️ Không đọc được candle store %s: %v", key, err)
+			continue
+		}
+		if ok {
+			watermarkLock.Lock()
+			if watermark[name] < closed {
+				watermark[name] = closed
+			}
+			watermarkLock.Unlock()
+		}
+
+		now := time.Now().UnixMilli()
+		gaps, err := candles.Default().Gaps(key, first, last)
+		if err != nil {
+			log.Printf("⚠️ Không đọc được candle store %s: %v", key, err)
+			continue
+		}
+		gaps = append(gaps, candles.Range{From: last, To: now})
+		filled := 0
+		for _, gap := range gaps {
+			n, err := fillRange(ctx, iv, name, gap, true)
+			filled += n
+			if err != nil {
+				log.Printf("Error backfilling %s [%d, %d]: %v", name, gap.From, gap.To, err)
+				break
+			}
+		}
+		if len(gaps) > 1 {
+			log.Printf("🩹 %s: %d khoảng trống, đã lấy %d nến", name, len(gaps)-1, filled)
+		}
+	}
+}
+
+// fillRange lật trang /api/v3/klines với startTime/endTime cho tới hết khoảng
+func fillRange(ctx context.Context, iv interval, stream string, gap candles.Range, publish bool) (int, error) {
+	key := candleKey(stream)
+	gapID := fmt.Sprintf("%s:%d:%d", stream, gap.From, gap.To)
+	emptyGapsLock.Lock()
+	skip := emptyGaps[gapID]
+	emptyGapsLock.Unlock()
+	if skip {
+		return 0, nil
+	}
+
+	total := 0
+	for from := gap.From; from <= gap.To; {
+		batch, err := fetchKlines(ctx, key.Symbol, key.Interval, from, gap.To, pageSize)
+		if err != nil {
+			return total, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		record(iv, stream, batch, publish)
+		total += len(batch)
+		if len(batch) < pageSize {
+			break
+		}
+		from = batch[len(batch)-1].OpenTime + 1
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(pageDelay):
+		}
+	}
+	if total == 0 {
+		emptyGapsLock.Lock()
+		emptyGaps[gapID] = true
+		emptyGapsLock.Unlock()
+	}
+	return total, nil
+}
+
+// BackfillHistory lấy toàn bộ nến của symbol từ since (zero là từ lúc niêm yết) tới nay
+// vào candle store; nến chưa có được ghi ra sink. interval rỗng là mọi khung thời gian
+// đã cấu hình. Trả về số nến đã lấy.
+func BackfillHistory(ctx context.Context, symbol, intervalName string, since time.Time) (int, error) {
+	var selected []interval
+	for _, iv := range configuredIntervals() {
+		if intervalName == "" || iv.name == intervalName {
+			selected = append(selected, iv)
+		}
+	}
+	if len(selected) == 0 {
+		return 0, fmt.Errorf("khung thời gian chưa được cấu hình: %s", intervalName)
+	}
+
+	// startTime=1 thay vì bỏ trống: Binance trả về từ nến đầu tiên thay vì nến mới nhất
+	from := int64(1)
+	if !since.IsZero() {
+		from = since.UnixMilli()
+	}
+	total := 0
+	for _, iv := range selected {
+		stream := strings.ToLower(symbol) + "@kline_" + iv.name
+		n, err := fillRange(ctx, iv, stream, candles.Range{From: from, To: time.Now().UnixMilli()}, false)
+		total += n
+		log.Printf("📚 %s: đã nạp %d nến lịch sử", stream, n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// fetchKlines gọi /api/v3/klines; start và end bằng 0 thì bỏ tham số tương ứng
+func fetchKlines(ctx context.Context, symbol, interval string, start, end int64, limit int) ([]candles.Candle, error) {
+	url := fmt.Sprintf(BinanceAPIBaseURL, strings.ToUpper(symbol), interval, limit)
+	if start > 0 {
+		url += fmt.Sprintf("&startTime=%d", start)
+	}
+	if end > 0 {
+		url += fmt.Sprintf("&endTime=%d", end)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var rows [][]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	out := make([]candles.Candle, 0, len(rows))
+	for _, row := range rows {
+		// Bỏ dòng sai định dạng thay vì panic khi ép kiểu
+		if len(row) < 11 {
+			continue
+		}
+		var c candles.Candle
+		var trades json.Number
+		if json.Unmarshal(row[0], &c.OpenTime) != nil || json.Unmarshal(row[6], &c.CloseTime) != nil ||
+			json.Unmarshal(row[1], &c.Open) != nil || json.Unmarshal(row[2], &c.High) != nil ||
+			json.Unmarshal(row[3], &c.Low) != nil || json.Unmarshal(row[4], &c.Close) != nil ||
+			json.Unmarshal(row[5], &c.Volume) != nil || json.Unmarshal(row[7], &c.QuoteVolume) != nil ||
+			json.Unmarshal(row[8], &trades) != nil || json.Unmarshal(row[9], &c.TakerBuyBase) != nil ||
+			json.Unmarshal(row[10], &c.TakerBuyQuote) != nil {
+			continue
+		}
+		c.Trades, _ = strconv.ParseInt(trades.String(), 10, 64)
+		c.Closed = c.CloseTime < now
+		out = append(out, c)
+	}
+	return out, nil
+}
@@ -10,7 +10,7 @@ import (
 
 const (
 	BinanceWSBaseURL  = "wss://stream.binance.com:9443/ws"
-	BinanceAPIBaseURL = "https://api.binance.com/api/v3/klines?symbol=%s&interval=%s&limit=500"
+	BinanceAPIBaseURL = "https://api.binance.com/api/v3/klines?symbol=%s&interval=%s&limit=%d"
 )
 
 // Danh sách các coin
@@ -30,8 +30,6 @@ var coins = []string{
 // StringOrFloat là kiểu tùy chỉnh để xử lý cả string và float64
 type StringOrFloat string
 
-var timetemp = make(map[string]*big.Int) // Lưu thời gian cuối cùng cho từng symbol
-
 // Kline struct để lưu dữ liệu nến
 type Kline struct {
 	Symbol              string        `json:"s"`
@@ -46,6 +44,7 @@ type Kline struct {
 	NumberOfTrades      int           `json:"n"`
 	TakerBuyBaseVolume  StringOrFloat `json:"V"`
 	TakerBuyQuoteVolume StringOrFloat `json:"Q"`
+	Closed              bool          `json:"x"` // nến đã đóng
 }
 
 type ResponseOHLCV struct {
@@ -1,7 +0,0 @@
-package ohlcv
-
-func RunOHLCV() {
-	go WebSocketOHLCVDay()
-	go WebSocketOHLCVWeek()
-	go WebSocketOHLCVMonth()
-}
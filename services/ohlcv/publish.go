@@ -0,0 +1,165 @@
+package ohlcv
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"log"
+	ohlcvConfig "main/config/ohlcv"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"main/services/health"
+	"main/services/metrics"
+	"main/services/outbox"
+)
+
+// publisher gửi nến đã đóng của một khung thời gian lên contract qua hộp thư đi
+// (services/outbox): replica nào cũng xếp nến vào hộp thư, chỉ leader gửi, lần lượt để nonce
+// không trùng. Mỗi nến chỉ được gửi một lần theo khóa idempotency, kể cả qua các lần khởi
+// động lại hay khi leader đổi.
+type publisher struct {
+	cfg    *ohlcvConfig.Publisher
+	iv     interval
+	outbox *outbox.Outbox[ResponseOHLCV]
+}
+
+func newPublisher(cfg *ohlcvConfig.Publisher, iv interval) *publisher {
+	p := &publisher{cfg: cfg, iv: iv}
+	p.outbox = outbox.NewTx(cfg.Name, 0, p.send)
+	return p
+}
+
+// publishKey là khóa idempotency của nến; openTime là giá trị gốc của Binance (chưa cộng offset)
+func publishKey(symbol, interval string, openTime int64) string {
+	return fmt.Sprintf("%s/%s/%d", symbol, interval, openTime)
+}
+
+// enqueue xếp nến vào hộp thư; nến đã xếp hoặc đã gửi với cùng nội dung thì bỏ qua
+func (p *publisher) enqueue(key string, candle ResponseOHLCV) {
+	if err := p.outbox.Put(key, candle); err != nil {
+		log.Printf("❌ [%s] Không xếp được nến %s vào hộp thư: %v", p.cfg.Name, key, err)
+	}
+}
+
+// contractCandle cộng offset của khung thời gian vào giờ của nến, như dữ liệu contract đã
+// nhận trước đây; nến trong hộp thư giữ giờ gốc
+func contractCandle(iv interval, c ResponseOHLCV) ResponseOHLCV {
+	offset := new(big.Int).SetUint64(iv.offset)
+	c.OpenTime = new(big.Int).Add(c.OpenTime, offset)
+	c.CloseTime = new(big.Int).Add(c.CloseTime, offset)
+	return c
+}
+
+// send gửi một nến lên contract; outbox chỉ gọi trên leader
+func (p *publisher) send(candle ResponseOHLCV, prevTx string, pending func(txHash string) error) (err error) {
+	key := publishKey(candle.Symbol, p.iv.name, candle.OpenTime.Int64())
+	defer func() {
+		metrics.ObservePublish(p.cfg.Name, err)
+		health.Publisher(p.cfg.Name).Done(err)
+	}()
+
+	// Kết nối WebSocket tới node BSC Testnet
+	client, err := ethclient.Dial("wss://bsc-testnet-rpc.publicnode.com")
+	if err != nil {
+		return fmt.Errorf("error creating client: %w", err)
+	}
+	defer client.Close()
+
+	// Lần trước dừng giữa lúc gửi và lúc ghi "sent": giao dịch có thể đã lên mạng
+	if prevTx != "" {
+		_, _, err := client.TransactionByHash(context.Background(), common.HexToHash(prevTx))
+		if err == nil {
+			log.Printf("🔁 [%s] Nến %s đã được gửi trước đó (tx %s)", p.cfg.Name, key, prevTx)
+			return nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return fmt.Errorf("failed to check pending transaction %s: %w", prevTx, err)
+		}
+	}
+
+	// Địa chỉ hợp đồng mà bạn muốn lắng nghe sự kiện
+	contractAddr := common.HexToAddress(p.cfg.ContractAddress)
+
+	// Parse ABI
+	contractABI, err := abi.JSON(strings.NewReader(ohlcvConfig.ContractABI))
+	if err != nil {
+		return fmt.Errorf("error parsing ABI: %w", err)
+	}
+
+	// Private key của người gửi (dùng cho giao dịch)
+	privateKey, err := crypto.HexToECDSA(p.cfg.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %w", err)
+	}
+
+	// Địa chỉ từ private key
+	publicKey := privateKey.Public()
+	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("error casting public key to ECDSA")
+	}
+	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
+
+	// Lấy nonce của tài khoản người gửi
+	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %w", err)
+	}
+
+	// Lấy gasPrice hiện tại
+	gasPrice, err := client.SuggestGasPrice(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get gas price: %w", err)
+	}
+
+	// Dữ liệu được mã hóa cho hàm recordData
+	data, err := contractABI.Pack("recordData", contractCandle(p.iv, candle))
+	if err != nil {
+		return fmt.Errorf("failed to pack function call date: %w", err)
+	}
+
+	// Tạo giao dịch
+	gasLimit, err := client.EstimateGas(context.Background(), ethereum.CallMsg{
+		From: fromAddress,
+		To:   &contractAddr,
+		Data: data,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to estimate gas: %w", err)
+	}
+	gasLimit = gasLimit * 12 / 10 // Add 20% buffer
+	tx := types.NewTransaction(nonce, contractAddr, big.NewInt(0), gasLimit, gasPrice, data)
+
+	// Ký giao dịch bằng private key
+	chainID, err := client.NetworkID(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get chain ID: %w", err)
+	}
+
+	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	// Ghi hash trước khi gửi để lần sau biết phải kiểm tra thay vì gửi lại
+	if err := pending(signedTx.Hash().Hex()); err != nil {
+		return fmt.Errorf("không ghi được hộp thư: %w", err)
+	}
+
+	// Gửi giao dịch
+	err = client.SendTransaction(context.Background(), signedTx)
+	if err != nil {
+		return fmt.Errorf("failed to send transactionDate %s: %w", key, err)
+	}
+	return nil
+}
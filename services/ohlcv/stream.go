@@ -0,0 +1,408 @@
+package ohlcv
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	ohlcvConfig "main/config/ohlcv"
+	"main/services/candles"
+	"main/services/health"
+	"main/services/metrics"
+	"main/services/sink"
+)
+
+// BinanceCombinedURL nhận nhiều stream trên một kết nối: <url>a@kline_1d/b@kline_1d/...
+const BinanceCombinedURL = "wss://stream.binance.com:9443/stream?streams="
+
+// Binance cho tối đa 1024 stream mỗi kết nối; chia nhỏ hơn để URL không quá dài và một
+// kết nối rớt chỉ ảnh hưởng một phần symbol
+const maxStreamsPerConn = 200
+
+const (
+	// readTimeout: stream kline đẩy cập nhật vài giây một lần, im lâu hơn là kết nối treo
+	readTimeout = time.Minute
+	// symbolCheck là chu kỳ so danh sách symbol (admin API có thể thêm/bớt lúc chạy)
+	symbolCheck = 30 * time.Second
+	maxBackoff  = time.Minute
+)
+
+// interval là một khung nến được stream
+type interval struct {
+	name      string // tên trong stream Binance (vd: 4h, 1d)
+	kind      string // loại bản ghi trong sink
+	offset    uint64
+	publisher *publisher // nil nếu không gửi lên contract
+}
+
+// binanceIntervals là các khung thời gian Binance hỗ trợ cho kline
+var binanceIntervals = map[string]bool{
+	"1s": true, "1m": true, "3m": true, "5m": true, "15m": true, "30m": true,
+	"1h": true, "2h": true, "4h": true, "6h": true, "8h": true, "12h": true,
+	"1d": true, "3d": true, "1w": true, "1M": true,
+}
+
+var (
+	intervals     []interval
+	intervalsOnce sync.Once
+)
+
+// configuredIntervals dựng danh sách khung thời gian từ ohlcvConfig.Intervals một lần
+func configuredIntervals() []interval {
+	intervalsOnce.Do(func() {
+		for _, cfg := range ohlcvConfig.Intervals {
+			if !binanceIntervals[cfg.Name] {
+				log.Printf("⚠️ Bỏ khung thời gian OHLCV không hợp lệ: %q", cfg.Name)
+				continue
+			}
+			iv := interval{name: cfg.Name, kind: "ohlcv_" + cfg.Name, offset: cfg.Offset}
+			if cfg.Publisher != nil && cfg.Publisher.Enabled {
+				iv.publisher = newPublisher(cfg.Publisher, iv)
+			}
+			intervals = append(intervals, iv)
+		}
+	})
+	return intervals
+}
+
+// watermark giữ OpenTime (gốc của Binance) của nến đã đóng mới nhất đã ghi ra sink cho mỗi
+// stream ("btcusdt@kline_1d"). Nến chỉ được chốt (ghi ra sink, gửi lên contract) khi đã
+// đóng: cờ x của Binance, hoặc CloseTime đã qua với nến lấy từ REST.
+var (
+	watermark     = make(map[string]int64)
+	watermarkLock sync.Mutex
+)
+
+// live giữ bản cập nhật mới nhất của nến đang chạy cho mỗi stream, xem Live
+var (
+	live     = make(map[string]candles.Candle)
+	liveLock sync.RWMutex
+)
+
+// Stream nạp lịch sử từ REST rồi stream đồng thời mọi symbol × khung thời gian (theo
+// ohlcvConfig.Intervals) qua các kết nối combined stream. Khi danh sách symbol đổi, các kết nối được mở lại với danh
+// sách mới.
+func Stream(ctx context.Context) error {
+	for {
+		symbols := Symbols()
+		streams := make([]string, 0, len(symbols)*len(configuredIntervals()))
+		for _, iv := range configuredIntervals() {
+			for _, symbol := range symbols {
+				streams = append(streams, symbol+"@kline_"+iv.name)
+			}
+		}
+		backfill(ctx, streams)
+
+		runCtx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		for start := 0; start < len(streams); start += maxStreamsPerConn {
+			chunk := streams[start:min(start+maxStreamsPerConn, len(streams))]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				streamChunk(runCtx, chunk)
+			}()
+		}
+		log.Printf("📶 Stream %d nến Binance qua %d kết nối", len(streams), (len(streams)+maxStreamsPerConn-1)/maxStreamsPerConn)
+
+		changed := waitSymbolsChanged(ctx, symbols)
+		cancel()
+		wg.Wait()
+		if !changed {
+			return nil
+		}
+		log.Printf("🔄 Danh sách symbol OHLCV đã đổi, mở lại kết nối")
+	}
+}
+
+func waitSymbolsChanged(ctx context.Context, symbols []string) bool {
+	ticker := time.NewTicker(symbolCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			if !slices.Equal(symbols, Symbols()) {
+				return true
+			}
+		}
+	}
+}
+
+// streamChunk giữ một kết nối combined stream; rớt kết nối thì nạp bù nến bị lỡ từ REST
+// rồi kết nối và đăng ký lại
+func streamChunk(ctx context.Context, streams []string) {
+	url := BinanceCombinedURL + strings.Join(streams, "/")
+	missed := newMissedCloses()
+	go missed.run(ctx)
+
+	backoff := time.Second
+	for first := true; ctx.Err() == nil; first = false {
+		if !first {
+			metrics.WSReconnects.WithLabelValues("binance-ohlcv").Inc()
+			backfill(ctx, streams)
+		}
+		received, err := readStreams(ctx, url, streams, missed)
+		if ctx.Err() != nil {
+			return
+		}
+		// Kết nối đã chạy được thì lần rớt này tính lại từ đầu, không chờ theo lần lỗi trước
+		if received {
+			backoff = time.Second
+		}
+		for _, name := range streams {
+			health.Stream("binance:" + name).Fail(err)
+		}
+		log.Printf("⚠️ Kết nối combined stream (%d stream, %s...) lỗi: %v, thử lại sau %s", len(streams), streams[0], err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// readStreams đọc combined stream cho tới khi kết nối lỗi; received cho biết đã nhận được
+// message nào chưa
+func readStreams(ctx context.Context, url string, streams []string, missed *missedCloses) (received bool, err error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	// Đóng kết nối khi ctx bị hủy để ReadMessage trả về
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	log.Printf("🔌 Đã kết nối combined stream Binance (%d stream)", len(streams))
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return received, err
+		}
+		received = true
+		var msg struct {
+			Stream string    `json:"stream"`
+			Data   WSMessage `json:"data"`
+		}
+		if err := json.Unmarshal(message, &msg); err != nil {
+			log.Printf("Error unmarshalling combined stream message: %v", err)
+			continue
+		}
+		health.Stream("binance:" + msg.Stream).Event()
+
+		_, name, _ := strings.Cut(msg.Stream, "@kline_")
+		iv, ok := intervalOf(name)
+		if !ok {
+			continue
+		}
+		k := msg.Data.Kline
+		candle := candles.Candle{
+			OpenTime:      k.OpenTime,
+			CloseTime:     k.CloseTime,
+			Open:          string(k.Open),
+			High:          string(k.High),
+			Low:           string(k.Low),
+			Close:         string(k.Close),
+			Volume:        string(k.Volume),
+			QuoteVolume:   string(k.QuoteAssetVolume),
+			Trades:        int64(k.NumberOfTrades),
+			TakerBuyBase:  string(k.TakerBuyBaseVolume),
+			TakerBuyQuote: string(k.TakerBuyQuoteVolume),
+			Closed:        k.Closed,
+		}
+		prev, seen := setLive(msg.Stream, candle)
+		switch {
+		case k.Closed:
+			record(iv, msg.Stream, []candles.Candle{candle}, true)
+		case !seen || candle.OpenTime > prev.OpenTime:
+			// Nến mới mở: lưu vào store để biết nến đã có; cập nhật giữa kỳ chỉ giữ trong live
+			record(iv, msg.Stream, []candles.Candle{candle}, true)
+			if seen && !prev.Closed && !finalized(msg.Stream, prev.OpenTime) {
+				// Lỡ message đóng của nến trước: lấy bản chốt từ REST thay vì chốt bản giữa kỳ
+				missed.add(msg.Stream, prev.OpenTime)
+			}
+		}
+	}
+}
+
+// missedCloses gom nến bị lỡ message đóng của một kết nối cho một worker lấy lại từ REST.
+// Mỗi stream giữ tối đa một khoảng chờ (lỡ thêm thì gộp vào) nên hàng đợi không vượt quá
+// số stream của kết nối và cùng một nến không bị lấy nhiều lần song song.
+type missedCloses struct {
+	mu      sync.Mutex
+	pending map[string]candles.Range
+	order   []string
+	wake    chan struct{}
+}
+
+func newMissedCloses() *missedCloses {
+	return &missedCloses{pending: make(map[string]candles.Range), wake: make(chan struct{}, 1)}
+}
+
+func (m *missedCloses) add(stream string, openTime int64) {
+	m.mu.Lock()
+	if r, ok := m.pending[stream]; ok {
+		m.pending[stream] = candles.Range{From: min(r.From, openTime), To: max(r.To, openTime)}
+	} else {
+		m.pending[stream] = candles.Range{From: openTime, To: openTime}
+		m.order = append(m.order, stream)
+	}
+	m.mu.Unlock()
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *missedCloses) next() (string, candles.Range, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.order) == 0 {
+		return "", candles.Range{}, false
+	}
+	stream := m.order[0]
+	m.order = m.order[1:]
+	r := m.pending[stream]
+	delete(m.pending, stream)
+	return stream, r, true
+}
+
+// run lấy lần lượt các nến bị lỡ cho tới khi ctx bị hủy
+func (m *missedCloses) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.wake:
+		}
+		for ctx.Err() == nil {
+			stream, r, ok := m.next()
+			if !ok {
+				break
+			}
+			iv, ok := intervalOf(candleKey(stream).Interval)
+			if !ok {
+				continue
+			}
+			if _, err := fillRange(ctx, iv, stream, r, true); err != nil && ctx.Err() == nil {
+				log.Printf("⚠️ Không lấy lại được nến bị lỡ %s [%d, %d]: %v", stream, r.From, r.To, err)
+			}
+		}
+	}
+}
+
+// setLive ghi nến mới nhất của stream, trả về bản trước đó
+func setLive(stream string, c candles.Candle) (candles.Candle, bool) {
+	liveLock.Lock()
+	defer liveLock.Unlock()
+	prev, ok := live[stream]
+	if !ok || c.OpenTime >= prev.OpenTime {
+		live[stream] = c
+	}
+	return prev, ok
+}
+
+func finalized(stream string, openTime int64) bool {
+	watermarkLock.Lock()
+	defer watermarkLock.Unlock()
+	return openTime <= watermark[stream]
+}
+
+// Live trả về nến đang chạy (chưa đóng) của symbol theo khung thời gian, cho dashboard.
+// Các nến này thay đổi tới lúc đóng nên không được ghi ra sink hay gửi lên contract.
+func Live(symbol string) map[string]ResponseOHLCV {
+	symbol = strings.ToLower(symbol)
+	out := make(map[string]ResponseOHLCV)
+	liveLock.RLock()
+	defer liveLock.RUnlock()
+	for _, iv := range configuredIntervals() {
+		c, ok := live[symbol+"@kline_"+iv.name]
+		if !ok || c.Closed {
+			continue
+		}
+		out[iv.name] = toResponse(strings.ToUpper(symbol), c)
+	}
+	return out
+}
+
+func intervalOf(name string) (interval, bool) {
+	for _, iv := range configuredIntervals() {
+		if iv.name == name {
+			return iv, true
+		}
+	}
+	return interval{}, false
+}
+
+// candleKey là khóa trong candle store của stream "btcusdt@kline_1d"
+func candleKey(stream string) candles.Key {
+	symbol, name, _ := strings.Cut(stream, "@kline_")
+	return candles.Key{Exchange: "binance", Symbol: strings.ToUpper(symbol), Interval: name}
+}
+
+// record lưu nến vào candle store rồi chốt những nến đã đóng chưa chốt: sau watermark của
+// stream, hoặc lấp khoảng trống trong store. Nến đang chạy chỉ được lưu. publish là false
+// với lịch sử nạp khi store còn rỗng để không gửi hàng trăm nến cũ lên contract.
+func record(iv interval, stream string, batch []candles.Candle, publish bool) {
+	key := candleKey(stream)
+	added, err := candles.Default().Put(key, batch)
+	if err != nil {
+		log.Printf("⚠️ Không lưu được nến %s: %v", key, err)
+	}
+	filled := make(map[int64]bool, len(added))
+	for _, c := range added {
+		filled[c.OpenTime] = true
+	}
+
+	for _, c := range batch {
+		if !c.Closed {
+			continue
+		}
+		watermarkLock.Lock()
+		newer := c.OpenTime > watermark[stream]
+		if newer {
+			watermark[stream] = c.OpenTime
+		}
+		watermarkLock.Unlock()
+		if !newer && !filled[c.OpenTime] {
+			continue
+		}
+
+		candle := toResponse(key.Symbol, c)
+		sink.Write(sink.Record{Kind: iv.kind, Chain: "binance", Data: candle})
+		if publish && iv.publisher != nil {
+			iv.publisher.enqueue(publishKey(key.Symbol, key.Interval, c.OpenTime), candle)
+		}
+	}
+}
+
+// toResponse đổi nến sang ResponseOHLCV với giờ gốc của Binance; offset của khung thời gian
+// chỉ được cộng khi dựng dữ liệu gửi lên contract (xem contractCandle)
+func toResponse(symbol string, c candles.Candle) ResponseOHLCV {
+	return ResponseOHLCV{
+		Symbol:           symbol,
+		OpenTime:         new(big.Int).SetUint64(uint64(c.OpenTime)),
+		Open:             strToBigInt(c.Open),
+		High:             strToBigInt(c.High),
+		Low:              strToBigInt(c.Low),
+		Close:            strToBigInt(c.Close),
+		Volume:           c.Volume,
+		CloseTime:        new(big.Int).SetUint64(uint64(c.CloseTime)),
+		QuoteAssetVolume: c.QuoteVolume,
+		NumberOfTrades:   new(big.Int).SetUint64(uint64(c.Trades)),
+		TakerBuyBaseVol:  c.TakerBuyBase,
+		TakerBuyQuoteVol: c.TakerBuyQuote,
+	}
+}
@@ -0,0 +1,61 @@
+package ohlcv
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// coinsMutex bảo vệ coins vì danh sách symbol có thể đổi lúc chạy
+var coinsMutex sync.RWMutex
+
+// Symbols trả về bản sao danh sách symbol đang theo dõi (chữ thường, vd: btcusdt)
+func Symbols() []string {
+	coinsMutex.RLock()
+	defer coinsMutex.RUnlock()
+	return append([]string(nil), coins...)
+}
+
+// SetSymbols thay danh sách symbol; các luồng OHLCV dùng danh sách mới khi kết nối lại
+func SetSymbols(symbols []string) {
+	coinsMutex.Lock()
+	defer coinsMutex.Unlock()
+
+	coins = coins[:0]
+	for _, symbol := range symbols {
+		coins = append(coins, strings.ToLower(symbol))
+	}
+}
+
+// AddSymbol thêm symbol (vd: BTCUSDT); lỗi nếu đã có
+func AddSymbol(symbol string) error {
+	symbol = strings.ToLower(strings.TrimSpace(symbol))
+	if symbol == "" {
+		return fmt.Errorf("symbol rỗng")
+	}
+
+	coinsMutex.Lock()
+	defer coinsMutex.Unlock()
+	for _, coin := range coins {
+		if coin == symbol {
+			return fmt.Errorf("symbol %s đã được theo dõi", symbol)
+		}
+	}
+	coins = append(coins, symbol)
+	return nil
+}
+
+// RemoveSymbol gỡ symbol khỏi danh sách; lỗi nếu không có
+func RemoveSymbol(symbol string) error {
+	symbol = strings.ToLower(strings.TrimSpace(symbol))
+
+	coinsMutex.Lock()
+	defer coinsMutex.Unlock()
+	for i, coin := range coins {
+		if coin == symbol {
+			coins = append(coins[:i], coins[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("symbol %s chưa được theo dõi", symbol)
+}
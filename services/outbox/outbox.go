@@ -0,0 +1,391 @@
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"main/services/filelock"
+	"main/services/leader"
+	"main/services/metrics"
+)
+
+// DefaultDir chứa hộp thư đi của từng publisher (<tên>.jsonl), đổi bằng OUTBOX_DIR. Các
+// replica dùng chung thư mục: replica nào cũng ghi việc cần gửi, chỉ leader lấy ra gửi,
+// nên việc của follower không bị mất khi leader đổi.
+const DefaultDir = "./data/outbox"
+
+// DrainInterval là chu kỳ leader quét hộp thư; Put trên chính replica leader đánh thức sớm hơn
+const DrainInterval = 5 * time.Second
+
+// maxBackoff là thời gian chờ lâu nhất giữa các lần gửi lại sau lỗi
+const maxBackoff = 5 * time.Minute
+
+// Trạng thái của một việc trong hộp thư
+const (
+	statusQueued = "queued"
+	// statusPending: giao dịch đã ký và sắp phát (xem NewTx); lần gửi sau phải kiểm tra
+	// TxHash đã lên mạng chưa trước khi gửi lại
+	statusPending = "pending"
+	statusSent    = "sent"
+)
+
+// entry là một dòng trong file; dòng sau ghi đè dòng trước cùng key
+type entry struct {
+	Key     string          `json:"key"`
+	Status  string          `json:"status"`
+	Payload json.RawMessage `json:"payload"`
+	TxHash  string          `json:"tx_hash,omitempty"`
+
+	seq int // thứ tự lần xếp hàng gần nhất, để gửi theo đúng thứ tự
+}
+
+// drainer là phần không phụ thuộc kiểu payload mà Run cần
+type drainer interface {
+	drain(ctx context.Context)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []drainer
+
+	wake = make(chan struct{}, 1)
+)
+
+// Dir trả về thư mục đã cấu hình
+func Dir() string {
+	if dir := os.Getenv("OUTBOX_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultDir
+}
+
+// TxSender gửi một việc bằng giao dịch on-chain. prevTx là hash của lần gửi trước dừng
+// giữa lúc phát và lúc ghi "sent" (rỗng nếu không có): giao dịch đó có thể đã lên mạng nên
+// phải kiểm tra trước khi gửi lại. pending ghi hash của giao dịch đã ký xuống hộp thư, gọi
+// trước khi phát.
+type TxSender[T any] func(v T, prevTx string, pending func(txHash string) error) error
+
+// Outbox là hộp thư đi của một publisher. Mỗi việc có một key idempotency; Put cùng key
+// với payload khác (vd: dòng tiền trong ngày được cộng thêm) xếp hàng gửi lại giá trị mới.
+type Outbox[T any] struct {
+	name  string
+	path  string
+	send  TxSender[T]
+	pause time.Duration
+
+	mu      sync.Mutex
+	entries map[string]entry
+	lines   int
+	offset  int64
+	file    os.FileInfo // file đã đọc; bị nén lại (thay file) thì đọc lại từ đầu
+	seq     int
+
+	backoff time.Duration
+	retryAt time.Time
+}
+
+// New tạo hộp thư name và đăng ký cho Run; send gửi một việc, pause là thời gian nghỉ
+// giữa hai lần gửi liên tiếp
+func New[T any](name string, pause time.Duration, send func(T) error) *Outbox[T] {
+	return NewTx(name, pause, func(v T, _ string, _ func(string) error) error {
+		return send(v)
+	})
+}
+
+// NewTx như New cho publisher gửi giao dịch: hộp thư nhớ tx hash của lần gửi dở để không
+// gửi trùng khi tiến trình dừng giữa chừng hoặc leader đổi
+func NewTx[T any](name string, pause time.Duration, send TxSender[T]) *Outbox[T] {
+	o := &Outbox[T]{
+		name:    name,
+		path:    filepath.Join(Dir(), name+".jsonl"),
+		send:    send,
+		pause:   pause,
+		entries: make(map[string]entry),
+	}
+	registryMu.Lock()
+	registry = append(registry, o)
+	registryMu.Unlock()
+	return o
+}
+
+// Put xếp việc vào hàng trên mọi replica; gọi lại với cùng key và payload thì không làm gì
+func (o *Outbox[T]) Put(key string, v T) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, unlock, err := filelock.Lock(o.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := o.refresh(); err != nil {
+		return err
+	}
+	if e, ok := o.entries[key]; ok && bytes.Equal(e.Payload, payload) {
+		return nil
+	}
+	if err := o.append(entry{Key: key, Status: statusQueued, Payload: payload}); err != nil {
+		return err
+	}
+	o.setDepth()
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// queued trả về các việc chờ gửi theo thứ tự xếp hàng
+func (o *Outbox[T]) queued() ([]entry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.refresh(); err != nil {
+		return nil, err
+	}
+	o.setDepth()
+	var out []entry
+	for _, e := range o.entries {
+		if e.Status != statusSent {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].seq < out[j].seq })
+	return out, nil
+}
+
+// drain gửi lần lượt các việc đang chờ; lỗi thì dừng lượt này và thử lại sau backoff
+func (o *Outbox[T]) drain(ctx context.Context) {
+	if time.Now().Before(o.retryAt) {
+		return
+	}
+	jobs, err := o.queued()
+	if err != nil {
+		log.Printf("❌ [outbox %s] Không đọc được hộp thư: %v", o.name, err)
+		return
+	}
+	for i, job := range jobs {
+		if ctx.Err() != nil || !leader.Allow(o.name) {
+			return
+		}
+		if i > 0 && o.pause > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(o.pause):
+			}
+		}
+		var v T
+		if err := json.Unmarshal(job.Payload, &v); err != nil {
+			log.Printf("⚠️ [outbox %s] Bỏ việc %s không đọc được: %v", o.name, job.Key, err)
+		} else if err := o.send(v, job.TxHash, func(txHash string) error {
+			return o.markPending(job, txHash)
+		}); err != nil {
+			o.backoff = min(max(2*o.backoff, DrainInterval), maxBackoff)
+			o.retryAt = time.Now().Add(o.backoff)
+			log.Printf("❌ [outbox %s] Gửi %s thất bại, thử lại sau %s: %v", o.name, job.Key, o.backoff, err)
+			return
+		}
+		o.backoff = 0
+		if err := o.markSent(job); err != nil {
+			log.Printf("❌ [outbox %s] Không ghi được trạng thái %s: %v", o.name, job.Key, err)
+			return
+		}
+	}
+}
+
+// markSent ghi việc đã gửi, trừ khi payload đã được thay trong lúc gửi (việc mới vẫn chờ)
+func (o *Outbox[T]) markSent(job entry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, unlock, err := filelock.Lock(o.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := o.refresh(); err != nil {
+		return err
+	}
+	e := o.entries[job.Key]
+	if e.Status == statusSent || !bytes.Equal(e.Payload, job.Payload) {
+		return nil
+	}
+	if err := o.append(entry{Key: job.Key, Status: statusSent, Payload: job.Payload, TxHash: e.TxHash}); err != nil {
+		return err
+	}
+	o.setDepth()
+	if o.lines > 2*len(o.entries)+1000 {
+		return o.compact()
+	}
+	return nil
+}
+
+// markPending ghi tx hash của việc đang gửi; payload đã được thay trong lúc gửi thì giữ
+// nguyên việc mới đang chờ
+func (o *Outbox[T]) markPending(job entry, txHash string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, unlock, err := filelock.Lock(o.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := o.refresh(); err != nil {
+		return err
+	}
+	if e := o.entries[job.Key]; e.Status == statusSent || !bytes.Equal(e.Payload, job.Payload) {
+		return nil
+	}
+	return o.append(entry{Key: job.Key, Status: statusPending, Payload: job.Payload, TxHash: txHash})
+}
+
+func (o *Outbox[T]) setDepth() {
+	n := 0
+	for _, e := range o.entries {
+		if e.Status != statusSent {
+			n++
+		}
+	}
+	metrics.OutboxDepth.WithLabelValues(o.name).Set(float64(n))
+}
+
+// append ghi nối một dòng và sync xuống đĩa; gọi khi đang giữ o.mu và file lock (Lock đã
+// tạo thư mục)
+func (o *Outbox[T]) append(e entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(o.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// Đọc lại qua refresh để offset và thứ tự khớp với dòng vừa ghi
+	return o.refresh()
+}
+
+// compact viết lại file chỉ giữ dòng mới nhất của mỗi key; gọi khi đang giữ file lock
+func (o *Outbox[T]) compact() error {
+	list := make([]entry, 0, len(o.entries))
+	for _, e := range o.entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].seq < list[j].seq })
+
+	var buf bytes.Buffer
+	for _, e := range list {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, o.path); err != nil {
+		return err
+	}
+	o.file = nil
+	return o.refresh()
+}
+
+// refresh đọc các dòng mới từ offset; gọi khi đang giữ o.mu
+func (o *Outbox[T]) refresh() error {
+	f, err := os.Open(o.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if o.file == nil || !os.SameFile(o.file, info) || info.Size() < o.offset {
+		o.entries = make(map[string]entry)
+		o.offset, o.lines = 0, 0
+	}
+	o.file = info
+	if _, err := f.Seek(o.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// Dòng chưa có '\n' là dòng đang ghi dở, để lần sau đọc lại
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		o.offset += int64(len(line))
+		o.lines++
+		var e entry
+		if json.Unmarshal(line, &e) != nil || e.Key == "" {
+			continue
+		}
+		e.seq = o.seq
+		// Chỉ lần xếp hàng mới đổi thứ tự; pending và sent giữ chỗ cũ
+		if old, ok := o.entries[e.Key]; ok && e.Status != statusQueued {
+			e.seq = old.seq
+		}
+		o.seq++
+		o.entries[e.Key] = e
+	}
+}
+
+// Run là service dùng chung: khi replica là leader thì gửi hết việc đang chờ của mọi hộp
+// thư, kể cả việc follower đã xếp trước khi leader đổi
+func Run(ctx context.Context) error {
+	ticker := time.NewTicker(DrainInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-wake:
+		}
+		if !leader.IsLeader() {
+			continue
+		}
+		registryMu.Lock()
+		list := append([]drainer(nil), registry...)
+		registryMu.Unlock()
+		for _, d := range list {
+			d.drain(ctx)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package outbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"main/services/leader"
+)
+
+// becomeLeader chạy leader.Run trên file lease tạm để drain được phép gửi
+func becomeLeader(t *testing.T) {
+	t.Helper()
+	if leader.IsLeader() {
+		return
+	}
+	t.Setenv("LEADER_LEASE_FILE", filepath.Join(t.TempDir(), "leader.lease"))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		leader.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	deadline := time.Now().Add(2 * time.Second)
+	for !leader.IsLeader() {
+		if time.Now().After(deadline) {
+			t.Fatal("không lấy được lease")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestOutbox(t *testing.T, send func(int) error) *Outbox[int] {
+	t.Helper()
+	t.Setenv("OUTBOX_DIR", t.TempDir())
+	return New[int]("test", 0, send)
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("đọc %s: %v", path, err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestPutSamePayloadIsNoop(t *testing.T) {
+	o := newTestOutbox(t, func(int) error { return nil })
+	for i := 0; i < 3; i++ {
+		if err := o.Put("day-1", 10); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	if n := countLines(t, o.path); n != 1 {
+		t.Fatalf("file có %d dòng, muốn 1", n)
+	}
+
+	// Payload khác với cùng key thay việc đang chờ, không thêm việc mới
+	if err := o.Put("day-1", 11); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	jobs, err := o.queued()
+	if err != nil {
+		t.Fatalf("queued: %v", err)
+	}
+	if len(jobs) != 1 || string(jobs[0].Payload) != "11" {
+		t.Fatalf("queued = %+v", jobs)
+	}
+}
+
+func TestDrainSendsInOrderAndMarksSent(t *testing.T) {
+	becomeLeader(t)
+	var sent []int
+	o := newTestOutbox(t, func(v int) error {
+		sent = append(sent, v)
+		return nil
+	})
+	for i, key := range []string{"a", "b", "c"} {
+		if err := o.Put(key, i); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	o.drain(context.Background())
+	if len(sent) != 3 || sent[0] != 0 || sent[1] != 1 || sent[2] != 2 {
+		t.Fatalf("sent = %v", sent)
+	}
+	o.drain(context.Background())
+	if len(sent) != 3 {
+		t.Fatalf("gửi lại việc đã gửi: %v", sent)
+	}
+
+	// Replica khác đọc cùng file thấy việc đã gửi, không còn gì chờ
+	other := New[int]("test", 0, func(int) error { return nil })
+	jobs, err := other.queued()
+	if err != nil {
+		t.Fatalf("queued: %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Fatalf("replica khác còn %d việc chờ", len(jobs))
+	}
+
+	// Đã gửi mà Put lại cùng payload thì không gửi lại
+	if err := o.Put("a", 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	o.drain(context.Background())
+	if len(sent) != 3 {
+		t.Fatalf("gửi lại payload cũ: %v", sent)
+	}
+}
+
+func TestPayloadReplacedWhileInFlight(t *testing.T) {
+	becomeLeader(t)
+	var sent []int
+	var o *Outbox[int]
+	o = newTestOutbox(t, func(v int) error {
+		sent = append(sent, v)
+		if v == 1 {
+			// Dòng tiền trong ngày được cộng thêm trong lúc bản cũ đang gửi
+			if err := o.Put("day-1", 2); err != nil {
+				t.Errorf("Put: %v", err)
+			}
+		}
+		return nil
+	})
+	if err := o.Put("day-1", 1); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	o.drain(context.Background())
+	// markSent bỏ qua vì payload đã đổi: bản mới vẫn chờ gửi
+	jobs, err := o.queued()
+	if err != nil {
+		t.Fatalf("queued: %v", err)
+	}
+	if len(jobs) != 1 || string(jobs[0].Payload) != "2" {
+		t.Fatalf("queued = %+v", jobs)
+	}
+
+	o.drain(context.Background())
+	if len(sent) != 2 || sent[1] != 2 {
+		t.Fatalf("sent = %v", sent)
+	}
+	if jobs, _ := o.queued(); len(jobs) != 0 {
+		t.Fatalf("còn %d việc chờ", len(jobs))
+	}
+}
+
+func TestDrainBacksOffAfterError(t *testing.T) {
+	becomeLeader(t)
+	calls := 0
+	o := newTestOutbox(t, func(int) error {
+		calls++
+		return os.ErrDeadlineExceeded
+	})
+	if err := o.Put("a", 1); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	o.drain(context.Background())
+	o.drain(context.Background())
+	if calls != 1 {
+		t.Fatalf("send được gọi %d lần trong backoff", calls)
+	}
+	if jobs, _ := o.queued(); len(jobs) != 1 {
+		t.Fatalf("việc lỗi phải còn chờ, có %d", len(jobs))
+	}
+}
+
+func TestPendingTxHashSurvivesFailedSend(t *testing.T) {
+	becomeLeader(t)
+	t.Setenv("OUTBOX_DIR", t.TempDir())
+	var prevs []string
+	fail := true
+	o := NewTx[int]("test-tx", 0, func(v int, prevTx string, pending func(string) error) error {
+		prevs = append(prevs, prevTx)
+		if err := pending("0xabc"); err != nil {
+			return err
+		}
+		if fail {
+			return os.ErrDeadlineExceeded
+		}
+		return nil
+	})
+	if err := o.Put("candle-1", 1); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	o.drain(context.Background())
+	// Replica khác (leader mới) đọc file thấy việc pending kèm tx hash
+	fail = false
+	other := NewTx[int]("test-tx", 0, o.send)
+	jobs, err := other.queued()
+	if err != nil {
+		t.Fatalf("queued: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].Status != statusPending || jobs[0].TxHash != "0xabc" {
+		t.Fatalf("queued = %+v", jobs)
+	}
+
+	other.drain(context.Background())
+	if len(prevs) != 2 || prevs[0] != "" || prevs[1] != "0xabc" {
+		t.Fatalf("prevTx = %q", prevs)
+	}
+	if jobs, _ := other.queued(); len(jobs) != 0 {
+		t.Fatalf("còn %d việc chờ", len(jobs))
+	}
+}
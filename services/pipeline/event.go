@@ -0,0 +1,41 @@
+package pipeline
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Event là dạng chuẩn (canonical) của một sự kiện on-chain sau khi trích xuất,
+// dùng chung cho mọi chain và mọi processor.
+type Event struct {
+	Chain           string                 `json:"chain"`
+	Rule            string                 `json:"rule,omitempty"`
+	BlockNumber     uint64                 `json:"block_number"`
+	BlockHash       string                 `json:"block_hash,omitempty"`
+	TxHash          string                 `json:"tx_hash"`
+	LogIndex        uint                   `json:"log_index"`
+	Contract        string                 `json:"contract"`
+	From            string                 `json:"from_address,omitempty"`
+	To              string                 `json:"to_address,omitempty"`
+	Amount          *big.Int               `json:"amount"`
+	EventSignature  string                 `json:"event_signature,omitempty"`
+	TransactionType string                 `json:"transaction_type,omitempty"`
+	Timestamp       time.Time              `json:"timestamp"`
+	Removed         bool                   `json:"removed,omitempty"`
+	Raw             map[string]interface{} `json:"-"`
+}
+
+// Key định danh duy nhất của event trong một chain (block-tx-logIndex)
+func (e Event) Key() string {
+	return fmt.Sprintf("%s-%d-%s-%d", e.Chain, e.BlockNumber, e.TxHash, e.LogIndex)
+}
+
+// Block đánh dấu ranh giới khối: được gửi sau khi mọi event của khối đã được dispatch.
+type Block struct {
+	Chain     string    `json:"chain"`
+	Number    uint64    `json:"number"`
+	Hash      string    `json:"hash,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	TxCount   int       `json:"tx_count"`
+}
@@ -0,0 +1,166 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Wildcard dùng khi đăng ký processor cho mọi chain hoặc mọi filter rule
+const Wildcard = "*"
+
+// Processor nhận các event chuẩn và ranh giới khối của chain đã đăng ký.
+// Lỗi hoặc panic của một processor không ảnh hưởng tới chain và các processor khác.
+type Processor interface {
+	Name() string
+	ProcessEvent(ev Event) error
+	ProcessBlock(b Block) error
+}
+
+// ProcessorStats là số liệu riêng của từng processor
+type ProcessorStats struct {
+	Events       uint64        `json:"events"`
+	Blocks       uint64        `json:"blocks"`
+	Errors       uint64        `json:"errors"` // gồm cả các lần panic
+	Panics       uint64        `json:"panics"`
+	LastError    string        `json:"last_error,omitempty"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastEventAt  time.Time     `json:"last_event_at"`
+}
+
+type registration struct {
+	chain     string
+	rule      string
+	processor Processor
+	stats     ProcessorStats
+}
+
+var (
+	registrations []*registration
+	registryLock  sync.RWMutex
+)
+
+// Register đăng ký processor cho một chain và một filter rule.
+// Dùng Wildcard (hoặc chuỗi rỗng) để nhận mọi chain / mọi rule.
+func Register(chain, rule string, p Processor) {
+	if chain == "" {
+		chain = Wildcard
+	}
+	if rule == "" {
+		rule = Wildcard
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	registrations = append(registrations, &registration{
+		chain:     chain,
+		rule:      rule,
+		processor: p,
+	})
+	log.Printf("🧩 Đã đăng ký processor %s (chain=%s, rule=%s)", p.Name(), chain, rule)
+}
+
+// Unregister gỡ mọi đăng ký của processor theo tên
+func Unregister(name string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	kept := registrations[:0]
+	for _, reg := range registrations {
+		if reg.processor.Name() != name {
+			kept = append(kept, reg)
+		}
+	}
+	registrations = kept
+}
+
+func (r *registration) matches(chain, rule string) bool {
+	if r.chain != Wildcard && r.chain != chain {
+		return false
+	}
+	if r.rule != Wildcard && r.rule != rule {
+		return false
+	}
+	return true
+}
+
+func matching(chain, rule string, blockLevel bool) []*registration {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	var result []*registration
+	for _, reg := range registrations {
+		// Ranh giới khối không gắn với rule nào nên chỉ lọc theo chain
+		if blockLevel && (reg.chain == Wildcard || reg.chain == chain) {
+			result = append(result, reg)
+			continue
+		}
+		if !blockLevel && reg.matches(chain, rule) {
+			result = append(result, reg)
+		}
+	}
+	return result
+}
+
+// Dispatch gửi event tới mọi processor khớp chain và rule
+func Dispatch(ev Event) {
+	for _, reg := range matching(ev.Chain, ev.Rule, false) {
+		reg.run(func() error { return reg.processor.ProcessEvent(ev) }, false)
+	}
+}
+
+// DispatchBlock gửi ranh giới khối tới mọi processor của chain
+func DispatchBlock(b Block) {
+	for _, reg := range matching(b.Chain, "", true) {
+		reg.run(func() error { return reg.processor.ProcessBlock(b) }, true)
+	}
+}
+
+func (r *registration) run(fn func() error, block bool) {
+	start := time.Now()
+
+	err := func() (err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				registryLock.Lock()
+				r.stats.Panics++
+				registryLock.Unlock()
+				err = fmt.Errorf("panic: %v", rec)
+				log.Printf("🔥 Processor %s bị panic: %v\n%s", r.processor.Name(), rec, debug.Stack())
+			}
+		}()
+		return fn()
+	}()
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	r.stats.LastDuration = time.Since(start)
+	if block {
+		r.stats.Blocks++
+	} else {
+		r.stats.Events++
+		r.stats.LastEventAt = time.Now()
+	}
+	if err != nil {
+		r.stats.Errors++
+		r.stats.LastError = err.Error()
+		log.Printf("⚠️ Processor %s lỗi: %v", r.processor.Name(), err)
+	}
+}
+
+// Stats trả về số liệu của từng processor, khóa theo "tên@chain/rule"
+func Stats() map[string]ProcessorStats {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	result := make(map[string]ProcessorStats, len(registrations))
+	for _, reg := range registrations {
+		key := fmt.Sprintf("%s@%s/%s", reg.processor.Name(), reg.chain, reg.rule)
+		result[key] = reg.stats
+	}
+	return result
+}
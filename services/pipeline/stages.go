@@ -0,0 +1,267 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StageFunc xử lý một phần tử và trả về phần tử cho stage kế tiếp.
+// Trả về nil để dừng phần tử tại stage này (vd: bị lọc hoặc trùng lặp).
+type StageFunc func(ctx context.Context, item interface{}) (interface{}, error)
+
+// StageConfig cấu hình một stage với hàng đợi có giới hạn và số worker riêng
+type StageConfig struct {
+	Name      string
+	Workers   int
+	QueueSize int
+	Fn        StageFunc
+}
+
+// StageStats là số liệu của một stage, dùng cho metrics queue depth
+type StageStats struct {
+	Pipeline  string `json:"pipeline"`
+	Stage     string `json:"stage"`
+	Depth     int    `json:"depth"`
+	Capacity  int    `json:"capacity"`
+	Processed uint64 `json:"processed"`
+	Errors    uint64 `json:"errors"`
+	Blocked   uint64 `json:"blocked"`
+}
+
+// envelope mang số thứ tự Submit của phần tử qua các stage, để Drain biết phần tử nào
+// có trước lời gọi
+type envelope struct {
+	seq  uint64
+	item interface{}
+}
+
+type stage struct {
+	config    StageConfig
+	queue     chan envelope
+	processed atomic.Uint64
+	errors    atomic.Uint64
+	blocked   atomic.Uint64
+}
+
+// Pipeline gồm các stage nối tiếp bằng hàng đợi có giới hạn.
+// Khi một stage chậm, hàng đợi phía trước đầy và Submit bị chặn,
+// nhờ vậy áp lực được đẩy ngược về fetcher thay vì làm rơi dữ liệu.
+type Pipeline struct {
+	name   string
+	stages []*stage
+
+	pendingLock sync.Mutex
+	pendingCond *sync.Cond
+	pending     map[uint64]struct{} // số thứ tự các phần tử chưa đi hết pipeline
+	seq         uint64
+
+	workers sync.WaitGroup
+}
+
+var (
+	pipelines     = make(map[string]*Pipeline)
+	pipelinesLock sync.RWMutex
+)
+
+// New tạo pipeline và đăng ký vào registry để thu thập số liệu
+func New(name string, stages ...StageConfig) *Pipeline {
+	p := &Pipeline{name: name, pending: make(map[uint64]struct{})}
+	p.pendingCond = sync.NewCond(&p.pendingLock)
+
+	for _, cfg := range stages {
+		if cfg.Workers <= 0 {
+			cfg.Workers = 1
+		}
+		if cfg.QueueSize <= 0 {
+			cfg.QueueSize = 1
+		}
+		p.stages = append(p.stages, &stage{
+			config: cfg,
+			queue:  make(chan envelope, cfg.QueueSize),
+		})
+	}
+
+	pipelinesLock.Lock()
+	pipelines[name] = p
+	pipelinesLock.Unlock()
+
+	return p
+}
+
+// Start khởi động worker cho từng stage; các worker dừng khi ctx kết thúc
+func (p *Pipeline) Start(ctx context.Context) {
+	for i, st := range p.stages {
+		var next *stage
+		if i+1 < len(p.stages) {
+			next = p.stages[i+1]
+		}
+		for w := 0; w < st.config.Workers; w++ {
+			p.workers.Add(1)
+			go p.work(ctx, st, next)
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		p.workers.Wait()
+
+		// Giải phóng các lệnh Drain đang chờ
+		p.pendingLock.Lock()
+		p.pending = make(map[uint64]struct{})
+		p.pendingCond.Broadcast()
+		p.pendingLock.Unlock()
+
+		pipelinesLock.Lock()
+		if pipelines[p.name] == p {
+			delete(pipelines, p.name)
+		}
+		pipelinesLock.Unlock()
+	}()
+}
+
+func (p *Pipeline) work(ctx context.Context, st *stage, next *stage) {
+	defer p.workers.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case env := <-st.queue:
+			out, err := p.runStage(ctx, st, env.item)
+			if err != nil {
+				st.errors.Add(1)
+				log.Printf("⚠️ Pipeline %s, stage %s lỗi: %v", p.name, st.config.Name, err)
+			}
+			st.processed.Add(1)
+
+			if out == nil || next == nil {
+				p.done(env.seq)
+				continue
+			}
+
+			if !p.enqueue(ctx, next, envelope{seq: env.seq, item: out}) {
+				p.done(env.seq)
+				return
+			}
+		}
+	}
+}
+
+func (p *Pipeline) runStage(ctx context.Context, st *stage, item interface{}) (out interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out = nil
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return st.config.Fn(ctx, item)
+}
+
+// enqueue gửi vào hàng đợi, chặn khi đầy (backpressure) cho tới khi có chỗ hoặc ctx kết thúc
+func (p *Pipeline) enqueue(ctx context.Context, st *stage, item envelope) bool {
+	select {
+	case st.queue <- item:
+		return true
+	default:
+	}
+
+	st.blocked.Add(1)
+	select {
+	case st.queue <- item:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p *Pipeline) done(seq uint64) {
+	p.pendingLock.Lock()
+	delete(p.pending, seq)
+	p.pendingCond.Broadcast()
+	p.pendingLock.Unlock()
+}
+
+// Submit đưa phần tử vào stage đầu tiên, chặn khi hàng đợi đầy
+func (p *Pipeline) Submit(ctx context.Context, item interface{}) error {
+	if len(p.stages) == 0 {
+		return fmt.Errorf("pipeline %s không có stage nào", p.name)
+	}
+
+	p.pendingLock.Lock()
+	p.seq++
+	seq := p.seq
+	p.pending[seq] = struct{}{}
+	p.pendingLock.Unlock()
+
+	if !p.enqueue(ctx, p.stages[0], envelope{seq: seq, item: item}) {
+		p.done(seq)
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Drain chờ cho tới khi mọi phần tử đã Submit trước lời gọi đi hết các stage; phần tử
+// Submit sau đó (vd: từ subscription đang chạy) không làm Drain phải chờ thêm
+func (p *Pipeline) Drain(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	timer := time.AfterFunc(timeout, func() {
+		p.pendingLock.Lock()
+		p.pendingCond.Broadcast()
+		p.pendingLock.Unlock()
+	})
+	defer timer.Stop()
+
+	p.pendingLock.Lock()
+	defer p.pendingLock.Unlock()
+	upTo := p.seq
+	for p.pendingUpTo(upTo) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		p.pendingCond.Wait()
+	}
+	return true
+}
+
+// pendingUpTo cho biết còn phần tử có số thứ tự <= upTo chưa xong; gọi khi giữ pendingLock
+func (p *Pipeline) pendingUpTo(upTo uint64) bool {
+	for seq := range p.pending {
+		if seq <= upTo {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats trả về số liệu của từng stage
+func (p *Pipeline) Stats() []StageStats {
+	stats := make([]StageStats, 0, len(p.stages))
+	for _, st := range p.stages {
+		stats = append(stats, StageStats{
+			Pipeline:  p.name,
+			Stage:     st.config.Name,
+			Depth:     len(st.queue),
+			Capacity:  cap(st.queue),
+			Processed: st.processed.Load(),
+			Errors:    st.errors.Load(),
+			Blocked:   st.blocked.Load(),
+		})
+	}
+	return stats
+}
+
+// AllStageStats trả về số liệu của mọi pipeline đang chạy
+func AllStageStats() []StageStats {
+	pipelinesLock.RLock()
+	defer pipelinesLock.RUnlock()
+
+	var stats []StageStats
+	for _, p := range pipelines {
+		stats = append(stats, p.Stats()...)
+	}
+	return stats
+}
@@ -0,0 +1,100 @@
+package poller
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Budget là token bucket giới hạn số đơn vị (request hoặc compute unit) mỗi giây của một endpoint
+type Budget struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+var (
+	budgets     = make(map[string]*Budget)
+	budgetsLock sync.Mutex
+)
+
+// NewBudget tạo budget với tốc độ perSecond; burst mặc định bằng 1 giây
+func NewBudget(perSecond float64) *Budget {
+	return &Budget{
+		rate:     perSecond,
+		capacity: perSecond,
+		tokens:   perSecond,
+		last:     time.Now(),
+	}
+}
+
+// ForEndpoint trả về budget dùng chung cho mọi scanner gọi cùng endpoint.
+// perSecond <= 0 nghĩa là không giới hạn (trả về nil).
+func ForEndpoint(key string, perSecond float64) *Budget {
+	if perSecond <= 0 {
+		return nil
+	}
+
+	budgetsLock.Lock()
+	defer budgetsLock.Unlock()
+
+	if b, ok := budgets[key]; ok {
+		b.SetRate(perSecond)
+		return b
+	}
+	b := NewBudget(perSecond)
+	budgets[key] = b
+	return b
+}
+
+// SetRate đổi tốc độ khi cấu hình thay đổi
+func (b *Budget) SetRate(perSecond float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	b.rate = perSecond
+	b.capacity = perSecond
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+func (b *Budget) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+// Wait chờ cho tới khi đủ cost đơn vị hoặc ctx kết thúc. Budget nil luôn cho qua.
+func (b *Budget) Wait(ctx context.Context, cost float64) error {
+	if b == nil || cost <= 0 {
+		return nil
+	}
+
+	for {
+		b.mu.Lock()
+		b.refill(time.Now())
+
+		// Cost lớn hơn sức chứa thì cho phép nợ để không chặn vĩnh viễn
+		if b.tokens >= cost || b.tokens >= b.capacity {
+			b.tokens -= cost
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((cost - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Config cấu hình scheduler của một chain / endpoint
+type Config struct {
+	// Chu kỳ khối ban đầu trước khi học được từ dữ liệu thực (vd: TimeNeedToBlock)
+	InitialInterval time.Duration
+	MinInterval     time.Duration
+	MaxInterval     time.Duration
+
+	// Endpoint dùng làm khóa cho budget chung
+	Endpoint              string
+	RequestsPerSecond     float64
+	ComputeUnitsPerSecond float64
+	ComputeUnitCosts      map[string]float64
+}
+
+// Stats là trạng thái hiện tại của scheduler
+type Stats struct {
+	BlockInterval time.Duration `json:"block_interval"`
+	Head          uint64        `json:"head"`
+	Processed     uint64        `json:"processed"`
+	Lag           uint64        `json:"lag"`
+	Backoff       time.Duration `json:"backoff"`
+	NextDelay     time.Duration `json:"next_delay"`
+	RateLimited   uint64        `json:"rate_limited"`
+}
+
+// Scheduler học chu kỳ khối và độ trễ so với đầu chuỗi để quyết định lúc poll tiếp:
+// poll dồn dập khi bị tụt lại, giãn ra khi đã bắt kịp hoặc bị rate limit (HTTP 429).
+type Scheduler struct {
+	name   string
+	config Config
+
+	requests *Budget
+	units    *Budget
+
+	mu            sync.Mutex
+	blockInterval time.Duration
+	head          uint64
+	headAt        time.Time
+	processed     uint64
+	backoff       time.Duration
+	rateLimited   uint64
+}
+
+var (
+	schedulers     = make(map[string]*Scheduler)
+	schedulersLock sync.RWMutex
+)
+
+// NewScheduler tạo scheduler và đăng ký để thu thập số liệu
+func NewScheduler(name string, config Config) *Scheduler {
+	if config.MinInterval <= 0 {
+		config.MinInterval = 50 * time.Millisecond
+	}
+	if config.MaxInterval <= 0 {
+		config.MaxInterval = 30 * time.Second
+	}
+	if config.InitialInterval <= 0 {
+		config.InitialInterval = time.Second
+	}
+
+	s := &Scheduler{
+		name:          name,
+		config:        config,
+		requests:      ForEndpoint(config.Endpoint+"#requests", config.RequestsPerSecond),
+		units:         ForEndpoint(config.Endpoint+"#units", config.ComputeUnitsPerSecond),
+		blockInterval: config.InitialInterval,
+	}
+
+	schedulersLock.Lock()
+	schedulers[name] = s
+	schedulersLock.Unlock()
+
+	return s
+}
+
+// AllStats trả về trạng thái của mọi scheduler
+func AllStats() map[string]Stats {
+	schedulersLock.RLock()
+	defer schedulersLock.RUnlock()
+
+	result := make(map[string]Stats, len(schedulers))
+	for name, s := range schedulers {
+		result[name] = s.Stats()
+	}
+	return result
+}
+
+// ObserveHead ghi nhận số khối mới nhất của chain và cập nhật chu kỳ khối (EWMA)
+func (s *Scheduler) ObserveHead(head uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.head > 0 && head > s.head && !s.headAt.IsZero() {
+		observed := now.Sub(s.headAt) / time.Duration(head-s.head)
+		s.blockInterval = (s.blockInterval*4 + observed) / 5
+	}
+	if head >= s.head {
+		if head > s.head {
+			s.headAt = now
+		}
+		s.head = head
+	}
+}
+
+// ObserveProcessed ghi nhận khối cuối cùng đã xử lý xong. head và headAt chỉ do
+// ObserveHead cập nhật để chu kỳ khối không bị tính từ mốc thời gian cũ.
+func (s *Scheduler) ObserveProcessed(processed uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.processed = processed
+}
+
+// Success xóa backoff sau một lần gọi thành công
+func (s *Scheduler) Success() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backoff = 0
+}
+
+// RateLimited tăng backoff theo cấp số nhân khi provider trả về 429 hoặc lỗi tạm thời
+func (s *Scheduler) RateLimited() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rateLimited++
+	if s.backoff == 0 {
+		s.backoff = s.blockInterval
+		if s.backoff < time.Second {
+			s.backoff = time.Second
+		}
+	} else {
+		s.backoff *= 2
+	}
+	if s.backoff > s.config.MaxInterval {
+		s.backoff = s.config.MaxInterval
+	}
+}
+
+// Next tính khoảng chờ trước lần poll tiếp theo
+func (s *Scheduler) Next() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextLocked()
+}
+
+func (s *Scheduler) nextLocked() time.Duration {
+	if s.backoff > 0 {
+		return s.backoff
+	}
+
+	var delay time.Duration
+	lag := s.lagLocked()
+	switch {
+	case lag > 1:
+		// Đang tụt lại: poll ngay, chỉ bị giới hạn bởi budget
+		delay = s.config.MinInterval
+	case lag == 1:
+		delay = s.blockInterval / 4
+	default:
+		// Đã bắt kịp: chờ tới khoảng lúc khối kế tiếp xuất hiện
+		delay = s.blockInterval / 2
+		if !s.headAt.IsZero() {
+			if untilNext := s.blockInterval - time.Since(s.headAt); untilNext > delay {
+				delay = untilNext
+			}
+		}
+	}
+
+	if delay < s.config.MinInterval {
+		delay = s.config.MinInterval
+	}
+	if delay > s.config.MaxInterval {
+		delay = s.config.MaxInterval
+	}
+	return delay
+}
+
+func (s *Scheduler) lagLocked() uint64 {
+	if s.head > s.processed {
+		return s.head - s.processed
+	}
+	return 0
+}
+
+// Wait ngủ theo Next() rồi trừ budget cho lần gọi method sắp thực hiện
+func (s *Scheduler) Wait(ctx context.Context, method string) error {
+	timer := time.NewTimer(s.Next())
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	}
+	return s.Acquire(ctx, method)
+}
+
+// Acquire chỉ trừ budget (request/giây và compute unit/giây) mà không chờ theo chu kỳ khối
+func (s *Scheduler) Acquire(ctx context.Context, method string) error {
+	if err := s.requests.Wait(ctx, 1); err != nil {
+		return err
+	}
+
+	cost := 1.0
+	if c, ok := s.config.ComputeUnitCosts[method]; ok {
+		cost = c
+	}
+	return s.units.Wait(ctx, cost)
+}
+
+// Stats trả về trạng thái hiện tại
+func (s *Scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return Stats{
+		BlockInterval: s.blockInterval,
+		Head:          s.head,
+		Processed:     s.processed,
+		Lag:           s.lagLocked(),
+		Backoff:       s.backoff,
+		NextDelay:     s.nextLocked(),
+		RateLimited:   s.rateLimited,
+	}
+}
+
+// IsRateLimited nhận diện lỗi 429 từ go-ethereum rpc hoặc thông báo lỗi của SDK khác
+func IsRateLimited(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "429") || strings.Contains(msg, "too many requests") || strings.Contains(msg, "rate limit")
+}
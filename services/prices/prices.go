@@ -0,0 +1,114 @@
+package prices
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"sync"
+
+	"main/services/pipeline"
+	"main/services/sink"
+)
+
+// Token là thông tin để quy đổi số lượng token on-chain sang USD
+type Token struct {
+	Symbol   string
+	Decimals int
+}
+
+// Stablecoin được định giá 1 USD
+var stablecoins = map[string]bool{"USDT": true, "USDC": true, "BUSD": true, "DAI": true, "FRAX": true}
+
+var (
+	mu     sync.RWMutex
+	tokens = make(map[string]Token)   // chain:contract -> token
+	usd    = make(map[string]float64) // symbol -> giá USD gần nhất
+)
+
+// RegisterToken khai báo contract token của một chain để tính được giá trị USD
+func RegisterToken(chain, contract, symbol string, decimals int) {
+	mu.Lock()
+	defer mu.Unlock()
+	tokens[tokenKey(chain, contract)] = Token{Symbol: strings.ToUpper(symbol), Decimals: decimals}
+}
+
+// Set cập nhật giá USD của symbol (BTC, ETH, ...)
+func Set(symbol string, price float64) {
+	mu.Lock()
+	defer mu.Unlock()
+	usd[strings.ToUpper(symbol)] = price
+}
+
+// Price trả về giá USD gần nhất của symbol
+func Price(symbol string) (float64, bool) {
+	symbol = strings.ToUpper(symbol)
+	if stablecoins[symbol] {
+		return 1, true
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	price, ok := usd[symbol]
+	return price, ok
+}
+
+// USDValue quy đổi Amount của event sang USD; false nếu token chưa đăng ký hoặc chưa có giá
+func USDValue(ev pipeline.Event) (float64, bool) {
+	if ev.Amount == nil {
+		return 0, false
+	}
+
+	mu.RLock()
+	token, ok := tokens[tokenKey(ev.Chain, ev.Contract)]
+	mu.RUnlock()
+	if !ok {
+		return 0, false
+	}
+
+	price, ok := Price(token.Symbol)
+	if !ok {
+		return 0, false
+	}
+
+	amount := new(big.Float).SetInt(ev.Amount)
+	divisor := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(token.Decimals)), nil))
+	value, _ := new(big.Float).Mul(new(big.Float).Quo(amount, divisor), big.NewFloat(price)).Float64()
+	return value, true
+}
+
+func tokenKey(chain, contract string) string {
+	return chain + ":" + strings.ToLower(contract)
+}
+
+// Sink cập nhật giá từ các nến OHLCV (cặp xxxUSDT) đi qua sink
+type Sink struct{}
+
+func (Sink) Write(r sink.Record) error {
+	if !strings.HasPrefix(r.Kind, "ohlcv_") {
+		return nil
+	}
+
+	raw, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	var candle struct {
+		Symbol string
+		Close  *big.Int
+	}
+	if err := json.Unmarshal(raw, &candle); err != nil || candle.Close == nil {
+		return err
+	}
+
+	base, ok := strings.CutSuffix(strings.ToUpper(candle.Symbol), "USDT")
+	if !ok || base == "" {
+		return nil
+	}
+	// Giá trong ResponseOHLCV được nhân 100 (xem ohlcv.strToBigInt)
+	price, _ := new(big.Float).Quo(new(big.Float).SetInt(candle.Close), big.NewFloat(100)).Float64()
+	Set(base, price)
+	return nil
+}
+
+func (Sink) Flush() error { return nil }
+func (Sink) Close() error { return nil }
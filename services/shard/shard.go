@@ -0,0 +1,306 @@
+package shard
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"main/services/leader"
+	"main/services/metrics"
+)
+
+// DefaultDir chứa heartbeat của các worker (<worker>.json), đổi bằng SHARD_DIR.
+// Mọi worker của cùng một cụm phải thấy cùng thư mục này.
+const DefaultDir = "./data/workers"
+
+// DefaultTTL: worker không gửi heartbeat quá khoảng này bị coi là đã chết và chain của
+// nó được chia lại. Đổi bằng SHARD_TTL (vd: "10s").
+const DefaultTTL = 15 * time.Second
+
+// Heartbeat là file mỗi worker ghi định kỳ: nó đang chạy những chain nào
+type Heartbeat struct {
+	Worker  string    `json:"worker"`
+	Chains  []string  `json:"chains"`
+	Updated time.Time `json:"updated"`
+}
+
+type unit struct {
+	sticky  bool
+	start   func()
+	stop    func()
+	running bool
+	warned  bool
+}
+
+// Manager giao các chain cho worker còn sống bằng rendezvous hashing (hoặc theo
+// SHARD_ASSIGN) và bật/tắt service của chain ở worker này cho khớp.
+//
+// Một chain chỉ được bật khi không worker sống nào khác còn báo đang chạy nó, nên khi
+// chain chuyển chủ, worker cũ dừng hẳn (và lưu checkpoint) trước khi worker mới bắt đầu.
+type Manager struct {
+	id     string
+	dir    string
+	ttl    time.Duration
+	assign map[string]string
+
+	// mu giữ trong cả lúc bật/tắt service để Add/Remove không chen giữa một lần phân chia
+	mu    sync.Mutex
+	units map[string]*unit
+	// wake rút ngắn lần phân chia kế tiếp khi danh sách chain đổi
+	wake chan struct{}
+}
+
+// ID là tên worker: WORKER_ID hoặc định danh replica dùng cho bầu leader
+func ID() string {
+	if id := os.Getenv("WORKER_ID"); id != "" {
+		return id
+	}
+	return leader.ID()
+}
+
+// Dir trả về thư mục heartbeat đã cấu hình
+func Dir() string {
+	if dir := os.Getenv("SHARD_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultDir
+}
+
+// New tạo manager theo biến môi trường WORKER_ID, SHARD_DIR, SHARD_TTL và
+// SHARD_ASSIGN (vd: "tron=worker-a,vechain=worker-b")
+func New() *Manager {
+	m := &Manager{
+		id:     ID(),
+		dir:    Dir(),
+		ttl:    DefaultTTL,
+		assign: parseAssign(os.Getenv("SHARD_ASSIGN")),
+		units:  make(map[string]*unit),
+		wake:   make(chan struct{}, 1),
+	}
+	if v := os.Getenv("SHARD_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			m.ttl = d
+		}
+	}
+	return m
+}
+
+func parseAssign(v string) map[string]string {
+	assign := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		chain, worker, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && chain != "" && worker != "" {
+			assign[strings.TrimSpace(chain)] = strings.TrimSpace(worker)
+		}
+	}
+	return assign
+}
+
+// Add khai báo chain cùng hàm bật/tắt service của nó. sticky dành cho service không
+// dừng được: chain chỉ rời worker đang chạy nó khi worker đó chết.
+func (m *Manager) Add(chain string, sticky bool, start, stop func()) {
+	m.mu.Lock()
+	m.units[chain] = &unit{sticky: sticky, start: start, stop: stop}
+	m.mu.Unlock()
+	m.poke()
+}
+
+// Remove dừng chain (nếu đang chạy ở đây) và bỏ nó khỏi danh sách
+func (m *Manager) Remove(chain string) {
+	m.mu.Lock()
+	if u, ok := m.units[chain]; ok {
+		delete(m.units, chain)
+		if u.running {
+			u.stop()
+		}
+	}
+	m.mu.Unlock()
+	m.poke()
+}
+
+func (m *Manager) poke() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run phân chia lại chain mỗi TTL/3 cho tới khi ctx bị hủy. Heartbeat được giữ tới
+// Leave để worker khác không nhận chain khi service ở đây còn đang dừng.
+func (m *Manager) Run(ctx context.Context) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	log.Printf("🧩 [SHARD] Worker %s tham gia cụm tại %s", m.id, m.dir)
+
+	ticker := time.NewTicker(m.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		m.rebalance()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-m.wake:
+		}
+	}
+}
+
+// Leave xóa heartbeat của worker; gọi sau khi mọi service đã dừng
+func (m *Manager) Leave(ctx context.Context) error {
+	err := os.Remove(m.heartbeatPath(m.id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (m *Manager) rebalance() {
+	heartbeats, err := ReadHeartbeats(m.dir)
+	if err != nil {
+		log.Printf("⚠️ [SHARD] Không đọc được heartbeat: %v", err)
+		return
+	}
+
+	live := []string{m.id}
+	claimed := make(map[string]string)
+	for _, hb := range heartbeats {
+		if hb.Worker == m.id || time.Since(hb.Updated) > m.ttl {
+			continue
+		}
+		live = append(live, hb.Worker)
+		for _, chain := range hb.Chains {
+			claimed[chain] = hb.Worker
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.units))
+	for name := range m.units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var running []string
+	for _, name := range names {
+		u := m.units[name]
+		owner := Owner(name, live, m.assign)
+		switch {
+		case u.running && owner != m.id && !u.sticky:
+			log.Printf("🧩 [SHARD] Chuyển chain %s cho worker %s", name, owner)
+			u.stop()
+			u.running = false
+			metrics.ShardMoves.Inc()
+		case u.running && owner != m.id:
+			if !u.warned {
+				log.Printf("⚠️ [SHARD] Chain %s thuộc về %s nhưng service cũ không dừng được, giữ ở đây tới khi khởi động lại worker", name, owner)
+				u.warned = true
+			}
+		case !u.running && owner == m.id:
+			if holder, ok := claimed[name]; ok {
+				log.Printf("⏳ [SHARD] Chờ worker %s nhả chain %s", holder, name)
+				break
+			}
+			log.Printf("🧩 [SHARD] Nhận chain %s", name)
+			u.start()
+			u.running = true
+			u.warned = false
+		}
+		if u.running {
+			running = append(running, name)
+		}
+	}
+
+	metrics.ShardChains.Set(float64(len(running)))
+	metrics.ShardWorkers.Set(float64(len(live)))
+	if err := m.writeHeartbeat(running); err != nil {
+		log.Printf("⚠️ [SHARD] Không ghi được heartbeat: %v", err)
+	}
+}
+
+func (m *Manager) heartbeatPath(worker string) string {
+	return filepath.Join(m.dir, worker+".json")
+}
+
+func (m *Manager) writeHeartbeat(chains []string) error {
+	if chains == nil {
+		chains = []string{}
+	}
+	data, err := json.Marshal(Heartbeat{Worker: m.id, Chains: chains, Updated: time.Now()})
+	if err != nil {
+		return err
+	}
+	path := m.heartbeatPath(m.id)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Owner chọn worker cho chain: worker ghi trong assign nếu còn sống, nếu không thì
+// worker có hash(worker, chain) lớn nhất. Thêm/bớt một worker chỉ làm chuyển các chain
+// của chính worker đó.
+func Owner(chain string, live []string, assign map[string]string) string {
+	if worker, ok := assign[chain]; ok {
+		for _, w := range live {
+			if w == worker {
+				return worker
+			}
+		}
+	}
+
+	var best string
+	var bestScore uint64
+	for _, worker := range live {
+		h := fnv.New64a()
+		h.Write([]byte(worker + "/" + chain))
+		if score := mix(h.Sum64()); best == "" || score > bestScore || (score == bestScore && worker < best) {
+			best, bestScore = worker, score
+		}
+	}
+	return best
+}
+
+// mix trộn đều các bit của FNV (finalizer của splitmix64); chỉ FNV thì tên worker chỉ
+// khác nhau ký tự cuối cho điểm gần nhau và chain dồn về một worker
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// ReadHeartbeats đọc heartbeat của mọi worker trong dir (kể cả worker đã chết)
+func ReadHeartbeats(dir string) ([]Heartbeat, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var heartbeats []Heartbeat
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var hb Heartbeat
+		if json.Unmarshal(data, &hb) == nil && hb.Worker != "" {
+			heartbeats = append(heartbeats, hb)
+		}
+	}
+	return heartbeats, nil
+}
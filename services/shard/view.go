@@ -0,0 +1,56 @@
+package shard
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// WorkerView là một worker trong báo cáo /shards
+type WorkerView struct {
+	Worker  string    `json:"worker"`
+	Live    bool      `json:"live"`
+	Updated time.Time `json:"updated"`
+	Chains  []string  `json:"chains"`
+}
+
+// View là bức tranh cả cụm: worker nào sống, chain nào đang chạy ở đâu
+type View struct {
+	Workers []WorkerView      `json:"workers"`
+	Chains  map[string]string `json:"chains"`
+}
+
+// ReadView dựng View từ heartbeat trong dir; worker đã chết vẫn được liệt kê với live=false
+func ReadView(dir string, ttl time.Duration) (View, error) {
+	heartbeats, err := ReadHeartbeats(dir)
+	if err != nil {
+		return View{}, err
+	}
+
+	view := View{Workers: []WorkerView{}, Chains: make(map[string]string)}
+	for _, hb := range heartbeats {
+		live := time.Since(hb.Updated) <= ttl
+		view.Workers = append(view.Workers, WorkerView{Worker: hb.Worker, Live: live, Updated: hb.Updated, Chains: hb.Chains})
+		if live {
+			for _, chain := range hb.Chains {
+				view.Chains[chain] = hb.Worker
+			}
+		}
+	}
+	sort.Slice(view.Workers, func(i, j int) bool { return view.Workers[i].Worker < view.Workers[j].Worker })
+	return view, nil
+}
+
+// ViewHandler phục vụ /shards từ thư mục heartbeat; worker nào trong cụm cũng trả cùng kết quả
+func ViewHandler(w http.ResponseWriter, r *http.Request) {
+	m := New()
+	view, err := ReadView(m.dir, m.ttl)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(view)
+}
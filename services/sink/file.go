@@ -0,0 +1,105 @@
+package sink
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"main/services/logging"
+)
+
+// DefaultDir là thư mục mặc định của FileSink, có thể đổi bằng biến môi trường SINK_DIR
+const DefaultDir = "./data/sink"
+
+// FileSink ghi bản ghi dạng JSON Lines vào <dir>/<kind>/<chain>.jsonl. Mỗi bản ghi được ghi
+// nguyên dòng thẳng xuống file nên người đọc (store, export) luôn thấy dòng đầy đủ. File xoay
+// vòng theo kích thước nhưng không bản cũ nào bị xóa: đây là dữ liệu, không phải log.
+type FileSink struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	writers map[string]*logging.RotatingFile
+}
+
+// NewFileSink tạo FileSink; dir rỗng thì dùng SINK_DIR hoặc DefaultDir
+func NewFileSink(dir string, maxBytes int64) *FileSink {
+	if dir == "" {
+		dir = os.Getenv("SINK_DIR")
+	}
+	if dir == "" {
+		dir = DefaultDir
+	}
+	return &FileSink{dir: dir, maxBytes: maxBytes, writers: make(map[string]*logging.RotatingFile)}
+}
+
+// Dir trả về thư mục chứa dữ liệu của sink
+func (s *FileSink) Dir() string {
+	return s.dir
+}
+
+func (s *FileSink) Write(r Record) error {
+	// Mỗi dòng kèm thời điểm ghi để export biết ngày nào có dữ liệu tới muộn
+	data, err := json.Marshal(struct {
+		Record
+		Ingested time.Time `json:"ingested"`
+	}{r, time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	name := r.Chain
+	if name == "" {
+		name = "all"
+	}
+	key := filepath.Join(r.Kind, name+".jsonl")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.writers[key]
+	if !ok {
+		w, err = logging.OpenRotating(filepath.Join(s.dir, key), s.maxBytes, logging.KeepAll)
+		if err != nil {
+			return err
+		}
+		s.writers[key] = w
+	}
+
+	// Một lần Write cho cả dòng: file chỉ xoay vòng giữa các dòng
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Flush sync dữ liệu đã ghi xuống đĩa
+func (s *FileSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for _, w := range s.writers {
+		if err := w.Sync(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for key, w := range s.writers {
+		if err := w.Sync(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := w.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.writers, key)
+	}
+	return firstErr
+}
@@ -0,0 +1,98 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StoredRecord là một dòng đã ghi bởi FileSink; Data giữ nguyên JSON
+type StoredRecord struct {
+	Record
+	Data json.RawMessage `json:"data"`
+	// Ingested là lúc FileSink ghi dòng (rỗng với dòng ghi trước khi có trường này)
+	Ingested time.Time `json:"ingested,omitempty"`
+}
+
+// AllKinds dùng làm kind của ReadFiles để đọc mọi loại bản ghi
+const AllKinds = "*"
+
+// ReadFiles đọc mọi bản ghi loại kind đã ghi dưới dir (<dir>/<kind>/<chain>.jsonl và các
+// bản xoay vòng), từ cũ tới mới trong từng chain. Dòng hỏng bị bỏ qua.
+func ReadFiles(dir, kind string, fn func(StoredRecord) error) error {
+	paths, err := filepath.Glob(filepath.Join(dir, kind, "*.jsonl*"))
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string][]string) // file gốc -> các bản xoay vòng
+	for _, path := range paths {
+		base, n := splitRotation(path)
+		if n >= 0 {
+			files[base] = append(files[base], path)
+		}
+	}
+	bases := make([]string, 0, len(files))
+	for base := range files {
+		bases = append(bases, base)
+	}
+	sort.Strings(bases)
+
+	for _, base := range bases {
+		paths := files[base]
+		// Bản xoay vòng số lớn hơn là dữ liệu cũ hơn
+		sort.Slice(paths, func(i, j int) bool {
+			_, a := splitRotation(paths[i])
+			_, b := splitRotation(paths[j])
+			return a > b
+		})
+		for _, path := range paths {
+			if err := readFile(path, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// splitRotation tách "<x>.jsonl.<n>" thành file gốc và n (0 với file gốc, -1 nếu không khớp)
+func splitRotation(path string) (string, int) {
+	if strings.HasSuffix(path, ".jsonl") {
+		return path, 0
+	}
+	i := strings.LastIndex(path, ".jsonl.")
+	if i < 0 {
+		return "", -1
+	}
+	n, err := strconv.Atoi(path[i+len(".jsonl."):])
+	if err != nil {
+		return "", -1
+	}
+	return path[:i+len(".jsonl")], n
+}
+
+func readFile(path string, fn func(StoredRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1<<20), 64<<20)
+	for scanner.Scan() {
+		var r StoredRecord
+		if json.Unmarshal(scanner.Bytes(), &r) != nil {
+			continue
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
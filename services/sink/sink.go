@@ -0,0 +1,89 @@
+package sink
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Record là một bản ghi dữ liệu (block, giao dịch, dòng tiền...) gửi tới các sink
+type Record struct {
+	Kind   string      `json:"kind"`
+	Chain  string      `json:"chain,omitempty"`
+	Block  uint64      `json:"block,omitempty"`
+	Hash   string      `json:"hash,omitempty"` // hash của khối với Kind "block"
+	TxHash string      `json:"tx_hash,omitempty"`
+	Time   time.Time   `json:"time"`
+	Data   interface{} `json:"data"`
+}
+
+// Sink nhận bản ghi dữ liệu; dữ liệu không được ghi vào file log
+type Sink interface {
+	Write(r Record) error
+	Flush() error
+	Close() error
+}
+
+var (
+	mu    sync.RWMutex
+	sinks = make(map[string]Sink)
+	order []string
+)
+
+// Register thêm sink vào danh sách nhận bản ghi; tên trùng sẽ thay sink cũ
+func Register(name string, s Sink) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := sinks[name]; !ok {
+		order = append(order, name)
+	}
+	sinks[name] = s
+}
+
+// Write gửi bản ghi tới mọi sink đã đăng ký. Lỗi của một sink chỉ được ghi log,
+// không chặn các sink còn lại.
+func Write(r Record) {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, name := range order {
+		if err := sinks[name].Write(r); err != nil {
+			slog.Error("sink: ghi bản ghi thất bại", "sink", name, "kind", r.Kind, "chain", r.Chain, "error", err)
+		}
+	}
+}
+
+// FlushAll đẩy dữ liệu đệm của mọi sink
+func FlushAll() error {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var firstErr error
+	for _, name := range order {
+		if err := sinks[name].Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// CloseAll đóng mọi sink và xóa khỏi danh sách (shutdown hook)
+func CloseAll() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var firstErr error
+	for _, name := range order {
+		if err := sinks[name].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	sinks = make(map[string]Sink)
+	order = nil
+	return firstErr
+}
@@ -0,0 +1,33 @@
+package sink
+
+import (
+	"main/services/pipeline"
+)
+
+// KindTransfer là loại bản ghi của transfer đã qua pipeline
+const KindTransfer = "transfer"
+
+// TransferProcessor là processor của pipeline ghi mọi event chuẩn vào các sink.
+// Event bị gỡ do reorg (Removed) cũng được ghi để sink có thể xóa bản ghi cũ.
+type TransferProcessor struct{}
+
+func (TransferProcessor) Name() string {
+	return "sink"
+}
+
+func (TransferProcessor) ProcessEvent(ev pipeline.Event) error {
+	Write(Record{
+		Kind:   KindTransfer,
+		Chain:  ev.Chain,
+		Block:  ev.BlockNumber,
+		Hash:   ev.BlockHash,
+		TxHash: ev.TxHash,
+		Time:   ev.Timestamp,
+		Data:   ev,
+	})
+	return nil
+}
+
+func (TransferProcessor) ProcessBlock(b pipeline.Block) error {
+	return nil
+}
@@ -13,9 +13,11 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+
+	"main/services/headercache"
 )
 
-func listenToTransactions(client *ethclient.Client, config ConfigStablecoin) {
+func listenToTransactions(ctx context.Context, client *ethclient.Client, config ConfigStablecoin) error {
 	// Tạo danh sách địa chỉ stablecoin để lọc
 	addresses := make([]common.Address, len(config.Stablecoins))
 	for i, sc := range config.Stablecoins {
@@ -30,25 +32,26 @@ func listenToTransactions(client *ethclient.Client, config ConfigStablecoin) {
 
 	// Kênh để nhận log
 	logs := make(chan types.Log)
-	ctx := context.Background()
 
 	// Đăng ký lắng nghe sự kiện
 	sub, err := client.SubscribeFilterLogs(ctx, query, logs)
 	if err != nil {
-		log.Fatalf("Failed to subscribe to logs: %v", err)
+		return fmt.Errorf("failed to subscribe to logs: %w", err)
 	}
 	defer sub.Unsubscribe()
 
-	fmt.Println("Listening for real-time transactions...")
+	log.Println("Listening for real-time transactions...")
 
 	// Xử lý log theo thời gian thực
 	for {
 		select {
 		case err := <-sub.Err():
-			log.Fatalf("Subscription error: %v", err)
+			return fmt.Errorf("subscription error: %w", err)
+		case <-ctx.Done():
+			return nil
 		case vLog := <-logs:
 			// Lấy timestamp từ block
-			timestamp, err := getBlockTimestamp(ctx, client, vLog.BlockNumber)
+			timestamp, err := getBlockTimestamp(ctx, client, config.Chain, vLog.BlockNumber)
 			if err != nil {
 				log.Printf("Failed to get timestamp: %v", err)
 				continue
@@ -74,34 +77,42 @@ func listenToTransactions(client *ethclient.Client, config ConfigStablecoin) {
 				"amount":           amount,
 				"tx_hash":          txHash,
 			}
-			flowDataDate(tx)
-			time.Sleep(5 * time.Second)
-			flowDataWeek(tx)
-			time.Sleep(5 * time.Second)
-			flowDataMonth(tx)
+			handleFlowTx(tx)
 		}
 	}
 }
 
-func getBlockTimestamp(ctx context.Context, client *ethclient.Client, blockNumber uint64) (time.Time, error) {
-	block, err := client.BlockByNumber(ctx, big.NewInt(int64(blockNumber)))
-	if err != nil {
-		return time.Time{}, err
-	}
-	return time.Unix(int64(block.Time()), 0), nil
+// handleFlowTx cập nhật dòng tiền ngày/tuần/tháng cho một giao dịch stablecoin
+func handleFlowTx(tx map[string]interface{}) {
+	flowDataDate(tx)
+	time.Sleep(5 * time.Second)
+	flowDataWeek(tx)
+	time.Sleep(5 * time.Second)
+	flowDataMonth(tx)
 }
 
-func Stablecoin() {
+// getBlockTimestamp lấy thời gian khối qua header cache dùng chung thay vì tải cả khối cho mỗi log
+func getBlockTimestamp(ctx context.Context, client *ethclient.Client, chain string, blockNumber uint64) (time.Time, error) {
+	return headercache.ForChain(chain).Timestamp(ctx, blockNumber, headercache.EthFetcher(client))
+}
+
+func Stablecoin(ctx context.Context) error {
 	// Kết nối đến node BSC qua WebSocket
 	nodeURL := "wss://bsc-mainnet.core.chainstack.com/8e6310f0dc371b60ddc0de98a4d5d1e3" // WebSocket công cộng BSC
-	client, err := ethclient.Dial(nodeURL)
+	client, err := ethclient.DialContext(ctx, nodeURL)
 	if err != nil {
-		log.Fatalf("Failed to connect to BSC node: %v", err)
+		return fmt.Errorf("failed to connect to BSC node: %w", err)
 	}
 	defer client.Close()
 
-	// Cấu hình stablecoin
-	config := ConfigStablecoin{
+	// Bắt đầu lắng nghe giao dịch real-time
+	return listenToTransactions(ctx, client, defaultConfig())
+}
+
+// Cấu hình stablecoin trên BSC
+func defaultConfig() ConfigStablecoin {
+	return ConfigStablecoin{
+		Chain: "bsc",
 		Stablecoins: []StablecoinInfo{
 			{Address: "0x55d398326f99059fF775485246999027B3197955", Decimals: 18, Name: "USDT"},
 			{Address: "0x8AC76a51cc950d9822D68b83fE1Ad97B32Cd580d", Decimals: 18, Name: "USDC"},
@@ -110,8 +121,6 @@ func Stablecoin() {
 			{Address: "0x90C97F71E18723b0CF0dfa30ee176Ab653E89F68", Decimals: 18, Name: "FRAX"},
 		},
 	}
-	// Bắt đầu lắng nghe giao dịch real-time
-	listenToTransactions(client, config)
 }
 
 func flowDataDate(tx map[string]interface{}) {
@@ -123,7 +132,7 @@ func flowDataDate(tx map[string]interface{}) {
 	}
 
 	// In kết quả
-	fmt.Printf("Processed tx %s at %s:\n", tx["tx_hash"], tx["timestamp"])
+	log.Printf("Processed tx %s at %s:", tx["tx_hash"], tx["timestamp"])
 	for name, flow := range flowDataMap {
 		// Chuyển đổi StartTime từ string sang int
 		startTimeParsed, err := time.Parse("2006-01-02 15:04:05", flow.StartTime)
@@ -136,7 +145,9 @@ func flowDataDate(tx map[string]interface{}) {
 		outgoing := strconv.FormatFloat(flow.Outgoing, 'f', -1, 64)
 		balance := strconv.FormatFloat(flow.Balance, 'f', -1, 64)
 		// fmt.Printf("  %s: %+v\n", name, flow)
-		StablecoinSMCDate(startTime, incoming, outgoing, balance, name, flow.NameCoin)
+		if err := StablecoinSMCDate(startTime, incoming, outgoing, balance, name, flow.NameCoin); err != nil {
+			log.Printf("Error queueing flow for contract: %v", err)
+		}
 	}
 }
 func flowDataWeek(tx map[string]interface{}) {
@@ -148,7 +159,7 @@ func flowDataWeek(tx map[string]interface{}) {
 	}
 
 	// In kết quả
-	fmt.Printf("Processed tx %s at %s:\n", tx["tx_hash"], tx["timestamp"])
+	log.Printf("Processed tx %s at %s:", tx["tx_hash"], tx["timestamp"])
 	for name, flow := range flowDataMapWeek {
 		// Chuyển đổi StartTime từ string sang int
 		startTimeParsed, err := time.Parse("2006-01-02 15:04:05", flow.StartTime)
@@ -161,7 +172,9 @@ func flowDataWeek(tx map[string]interface{}) {
 		outgoing := strconv.FormatFloat(flow.Outgoing, 'f', -1, 64)
 		balance := strconv.FormatFloat(flow.Balance, 'f', -1, 64)
 		// fmt.Printf("  %s: %+v\n", name, flow)
-		StablecoinSMCWeek(startTime, incoming, outgoing, balance, name, flow.NameCoin)
+		if err := StablecoinSMCWeek(startTime, incoming, outgoing, balance, name, flow.NameCoin); err != nil {
+			log.Printf("Error queueing flow for contract: %v", err)
+		}
 	}
 }
 func flowDataMonth(tx map[string]interface{}) {
@@ -173,7 +186,7 @@ func flowDataMonth(tx map[string]interface{}) {
 	}
 
 	// In kết quả
-	fmt.Printf("Processed tx %s at %s:\n", tx["tx_hash"], tx["timestamp"])
+	log.Printf("Processed tx %s at %s:", tx["tx_hash"], tx["timestamp"])
 	for name, flow := range flowDataMapMonth {
 		// Chuyển đổi StartTime từ string sang int
 		startTimeParsed, err := time.Parse("2006-01-02 15:04:05", flow.StartTime)
@@ -186,6 +199,8 @@ func flowDataMonth(tx map[string]interface{}) {
 		outgoing := strconv.FormatFloat(flow.Outgoing, 'f', -1, 64)
 		balance := strconv.FormatFloat(flow.Balance, 'f', -1, 64)
 		// fmt.Printf("  %s: %+v\n", name, flow)
-		StablecoinSMCMonth(startTime, incoming, outgoing, balance, name, flow.NameCoin)
+		if err := StablecoinSMCMonth(startTime, incoming, outgoing, balance, name, flow.NameCoin); err != nil {
+			log.Printf("Error queueing flow for contract: %v", err)
+		}
 	}
 }
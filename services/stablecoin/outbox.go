@@ -0,0 +1,36 @@
+package stablecoin
+
+import (
+	"fmt"
+
+	"main/services/outbox"
+)
+
+// flowPayload là một dòng tiền chờ gửi lên contract
+type flowPayload struct {
+	Timestamp    int64  `json:"timestamp"`
+	Incoming     string `json:"incoming"`
+	Outgoing     string `json:"outgoing"`
+	Balance      string `json:"balance"`
+	TokenSymbol  string `json:"token_symbol"`
+	ExchangeName string `json:"exchange_name"`
+}
+
+// Hộp thư đi của từng contract; dòng tiền của cùng kỳ được cộng dần nên giá trị mới nhất
+// thay cho giá trị chưa kịp gửi
+var (
+	dateOutbox  = outbox.New("stablecoin-date", 0, flowSender(sendDateFlow))
+	weekOutbox  = outbox.New("stablecoin-week", 0, flowSender(sendWeekFlow))
+	monthOutbox = outbox.New("stablecoin-month", 0, flowSender(sendMonthFlow))
+)
+
+// flowKey là khóa idempotency của dòng tiền: một kỳ của một token trên một sàn
+func flowKey(timestamp int64, tokenSymbol, exchangeName string) string {
+	return fmt.Sprintf("%s/%s/%d", exchangeName, tokenSymbol, timestamp)
+}
+
+func flowSender(send func(timestamp int64, incoming, outgoing, balance, tokenSymbol, exchangeName string) error) func(flowPayload) error {
+	return func(p flowPayload) error {
+		return send(p.Timestamp, p.Incoming, p.Outgoing, p.Balance, p.TokenSymbol, p.ExchangeName)
+	}
+}
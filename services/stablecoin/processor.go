@@ -0,0 +1,102 @@
+package stablecoin
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"main/services/metrics"
+	"main/services/pipeline"
+	"main/services/prices"
+)
+
+const transferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// FlowProcessor tính dòng tiền stablecoin từ các event Transfer của pipeline dùng chung,
+// thay cho việc tự mở subscription riêng như listenToTransactions.
+type FlowProcessor struct {
+	stablecoins map[string]bool
+	queue       chan map[string]interface{}
+}
+
+// RegisterFlowProcessor đăng ký processor dòng tiền stablecoin cho một chain (thường là "bsc")
+func RegisterFlowProcessor(chain string) *FlowProcessor {
+	p := NewFlowProcessor(defaultConfig())
+	go p.run()
+	pipeline.Register(chain, pipeline.Wildcard, p)
+	return p
+}
+
+// RegisterTokens khai báo các stablecoin của chain cho bảng giá để tính giá trị USD của transfer
+func RegisterTokens(chain string) {
+	for _, sc := range defaultConfig().Stablecoins {
+		prices.RegisterToken(chain, sc.Address, sc.Name, sc.Decimals)
+	}
+}
+
+func NewFlowProcessor(config ConfigStablecoin) *FlowProcessor {
+	p := &FlowProcessor{
+		stablecoins: make(map[string]bool),
+		queue:       make(chan map[string]interface{}, 1000),
+	}
+	for _, sc := range config.Stablecoins {
+		p.stablecoins[strings.ToLower(sc.Address)] = true
+	}
+	return p
+}
+
+func (p *FlowProcessor) Name() string {
+	return "stablecoin-flow"
+}
+
+func (p *FlowProcessor) ProcessEvent(ev pipeline.Event) error {
+	if !strings.EqualFold(ev.EventSignature, transferTopic) || ev.Removed {
+		return nil
+	}
+
+	contract := strings.ToLower(ev.Contract)
+	if !p.stablecoins[contract] {
+		return nil
+	}
+
+	// Xác định transaction_type giống listenToTransactions
+	txType := "Deposit"
+	if strings.EqualFold(ev.From, ev.Contract) {
+		txType = "Withdrawal"
+	}
+
+	tx := map[string]interface{}{
+		"timestamp":        ev.Timestamp.Format("2006-01-02 15:04:05"),
+		"address":          ev.Contract,
+		"transaction_type": txType,
+		"amount":           ev.Amount.String(),
+		"tx_hash":          ev.TxHash,
+	}
+
+	// Việc gửi lên smart contract chậm nên chạy ở goroutine riêng, không chặn chain
+	select {
+	case p.queue <- tx:
+		metrics.OutboxDepth.WithLabelValues(p.Name()).Set(float64(len(p.queue)))
+		return nil
+	default:
+		return fmt.Errorf("hàng đợi stablecoin-flow đầy, bỏ qua tx %s", ev.TxHash)
+	}
+}
+
+func (p *FlowProcessor) ProcessBlock(b pipeline.Block) error {
+	return nil
+}
+
+func (p *FlowProcessor) run() {
+	for tx := range p.queue {
+		metrics.OutboxDepth.WithLabelValues(p.Name()).Set(float64(len(p.queue)))
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Recovered from panic in stablecoin-flow: %v", r)
+				}
+			}()
+			handleFlowTx(tx)
+		}()
+	}
+}
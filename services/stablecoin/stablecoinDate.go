@@ -1,13 +1,12 @@
 package stablecoin
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
 	"math/big"
-	"os"
 	"strings"
 	"time"
+
+	"main/services/sink"
 )
 
 type FlowData struct {
@@ -22,6 +21,7 @@ type FlowData struct {
 }
 
 type ConfigStablecoin struct {
+	Chain       string // tên chain trong header cache, vd: bsc
 	Stablecoins []StablecoinInfo
 }
 
@@ -143,13 +143,6 @@ func CalculateFlowDate(tx map[string]interface{}) (map[string]FlowData, error) {
 }
 
 func logPreviousDay(config ConfigStablecoin, dayStart time.Time) error {
-	logFile, err := os.OpenFile("flow_data.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %v", err)
-	}
-	defer logFile.Close()
-	logger := log.New(logFile, "", log.LstdFlags)
-
 	for _, sc := range config.Stablecoins {
 		addr := strings.ToLower(sc.Address)
 		scFlow := flows[addr]
@@ -163,11 +156,7 @@ func logPreviousDay(config ConfigStablecoin, dayStart time.Time) error {
 			Balance:   scFlow.CurrentBalance + (scFlow.Incoming - scFlow.Outgoing),
 			Duration:  "1d",
 		}
-		flowDataJSON, err := json.MarshalIndent(flowData, "", "  ")
-		if err != nil {
-			return fmt.Errorf("unable to convert flow data to JSON: %v", err)
-		}
-		logger.Printf("Daily FlowData for %s: %s", sc.Name, string(flowDataJSON))
+		sink.Write(sink.Record{Kind: "stablecoin_flow", Chain: "bsc", Time: dayStart, Data: flowData})
 
 		// Reset cho ngày mới
 		flows[addr] = StablecoinFlow{
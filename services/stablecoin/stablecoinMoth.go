@@ -1,13 +1,12 @@
 package stablecoin
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
 	"math/big"
-	"os"
 	"strings"
 	"time"
+
+	"main/services/sink"
 )
 
 type StablecoinFlowMonth struct {
@@ -122,13 +121,6 @@ func CalculateFlowMonth(tx map[string]interface{}) (map[string]FlowData, error)
 }
 
 func logPreviousMonth(config ConfigStablecoin, monthStart time.Time) error {
-	logFile, err := os.OpenFile("flow_data.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %v", err)
-	}
-	defer logFile.Close()
-	logger := log.New(logFile, "", log.LstdFlags)
-
 	for _, sc := range config.Stablecoins {
 		addr := strings.ToLower(sc.Address)
 		scFlow := flowsMonth[addr]
@@ -142,11 +134,7 @@ func logPreviousMonth(config ConfigStablecoin, monthStart time.Time) error {
 			Balance:   scFlow.CurrentBalance + (scFlow.Incoming - scFlow.Outgoing),
 			Duration:  "1m", // Thay đổi Duration thành "1m"
 		}
-		flowDataJSON, err := json.MarshalIndent(flowData, "", "  ")
-		if err != nil {
-			return fmt.Errorf("unable to convert flow data to JSON: %v", err)
-		}
-		logger.Printf("Monthly FlowData for %s: %s", sc.Name, string(flowDataJSON))
+		sink.Write(sink.Record{Kind: "stablecoin_flow", Chain: "bsc", Time: monthStart, Data: flowData})
 
 		// Reset cho tháng mới
 		flowsMonth[addr] = StablecoinFlowMonth{
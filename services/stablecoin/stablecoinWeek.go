@@ -1,13 +1,12 @@
 package stablecoin
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
 	"math/big"
-	"os"
 	"strings"
 	"time"
+
+	"main/services/sink"
 )
 
 type StablecoinFlowWeek struct {
@@ -122,13 +121,6 @@ func CalculateFlowWeek(tx map[string]interface{}) (map[string]FlowData, error) {
 }
 
 func logPreviousWeek(config ConfigStablecoin, weekStart time.Time) error {
-	logFile, err := os.OpenFile("flow_data.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %v", err)
-	}
-	defer logFile.Close()
-	logger := log.New(logFile, "", log.LstdFlags)
-
 	for _, sc := range config.Stablecoins {
 		addr := strings.ToLower(sc.Address)
 		scFlow := flows[addr]
@@ -142,11 +134,7 @@ func logPreviousWeek(config ConfigStablecoin, weekStart time.Time) error {
 			Balance:   scFlow.CurrentBalance + (scFlow.Incoming - scFlow.Outgoing),
 			Duration:  "1w", // Thay đổi Duration thành "1w"
 		}
-		flowDataJSON, err := json.MarshalIndent(flowData, "", "  ")
-		if err != nil {
-			return fmt.Errorf("unable to convert flow data to JSON: %v", err)
-		}
-		logger.Printf("Weekly FlowData for %s: %s", sc.Name, string(flowDataJSON))
+		sink.Write(sink.Record{Kind: "stablecoin_flow", Chain: "bsc", Time: weekStart, Data: flowData})
 
 		// Reset cho tuần mới
 		flowsWeek[addr] = StablecoinFlowWeek{
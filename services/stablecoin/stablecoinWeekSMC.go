@@ -4,10 +4,7 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"fmt"
-	"log"
-	"main/config"
-	"math/big"
-	"strings"
+	"log/slog"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -15,15 +12,35 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"main/config"
+	"main/services/health"
+	"main/services/metrics"
+	"math/big"
+	"strings"
 )
 
-func StablecoinSMCWeek(timestamp int64, incoming, outgoing, balance, tokenSymbol, exchangeName string) {
-	fmt.Println("Weekkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkk\nTimestamp:", timestamp, "\nIncoming:", incoming, "\nOutgoing:", outgoing, "\nBalance:", balance, "\nToken Symbol:", tokenSymbol, "\nExchange Name:", exchangeName)
+// StablecoinSMCWeek xếp dòng tiền vào hộp thư đi; leader gửi lên contract qua sendWeekFlow
+func StablecoinSMCWeek(timestamp int64, incoming, outgoing, balance, tokenSymbol, exchangeName string) error {
+	return weekOutbox.Put(flowKey(timestamp, tokenSymbol, exchangeName), flowPayload{
+		Timestamp: timestamp, Incoming: incoming, Outgoing: outgoing, Balance: balance,
+		TokenSymbol: tokenSymbol, ExchangeName: exchangeName,
+	})
+}
+
+func sendWeekFlow(timestamp int64, incoming, outgoing, balance, tokenSymbol, exchangeName string) (err error) {
+	defer func() {
+		metrics.ObservePublish("stablecoin-week", err)
+		health.Publisher("stablecoin-week").Done(err)
+	}()
+
+	slog.Info("Gửi dòng tiền stablecoin lên SMC", "service", "stablecoin", "period", "week", "timestamp", timestamp,
+		"incoming", incoming, "outgoing", outgoing, "balance", balance, "token", tokenSymbol, "exchange", exchangeName)
 	// Kết nối WebSocket tới node BSC Testnet
 	client, err := ethclient.Dial("wss://bsc-testnet-rpc.publicnode.com")
 	if err != nil {
-		log.Fatalf("Error creating client: %v", err)
+		return fmt.Errorf("error creating client: %w", err)
 	}
+	defer client.Close()
 
 	// Địa chỉ hợp đồng mà bạn muốn lắng nghe sự kiện
 	contractAddr := common.HexToAddress(config.ContractAddressWeekS)
@@ -31,39 +48,39 @@ func StablecoinSMCWeek(timestamp int64, incoming, outgoing, balance, tokenSymbol
 	// Parse ABI
 	contractABI, err := abi.JSON(strings.NewReader(config.ContractABIWeekS))
 	if err != nil {
-		fmt.Printf("Error parsing ABI: %v\n", err)
+		return fmt.Errorf("error parsing ABI: %w", err)
 	}
 
 	// Private key của người gửi (dùng cho giao dịch)
 	privateKey, err := crypto.HexToECDSA(config.PrivateKeyS)
 	if err != nil {
-		log.Fatalf("Failed to load private key: %v", err)
+		return fmt.Errorf("failed to load private key: %w", err)
 	}
 
 	// Địa chỉ từ private key
 	publicKey := privateKey.Public()
 	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
 	if !ok {
-		log.Fatal("Error casting public key to ECDSA")
+		return fmt.Errorf("error casting public key to ECDSA")
 	}
 	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
 
 	// Lấy nonce của tài khoản người gửi
 	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
 	if err != nil {
-		log.Fatalf("Failed to get nonce: %v", err)
+		return fmt.Errorf("failed to get nonce: %w", err)
 	}
 
 	// Lấy gasPrice hiện tại
 	gasPrice, err := client.SuggestGasPrice(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to get gas price: %v", err)
+		return fmt.Errorf("failed to get gas price: %w", err)
 	}
 
 	// Convert timestamp (int64) to *big.Int for uint256 compatibility
 	timestampBig := big.NewInt(timestamp)
 	if timestamp < 0 {
-		log.Fatalf("Timestamp cannot be negative for uint256")
+		return fmt.Errorf("timestamp cannot be negative for uint256")
 	}
 
 	// Dữ liệu được mã hóa cho hàm recordData
@@ -71,7 +88,7 @@ func StablecoinSMCWeek(timestamp int64, incoming, outgoing, balance, tokenSymbol
 		timestampBig, // Use *big.Int instead of int64
 		incoming, outgoing, balance, tokenSymbol, exchangeName)
 	if err != nil {
-		log.Fatalf("Failed to pack function call date: %v", err)
+		return fmt.Errorf("failed to pack function call date: %w", err)
 	}
 
 	// Tạo giao dịch
@@ -82,7 +99,7 @@ func StablecoinSMCWeek(timestamp int64, incoming, outgoing, balance, tokenSymbol
 	})
 
 	if err != nil {
-		log.Fatalf("Failed to estimate gas: %v", err)
+		return fmt.Errorf("failed to estimate gas: %w", err)
 	}
 	gasLimit = gasLimit * 12 / 10 // Add 20% buffer
 	tx := types.NewTransaction(nonce, contractAddr, big.NewInt(0), gasLimit, gasPrice, data)
@@ -90,17 +107,18 @@ func StablecoinSMCWeek(timestamp int64, incoming, outgoing, balance, tokenSymbol
 	// Ký giao dịch bằng private key
 	chainID, err := client.NetworkID(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to get chain ID: %v", err)
+		return fmt.Errorf("failed to get chain ID: %w", err)
 	}
 
 	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
 	if err != nil {
-		log.Fatalf("Failed to sign transaction: %v", err)
+		return fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
 	// Gửi giao dịch
 	err = client.SendTransaction(context.Background(), signedTx)
 	if err != nil {
-		log.Fatalf("Failed to send transactionDate: %v", err)
+		return fmt.Errorf("failed to send transactionDate: %w", err)
 	}
+	return nil
 }
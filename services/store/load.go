@@ -0,0 +1,29 @@
+package store
+
+import (
+	"log"
+
+	"main/services/sink"
+)
+
+// Load nạp lại dữ liệu từ các file JSON Lines của FileSink trong dir (kể cả bản đã xoay vòng),
+// theo thứ tự cũ trước mới sau. Dòng hỏng được bỏ qua.
+func (s *Store) Load(dir string) error {
+	total := 0
+	err := sink.ReadFiles(dir, sink.AllKinds, func(r sink.StoredRecord) error {
+		rec := r.Record
+		rec.Data = r.Data
+		if s.Write(rec) == nil {
+			total++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if total > 0 {
+		log.Printf("📦 Đã nạp %d bản ghi từ %s", total, dir)
+	}
+	return nil
+}
@@ -0,0 +1,371 @@
+package store
+
+import (
+	"encoding/json"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"main/services/pipeline"
+	"main/services/sink"
+)
+
+// DefaultRetention là số bản ghi giữ lại cho mỗi chuỗi dữ liệu (mỗi chain, mỗi symbol, ...).
+// Chuỗi được cắt theo lô khi dài quá retention thêm 1/4 để không phải chép lại mỗi lần ghi.
+const DefaultRetention = 100000
+
+// Store là sink giữ dữ liệu đã ingest trong bộ nhớ, đánh chỉ mục để phục vụ API truy vấn.
+// Khi khởi động, dữ liệu được nạp lại từ file JSON Lines của FileSink bằng Load.
+type Store struct {
+	retention int
+
+	mu        sync.RWMutex
+	seq       uint64
+	transfers []Transfer
+	blocks    map[string]*blockSeries
+	candles   map[string]map[string][]Candle // interval -> symbol -> nến theo OpenTime
+	flows     map[string][]Entry             // kỳ (1d/1w/1m) -> dòng tiền stablecoin
+	fearGreed []Entry
+	netflow   map[string][]Entry // kỳ (daily/weekly/monthly) -> BTC netflow
+}
+
+// Entry là một bản ghi đã lưu, Data giữ nguyên JSON của bản ghi gốc
+type Entry struct {
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Transfer là một transfer đã lưu kèm số thứ tự dùng làm cursor phân trang
+type Transfer struct {
+	Seq   uint64
+	Event pipeline.Event
+	Data  json.RawMessage
+}
+
+// Candle là một nến OHLCV đã lưu
+type Candle struct {
+	OpenTime int64
+	Data     json.RawMessage
+}
+
+type blockSeries struct {
+	byHeight map[uint64]json.RawMessage
+	heights  []uint64
+}
+
+// New tạo store rỗng; retention <= 0 thì dùng DefaultRetention
+func New(retention int) *Store {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &Store{
+		retention: retention,
+		blocks:    make(map[string]*blockSeries),
+		candles:   make(map[string]map[string][]Candle),
+		flows:     make(map[string][]Entry),
+		netflow:   make(map[string][]Entry),
+	}
+}
+
+// Write nhận bản ghi từ sink và cập nhật chỉ mục tương ứng với Kind
+func (s *Store) Write(r sink.Record) error {
+	raw, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Kind == sink.KindTransfer:
+		var ev pipeline.Event
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return err
+		}
+		s.addTransfer(ev, raw)
+	case r.Kind == "block":
+		s.addBlock(r.Chain, r.Block, raw)
+	case strings.HasPrefix(r.Kind, "ohlcv_"):
+		var candle struct {
+			Symbol   string
+			OpenTime *big.Int
+		}
+		if err := json.Unmarshal(raw, &candle); err != nil {
+			return err
+		}
+		if candle.OpenTime == nil {
+			return nil
+		}
+		s.addCandle(strings.TrimPrefix(r.Kind, "ohlcv_"), strings.ToUpper(candle.Symbol), candle.OpenTime.Int64(), raw)
+	case r.Kind == "stablecoin_flow":
+		var flow struct {
+			Duration string `json:"Duration"`
+		}
+		if err := json.Unmarshal(raw, &flow); err != nil {
+			return err
+		}
+		s.flows[flow.Duration] = trim(append(s.flows[flow.Duration], Entry{Time: r.Time, Data: raw}), s.retention)
+	case r.Kind == "feargreed":
+		s.fearGreed = trim(append(s.fearGreed, Entry{Time: r.Time, Data: raw}), s.retention)
+	case r.Kind == "btc_netflow":
+		var flow struct {
+			Period string `json:"period"`
+		}
+		if err := json.Unmarshal(raw, &flow); err != nil {
+			return err
+		}
+		s.netflow[flow.Period] = trim(append(s.netflow[flow.Period], Entry{Time: r.Time, Data: raw}), s.retention)
+	}
+	return nil
+}
+
+func (s *Store) Flush() error { return nil }
+func (s *Store) Close() error { return nil }
+
+// trim giữ retention phần tử mới nhất khi items dài quá retention + retention/4
+func trim[T any](items []T, retention int) []T {
+	if len(items) <= retention+retention/4 {
+		return items
+	}
+	return append([]T(nil), items[len(items)-retention:]...)
+}
+
+func (s *Store) addTransfer(ev pipeline.Event, raw json.RawMessage) {
+	if ev.Removed {
+		// Reorg: gỡ transfer khỏi store; reorg chỉ chạm các khối gần đây nên duyệt từ cuối
+		key := ev.Key()
+		for i := len(s.transfers) - 1; i >= 0; i-- {
+			if s.transfers[i].Event.Key() == key {
+				s.transfers = append(s.transfers[:i], s.transfers[i+1:]...)
+				break
+			}
+		}
+		return
+	}
+
+	s.seq++
+	s.transfers = trim(append(s.transfers, Transfer{Seq: s.seq, Event: ev, Data: raw}), s.retention)
+}
+
+func (s *Store) addBlock(chain string, height uint64, raw json.RawMessage) {
+	series, ok := s.blocks[chain]
+	if !ok {
+		series = &blockSeries{byHeight: make(map[uint64]json.RawMessage)}
+		s.blocks[chain] = series
+	}
+	if _, exists := series.byHeight[height]; !exists {
+		series.heights = append(series.heights, height)
+	}
+	series.byHeight[height] = raw
+
+	heights := trim(series.heights, s.retention)
+	if len(heights) < len(series.heights) {
+		for _, h := range series.heights[:len(series.heights)-len(heights)] {
+			delete(series.byHeight, h)
+		}
+	}
+	series.heights = heights
+}
+
+func (s *Store) addCandle(interval, symbol string, openTime int64, raw json.RawMessage) {
+	bySymbol, ok := s.candles[interval]
+	if !ok {
+		bySymbol = make(map[string][]Candle)
+		s.candles[interval] = bySymbol
+	}
+
+	// Nến cùng OpenTime được cập nhật tại chỗ, giữ thứ tự tăng dần theo thời gian
+	candles := bySymbol[symbol]
+	i := sort.Search(len(candles), func(i int) bool { return candles[i].OpenTime >= openTime })
+	if i < len(candles) && candles[i].OpenTime == openTime {
+		candles[i].Data = raw
+		return
+	}
+	candles = append(candles, Candle{})
+	copy(candles[i+1:], candles[i:])
+	candles[i] = Candle{OpenTime: openTime, Data: raw}
+
+	bySymbol[symbol] = trim(candles, s.retention)
+}
+
+// TransferQuery là bộ lọc của /transfers
+type TransferQuery struct {
+	Chain     string
+	Address   string // khớp from hoặc to
+	Token     string // địa chỉ contract
+	From, To  time.Time
+	MinAmount *big.Int
+	Cursor    uint64 // chỉ trả về transfer có Seq nhỏ hơn cursor (0 = mới nhất)
+	Limit     int
+}
+
+// Transfers trả về transfer khớp bộ lọc, mới nhất trước, cùng cursor của trang kế tiếp (0 nếu hết)
+func (s *Store) Transfers(q TransferQuery) ([]json.RawMessage, uint64) {
+	items, next := s.queryTransfers(q)
+	result := make([]json.RawMessage, 0, len(items))
+	for _, t := range items {
+		result = append(result, t.Data)
+	}
+	return result, next
+}
+
+// TransferEvents giống Transfers nhưng trả về event chuẩn thay vì JSON
+func (s *Store) TransferEvents(q TransferQuery) ([]pipeline.Event, uint64) {
+	items, next := s.queryTransfers(q)
+	result := make([]pipeline.Event, 0, len(items))
+	for _, t := range items {
+		result = append(result, t.Event)
+	}
+	return result, next
+}
+
+// TransfersAfter trả về transfer của chain nằm sau vị trí (block, logIndex), cũ trước mới sau, tối đa limit
+func (s *Store) TransfersAfter(chain string, block uint64, logIndex uint, limit int) []pipeline.Event {
+	s.mu.RLock()
+	var result []pipeline.Event
+	for _, t := range s.transfers {
+		ev := t.Event
+		if ev.Chain != chain {
+			continue
+		}
+		if ev.BlockNumber < block || (ev.BlockNumber == block && ev.LogIndex <= logIndex) {
+			continue
+		}
+		result = append(result, ev)
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].BlockNumber != result[j].BlockNumber {
+			return result[i].BlockNumber < result[j].BlockNumber
+		}
+		return result[i].LogIndex < result[j].LogIndex
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (s *Store) queryTransfers(q TransferQuery) ([]Transfer, uint64) {
+	address := strings.ToLower(q.Address)
+	token := strings.ToLower(q.Token)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []Transfer
+	var lastSeq uint64
+	for i := len(s.transfers) - 1; i >= 0; i-- {
+		t := s.transfers[i]
+		if q.Cursor != 0 && t.Seq >= q.Cursor {
+			continue
+		}
+		ev := t.Event
+		if q.Chain != "" && ev.Chain != q.Chain {
+			continue
+		}
+		if address != "" && strings.ToLower(ev.From) != address && strings.ToLower(ev.To) != address {
+			continue
+		}
+		if token != "" && strings.ToLower(ev.Contract) != token {
+			continue
+		}
+		if !q.From.IsZero() && ev.Timestamp.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && ev.Timestamp.After(q.To) {
+			continue
+		}
+		if q.MinAmount != nil && (ev.Amount == nil || ev.Amount.Cmp(q.MinAmount) < 0) {
+			continue
+		}
+
+		if len(result) == q.Limit {
+			return result, lastSeq
+		}
+		result = append(result, t)
+		lastSeq = t.Seq
+	}
+	return result, 0
+}
+
+// Block trả về dữ liệu khối đã lưu của chain
+func (s *Store) Block(chain string, height uint64) (json.RawMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	series, ok := s.blocks[chain]
+	if !ok {
+		return nil, false
+	}
+	raw, ok := series.byHeight[height]
+	return raw, ok
+}
+
+// Candles trả về nến của symbol theo interval trong khoảng [from, to] (ms), tối đa limit nến mới nhất
+func (s *Store) Candles(symbol, interval string, from, to int64, limit int) []json.RawMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []json.RawMessage
+	for _, c := range s.candles[interval][strings.ToUpper(symbol)] {
+		if (from != 0 && c.OpenTime < from) || (to != 0 && c.OpenTime > to) {
+			continue
+		}
+		result = append(result, c.Data)
+	}
+	if limit > 0 && len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+	return result
+}
+
+// Các kỳ hợp lệ của API (REST, gRPC), chuẩn hóa về khóa lưu trong store
+var (
+	flowPeriods    = map[string]string{"1d": "1d", "day": "1d", "daily": "1d", "1w": "1w", "week": "1w", "weekly": "1w", "1m": "1m", "month": "1m", "monthly": "1m"}
+	netflowPeriods = map[string]string{"1d": "daily", "day": "daily", "daily": "daily", "1w": "weekly", "week": "weekly", "weekly": "weekly", "1m": "monthly", "month": "monthly", "monthly": "monthly"}
+)
+
+// FlowPeriod chuẩn hóa kỳ của StablecoinFlows (1d, day, daily, ...) về 1d, 1w hoặc 1m
+func FlowPeriod(v string) (string, bool) {
+	p, ok := flowPeriods[v]
+	return p, ok
+}
+
+// NetFlowPeriod chuẩn hóa kỳ của BTCNetFlow (1d, day, daily, ...) về daily, weekly hoặc monthly
+func NetFlowPeriod(v string) (string, bool) {
+	p, ok := netflowPeriods[v]
+	return p, ok
+}
+
+// StablecoinFlows trả về dòng tiền stablecoin của kỳ (1d, 1w, 1m), tối đa limit bản ghi mới nhất
+func (s *Store) StablecoinFlows(period string, limit int) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return latest(s.flows[period], limit)
+}
+
+// FearGreed trả về chỉ số Fear & Greed, tối đa limit bản ghi mới nhất
+func (s *Store) FearGreed(limit int) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return latest(s.fearGreed, limit)
+}
+
+// BTCNetFlow trả về netflow BTC của kỳ (daily, weekly, monthly), tối đa limit bản ghi mới nhất
+func (s *Store) BTCNetFlow(period string, limit int) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return latest(s.netflow[period], limit)
+}
+
+func latest(entries []Entry, limit int) []Entry {
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return append(make([]Entry, 0, len(entries)), entries...)
+}
@@ -0,0 +1,401 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Service là một dịch vụ chạy dài hạn; phải dừng khi ctx bị hủy.
+// Trả về nil nghĩa là đã hoàn thành, trả về lỗi (hoặc panic) sẽ được khởi động lại theo Policy.
+type Service func(ctx context.Context) error
+
+// RestartMode quyết định khi nào service được khởi động lại
+type RestartMode int
+
+const (
+	// RestartOnFailure chỉ khởi động lại khi service trả về lỗi hoặc panic
+	RestartOnFailure RestartMode = iota
+	// RestartAlways khởi động lại cả khi service kết thúc bình thường
+	RestartAlways
+	// RestartNever chạy đúng một lần
+	RestartNever
+)
+
+// Policy là chính sách khởi động lại của một service
+type Policy struct {
+	Mode           RestartMode
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxRestarts trong khoảng Window; vượt quá thì service bị đánh dấu failed và không chạy lại
+	MaxRestarts int
+	Window      time.Duration
+	// HealthyAfter: lần chạy dài hơn mức này được coi là ổn định, backoff quay về
+	// InitialBackoff (mặc định defaultHealthyAfter)
+	HealthyAfter time.Duration
+}
+
+// defaultHealthyAfter dùng khi Policy không khai báo HealthyAfter
+const defaultHealthyAfter = time.Minute
+
+// DefaultPolicy: backoff 1s → 1 phút, tối đa 10 lần khởi động lại trong 10 phút
+var DefaultPolicy = Policy{
+	Mode:           RestartOnFailure,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+	MaxRestarts:    10,
+	Window:         10 * time.Minute,
+}
+
+// Status là trạng thái của service trong supervisor
+type Status string
+
+const (
+	StatusStarting   Status = "starting"
+	StatusRunning    Status = "running"
+	StatusRestarting Status = "restarting"
+	StatusStopped    Status = "stopped"
+	StatusCompleted  Status = "completed"
+	StatusFailed     Status = "failed"
+)
+
+// ServiceState là ảnh chụp trạng thái của một service
+type ServiceState struct {
+	Name      string    `json:"name"`
+	Status    Status    `json:"status"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type child struct {
+	name    string
+	service Service
+	policy  Policy
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu       sync.Mutex
+	state    ServiceState
+	restarts []time.Time
+}
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Supervisor quản lý một nhóm service. Supervisor cũng là một Service (qua Run)
+// nên có thể lồng vào supervisor cha để tạo thành cây.
+type Supervisor struct {
+	name string
+
+	mu       sync.Mutex
+	children map[string]*child
+	order    []string
+	hooks    []shutdownHook
+	ctx      context.Context
+	wg       sync.WaitGroup
+}
+
+var (
+	supervisors     = make(map[string]*Supervisor)
+	supervisorsLock sync.Mutex
+)
+
+// New tạo supervisor rỗng và đăng ký để báo cáo trạng thái
+func New(name string) *Supervisor {
+	s := &Supervisor{
+		name:     name,
+		children: make(map[string]*child),
+	}
+
+	supervisorsLock.Lock()
+	supervisors[name] = s
+	supervisorsLock.Unlock()
+
+	return s
+}
+
+// AllStates trả về trạng thái service của mọi supervisor, theo tên supervisor
+func AllStates() map[string][]ServiceState {
+	supervisorsLock.Lock()
+	list := make(map[string]*Supervisor, len(supervisors))
+	for name, s := range supervisors {
+		list[name] = s
+	}
+	supervisorsLock.Unlock()
+
+	result := make(map[string][]ServiceState, len(list))
+	for name, s := range list {
+		result[name] = s.States()
+	}
+	return result
+}
+
+// Add thêm service. Nếu supervisor đang chạy, service được khởi động ngay.
+func (s *Supervisor) Add(name string, service Service, policy Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.children[name]; exists {
+		log.Printf("⚠️ [%s] Service %s đã tồn tại, bỏ qua", s.name, name)
+		return
+	}
+
+	c := &child{
+		name:    name,
+		service: service,
+		policy:  policy,
+		state:   ServiceState{Name: name, Status: StatusStarting},
+	}
+	s.children[name] = c
+	s.order = append(s.order, name)
+
+	if s.ctx != nil {
+		s.startLocked(c)
+	}
+}
+
+// Remove dừng và gỡ một service mà không ảnh hưởng các service khác
+func (s *Supervisor) Remove(name string) bool {
+	s.mu.Lock()
+	c, exists := s.children[name]
+	if exists {
+		delete(s.children, name)
+		for i, n := range s.order {
+			if n == name {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		return false
+	}
+	if c.cancel != nil {
+		c.cancel()
+		<-c.done
+	}
+	return true
+}
+
+// Restart dừng service rồi chạy lại với cùng chính sách (dùng khi cấu hình thay đổi)
+func (s *Supervisor) Restart(name string) bool {
+	s.mu.Lock()
+	c, exists := s.children[name]
+	s.mu.Unlock()
+	if !exists {
+		return false
+	}
+
+	service, policy := c.service, c.policy
+	s.Remove(name)
+	s.Add(name, service, policy)
+	return true
+}
+
+// OnShutdown đăng ký hook chạy sau khi mọi service đã dừng (flush sink, lưu checkpoint, ...)
+func (s *Supervisor) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// States trả về trạng thái của mọi service, sắp theo tên
+func (s *Supervisor) States() []ServiceState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make([]ServiceState, 0, len(s.children))
+	for _, c := range s.children {
+		c.mu.Lock()
+		states = append(states, c.state)
+		c.mu.Unlock()
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// Run khởi động mọi service và chặn cho tới khi ctx bị hủy, sau đó dừng các service
+// và chạy các shutdown hook theo thứ tự ngược với lúc đăng ký.
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.ctx != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("supervisor %s đang chạy", s.name)
+	}
+	s.ctx = ctx
+	for _, name := range s.order {
+		s.startLocked(s.children[name])
+	}
+	s.mu.Unlock()
+
+	log.Printf("🚦 [%s] Supervisor đã khởi động %d service", s.name, len(s.order))
+
+	<-ctx.Done()
+	log.Printf("🛑 [%s] Đang dừng các service...", s.name)
+	s.wg.Wait()
+
+	return s.shutdown()
+}
+
+func (s *Supervisor) shutdown() error {
+	s.mu.Lock()
+	hooks := append([]shutdownHook(nil), s.hooks...)
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		if err := hook.fn(ctx); err != nil {
+			log.Printf("❌ [%s] Shutdown hook %s lỗi: %v", s.name, hook.name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", hook.name, err))
+		} else {
+			log.Printf("✅ [%s] Shutdown hook %s hoàn tất", s.name, hook.name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Supervisor) startLocked(c *child) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	c.cancel = cancel
+	c.done = make(chan struct{})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(c.done)
+		s.loop(ctx, c)
+	}()
+}
+
+func (s *Supervisor) loop(ctx context.Context, c *child) {
+	initial := c.policy.InitialBackoff
+	if initial <= 0 {
+		initial = time.Second
+	}
+	healthyAfter := c.policy.HealthyAfter
+	if healthyAfter <= 0 {
+		healthyAfter = defaultHealthyAfter
+	}
+	backoff := initial
+
+	for {
+		c.setState(StatusRunning, nil)
+		started := time.Now()
+		err := runProtected(ctx, c.service)
+
+		// Service đã chạy ổn định một thời gian: lỗi lần này không nối tiếp chuỗi lỗi trước
+		if time.Since(started) >= healthyAfter {
+			backoff = initial
+		}
+
+		if ctx.Err() != nil {
+			c.setState(StatusStopped, err)
+			return
+		}
+
+		if err == nil && c.policy.Mode != RestartAlways {
+			log.Printf("✅ [%s] Service %s đã hoàn thành", s.name, c.name)
+			c.setState(StatusCompleted, nil)
+			return
+		}
+		if c.policy.Mode == RestartNever {
+			log.Printf("❌ [%s] Service %s dừng với lỗi: %v", s.name, c.name, err)
+			c.setState(StatusFailed, err)
+			return
+		}
+
+		if !c.allowRestart() {
+			log.Printf("❌ [%s] Service %s vượt quá %d lần khởi động lại trong %v, dừng hẳn: %v",
+				s.name, c.name, c.policy.MaxRestarts, c.policy.Window, err)
+			c.setState(StatusFailed, err)
+			return
+		}
+
+		log.Printf("🔁 [%s] Service %s dừng (%v), khởi động lại sau %v", s.name, c.name, err, backoff)
+		c.setState(StatusRestarting, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			c.setState(StatusStopped, err)
+			return
+		}
+
+		backoff *= 2
+		if c.policy.MaxBackoff > 0 && backoff > c.policy.MaxBackoff {
+			backoff = c.policy.MaxBackoff
+		}
+	}
+}
+
+// runProtected chạy service và chuyển panic thành lỗi để supervisor khởi động lại
+func runProtected(ctx context.Context, service Service) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic in service: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return service(ctx)
+}
+
+func (c *child) allowRestart() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.policy.Window > 0 {
+		recent := c.restarts[:0]
+		for _, t := range c.restarts {
+			if now.Sub(t) <= c.policy.Window {
+				recent = append(recent, t)
+			}
+		}
+		c.restarts = recent
+	}
+
+	if c.policy.MaxRestarts > 0 && len(c.restarts) >= c.policy.MaxRestarts {
+		return false
+	}
+	c.restarts = append(c.restarts, now)
+	c.state.Restarts++
+	return true
+}
+
+func (c *child) setState(status Status, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state.Status = status
+	if status == StatusRunning {
+		c.state.StartedAt = time.Now()
+	}
+	if err != nil {
+		c.state.LastError = err.Error()
+	}
+}
+
+// SignalContext trả về context bị hủy khi nhận SIGINT/SIGTERM.
+// Service chạy dưới supervisor không tự đăng ký tín hiệu mà dừng theo ctx này.
+func SignalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+}
@@ -0,0 +1,50 @@
+package tsdb
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// encodeInflux ghi điểm theo InfluxDB line protocol, timestamp theo nano giây
+func encodeInflux(points []Point) []byte {
+	var buf bytes.Buffer
+	for _, p := range points {
+		buf.WriteString(measurementEscaper.Replace(p.Measurement))
+		for _, k := range sortedKeys(p.Tags) {
+			buf.WriteByte(',')
+			buf.WriteString(keyEscaper.Replace(k))
+			buf.WriteByte('=')
+			buf.WriteString(keyEscaper.Replace(p.Tags[k]))
+		}
+		for i, k := range sortedKeys(p.Fields) {
+			if i == 0 {
+				buf.WriteByte(' ')
+			} else {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(keyEscaper.Replace(k))
+			buf.WriteByte('=')
+			buf.WriteString(strconv.FormatFloat(p.Fields[k], 'g', -1, 64))
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(p.Time.UnixNano(), 10))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,272 @@
+package tsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"main/services/sink"
+)
+
+// Mapping mô tả cách đổi một loại bản ghi thành điểm time series. Giá trị trong Tags,
+// Fields và Time là đường dẫn trong data của bản ghi (vd: "Symbol", "a.b"), hoặc một
+// trong các giá trị đặc biệt $kind, $chain, $interval (phần sau "ohlcv_" của kind).
+type Mapping struct {
+	Measurement string             `json:"measurement"`
+	Tags        map[string]string  `json:"tags,omitempty"`
+	Fields      map[string]string  `json:"fields"`
+	Scale       map[string]float64 `json:"scale,omitempty"` // field -> hệ số nhân
+	// Time rỗng thì dùng Time của bản ghi; số được hiểu là giây, hoặc mili giây nếu lớn hơn 1e12
+	Time string `json:"time,omitempty"`
+	// SampleTime là thời điểm của mẫu gửi qua remote-write, rỗng thì dùng Time. Prometheus
+	// bỏ mẫu cũ hơn head block (~1-2 giờ), nên bản ghi có Time ở đầu kỳ dài (nến 1d/1w/1M)
+	// phải dùng thời điểm cuối kỳ; dữ liệu backfill cũ hơn nữa cần bật
+	// storage.tsdb.out_of_order_time_window ở phía Prometheus.
+	SampleTime string `json:"sample_time,omitempty"`
+	// Split: field có giá trị là object (vd: netflow theo sàn) được tách thành một điểm cho
+	// mỗi khóa, khóa đó nằm ở tag tên Split
+	Split string `json:"split,omitempty"`
+}
+
+// DefaultMappings là mapping cho các bản ghi tổng hợp hiện có; khóa là kind hoặc mẫu
+// path.Match của kind
+var DefaultMappings = map[string]Mapping{
+	"stablecoin_flow": {
+		Measurement: "stablecoin_flow",
+		Tags:        map[string]string{"chain": "$chain", "symbol": "Symbol", "duration": "Duration"},
+		Fields:      map[string]string{"incoming": "Incoming", "outgoing": "Outgoing", "net_flow": "NetFlow", "balance": "Balance"},
+	},
+	"btc_netflow": {
+		Measurement: "btc_netflow",
+		Tags:        map[string]string{"period": "period", "source": "source"},
+		Fields:      map[string]string{"incoming": "incoming", "outgoing": "outgoing", "balance": "balance"},
+		Time:        "timestamp",
+		Split:       "exchange",
+	},
+	"feargreed": {
+		Measurement: "fear_greed",
+		Tags:        map[string]string{"classification": "value_classification"},
+		Fields:      map[string]string{"value": "value"},
+		Time:        "timestamp",
+	},
+	"ohlcv_*": {
+		Measurement: "ohlcv",
+		Tags:        map[string]string{"symbol": "Symbol", "interval": "$interval"},
+		Fields: map[string]string{
+			"open": "Open", "high": "High", "low": "Low", "close": "Close",
+			"volume": "Volume", "quote_volume": "QuoteAssetVolume", "trades": "NumberOfTrades",
+		},
+		// Giá trong ResponseOHLCV đã nhân 100 (ohlcv.strToBigInt)
+		Scale: map[string]float64{"open": 0.01, "high": 0.01, "low": 0.01, "close": 0.01},
+		// InfluxDB giữ điểm ở giờ mở nến; remote-write dùng giờ đóng (nến chỉ được ghi khi đã đóng)
+		Time:       "OpenTime",
+		SampleTime: "CloseTime",
+	},
+}
+
+// LoadMappings đọc file JSON {"<kind hoặc mẫu>": Mapping}. Mapping trong file thay mapping
+// mặc định cùng khóa; mapping có measurement rỗng tắt khóa đó.
+func LoadMappings(file string) (map[string]Mapping, error) {
+	mappings := make(map[string]Mapping, len(DefaultMappings))
+	for k, m := range DefaultMappings {
+		mappings[k] = m
+	}
+	if file == "" {
+		return mappings, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var custom map[string]Mapping
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("mapping %s không hợp lệ: %w", file, err)
+	}
+	for k, m := range custom {
+		if _, err := path.Match(k, ""); err != nil {
+			return nil, fmt.Errorf("mẫu kind không hợp lệ %q: %w", k, err)
+		}
+		if m.Measurement == "" {
+			delete(mappings, k)
+			continue
+		}
+		if len(m.Fields) == 0 {
+			return nil, fmt.Errorf("mapping %q không có field", k)
+		}
+		mappings[k] = m
+	}
+	return mappings, nil
+}
+
+// Point là một điểm đo
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]float64
+	Time        time.Time
+	// SampleTime là thời điểm dùng cho remote-write, xem Mapping.SampleTime
+	SampleTime time.Time
+}
+
+// lookup tìm mapping của kind; khóa trùng tên được ưu tiên hơn mẫu
+func lookup(mappings map[string]Mapping, kind string) (Mapping, bool) {
+	if m, ok := mappings[kind]; ok {
+		return m, true
+	}
+	patterns := make([]string, 0, len(mappings))
+	for k := range mappings {
+		patterns = append(patterns, k)
+	}
+	sort.Strings(patterns)
+	for _, k := range patterns {
+		if ok, _ := path.Match(k, kind); ok {
+			return mappings[k], true
+		}
+	}
+	return Mapping{}, false
+}
+
+// points đổi bản ghi thành điểm theo mapping. Field thiếu hoặc không phải số bị bỏ qua;
+// bản ghi không còn field nào thì không tạo điểm.
+func (m Mapping) points(r sink.Record) ([]Point, error) {
+	raw, err := json.Marshal(r.Data)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("data của %s không phải object: %w", r.Kind, err)
+	}
+	get := func(p string) interface{} {
+		switch p {
+		case "$kind":
+			return r.Kind
+		case "$chain":
+			return r.Chain
+		case "$interval":
+			return strings.TrimPrefix(r.Kind, "ohlcv_")
+		}
+		var v interface{} = data
+		for _, part := range strings.Split(p, ".") {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = obj[part]
+		}
+		return v
+	}
+
+	t := r.Time
+	if m.Time != "" {
+		if v, ok := toTime(get(m.Time)); ok {
+			t = v
+		}
+	}
+	sampleTime := t
+	if m.SampleTime != "" {
+		if v, ok := toTime(get(m.SampleTime)); ok {
+			sampleTime = v
+		}
+	}
+	tags := make(map[string]string, len(m.Tags)+1)
+	for tag, p := range m.Tags {
+		if v := get(p); v != nil {
+			if s := fmt.Sprint(v); s != "" {
+				tags[tag] = s
+			}
+		}
+	}
+
+	if m.Split == "" {
+		fields := make(map[string]float64, len(m.Fields))
+		for field, p := range m.Fields {
+			if v, ok := toFloat(get(p)); ok {
+				fields[field] = v * m.scale(field)
+			}
+		}
+		if len(fields) == 0 {
+			return nil, nil
+		}
+		return []Point{{Measurement: m.Measurement, Tags: tags, Fields: fields, Time: t, SampleTime: sampleTime}}, nil
+	}
+
+	// Mỗi khóa của các field dạng object là một điểm
+	split := make(map[string]map[string]float64)
+	for field, p := range m.Fields {
+		obj, _ := get(p).(map[string]interface{})
+		for key, raw := range obj {
+			if v, ok := toFloat(raw); ok {
+				if split[key] == nil {
+					split[key] = make(map[string]float64)
+				}
+				split[key][field] = v * m.scale(field)
+			}
+		}
+	}
+	keys := make([]string, 0, len(split))
+	for key := range split {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := make([]Point, 0, len(keys))
+	for _, key := range keys {
+		pointTags := make(map[string]string, len(tags)+1)
+		for k, v := range tags {
+			pointTags[k] = v
+		}
+		pointTags[m.Split] = key
+		out = append(out, Point{Measurement: m.Measurement, Tags: pointTags, Fields: split[key], Time: t, SampleTime: sampleTime})
+	}
+	return out, nil
+}
+
+func (m Mapping) scale(field string) float64 {
+	if s, ok := m.Scale[field]; ok {
+		return s
+	}
+	return 1
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		return f, err == nil
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	}
+	f, ok := toFloat(v)
+	if !ok || f <= 0 {
+		return time.Time{}, false
+	}
+	if f > 1e12 {
+		return time.UnixMilli(int64(f)), true
+	}
+	return time.Unix(int64(f), 0), true
+}
@@ -0,0 +1,79 @@
+package tsdb
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// encodeRemoteWrite dựng WriteRequest của Prometheus remote-write 1.0 (protobuf nén
+// snappy dạng block). Mỗi field là một series tên <measurement>_<field>, tag thành label.
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeRemoteWrite(points []Point) []byte {
+	var req []byte
+	for _, p := range points {
+		for _, field := range sortedKeys(p.Fields) {
+			labels := make([][2]string, 0, len(p.Tags)+1)
+			labels = append(labels, [2]string{"__name__", metricName(p.Measurement + "_" + field)})
+			for k, v := range p.Tags {
+				labels = append(labels, [2]string{metricName(k), v})
+			}
+			// Prometheus yêu cầu label sắp theo tên
+			sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+
+			var series []byte
+			for _, l := range labels {
+				var label []byte
+				label = protowire.AppendTag(label, 1, protowire.BytesType)
+				label = protowire.AppendString(label, l[0])
+				label = protowire.AppendTag(label, 2, protowire.BytesType)
+				label = protowire.AppendString(label, l[1])
+				series = protowire.AppendTag(series, 1, protowire.BytesType)
+				series = protowire.AppendBytes(series, label)
+			}
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(p.Fields[field]))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(p.sampleTime().UnixMilli()))
+			series = protowire.AppendTag(series, 2, protowire.BytesType)
+			series = protowire.AppendBytes(series, sample)
+
+			req = protowire.AppendTag(req, 1, protowire.BytesType)
+			req = protowire.AppendBytes(req, series)
+		}
+	}
+	return snappy.Encode(nil, req)
+}
+
+// sampleTime là thời điểm của mẫu remote-write; Point tự dựng không có SampleTime dùng Time
+func (p Point) sampleTime() time.Time {
+	if p.SampleTime.IsZero() {
+		return p.Time
+	}
+	return p.SampleTime
+}
+
+// metricName đưa tên về dạng [a-zA-Z_:][a-zA-Z0-9_:]* của Prometheus
+func metricName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,256 @@
+// Package tsdb ghi bản ghi tổng hợp (dòng tiền stablecoin, BTC netflow, fear & greed,
+// nến OHLCV) thành time series cho InfluxDB (line protocol) và/hoặc Prometheus
+// remote-write, để Grafana vẽ trực tiếp.
+package tsdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"main/services/health"
+	"main/services/metrics"
+	"main/services/sink"
+)
+
+// Mặc định của sink, đổi bằng TSDB_INTERVAL, TSDB_BATCH và TSDB_BUFFER
+const (
+	DefaultInterval = 10 * time.Second
+	DefaultBatch    = 1000
+	DefaultBuffer   = 100000
+)
+
+const maxAttempts = 3
+
+// Config là cấu hình của sink
+type Config struct {
+	InfluxURL      string // URL ghi đầy đủ, vd: http://influx:8086/api/v2/write?org=o&bucket=b
+	InfluxToken    string
+	RemoteWriteURL string // vd: http://prometheus:9090/api/v1/write
+	Mappings       map[string]Mapping
+	Interval       time.Duration
+	Batch          int
+	Buffer         int
+}
+
+// ConfigFromEnv đọc TSDB_INFLUX_URL, TSDB_INFLUX_TOKEN, TSDB_REMOTE_WRITE_URL, TSDB_MAPPING
+// (file JSON, xem LoadMappings), TSDB_INTERVAL, TSDB_BATCH và TSDB_BUFFER. ok là false khi
+// chưa đặt URL nào.
+func ConfigFromEnv() (cfg Config, ok bool, err error) {
+	cfg = Config{
+		InfluxURL:      os.Getenv("TSDB_INFLUX_URL"),
+		InfluxToken:    os.Getenv("TSDB_INFLUX_TOKEN"),
+		RemoteWriteURL: os.Getenv("TSDB_REMOTE_WRITE_URL"),
+		Interval:       DefaultInterval,
+		Batch:          DefaultBatch,
+		Buffer:         DefaultBuffer,
+	}
+	if cfg.InfluxURL == "" && cfg.RemoteWriteURL == "" {
+		return cfg, false, nil
+	}
+	if d, err := time.ParseDuration(os.Getenv("TSDB_INTERVAL")); err == nil && d > 0 {
+		cfg.Interval = d
+	}
+	if v, err := strconv.Atoi(os.Getenv("TSDB_BATCH")); err == nil && v > 0 {
+		cfg.Batch = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("TSDB_BUFFER")); err == nil && v > 0 {
+		cfg.Buffer = v
+	}
+	cfg.Mappings, err = LoadMappings(os.Getenv("TSDB_MAPPING"))
+	return cfg, true, err
+}
+
+// Sink gom điểm và gửi theo lô mỗi Interval hoặc khi đủ Batch điểm. Bản ghi không có
+// mapping bị bỏ qua. Ghi lại cùng series và timestamp là ghi đè nên nhiều replica cùng
+// gửi không tạo điểm trùng.
+type Sink struct {
+	cfg    Config
+	client *http.Client
+	// backend: tên -> hàm gửi một lô
+	backends map[string]func([]Point) error
+
+	mu      sync.Mutex
+	pending []Point
+	kick    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	sending sync.Mutex
+}
+
+// New tạo sink và bắt đầu goroutine gửi định kỳ
+func New(cfg Config) *Sink {
+	s := &Sink{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: 15 * time.Second},
+		backends: make(map[string]func([]Point) error),
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if cfg.InfluxURL != "" {
+		s.backends["influx"] = s.sendInflux
+		log.Printf("📈 [TSDB] Ghi InfluxDB line protocol tới %s", cfg.InfluxURL)
+	}
+	if cfg.RemoteWriteURL != "" {
+		s.backends["remote_write"] = s.sendRemoteWrite
+		log.Printf("📈 [TSDB] Ghi Prometheus remote-write tới %s", cfg.RemoteWriteURL)
+	}
+	go s.run()
+	return s
+}
+
+func (s *Sink) Write(r sink.Record) error {
+	m, ok := lookup(s.cfg.Mappings, r.Kind)
+	if !ok {
+		return nil
+	}
+	points, err := m.points(r)
+	if err != nil || len(points) == 0 {
+		return err
+	}
+
+	s.mu.Lock()
+	s.pending = append(s.pending, points...)
+	// Backend chậm hoặc chết: bỏ điểm cũ nhất để không giữ bộ nhớ mãi
+	if over := len(s.pending) - s.cfg.Buffer; over > 0 {
+		s.pending = append(s.pending[:0], s.pending[over:]...)
+		metrics.TSDBDropped.Add(float64(over))
+	}
+	full := len(s.pending) >= s.cfg.Batch
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		case <-s.kick:
+		}
+		s.Flush()
+	}
+}
+
+// Flush gửi mọi điểm đang chờ tới từng backend. Lô gửi lỗi sau khi thử lại bị bỏ.
+func (s *Sink) Flush() error {
+	s.sending.Lock()
+	defer s.sending.Unlock()
+
+	var firstErr error
+	for {
+		s.mu.Lock()
+		n := min(len(s.pending), s.cfg.Batch)
+		batch := append([]Point(nil), s.pending[:n]...)
+		s.pending = s.pending[n:]
+		s.mu.Unlock()
+		if len(batch) == 0 {
+			return firstErr
+		}
+
+		for name, send := range s.backends {
+			err := retry(func() error { return send(batch) })
+			health.Publisher("tsdb-" + name).Done(err)
+			if err != nil {
+				metrics.TSDBErrors.WithLabelValues(name).Inc()
+				log.Printf("❌ [TSDB] Không gửi được %d điểm tới %s: %v", len(batch), name, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			metrics.TSDBPoints.WithLabelValues(name).Add(float64(len(batch)))
+		}
+	}
+}
+
+// Close gửi nốt điểm đang chờ rồi dừng goroutine gửi
+func (s *Sink) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+		err = s.Flush()
+	})
+	return err
+}
+
+func retry(fn func() error) error {
+	var err error
+	delay := 500 * time.Millisecond
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		// 4xx là dữ liệu bị từ chối (vd: mẫu cũ hơn mẫu đã có), gửi lại cũng vậy
+		if _, ok := err.(rejectedError); ok {
+			return err
+		}
+		if attempt < maxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
+
+func (s *Sink) sendInflux(points []Point) error {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.InfluxURL, bytes.NewReader(encodeInflux(points)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.cfg.InfluxToken != "" {
+		req.Header.Set("Authorization", "Token "+s.cfg.InfluxToken)
+	}
+	return s.do(req)
+}
+
+func (s *Sink) sendRemoteWrite(points []Point) error {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.RemoteWriteURL, bytes.NewReader(encodeRemoteWrite(points)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	return s.do(req)
+}
+
+func (s *Sink) do(req *http.Request) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+			return rejectedError{err}
+		}
+		return err
+	}
+	return nil
+}
+
+type rejectedError struct{ error }
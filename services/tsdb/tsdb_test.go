@@ -0,0 +1,260 @@
+package tsdb
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"main/services/sink"
+)
+
+// receiver là backend giả: ghi lại từng request và trả về status
+type receiver struct {
+	status int
+
+	mu       sync.Mutex
+	bodies   [][]byte
+	headers  []http.Header
+	requests int
+}
+
+func newReceiver(t *testing.T, status int) (*receiver, *httptest.Server) {
+	r := &receiver{status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Errorf("đọc body: %v", err)
+		}
+		r.mu.Lock()
+		r.requests++
+		r.bodies = append(r.bodies, body)
+		r.headers = append(r.headers, req.Header.Clone())
+		r.mu.Unlock()
+		w.WriteHeader(r.status)
+		io.WriteString(w, "mẫu bị từ chối")
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv
+}
+
+var testMappings = map[string]Mapping{
+	"flow": {
+		Measurement: "flow rate",
+		Tags:        map[string]string{"chain": "$chain", "symbol": "Symbol"},
+		Fields:      map[string]string{"net flow": "Net", "balance": "Balance"},
+		Scale:       map[string]float64{"balance": 0.01},
+		SampleTime:  "Closed",
+	},
+}
+
+var (
+	openTime  = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	closeTime = openTime.Add(24*time.Hour - time.Millisecond)
+)
+
+func newTestSink(cfg Config) *Sink {
+	cfg.Mappings = testMappings
+	cfg.Interval = time.Hour
+	cfg.Batch = 100
+	cfg.Buffer = 1000
+	return New(cfg)
+}
+
+func writeFlow(t *testing.T, s *Sink) {
+	t.Helper()
+	err := s.Write(sink.Record{
+		Kind:  "flow",
+		Chain: "bsc",
+		Time:  openTime,
+		Data: map[string]interface{}{
+			"Symbol":  "USDT,x=1",
+			"Net":     -1.5,
+			"Balance": 250,
+			"Closed":  closeTime.Format(time.RFC3339Nano),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+}
+
+func TestInfluxLineProtocol(t *testing.T) {
+	recv, srv := newReceiver(t, http.StatusNoContent)
+	s := newTestSink(Config{InfluxURL: srv.URL + "/api/v2/write?org=o&bucket=b", InfluxToken: "secret"})
+	defer s.Close()
+
+	writeFlow(t, s)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if recv.requests != 1 {
+		t.Fatalf("requests = %d, muốn 1", recv.requests)
+	}
+	if got := recv.headers[0].Get("Authorization"); got != "Token secret" {
+		t.Errorf("Authorization = %q", got)
+	}
+	// Tag và field sắp theo tên, ký tự đặc biệt được escape, timestamp nano giây theo Time
+	want := `flow\ rate,chain=bsc,symbol=USDT\,x\=1 balance=2.5,net\ flow=-1.5 1714521600000000000` + "\n"
+	if got := string(recv.bodies[0]); got != want {
+		t.Errorf("body =\n%q\nmuốn\n%q", got, want)
+	}
+}
+
+type sample struct {
+	labels map[string]string
+	value  float64
+	ts     int64
+}
+
+// decodeWriteRequest giải nén snappy rồi đọc WriteRequest protobuf (xem encodeRemoteWrite)
+func decodeWriteRequest(t *testing.T, body []byte) []sample {
+	t.Helper()
+	raw, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("snappy: %v", err)
+	}
+	var out []sample
+	for len(raw) > 0 {
+		num, typ, n := protowire.ConsumeTag(raw)
+		if n < 0 || num != 1 || typ != protowire.BytesType {
+			t.Fatalf("WriteRequest: tag %d/%d không hợp lệ", num, typ)
+		}
+		raw = raw[n:]
+		series, n := protowire.ConsumeBytes(raw)
+		if n < 0 {
+			t.Fatal("WriteRequest: timeseries hỏng")
+		}
+		raw = raw[n:]
+
+		s := sample{labels: make(map[string]string)}
+		var names []string
+		for len(series) > 0 {
+			num, _, n := protowire.ConsumeTag(series)
+			series = series[n:]
+			msg, n := protowire.ConsumeBytes(series)
+			if n < 0 {
+				t.Fatal("TimeSeries hỏng")
+			}
+			series = series[n:]
+			switch num {
+			case 1: // Label
+				var kv [2]string
+				for len(msg) > 0 {
+					num, _, n := protowire.ConsumeTag(msg)
+					msg = msg[n:]
+					v, n := protowire.ConsumeString(msg)
+					msg = msg[n:]
+					kv[num-1] = v
+				}
+				s.labels[kv[0]] = kv[1]
+				names = append(names, kv[0])
+			case 2: // Sample
+				for len(msg) > 0 {
+					num, typ, n := protowire.ConsumeTag(msg)
+					msg = msg[n:]
+					switch {
+					case num == 1 && typ == protowire.Fixed64Type:
+						v, n := protowire.ConsumeFixed64(msg)
+						msg = msg[n:]
+						s.value = math.Float64frombits(v)
+					case num == 2 && typ == protowire.VarintType:
+						v, n := protowire.ConsumeVarint(msg)
+						msg = msg[n:]
+						s.ts = int64(v)
+					default:
+						t.Fatalf("Sample: field %d/%d lạ", num, typ)
+					}
+				}
+			}
+		}
+		for i := 1; i < len(names); i++ {
+			if names[i-1] >= names[i] {
+				t.Errorf("label chưa sắp theo tên: %v", names)
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestRemoteWriteProtobufSnappy(t *testing.T) {
+	recv, srv := newReceiver(t, http.StatusNoContent)
+	s := newTestSink(Config{RemoteWriteURL: srv.URL + "/api/v1/write"})
+	defer s.Close()
+
+	writeFlow(t, s)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if recv.requests != 1 {
+		t.Fatalf("requests = %d, muốn 1", recv.requests)
+	}
+	h := recv.headers[0]
+	if h.Get("Content-Type") != "application/x-protobuf" || h.Get("Content-Encoding") != "snappy" || h.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+		t.Errorf("header = %v", h)
+	}
+
+	samples := decodeWriteRequest(t, recv.bodies[0])
+	if len(samples) != 2 {
+		t.Fatalf("có %d series, muốn 2 (mỗi field một series)", len(samples))
+	}
+	want := map[string]float64{"flow_rate_balance": 2.5, "flow_rate_net_flow": -1.5}
+	for _, s := range samples {
+		name := s.labels["__name__"]
+		if v, ok := want[name]; !ok || s.value != v {
+			t.Errorf("series %s = %v", name, s.value)
+		}
+		if s.labels["chain"] != "bsc" || s.labels["symbol"] != "USDT,x=1" {
+			t.Errorf("labels = %v", s.labels)
+		}
+		// Mẫu remote-write dùng SampleTime (cuối kỳ), mili giây
+		if s.ts != closeTime.UnixMilli() {
+			t.Errorf("timestamp = %d, muốn %d", s.ts, closeTime.UnixMilli())
+		}
+	}
+}
+
+func TestRejectedBatchIsNotRetried(t *testing.T) {
+	recv, srv := newReceiver(t, http.StatusBadRequest)
+	s := newTestSink(Config{InfluxURL: srv.URL})
+	defer s.Close()
+
+	writeFlow(t, s)
+	err := s.Flush()
+	if err == nil || !strings.Contains(err.Error(), "HTTP 400") {
+		t.Fatalf("Flush = %v, muốn lỗi HTTP 400", err)
+	}
+	if recv.requests != 1 {
+		t.Fatalf("requests = %d, 4xx không được gửi lại", recv.requests)
+	}
+	// Lô bị từ chối bị bỏ, lần Flush sau không gửi lại
+	if err := s.Flush(); err != nil || recv.requests != 1 {
+		t.Fatalf("Flush lần hai = %v, requests = %d", err, recv.requests)
+	}
+}
+
+func TestServerErrorIsRetried(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		recv, srv := newReceiver(t, status)
+		s := newTestSink(Config{InfluxURL: srv.URL})
+
+		writeFlow(t, s)
+		if err := s.Flush(); err == nil {
+			t.Fatalf("HTTP %d: Flush không trả lỗi", status)
+		}
+		if recv.requests != maxAttempts {
+			t.Fatalf("HTTP %d: requests = %d, muốn %d", status, recv.requests, maxAttempts)
+		}
+		s.Close()
+	}
+}
@@ -0,0 +1,187 @@
+package wsserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"main/services/get_chains"
+	"main/services/metrics"
+)
+
+const (
+	writeWait        = 10 * time.Second
+	pongWait         = 60 * time.Second
+	pingInterval     = 30 * time.Second // heartbeat, phải nhỏ hơn pongWait
+	maxMessageSize   = 64 << 10
+	maxSubscriptions = 64
+)
+
+// client là một kết nối WebSocket. Mọi message gửi đi qua buffer send riêng;
+// buffer đầy nghĩa là client đọc không kịp và sẽ bị ngắt thay vì làm chậm pipeline.
+type client struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+
+	mu     sync.RWMutex
+	subs   map[string]*subscription
+	nextID int
+}
+
+func newClient(hub *Hub, conn *websocket.Conn, buffer int) *client {
+	return &client{
+		hub:  hub,
+		conn: conn,
+		send: make(chan []byte, buffer),
+		done: make(chan struct{}),
+		subs: make(map[string]*subscription),
+	}
+}
+
+// enqueue đưa message vào buffer mà không chặn; trả về false nếu client đã/bị ngắt
+func (c *client) enqueue(msg []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		metrics.WSSlowConsumers.Inc()
+		log.Printf("⚠️ [WS] Client %s không đọc kịp, ngắt kết nối", c.conn.RemoteAddr())
+		c.close(websocket.ClosePolicyViolation, "slow consumer")
+		return false
+	}
+}
+
+func (c *client) reply(action string, data interface{}, err error) {
+	resp := get_chains.WebSocketResponse{Action: action, Success: err == nil, Data: data}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	if msg, err := json.Marshal(resp); err == nil {
+		c.enqueue(msg)
+	}
+}
+
+// close gửi close frame (nếu có lý do), đóng kết nối và gỡ client khỏi hub; an toàn khi gọi nhiều lần
+func (c *client) close(code int, reason string) {
+	c.once.Do(func() {
+		close(c.done)
+		if reason != "" {
+			c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+		}
+		c.conn.Close()
+		c.hub.remove(c)
+	})
+}
+
+func (c *client) readPump() {
+	defer c.close(websocket.CloseNormalClosure, "")
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var msg get_chains.WebSocketMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				c.reply("error", nil, fmt.Errorf("message không phải JSON hợp lệ"))
+				continue
+			}
+			return
+		}
+		c.handle(msg)
+	}
+}
+
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingInterval)
+	defer func() {
+		ticker.Stop()
+		c.close(websocket.CloseNormalClosure, "")
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *client) handle(msg get_chains.WebSocketMessage) {
+	switch msg.Action {
+	case "subscribe":
+		sub, err := parseSubscription(msg.Params)
+		if err != nil {
+			c.reply(msg.Action, nil, err)
+			return
+		}
+
+		c.mu.Lock()
+		if len(c.subs) >= maxSubscriptions {
+			c.mu.Unlock()
+			c.reply(msg.Action, nil, fmt.Errorf("vượt quá %d subscription cho mỗi kết nối", maxSubscriptions))
+			return
+		}
+		c.nextID++
+		sub.ID = strconv.Itoa(c.nextID)
+		c.subs[sub.ID] = sub
+		c.mu.Unlock()
+
+		c.reply(msg.Action, sub, nil)
+
+	case "unsubscribe":
+		id := stringParam(msg.Params, "id")
+		c.mu.Lock()
+		_, ok := c.subs[id]
+		delete(c.subs, id)
+		c.mu.Unlock()
+
+		if !ok {
+			c.reply(msg.Action, nil, fmt.Errorf("không có subscription %q", id))
+			return
+		}
+		c.reply(msg.Action, map[string]string{"id": id}, nil)
+
+	case "list":
+		c.mu.RLock()
+		subs := make([]*subscription, 0, len(c.subs))
+		for _, sub := range c.subs {
+			subs = append(subs, sub)
+		}
+		c.mu.RUnlock()
+		c.reply(msg.Action, subs, nil)
+
+	case "ping":
+		c.reply("pong", time.Now().Unix(), nil)
+
+	default:
+		c.reply(msg.Action, nil, fmt.Errorf("action không được hỗ trợ: %q (subscribe, unsubscribe, list, ping)", msg.Action))
+	}
+}
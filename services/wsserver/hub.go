@@ -0,0 +1,197 @@
+package wsserver
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"main/services/get_chains"
+	"main/services/metrics"
+	"main/services/pipeline"
+	"main/services/prices"
+	"main/services/sink"
+)
+
+// DefaultBuffer là số message chờ gửi tối đa cho mỗi client, đổi bằng WS_CLIENT_BUFFER
+const DefaultBuffer = 256
+
+// Hub giữ các client WebSocket và đẩy cho họ event chuẩn (là processor của pipeline)
+// và nến OHLCV (là sink). Hub không bao giờ chặn nguồn dữ liệu: client chậm bị ngắt.
+type Hub struct {
+	buffer   int
+	upgrader websocket.Upgrader
+
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+}
+
+// eventPush là dữ liệu của message "event"
+type eventPush struct {
+	Subscription string         `json:"subscription"`
+	Event        pipeline.Event `json:"event"`
+	USDValue     *float64       `json:"usd_value,omitempty"`
+}
+
+// candlePush là dữ liệu của message "candle"
+type candlePush struct {
+	Subscription string          `json:"subscription"`
+	Interval     string          `json:"interval"`
+	Symbol       string          `json:"symbol"`
+	Candle       json.RawMessage `json:"candle"`
+}
+
+// NewHub tạo hub; buffer <= 0 thì dùng WS_CLIENT_BUFFER hoặc DefaultBuffer.
+// WS_ALLOWED_ORIGINS (phân tách bởi dấu phẩy) giới hạn Origin được phép kết nối.
+func NewHub(buffer int) *Hub {
+	if buffer <= 0 {
+		buffer, _ = strconv.Atoi(os.Getenv("WS_CLIENT_BUFFER"))
+	}
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+
+	h := &Hub{buffer: buffer, clients: make(map[*client]struct{})}
+	h.upgrader.CheckOrigin = allowedOrigins(os.Getenv("WS_ALLOWED_ORIGINS"))
+	return h
+}
+
+func allowedOrigins(list string) func(r *http.Request) bool {
+	if strings.TrimSpace(list) == "" {
+		return func(r *http.Request) bool { return true }
+	}
+	allowed := make(map[string]bool)
+	for _, origin := range strings.Split(list, ",") {
+		allowed[strings.TrimSpace(origin)] = true
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || allowed[origin]
+	}
+}
+
+// ServeHTTP nâng cấp kết nối lên WebSocket và chạy client cho tới khi ngắt
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("❌ [WS] Không thể nâng cấp kết nối từ %s: %v", r.RemoteAddr, err)
+		return
+	}
+
+	c := newClient(h, conn, h.buffer)
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	metrics.WSClients.Set(float64(len(h.clients)))
+	h.mu.Unlock()
+
+	go c.writePump()
+	c.readPump()
+}
+
+func (h *Hub) remove(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.clients, c)
+	metrics.WSClients.Set(float64(len(h.clients)))
+}
+
+func (h *Hub) snapshot() []*client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]*client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// push gửi message tới mọi subscription khớp; build tạo dữ liệu cho từng subscription
+func (h *Hub) push(kind, action string, match func(*subscription) bool, build func(id string) interface{}) {
+	for _, c := range h.snapshot() {
+		c.mu.RLock()
+		var ids []string
+		for id, sub := range c.subs {
+			if match(sub) {
+				ids = append(ids, id)
+			}
+		}
+		c.mu.RUnlock()
+
+		for _, id := range ids {
+			msg, err := json.Marshal(get_chains.WebSocketResponse{Action: action, Success: true, Data: build(id)})
+			if err != nil {
+				continue
+			}
+			if !c.enqueue(msg) {
+				break
+			}
+			metrics.WSMessagesSent.WithLabelValues(kind).Inc()
+		}
+	}
+}
+
+func (h *Hub) Name() string {
+	return "wsserver"
+}
+
+func (h *Hub) ProcessEvent(ev pipeline.Event) error {
+	usd, hasUSD := prices.USDValue(ev)
+	h.push("event", "event", func(s *subscription) bool {
+		return s.matchEvent(ev, usd, hasUSD)
+	}, func(id string) interface{} {
+		push := eventPush{Subscription: id, Event: ev}
+		if hasUSD {
+			push.USDValue = &usd
+		}
+		return push
+	})
+	return nil
+}
+
+func (h *Hub) ProcessBlock(b pipeline.Block) error {
+	return nil
+}
+
+// Write nhận nến OHLCV từ sink (Kind "ohlcv_<interval>")
+func (h *Hub) Write(r sink.Record) error {
+	interval, ok := strings.CutPrefix(r.Kind, "ohlcv_")
+	if !ok {
+		return nil
+	}
+
+	raw, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	var candle struct{ Symbol string }
+	if err := json.Unmarshal(raw, &candle); err != nil {
+		return err
+	}
+	symbol := strings.ToUpper(candle.Symbol)
+
+	h.push("candle", "candle", func(s *subscription) bool {
+		return s.matchCandle(interval, symbol)
+	}, func(id string) interface{} {
+		return candlePush{Subscription: id, Interval: interval, Symbol: symbol, Candle: raw}
+	})
+	return nil
+}
+
+func (h *Hub) Flush() error {
+	return nil
+}
+
+// Close ngắt mọi client (shutdown)
+func (h *Hub) Close() error {
+	for _, c := range h.snapshot() {
+		c.close(websocket.CloseGoingAway, "server shutting down")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package wsserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"main/services/pipeline"
+)
+
+// Loại subscription
+const (
+	KindEvents  = "events"
+	KindCandles = "candles"
+)
+
+// subscription là bộ lọc của một lệnh subscribe; trường rỗng nghĩa là không lọc
+type subscription struct {
+	ID        string  `json:"id"`
+	Kind      string  `json:"type"`
+	Chain     string  `json:"chain,omitempty"`
+	Address   string  `json:"address,omitempty"`
+	Token     string  `json:"token,omitempty"`
+	EventType string  `json:"event_type,omitempty"`
+	MinUSD    float64 `json:"min_usd,omitempty"`
+	Symbol    string  `json:"symbol,omitempty"`
+	Interval  string  `json:"interval,omitempty"`
+}
+
+// parseSubscription đọc params của lệnh subscribe. Không có "type" thì suy ra từ
+// tham số: có symbol/interval là nến, còn lại là event.
+func parseSubscription(params map[string]interface{}) (*subscription, error) {
+	sub := &subscription{
+		Kind:      stringParam(params, "type"),
+		Chain:     stringParam(params, "chain"),
+		Address:   strings.ToLower(stringParam(params, "address")),
+		Token:     strings.ToLower(stringParam(params, "token")),
+		EventType: stringParam(params, "event_type"),
+		Symbol:    strings.ToUpper(stringParam(params, "symbol")),
+		Interval:  stringParam(params, "interval"),
+	}
+
+	if v, ok := params["min_usd"]; ok {
+		switch n := v.(type) {
+		case float64:
+			sub.MinUSD = n
+		case string:
+			f, err := strconv.ParseFloat(n, 64)
+			if err != nil {
+				return nil, fmt.Errorf("min_usd không hợp lệ: %s", n)
+			}
+			sub.MinUSD = f
+		default:
+			return nil, fmt.Errorf("min_usd không hợp lệ: %v", v)
+		}
+	}
+
+	if sub.Kind == "" {
+		sub.Kind = KindEvents
+		if sub.Symbol != "" || sub.Interval != "" {
+			sub.Kind = KindCandles
+		}
+	}
+	if sub.Kind != KindEvents && sub.Kind != KindCandles {
+		return nil, fmt.Errorf("type không hợp lệ: %s (hỗ trợ %s, %s)", sub.Kind, KindEvents, KindCandles)
+	}
+	return sub, nil
+}
+
+func stringParam(params map[string]interface{}, key string) string {
+	v, _ := params[key].(string)
+	return strings.TrimSpace(v)
+}
+
+// matchEvent kiểm tra event chuẩn; khi có min_usd mà không quy đổi được USD thì bỏ qua event
+func (s *subscription) matchEvent(ev pipeline.Event, usd float64, hasUSD bool) bool {
+	if s.Kind != KindEvents {
+		return false
+	}
+	if s.Chain != "" && s.Chain != ev.Chain {
+		return false
+	}
+	if s.Address != "" && strings.ToLower(ev.From) != s.Address && strings.ToLower(ev.To) != s.Address {
+		return false
+	}
+	if s.Token != "" && strings.ToLower(ev.Contract) != s.Token {
+		return false
+	}
+	if s.EventType != "" && !strings.EqualFold(s.EventType, ev.TransactionType) &&
+		!strings.EqualFold(s.EventType, ev.EventSignature) && !strings.EqualFold(s.EventType, ev.Rule) {
+		return false
+	}
+	if s.MinUSD > 0 && (!hasUSD || usd < s.MinUSD) {
+		return false
+	}
+	return true
+}
+
+func (s *subscription) matchCandle(interval, symbol string) bool {
+	if s.Kind != KindCandles {
+		return false
+	}
+	if s.Interval != "" && s.Interval != interval {
+		return false
+	}
+	if s.Symbol != "" && s.Symbol != symbol {
+		return false
+	}
+	return true
+}